@@ -16,8 +16,11 @@
 package metaverse
 
 import (
-	"github.com/blocktree/go-owcrypt"
+	"encoding/hex"
+
+	"github.com/blocktree/go-owcdrivers/addressEncoder"
 	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
+	"github.com/blocktree/openwallet/v2/openwallet"
 )
 
 func init() {
@@ -35,47 +38,54 @@ func NewAddressDecoder(wm *WalletManager) *addressDecoder {
 	return &decoder
 }
 
-//PrivateKeyToWIF 私钥转WIF
-func (decoder *addressDecoder) PrivateKeyToWIF(priv []byte, isTestnet bool) (string, error) {
-	return "", nil
+//networkParams isTestnet与配置的网络一致时使用配置的网络参数，否则使用主网或测试网
+func (decoder *addressDecoder) networkParams(isTestnet bool) metaverse_addrdec.NetworkParams {
+	if isTestnet == decoder.wm.Config.IsTestNet {
+		return decoder.wm.Config.networkParams()
+	}
+	if isTestnet {
+		return metaverse_addrdec.TestNetParams
+	}
+	return metaverse_addrdec.MainNetParams
+}
 
+//PrivateKeyToWIF 私钥转WIF，使用压缩公钥格式
+func (decoder *addressDecoder) PrivateKeyToWIF(priv []byte, isTestnet bool) (string, error) {
+	return metaverse_addrdec.EncodeWIF(priv, true, decoder.networkParams(isTestnet).WIFPrefix)
 }
 
 //PublicKeyToAddress 公钥转地址
 func (decoder *addressDecoder) PublicKeyToAddress(pub []byte, isTestnet bool) (string, error) {
-	metaverse_addrdec.Default.IsTestNet = decoder.wm.Config.IsTestNet
-	address, err := metaverse_addrdec.Default.AddressEncode(pub)
-	if err != nil {
-		return "", err
-	}
-
+	address := addressEncoder.AddressEncode(pub, decoder.networkParams(isTestnet).P2PKH)
 	return address, nil
 
 }
 
-//RedeemScriptToAddress 多重签名赎回脚本转地址
+//RedeemScriptToAddress 多重签名赎回脚本转地址，公钥排序后构建OP_m <pubkeys> OP_n OP_CHECKMULTISIG赎回脚本
 func (decoder *addressDecoder) RedeemScriptToAddress(pubs [][]byte, required uint64, isTestnet bool) (string, error) {
-
-	cfg := metaverse_addrdec.ETP_mainnetAddressP2SH
-	if decoder.wm.Config.IsTestNet {
-		cfg = metaverse_addrdec.ETP_testnetAddressP2SH
-	}
-
-	redeemScript := make([]byte, 0)
-
-	for _, pub := range pubs {
-		redeemScript = append(redeemScript, pub...)
+	redeemScript, err := metaverse_addrdec.MultisigRedeemScript(int(required), metaverse_addrdec.SortPublicKeys(pubs))
+	if err != nil {
+		return "", err
 	}
-
-	pkHash := owcrypt.Hash(redeemScript, 0, owcrypt.HASH_ALG_HASH160)
-
-	address, _ := metaverse_addrdec.Default.AddressEncode(pkHash, cfg)
-
-	return address, nil
-
+	return metaverse_addrdec.RedeemScriptAddress(redeemScript, decoder.networkParams(isTestnet).P2SH), nil
 }
 
-//WIFToPrivateKey WIF转私钥
+//WIFToPrivateKey WIF转私钥，压缩和非压缩格式都可以解析
 func (decoder *addressDecoder) WIFToPrivateKey(wif string, isTestnet bool) ([]byte, error) {
-	return nil, nil
+	priv, _, err := metaverse_addrdec.DecodeWIF(wif, decoder.networkParams(isTestnet).WIFPrefix)
+	return priv, err
+}
+
+//NewMultisigAddress 创建m-of-n多重签名地址，PublicKey保存赎回脚本
+func (wm *WalletManager) NewMultisigAddress(pubs [][]byte, required uint64) (*openwallet.Address, error) {
+	redeemScript, err := metaverse_addrdec.MultisigRedeemScript(int(required), metaverse_addrdec.SortPublicKeys(pubs))
+	if err != nil {
+		return nil, err
+	}
+	address := metaverse_addrdec.RedeemScriptAddress(redeemScript, wm.Config.networkParams().P2SH)
+	return &openwallet.Address{
+		Address:   address,
+		PublicKey: hex.EncodeToString(redeemScript),
+		Symbol:    wm.Symbol(),
+	}, nil
 }
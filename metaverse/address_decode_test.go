@@ -46,3 +46,58 @@ func TestAddressDecoder_AddressDecode(t *testing.T) {
 	//p2shHash, _ := metaverse_addrdec.Default.AddressDecode(p2shAddr, metaverse_addrdec.ETP_mainnetAddressP2SH)
 	//t.Logf("p2shHash: %s", hex.EncodeToString(p2shHash))
 }
+
+func TestAddressDecoder_WIF(t *testing.T) {
+	wm := NewWalletManager()
+	priv, _ := hex.DecodeString("0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d")
+
+	wif, err := wm.Decoder.PrivateKeyToWIF(priv, false)
+	if err != nil || wif != "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617" {
+		t.Fatalf("PrivateKeyToWIF = %s, %v", wif, err)
+	}
+
+	//桌面钱包导出的非压缩格式
+	key, err := wm.Decoder.WIFToPrivateKey("5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ", false)
+	if err != nil || hex.EncodeToString(key) != hex.EncodeToString(priv) {
+		t.Fatalf("WIFToPrivateKey = %x, %v", key, err)
+	}
+
+	if _, err := wm.Decoder.WIFToPrivateKey(wif, true); err == nil {
+		t.Fatalf("mainnet wif should be rejected on testnet")
+	}
+	if _, err := wm.Decoder.PrivateKeyToWIF(nil, false); err == nil {
+		t.Fatalf("PrivateKeyToWIF with an empty key should fail")
+	}
+}
+
+func TestSimNode_MultisigAddress(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 3)
+
+	pubs := make([][]byte, 0)
+	for _, a := range wallet.addresses {
+		pub, _ := hex.DecodeString(a.PublicKey)
+		pubs = append(pubs, pub)
+	}
+	want, wantScript := node.MultisigAddress(2, pubs[2], pubs[0], pubs[1])
+
+	address, err := wm.Decoder.RedeemScriptToAddress(pubs, 2, wm.Config.IsTestNet)
+	if err != nil || address != want {
+		t.Fatalf("RedeemScriptToAddress = %s, %v, want %s", address, err, want)
+	}
+
+	a, err := wm.NewMultisigAddress([][]byte{pubs[1], pubs[2], pubs[0]}, 2)
+	if err != nil {
+		t.Fatalf("NewMultisigAddress unexpected error: %v", err)
+	}
+	if a.Address != want || a.PublicKey != hex.EncodeToString(wantScript) {
+		t.Fatalf("NewMultisigAddress = %s %s, want %s %x", a.Address, a.PublicKey, want, wantScript)
+	}
+	if !wm.DecoderV2.AddressVerify(a.Address) {
+		t.Fatalf("multisig address %s should be valid", a.Address)
+	}
+
+	if _, err := wm.Decoder.RedeemScriptToAddress(pubs, 4, wm.Config.IsTestNet); err == nil {
+		t.Fatalf("RedeemScriptToAddress with required > n should fail")
+	}
+}
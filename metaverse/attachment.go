@@ -0,0 +1,197 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"strings"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+//输出附件类型，节点返回的attachment.type
+const (
+	AttachmentETP           = "etp"
+	AttachmentETPAward      = "etp-award"
+	AttachmentAssetIssue    = "asset-issue"
+	AttachmentAssetTransfer = "asset-transfer"
+	AttachmentAssetCert     = "asset-cert"
+	AttachmentAssetMIT      = "asset-mit"
+	AttachmentDIDRegister   = "did-register"
+	AttachmentDIDTransfer   = "did-transfer"
+	AttachmentMessage       = "message"
+	AttachmentCoinstake     = "coinstake"
+)
+
+//ExtParamMemo 交易单ExtParam中备注的字段，创建交易时作为消息附件输出，提取交易时记录消息附件的内容
+const ExtParamMemo = "memo"
+
+//AssetQuantity 资产和数量，资产发行和资产转账的输出都包含
+type AssetQuantity struct {
+	Symbol   string `json:"symbol"`
+	Quantity string `json:"quantity"`
+}
+
+//ETPAwardAttachment 挖矿奖励附件，锁仓存款的利息
+type ETPAwardAttachment struct {
+	Height uint64 `json:"height"`
+}
+
+//AssetIssueAttachment 资产发行附件，发行的数量全部转入Address
+type AssetIssueAttachment struct {
+	AssetQuantity
+	Decimals                int32  `json:"decimal_number"`
+	Issuer                  string `json:"issuer"`
+	Address                 string `json:"address"`
+	Description             string `json:"description"`
+	SecondaryIssueThreshold int64  `json:"secondaryissue_threshold"`
+	IsSecondaryIssue        bool   `json:"is_secondaryissue"`
+}
+
+//AssetTransferAttachment 资产转账附件
+type AssetTransferAttachment struct {
+	AssetQuantity
+}
+
+//AssetCertAttachment 资产证书附件，证书可以发行、转移和自动生成
+type AssetCertAttachment struct {
+	Symbol  string `json:"symbol"`
+	Owner   string `json:"owner"`
+	Address string `json:"address"`
+	Cert    string `json:"cert"`   //证书类型，如issue、domain、naming、mining
+	Status  string `json:"status"` //如issued、transfered、autoissued
+}
+
+//AssetMITAttachment MIT（唯一资产）附件
+type AssetMITAttachment struct {
+	Symbol  string `json:"symbol"`
+	Address string `json:"address"`
+	Status  string `json:"status"`  //registered或transfered
+	Content string `json:"content"` //注册时的内容
+}
+
+//DIDAttachment DID注册和转移附件
+type DIDAttachment struct {
+	Symbol  string `json:"symbol"`
+	Address string `json:"address"`
+}
+
+//MessageAttachment 消息附件
+type MessageAttachment struct {
+	Content string `json:"content"`
+}
+
+//parseAttachment 按附件类型解析输出的附件，无法识别的类型只记录Type
+func (obj *Vout) parseAttachment(attachment *gjson.Result) {
+
+	obj.Type = attachment.Get("type").String()
+
+	switch obj.Type {
+	case AttachmentETPAward:
+		obj.ETPAward = &ETPAwardAttachment{
+			Height: attachment.Get("height").Uint(),
+		}
+	case AttachmentAssetIssue:
+		//旧版本节点返回maximum_supply
+		quantity := attachment.Get("quantity")
+		if !quantity.Exists() {
+			quantity = attachment.Get("maximum_supply")
+		}
+		obj.AssetIssue = &AssetIssueAttachment{
+			AssetQuantity: AssetQuantity{
+				Symbol:   attachment.Get("symbol").String(),
+				Quantity: quantity.String(),
+			},
+			Decimals:                int32(attachment.Get("decimal_number").Int()),
+			Issuer:                  attachment.Get("issuer").String(),
+			Address:                 attachment.Get("address").String(),
+			Description:             attachment.Get("description").String(),
+			SecondaryIssueThreshold: attachment.Get("secondaryissue_threshold").Int(),
+			IsSecondaryIssue:        attachment.Get("is_secondaryissue").Bool(),
+		}
+	case AttachmentAssetTransfer:
+		obj.AssetTransfer = &AssetTransferAttachment{
+			AssetQuantity: AssetQuantity{
+				Symbol:   attachment.Get("symbol").String(),
+				Quantity: attachment.Get("quantity").String(),
+			},
+		}
+	case AttachmentAssetCert:
+		obj.AssetCert = &AssetCertAttachment{
+			Symbol:  attachment.Get("symbol").String(),
+			Owner:   attachment.Get("owner").String(),
+			Address: attachment.Get("address").String(),
+			Cert:    attachment.Get("cert").String(),
+			Status:  attachment.Get("status").String(),
+		}
+	case AttachmentAssetMIT:
+		obj.AssetMIT = &AssetMITAttachment{
+			Symbol:  attachment.Get("symbol").String(),
+			Address: attachment.Get("address").String(),
+			Status:  attachment.Get("status").String(),
+			Content: attachment.Get("content").String(),
+		}
+	case AttachmentDIDRegister, AttachmentDIDTransfer:
+		obj.DID = &DIDAttachment{
+			Symbol:  attachment.Get("symbol").String(),
+			Address: attachment.Get("address").String(),
+		}
+	case AttachmentMessage:
+		obj.Message = &MessageAttachment{
+			Content: attachment.Get("content").String(),
+		}
+	}
+
+	//资产发行和资产转账的输出作为代币处理
+	obj.IsToken = obj.AssetIssue != nil || obj.AssetTransfer != nil
+}
+
+//Asset 输出包含的资产，不是资产发行或资产转账时返回nil
+func (obj *Vout) Asset() *AssetQuantity {
+	if obj.AssetIssue != nil {
+		return &obj.AssetIssue.AssetQuantity
+	}
+	if obj.AssetTransfer != nil {
+		return &obj.AssetTransfer.AssetQuantity
+	}
+	return nil
+}
+
+//IsCoinstake 是否coinstake附件的输出
+func (obj *Vout) IsCoinstake() bool {
+	return obj.Type == AttachmentCoinstake
+}
+
+//Memo 交易单消息附件的内容，多个消息按输出顺序以换行连接
+func (trx *Transaction) Memo() string {
+	messages := make([]string, 0)
+	for _, output := range trx.Vouts {
+		if output.Message != nil && len(output.Message.Content) > 0 {
+			messages = append(messages, output.Message.Content)
+		}
+	}
+	return strings.Join(messages, "\n")
+}
+
+//setTransactionMemo 提取的交易单记录消息附件的内容
+func setTransactionMemo(tx *openwallet.Transaction, memo string) {
+	if len(memo) == 0 {
+		return
+	}
+	tx.IsMemo = true
+	tx.Memo = memo
+	tx.SetExtParam(ExtParamMemo, memo)
+}
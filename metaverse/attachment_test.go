@@ -0,0 +1,233 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestNewTxOut_Attachments(t *testing.T) {
+	tests := []struct {
+		name       string
+		attachment string
+		isToken    bool
+		asset      *AssetQuantity
+		check      func(out *Vout) bool
+	}{
+		{
+			name:       "etp",
+			attachment: `{"type":"etp"}`,
+			check:      func(out *Vout) bool { return out.Type == AttachmentETP },
+		},
+		{
+			name:       "etp award",
+			attachment: `{"type":"etp-award","height":1930000}`,
+			check: func(out *Vout) bool {
+				return reflect.DeepEqual(out.ETPAward, &ETPAwardAttachment{Height: 1930000})
+			},
+		},
+		{
+			name:       "asset issue",
+			attachment: `{"type":"asset-issue","symbol":"DNA","quantity":5000000,"decimal_number":4,"issuer":"dna","address":"MDxBVnDcFV3eZbTToV6Eb96MuhiM8GM7vW","description":"DNA token","secondaryissue_threshold":0,"is_secondaryissue":false}`,
+			isToken:    true,
+			asset:      &AssetQuantity{Symbol: "DNA", Quantity: "5000000"},
+			check: func(out *Vout) bool {
+				return reflect.DeepEqual(out.AssetIssue, &AssetIssueAttachment{
+					AssetQuantity: AssetQuantity{Symbol: "DNA", Quantity: "5000000"},
+					Decimals:      4,
+					Issuer:        "dna",
+					Address:       "MDxBVnDcFV3eZbTToV6Eb96MuhiM8GM7vW",
+					Description:   "DNA token",
+				})
+			},
+		},
+		{
+			name:       "asset issue with maximum supply",
+			attachment: `{"type":"asset-issue","symbol":"DNA","maximum_supply":100,"decimal_number":0,"is_secondaryissue":true,"secondaryissue_threshold":-1}`,
+			isToken:    true,
+			asset:      &AssetQuantity{Symbol: "DNA", Quantity: "100"},
+			check: func(out *Vout) bool {
+				return out.AssetIssue.IsSecondaryIssue && out.AssetIssue.SecondaryIssueThreshold == -1
+			},
+		},
+		{
+			name:       "asset transfer",
+			attachment: `{"type":"asset-transfer","symbol":"DNA","quantity":268220000}`,
+			isToken:    true,
+			asset:      &AssetQuantity{Symbol: "DNA", Quantity: "268220000"},
+			check:      func(out *Vout) bool { return out.AssetIssue == nil && out.AssetTransfer != nil },
+		},
+		{
+			name:       "asset cert",
+			attachment: `{"type":"asset-cert","symbol":"DNA","owner":"dna","address":"MDxBVnDcFV3eZbTToV6Eb96MuhiM8GM7vW","cert":"issue","status":"issued"}`,
+			check: func(out *Vout) bool {
+				return reflect.DeepEqual(out.AssetCert, &AssetCertAttachment{
+					Symbol:  "DNA",
+					Owner:   "dna",
+					Address: "MDxBVnDcFV3eZbTToV6Eb96MuhiM8GM7vW",
+					Cert:    "issue",
+					Status:  "issued",
+				})
+			},
+		},
+		{
+			name:       "asset mit",
+			attachment: `{"type":"asset-mit","symbol":"ART.001","address":"MDxBVnDcFV3eZbTToV6Eb96MuhiM8GM7vW","status":"registered","content":"hello"}`,
+			check: func(out *Vout) bool {
+				return reflect.DeepEqual(out.AssetMIT, &AssetMITAttachment{
+					Symbol:  "ART.001",
+					Address: "MDxBVnDcFV3eZbTToV6Eb96MuhiM8GM7vW",
+					Status:  "registered",
+					Content: "hello",
+				})
+			},
+		},
+		{
+			name:       "did register",
+			attachment: `{"type":"did-register","symbol":"alice","address":"MDxBVnDcFV3eZbTToV6Eb96MuhiM8GM7vW"}`,
+			check: func(out *Vout) bool {
+				return reflect.DeepEqual(out.DID, &DIDAttachment{Symbol: "alice", Address: "MDxBVnDcFV3eZbTToV6Eb96MuhiM8GM7vW"})
+			},
+		},
+		{
+			name:       "did transfer",
+			attachment: `{"type":"did-transfer","symbol":"alice","address":"MJHnq4qNYEptPC4Fc4pZMtrp45htA65Ygr"}`,
+			check:      func(out *Vout) bool { return out.DID != nil && out.DID.Address == "MJHnq4qNYEptPC4Fc4pZMtrp45htA65Ygr" },
+		},
+		{
+			name:       "message",
+			attachment: `{"type":"message","content":"order 1024"}`,
+			check:      func(out *Vout) bool { return reflect.DeepEqual(out.Message, &MessageAttachment{Content: "order 1024"}) },
+		},
+		{
+			name:       "coinstake",
+			attachment: `{"type":"coinstake"}`,
+			check:      func(out *Vout) bool { return out.IsCoinstake() },
+		},
+		{
+			name:       "unknown",
+			attachment: `{"type":"future-type","symbol":"DNA","quantity":1}`,
+			check:      func(out *Vout) bool { return out.Type == "future-type" && !out.IsCoinstake() },
+		},
+	}
+
+	for _, test := range tests {
+		json := gjson.Parse(`{"address":"MJHnq4qNYEptPC4Fc4pZMtrp45htA65Ygr","index":1,"value":0,"script":"dup hash160 [ 71f8f5732d9af26486b2afe4c8bc13a60b2c0db6 ] equalverify checksig","attachment":` + test.attachment + `}`)
+		out := NewTxOut(&json)
+		if out.IsToken != test.isToken {
+			t.Errorf("%s: IsToken = %v, want %v", test.name, out.IsToken, test.isToken)
+		}
+		if !reflect.DeepEqual(out.Asset(), test.asset) {
+			t.Errorf("%s: Asset() = %+v, want %+v", test.name, out.Asset(), test.asset)
+		}
+		if !test.check(out) {
+			t.Errorf("%s: unexpected attachment: %+v", test.name, out)
+		}
+	}
+}
+
+func TestTransaction_Memo(t *testing.T) {
+	wm := NewWalletManager()
+	json := gjson.Parse(`{"hash":"20a1627a5cdf6cb6d3656161af949d2e54f18114f51888421733a2de8763a2b5","outputs":[
+		{"address":"MJYG1e7rjQDob7kMFqRKdHYWcwoErrthGT","attachment":{"type":"etp"},"index":0,"value":100},
+		{"address":"MJYG1e7rjQDob7kMFqRKdHYWcwoErrthGT","attachment":{"type":"message","content":"order 1024"},"index":1,"value":0},
+		{"address":"MJYG1e7rjQDob7kMFqRKdHYWcwoErrthGT","attachment":{"type":"message","content":"uid 7"},"index":2,"value":0}
+	]}`)
+	if memo := wm.NewTransaction(&json).Memo(); memo != "order 1024\nuid 7" {
+		t.Fatalf("Memo = %q", memo)
+	}
+
+	json = gjson.Parse(`{"outputs":[{"address":"MJYG1e7rjQDob7kMFqRKdHYWcwoErrthGT","attachment":{"type":"etp"},"index":0,"value":100}]}`)
+	if memo := wm.NewTransaction(&json).Memo(); memo != "" {
+		t.Fatalf("Memo without message = %q", memo)
+	}
+}
+
+func TestSimNode_TransactionDecoder_Memo(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+	from := wallet.addresses[0].Address
+	to := wallet.addresses[1].Address
+
+	node.Fund(from, 100000000)
+	node.Mine(1)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.ScanBlockTask()
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{to: "0.5"},
+	}
+	rawTx.SetExtParam(ExtParamMemo, "order 1024")
+
+	decoder := wm.GetTransactionDecoder()
+	if err := decoder.CreateRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("CreateRawTransaction unexpected error: %v", err)
+	}
+	//消息附件输出不计入TxTo
+	for _, to := range rawTx.TxTo {
+		if strings.HasSuffix(to, ":0") {
+			t.Fatalf("message output is recorded in TxTo: %v", rawTx.TxTo)
+		}
+	}
+	decoded, decodeErr := wm.DecodeRawTx(context.Background(), rawTx.RawHex)
+	if decodeErr != nil || decoded.Memo() != "order 1024" {
+		t.Fatalf("decoded memo = %v, %v", decoded, decodeErr)
+	}
+
+	if err := decoder.SignRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("SignRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.VerifyRawTransaction(wallet, rawTx); err != nil || !rawTx.IsCompleted {
+		t.Fatalf("VerifyRawTransaction failed: %v", err)
+	}
+	tx, err := decoder.SubmitRawTransaction(wallet, rawTx)
+	if err != nil {
+		t.Fatalf("SubmitRawTransaction unexpected error: %v", err)
+	}
+	node.Mine(1)
+	bs.ScanBlockTask()
+
+	data := observer.extractData(tx.TxID)
+	if len(data) == 0 {
+		t.Fatalf("memo transaction is not extracted")
+	}
+	extracted := data[0].Transaction
+	if !extracted.IsMemo || extracted.Memo != "order 1024" || extracted.GetExtParam().Get(ExtParamMemo).String() != "order 1024" {
+		t.Fatalf("unexpected memo: %+v", extracted)
+	}
+	//消息附件输出不作为收款提取
+	received := 0
+	for _, out := range data[0].TxOutputs {
+		if out.Address == to {
+			received++
+			if out.Amount != "0.5" {
+				t.Fatalf("unexpected receiver output: %+v", out)
+			}
+		}
+	}
+	if received != 1 {
+		t.Fatalf("receiver outputs = %d, want 1", received)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//DefaultBlockNotifyPath 新区块回调的默认路径
+	DefaultBlockNotifyPath = "/notify/block"
+	//blockEventBuffer 未处理的新区块通知数量，超过时丢弃，扫描器每次都会扫到最新高度
+	blockEventBuffer = 16
+)
+
+//BlockEvent 新区块通知
+type BlockEvent struct {
+	Height uint64
+	Hash   string
+}
+
+//BlockNotifier 新区块通知源。
+//扫描器收到通知后立即扫描，定时扫描仍然保留，通知丢失时由定时扫描补上。
+type BlockNotifier interface {
+	//Start 开始接收通知，通知发送到返回的通道
+	Start() (<-chan *BlockEvent, error)
+	//Stop 停止接收通知并关闭通道
+	Stop() error
+}
+
+//HTTPBlockNotifier 本地HTTP回调通知源，节点的sidecar出块后POST到Path：
+//{"height": 3584831, "hash": "..."}
+//body可以为空，只触发扫描。
+type HTTPBlockNotifier struct {
+	Addr string //监听地址，例如127.0.0.1:8822
+	Path string //回调路径
+
+	mu       sync.Mutex
+	listener net.Listener
+	server   *http.Server
+	events   chan *BlockEvent
+}
+
+//NewHTTPBlockNotifier 创建HTTP回调通知源，path为空时使用DefaultBlockNotifyPath
+func NewHTTPBlockNotifier(addr, path string) *HTTPBlockNotifier {
+	if len(path) == 0 {
+		path = DefaultBlockNotifyPath
+	}
+	return &HTTPBlockNotifier{Addr: addr, Path: path}
+}
+
+//Start 开始监听，已经启动时返回原来的通道
+func (n *HTTPBlockNotifier) Start() (<-chan *BlockEvent, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.server != nil {
+		return n.events, nil
+	}
+
+	listener, err := net.Listen("tcp", n.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(n.Path, n.handle)
+
+	//监听端口为0时记住实际分配的端口，重新启动后回调地址不变
+	n.Addr = listener.Addr().String()
+	n.listener = listener
+	n.server = &http.Server{Handler: mux}
+	n.events = make(chan *BlockEvent, blockEventBuffer)
+
+	go n.server.Serve(listener)
+
+	return n.events, nil
+}
+
+//Stop 停止监听并关闭通道
+func (n *HTTPBlockNotifier) Stop() error {
+	n.mu.Lock()
+	server := n.server
+	n.server = nil
+	n.listener = nil
+	n.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	err := server.Close()
+
+	n.mu.Lock()
+	close(n.events)
+	n.mu.Unlock()
+
+	return err
+}
+
+//URL 回调地址
+func (n *HTTPBlockNotifier) URL() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return "http://" + n.Addr + n.Path
+}
+
+//handle 接收新区块回调
+func (n *HTTPBlockNotifier) handle(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event := &BlockEvent{}
+	if len(body) > 0 {
+		if !gjson.ValidBytes(body) {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		result := gjson.ParseBytes(body)
+		event.Height = result.Get("height").Uint()
+		event.Hash = result.Get("hash").String()
+	}
+
+	n.mu.Lock()
+	if n.server != nil {
+		select {
+		case n.events <- event:
+		default:
+			//通道已满，扫描器正在处理之前的通知
+		}
+	}
+	n.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHTTPBlockNotifier(t *testing.T) {
+	notifier := NewHTTPBlockNotifier("127.0.0.1:0", "")
+	events, err := notifier.Start()
+	if err != nil {
+		t.Fatalf("Start unexpected error: %v", err)
+	}
+	url := notifier.URL()
+
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(`{"height":12,"hash":"abcd"}`))
+	if err != nil {
+		t.Fatalf("Post unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	select {
+	case event := <-events:
+		if event.Height != 12 || event.Hash != "abcd" {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no event received")
+	}
+
+	resp, _ = http.Post(url, "application/json", bytes.NewBufferString(`{"height":`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid json status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	resp, _ = http.Get(url)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	if err := notifier.Stop(); err != nil {
+		t.Fatalf("Stop unexpected error: %v", err)
+	}
+	if _, ok := <-events; ok {
+		t.Fatalf("events should be closed after Stop")
+	}
+	if _, err := http.Post(url, "application/json", nil); err == nil {
+		t.Fatalf("Post after Stop should fail")
+	}
+}
+
+func TestSimNode_BlockScanner_Notify(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+
+	bs, _ := newSimBlockScanner(wm, wallet)
+	bs.Scanning = false
+	//定时扫描不会在测试期间触发
+	bs.PeriodOfTask = time.Hour
+	bs.SetTask(bs.ScanBlockTask)
+
+	notifier := NewHTTPBlockNotifier("127.0.0.1:0", "")
+	bs.SetBlockNotifier(notifier)
+	if err := bs.Run(); err != nil {
+		t.Fatalf("Run unexpected error: %v", err)
+	}
+	t.Cleanup(func() { bs.Stop() })
+
+	node.SetBlockNotify(notifier.URL())
+
+	waitHeight := func(want uint64) {
+		deadline := time.Now().Add(3 * time.Second)
+		for bs.GetScannedBlockHeight() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("scanned height = %d, want %d", bs.GetScannedBlockHeight(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	node.Mine(2)
+	waitHeight(2)
+
+	node.Mine(1)
+	waitHeight(3)
+
+	//暂停后不再响应通知
+	bs.Pause()
+	node.Mine(1)
+	time.Sleep(100 * time.Millisecond)
+	if h := bs.GetScannedBlockHeight(); h != 3 {
+		t.Fatalf("scanned height = %d while paused, want 3", h)
+	}
+
+	bs.Restart()
+	node.Mine(1)
+	waitHeight(5)
+}
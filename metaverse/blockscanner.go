@@ -16,9 +16,11 @@
 package metaverse
 
 import (
+	"context"
 	"fmt"
 	"github.com/blocktree/openwallet/v2/openwallet"
 	"github.com/shopspring/decimal"
+	"sync"
 	"time"
 )
 
@@ -31,11 +33,38 @@ type ETPBlockScanner struct {
 	*openwallet.BlockScannerBase
 
 	CurrentBlockHeight   uint64         //当前区块高度
-	extractingCH         chan struct{}  //扫描工作令牌
+	extractingSize       int            //并发提取交易的线程数
 	wm                   *WalletManager //钱包管理者
 	IsScanMemPool        bool           //是否扫描交易池
 	RescanLastBlockCount uint64         //重扫上N个区块数量
-
+	MaxReorgDepth        uint64         //允许的最大分叉深度，为0时不限制
+	MinConfirmations     uint64         //区块确认数达到N后才通知提取的交易，为0或1时在最新区块通知
+	NotifySeen           bool           //未达到确认数的交易是否先通知已发现
+	CatchUpWorkers       int            //落后较多时并行追块的线程数，为0或1时逐块扫描
+
+	//ReorgAlarm 分叉深度超过MaxReorgDepth时的报警，扫描停止直到重新设置扫描高度
+	ReorgAlarm func(err *openwallet.Error)
+
+	scanMu     sync.Mutex    //定时扫描和通知扫描不同时执行
+	notifier   BlockNotifier //新区块通知源，为nil时只定时扫描
+	notifyMu   sync.Mutex
+	notifyDone chan struct{} //通知处理结束
+
+	depositMu sync.Mutex
+	deposits  map[string]*lockedDeposit //等待到期的锁仓存款，key为输出的Sid，保存在DBPath中
+
+	mempoolMu   sync.Mutex
+	mempoolTxs  map[string]*mempoolTx //已通知的未确认交易
+	mempoolSeen map[string]bool       //交易池中已提取过的交易，包括不相关的交易，离开交易池后删除
+
+	forkMu      sync.Mutex
+	forkRecords map[uint64]*blockExtractRecord //最近区块中提取的交易，分叉时通知，保存在DBPath中
+	forkDirty   bool                           //提取记录有变化，需要保存
+	reorgHalt   *openwallet.Error              //分叉过深停止扫描的原因
+
+	confirmMu    sync.Mutex
+	confirmWaits map[uint64]*confirmWait //等待达到确认数的交易，保存在DBPath中
+	confirmDirty bool                    //等待确认的交易有变化，需要保存
 }
 
 type ExtractOutput map[string][]*openwallet.TxOutPut
@@ -63,10 +92,11 @@ func NewETPBlockScanner(wm *WalletManager) *ETPBlockScanner {
 		BlockScannerBase: openwallet.NewBlockScannerBase(),
 	}
 
-	bs.extractingCH = make(chan struct{}, maxExtractingSize)
+	bs.extractingSize = maxExtractingSize
 	bs.wm = wm
 	bs.IsScanMemPool = true
 	bs.RescanLastBlockCount = 0
+	bs.MaxReorgDepth = DefaultMaxReorgDepth
 
 	//设置扫描任务
 	bs.SetTask(bs.ScanBlockTask)
@@ -74,6 +104,128 @@ func NewETPBlockScanner(wm *WalletManager) *ETPBlockScanner {
 	return &bs
 }
 
+//setScanConfig 应用扫描配置，等待正在执行的扫描结束。扫描运行中时新的扫描间隔在重新启动后生效
+func (bs *ETPBlockScanner) setScanConfig(c *WalletConfig) {
+	bs.scanMu.Lock()
+	defer bs.scanMu.Unlock()
+
+	bs.IsScanMemPool = c.IsScanMemPool
+	bs.RescanLastBlockCount = c.RescanLastBlockCount
+	bs.MaxReorgDepth = c.MaxReorgDepth
+	bs.MinConfirmations = c.MinConfirmations
+	bs.NotifySeen = c.NotifySeen
+	bs.CatchUpWorkers = c.CatchUpWorkers
+	if c.MaxExtractingSize > 0 {
+		bs.extractingSize = c.MaxExtractingSize
+	}
+	if c.ScanPeriod > 0 && c.ScanPeriod != bs.PeriodOfTask && !bs.Scanning {
+		bs.PeriodOfTask = c.ScanPeriod
+		bs.SetTask(bs.ScanBlockTask)
+	}
+}
+
+//SetBlockNotifier 设置新区块通知源，收到通知后立即扫描，定时扫描作为补充
+func (bs *ETPBlockScanner) SetBlockNotifier(notifier BlockNotifier) {
+	bs.stopNotifier()
+	bs.notifyMu.Lock()
+	bs.notifier = notifier
+	bs.notifyMu.Unlock()
+	if bs.Scanning {
+		bs.startNotifier()
+	}
+}
+
+//Run 运行
+func (bs *ETPBlockScanner) Run() error {
+	if err := bs.BlockScannerBase.Run(); err != nil {
+		return err
+	}
+	bs.startNotifier()
+	return nil
+}
+
+//Stop 停止扫描
+func (bs *ETPBlockScanner) Stop() error {
+	bs.stopNotifier()
+	return bs.BlockScannerBase.Stop()
+}
+
+//Pause 暂停扫描
+func (bs *ETPBlockScanner) Pause() error {
+	bs.stopNotifier()
+	return bs.BlockScannerBase.Pause()
+}
+
+//Restart 继续扫描
+func (bs *ETPBlockScanner) Restart() error {
+	if err := bs.BlockScannerBase.Restart(); err != nil {
+		return err
+	}
+	bs.startNotifier()
+	return nil
+}
+
+//CloseBlockScanner 关闭扫描器
+func (bs *ETPBlockScanner) CloseBlockScanner() error {
+	bs.stopNotifier()
+	return bs.BlockScannerBase.CloseBlockScanner()
+}
+
+//startNotifier 开始接收新区块通知，启动失败时只定时扫描
+func (bs *ETPBlockScanner) startNotifier() {
+	bs.notifyMu.Lock()
+	defer bs.notifyMu.Unlock()
+
+	if bs.notifier == nil || bs.notifyDone != nil {
+		return
+	}
+
+	events, err := bs.notifier.Start()
+	if err != nil {
+		bs.wm.Log.Std.Warning("block notifier can not start, fall back to polling; unexpected error: %v", err)
+		return
+	}
+
+	done := make(chan struct{})
+	bs.notifyDone = done
+
+	go func() {
+		defer close(done)
+		for event := range events {
+			//合并扫描期间收到的通知
+			for pending := true; pending; {
+				select {
+				case next, ok := <-events:
+					if !ok {
+						return
+					}
+					event = next
+				default:
+					pending = false
+				}
+			}
+			bs.wm.Log.Std.Info("block scanner notified of new block; height: %d, hash: %s", event.Height, event.Hash)
+			bs.ScanBlockTask()
+		}
+	}()
+}
+
+//stopNotifier 停止接收新区块通知，等待正在执行的扫描结束
+func (bs *ETPBlockScanner) stopNotifier() {
+	bs.notifyMu.Lock()
+	defer bs.notifyMu.Unlock()
+
+	if bs.notifyDone == nil {
+		return
+	}
+
+	if err := bs.notifier.Stop(); err != nil {
+		bs.wm.Log.Std.Warning("block notifier stop failed; unexpected error: %v", err)
+	}
+	<-bs.notifyDone
+	bs.notifyDone = nil
+}
+
 //SetRescanBlockHeight 重置区块链扫描高度
 func (bs *ETPBlockScanner) SetRescanBlockHeight(height uint64) error {
 	height = height - 1
@@ -81,19 +233,33 @@ func (bs *ETPBlockScanner) SetRescanBlockHeight(height uint64) error {
 		return fmt.Errorf("block height to rescan must greater than 0.")
 	}
 
-	block, err := bs.wm.GetBlockHeader(height)
+	block, err := bs.wm.GetBlockHeader(context.Background(), height)
 	if err != nil {
 		return err
 	}
 
 	bs.SaveLocalBlockHead(height, block.Hash)
 
+	//重新设置扫描高度后恢复因分叉过深停止的扫描
+	bs.forkMu.Lock()
+	bs.reorgHalt = nil
+	bs.forkMu.Unlock()
+
 	return nil
 }
 
 //ScanBlockTask 扫描任务
 func (bs *ETPBlockScanner) ScanBlockTask() {
 
+	bs.scanMu.Lock()
+	defer bs.scanMu.Unlock()
+
+	//分叉过深时停止扫描，等待重新设置扫描高度
+	if haltErr := bs.ReorgHalted(); haltErr != nil {
+		bs.wm.Log.Std.Error("block scanner is halted: %v", haltErr)
+		return
+	}
+
 	//获取本地区块高度
 	header, err := bs.GetScannedBlockHeader()
 	if err != nil {
@@ -104,6 +270,9 @@ func (bs *ETPBlockScanner) ScanBlockTask() {
 	currentHeight := header.Height
 	currentHash := header.Hash
 
+	//落后较多时并行追块
+	currentHeight, currentHash = bs.catchUp(currentHeight, currentHash)
+
 	for {
 
 		if !bs.Scanning {
@@ -112,7 +281,7 @@ func (bs *ETPBlockScanner) ScanBlockTask() {
 		}
 
 		//获取最大高度
-		maxHeader, err := bs.wm.GetBlockHeader()
+		maxHeader, err := bs.wm.GetBlockHeader(context.Background())
 		if err != nil {
 			//下一个高度找不到会报异常
 			bs.wm.Log.Std.Info("block scanner can not get rpc-server block height; unexpected error: %v", err)
@@ -132,10 +301,16 @@ func (bs *ETPBlockScanner) ScanBlockTask() {
 
 		bs.wm.Log.Std.Info("block scanner scanning height: %d ...", currentHeight)
 
-		block, err := bs.wm.GetBlockByHeight(currentHeight)
+		block, err := bs.wm.GetBlockByHeight(context.Background(), currentHeight)
 		if err != nil {
 			bs.wm.Log.Std.Info("block scanner can not get new block data; unexpected error: %v", err)
 
+			if IsRetryable(err) {
+				//节点暂时不可用，下次任务从该高度继续扫描
+				currentHeight = currentHeight - 1
+				break
+			}
+
 			//记录未扫区块
 			unscanRecord := openwallet.NewUnscanRecord(currentHeight, "", err.Error(), bs.wm.Symbol())
 			bs.SaveUnscanRecord(unscanRecord)
@@ -143,8 +318,6 @@ func (bs *ETPBlockScanner) ScanBlockTask() {
 			continue
 		}
 
-		isFork := false
-
 		//判断hash是否上一区块的hash
 		if currentHash != block.Previousblockhash {
 
@@ -152,54 +325,27 @@ func (bs *ETPBlockScanner) ScanBlockTask() {
 			bs.wm.Log.Std.Info("block height: %d local hash = %s ", currentHeight-1, currentHash)
 			bs.wm.Log.Std.Info("block height: %d mainnet hash = %s ", currentHeight-1, block.Previousblockhash)
 
-			bs.wm.Log.Std.Info("delete recharge records on block height: %d.", currentHeight-1)
-
-			//查询本地分叉的区块
-			forkBlock, _ := bs.GetLocalBlock(currentHeight - 1)
-
-			//删除上一区块链的所有充值记录
-			//bs.DeleteRechargesByHeight(currentHeight - 1)
-			//删除上一区块链的未扫记录
-			bs.DeleteUnscanRecord(currentHeight - 1)
-			currentHeight = currentHeight - 2 //倒退2个区块重新扫描
-			if currentHeight <= 0 {
-				currentHeight = 1
-			}
-
-			localBlock, err := bs.GetLocalBlock(currentHeight)
-			if err != nil {
-				bs.wm.Log.Std.Error("block scanner can not get local block; unexpected error: %v", err)
-
-				//查找core钱包的RPC
-				bs.wm.Log.Info("block scanner prev block height:", currentHeight)
-
-				localBlock, err = bs.wm.GetBlockByHeight(currentHeight)
-				if err != nil {
-					bs.wm.Log.Std.Error("block scanner can not get prev block; unexpected error: %v", err)
-					break
+			//向前查找共同祖先，通知所有被孤立的区块
+			ancestor, forkErr := bs.rollbackFork(currentHeight - 1)
+			if forkErr != nil {
+				bs.wm.Log.Std.Error("block scanner can not roll back fork; unexpected error: %v", forkErr)
+				if bs.ReorgHalted() != nil {
+					return
 				}
-
+				//下次任务重新检查分叉
+				currentHeight = currentHeight - 1
+				break
 			}
 
-			//重置当前区块的hash
-			currentHash = localBlock.Hash
+			//从共同祖先继续扫描
+			currentHeight = ancestor.Height
+			currentHash = ancestor.Hash
 
 			bs.wm.Log.Std.Info("rescan block on height: %d, hash: %s .", currentHeight, currentHash)
 
-			//重新记录一个新扫描起点
-			bs.SaveLocalBlockHead(localBlock.Height, localBlock.Hash)
-
-			isFork = true
-
-			if forkBlock != nil {
-
-				//通知分叉区块给观测者，异步处理
-				bs.newBlockNotify(forkBlock, isFork)
-			}
-
 		} else {
 
-			batchErr := bs.BatchExtractTransaction(block.Height, block.Hash, block.transactions)
+			_, batchErr := bs.BatchExtractTransaction(block.Height, block.Hash, block.transactions)
 			if batchErr != nil {
 				bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", batchErr)
 			}
@@ -207,26 +353,31 @@ func (bs *ETPBlockScanner) ScanBlockTask() {
 			//重置当前区块的hash
 			currentHash = block.Hash
 
-			//保存本地新高度
-			bs.SaveLocalBlockHead(currentHeight, currentHash)
-			bs.SaveLocalBlock(block)
-
-			isFork = false
-
-			//通知新区块给观测者，异步处理
-			bs.newBlockNotify(block, isFork)
+			bs.commitBlock(block)
 		}
 
 	}
 
 	//重扫前N个块，为保证记录找到
-	for i := currentHeight - bs.RescanLastBlockCount; i <= currentHeight; i++ {
+	rescanFrom := uint64(0)
+	if currentHeight > bs.RescanLastBlockCount {
+		rescanFrom = currentHeight - bs.RescanLastBlockCount
+	}
+	for i := rescanFrom; i <= currentHeight; i++ {
 		bs.scanBlock(i)
 	}
 
 	//重扫失败区块
 	bs.RescanFailedRecord()
 
+	//通知达到确认数的交易
+	bs.notifyConfirmedExtractData()
+
+	//扫描交易池
+	if bs.IsScanMemPool {
+		bs.ScanMemPool()
+	}
+
 }
 
 //ScanBlock 扫描指定高度区块
@@ -245,7 +396,7 @@ func (bs *ETPBlockScanner) ScanBlock(height uint64) error {
 
 func (bs *ETPBlockScanner) scanBlock(height uint64) (*Block, error) {
 
-	block, err := bs.wm.GetBlockByHeight(height)
+	block, err := bs.wm.GetBlockByHeight(context.Background(), height)
 	if err != nil {
 		bs.wm.Log.Std.Info("block scanner can not get new block data; unexpected error: %v", err)
 
@@ -258,7 +409,7 @@ func (bs *ETPBlockScanner) scanBlock(height uint64) (*Block, error) {
 
 	bs.wm.Log.Std.Info("block scanner scanning height: %d ...", block.Height)
 
-	batchErr := bs.BatchExtractTransaction(block.Height, block.Hash, block.transactions)
+	_, batchErr := bs.BatchExtractTransaction(block.Height, block.Hash, block.transactions)
 	if batchErr != nil {
 		bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", batchErr)
 	}
@@ -281,7 +432,7 @@ func (bs *ETPBlockScanner) GetScannedBlockHeader() (*openwallet.BlockHeader, err
 
 	//如果本地没有记录，查询接口的高度
 	if blockHeight == 0 {
-		header, err := bs.wm.GetBlockHeader()
+		header, err := bs.wm.GetBlockHeader(context.Background())
 		if err != nil {
 
 			return nil, err
@@ -290,7 +441,7 @@ func (bs *ETPBlockScanner) GetScannedBlockHeader() (*openwallet.BlockHeader, err
 		//就上一个区块链为当前区块
 		blockHeight = header.Height - 1
 
-		startHeader, err := bs.wm.GetBlockHeader(blockHeight)
+		startHeader, err := bs.wm.GetBlockHeader(context.Background(), blockHeight)
 		if err != nil {
 			return nil, err
 		}
@@ -336,13 +487,13 @@ func (bs *ETPBlockScanner) RescanFailedRecord() {
 
 		bs.wm.Log.Std.Info("block scanner rescanning height: %d ...", height)
 
-		block, err := bs.wm.GetBlockByHeight(height)
+		block, err := bs.wm.GetBlockByHeight(context.Background(), height)
 		if err != nil {
 			bs.wm.Log.Std.Info("block scanner can not get new block data; unexpected error: %v", err)
 			continue
 		}
 
-		batchErr := bs.BatchExtractTransaction(block.Height, block.Hash, block.transactions)
+		_, batchErr := bs.BatchExtractTransaction(block.Height, block.Hash, block.transactions)
 		if batchErr != nil {
 			bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", batchErr)
 			continue
@@ -353,6 +504,23 @@ func (bs *ETPBlockScanner) RescanFailedRecord() {
 	}
 }
 
+//commitBlock 保存已提取的区块为本地新高度，通知到期的锁仓存款和新区块
+func (bs *ETPBlockScanner) commitBlock(block *Block) {
+
+	//保存本地新高度
+	bs.SaveLocalBlockHead(block.Height, block.Hash)
+	bs.SaveLocalBlock(block)
+
+	//通知到期的锁仓存款
+	bs.notifyMaturedDeposits(block)
+
+	//保存区块中的提取记录和等待确认的交易
+	bs.saveScannerStates()
+
+	//通知新区块给观测者，异步处理
+	bs.newBlockNotify(block, false)
+}
+
 //newBlockNotify 获得新区块后，通知给观测者
 func (bs *ETPBlockScanner) newBlockNotify(block *Block, isFork bool) {
 	header := block.BlockHeader(bs.wm.Symbol())
@@ -360,129 +528,99 @@ func (bs *ETPBlockScanner) newBlockNotify(block *Block, isFork bool) {
 	bs.NewBlockNotify(header)
 }
 
-//BatchExtractTransaction 批量提取交易单
-//bitcoin 1M的区块链可以容纳3000笔交易，批量多线程处理，速度更快
-func (bs *ETPBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHash string, txs []*Transaction) error {
-
-	var (
-		quit       = make(chan struct{})
-		done       = 0 //完成标记
-		failed     = 0
-		shouldDone = len(txs) //需要完成的总数
-	)
-
-	if len(txs) == 0 {
-		return fmt.Errorf("BatchExtractTransaction block is nil.")
-	}
-
-	//生产通道
-	producer := make(chan ExtractResult)
-	defer close(producer)
-
-	//消费通道
-	worker := make(chan ExtractResult)
-	defer close(worker)
+//BatchExtractTransaction 批量提取交易单，按区块中的交易顺序通知并返回每笔交易的提取结果。
+//提取或通知失败的交易记录未扫区块，空区块视为成功
+func (bs *ETPBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHash string, txs []*Transaction) ([]ExtractResult, error) {
 
-	//保存工作
-	saveWork := func(height uint64, result chan ExtractResult) {
-		//回收创建的地址
-		for gets := range result {
+	results := bs.extractTransactions(blockHeight, blockHash, txs)
 
-			if gets.Success {
-
-				notifyErr := bs.newExtractDataNotify(height, gets.extractData)
-				//saveErr := bs.SaveRechargeToWalletDB(height, gets.Recharges)
-				if notifyErr != nil {
-					failed++ //标记保存失败数
-					bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", notifyErr)
-				}
-
-			} else {
-				//记录未扫区块
-				unscanRecord := openwallet.NewUnscanRecord(height, "", "", bs.wm.Symbol())
-				bs.SaveUnscanRecord(unscanRecord)
-				bs.wm.Log.Std.Info("block height: %d extract failed.", height)
-				failed++ //标记保存失败数
-			}
-			//累计完成的线程数
-			done++
-			if done == shouldDone {
-				//bs.wm.Log.Std.Info("done = %d, shouldDone = %d ", done, len(txs))
-				close(quit) //关闭通道，等于给通道传入nil
-			}
+	//需要确认数时获取最新高度
+	tipHeight := blockHeight
+	if bs.MinConfirmations > 1 && len(results) > 0 {
+		if tip := bs.GetGlobalMaxBlockHeight(); tip > 0 {
+			tipHeight = tip
 		}
 	}
 
-	//提取工作
-	extractWork := func(eblockHeight uint64, eBlockHash string, mTxs []*Transaction, eProducer chan ExtractResult) {
-		for _, tx := range mTxs {
-			bs.extractingCH <- struct{}{}
-			//shouldDone++
-			go func(mBlockHeight uint64, mTx *Transaction, end chan struct{}, mProducer chan<- ExtractResult) {
-
-				//导出提出的交易
-				mProducer <- bs.ExtractTransaction(mBlockHeight, eBlockHash, mTx, bs.ScanTargetFuncV2)
-				//释放
-				<-end
-
-			}(eblockHeight, tx, bs.extractingCH, eProducer)
+	failed := 0
+	for _, result := range results {
+		if !bs.saveExtractResult(blockHeight, blockHash, tipHeight, result) {
+			failed++ //标记保存失败数
 		}
 	}
 
-	/*	开启导出的线程	*/
-
-	//独立线程运行消费
-	go saveWork(blockHeight, worker)
-
-	//独立线程运行生产
-	go extractWork(blockHeight, blockHash, txs, producer)
+	if failed > 0 {
+		return results, fmt.Errorf("block height: %d, %d of %d transactions failed to extract", blockHeight, failed, len(txs))
+	}
+	return results, nil
+}
 
-	//以下使用生产消费模式
-	bs.extractRuntime(producer, worker, quit)
+//extractTransactions 固定数量的线程并发提取交易单，结果与txs的顺序相同
+func (bs *ETPBlockScanner) extractTransactions(blockHeight uint64, blockHash string, txs []*Transaction) []ExtractResult {
 
-	if failed > 0 {
-		return fmt.Errorf("block scanner saveWork failed")
-	} else {
-		return nil
+	results := make([]ExtractResult, len(txs))
+	if len(txs) == 0 {
+		return results
 	}
 
-	//return nil
-}
+	//批量填充区块内所有交易的输入信息，失败的交易在提取时单独重试
+	if fillErr := bs.wm.FillInputFields(context.Background(), txs...); fillErr != nil {
+		bs.wm.Log.Std.Warning("block height: %d batch fill inputs failed, unexpected error: %v", blockHeight, fillErr)
+	}
 
-//extractRuntime 提取运行时
-func (bs *ETPBlockScanner) extractRuntime(producer chan ExtractResult, worker chan ExtractResult, quit chan struct{}) {
+	workers := bs.extractingSize
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(txs) {
+		workers = len(txs)
+	}
 
 	var (
-		values = make([]ExtractResult, 0)
+		jobs = make(chan int)
+		wg   sync.WaitGroup
 	)
 
-	for {
-
-		var activeWorker chan<- ExtractResult
-		var activeValue ExtractResult
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			//每个线程只写入自己领取的位置
+			for i := range jobs {
+				results[i] = bs.ExtractTransaction(blockHeight, blockHash, txs[i], bs.ScanTargetFuncV2)
+			}
+		}()
+	}
 
-		//当数据队列有数据时，释放顶部，传输给消费者
-		if len(values) > 0 {
-			activeWorker = worker
-			activeValue = values[0]
+	for i := range txs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
 
-		}
+	return results
+}
 
-		select {
+//saveExtractResult 通知提取结果并关注其中的锁仓存款，提取失败时记录未扫区块
+func (bs *ETPBlockScanner) saveExtractResult(height uint64, blockHash string, tipHeight uint64, gets ExtractResult) bool {
 
-		//生成者不断生成数据，插入到数据队列尾部
-		case pa := <-producer:
-			values = append(values, pa)
-		case <-quit:
-			//退出
-			//bs.wm.Log.Std.Info("block scanner have been scanned!")
-			return
-		case activeWorker <- activeValue:
-			//wm.Log.Std.Info("Get %d", len(activeValue))
-			values = values[1:]
-		}
+	if !gets.Success {
+		//记录未扫区块
+		unscanRecord := openwallet.NewUnscanRecord(height, "", "", bs.wm.Symbol())
+		bs.SaveUnscanRecord(unscanRecord)
+		bs.wm.Log.Std.Info("block height: %d extract failed.", height)
+		return false
 	}
 
+	notifyErr := bs.notifyBlockExtractData(height, blockHash, tipHeight, gets)
+	bs.watchExtractedDeposits(gets.extractData)
+	bs.confirmMemPoolTx(gets.TxID)
+	//saveErr := bs.SaveRechargeToWalletDB(height, gets.Recharges)
+	if notifyErr != nil {
+		bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", notifyErr)
+		return false
+	}
+	return true
 }
 
 //ExtractTransaction 提取交易单
@@ -519,7 +657,7 @@ func (bs *ETPBlockScanner) extractTransaction(trx *Transaction, result *ExtractR
 
 		//检查交易单输入信息是否完整，不完整查上一笔交易单的输出填充数据
 
-		txErr := bs.wm.FillInputFields(trx)
+		txErr := bs.wm.FillInputFields(context.Background(), trx)
 		if txErr != nil {
 			success = false
 		} else {
@@ -546,7 +684,7 @@ func (bs *ETPBlockScanner) extractTransaction(trx *Transaction, result *ExtractR
 		//			preOut := preVouts[vout]
 		//			input.Addr = preOut.Addr
 		//			input.Value = preOut.Value
-		//			input.AssetAttachment = preOut.AssetAttachment
+		//			input.Asset = preOut.Asset()
 		//			input.IsToken = preOut.IsToken
 		//
 		//			success = true
@@ -566,6 +704,8 @@ func (bs *ETPBlockScanner) extractTransaction(trx *Transaction, result *ExtractR
 			tokenExtractOutput, to, totalReceived := bs.extractTxOutput(trx, result, scanTargetFunc)
 			//手续费
 			fees := totalSpent.Sub(totalReceived)
+			//消息附件作为备注
+			memo := trx.Memo()
 
 			for token, sourceExtractInput := range tokenExtractInput {
 
@@ -613,6 +753,7 @@ func (bs *ETPBlockScanner) extractTransaction(trx *Transaction, result *ExtractR
 						}
 						wxID := openwallet.GenTransactionWxID(extractData.Transaction)
 						extractData.Transaction.WxID = wxID
+						setTransactionMemo(extractData.Transaction, memo)
 					}
 
 					sourceKeyExtractData[sourceKey] = extractData
@@ -666,6 +807,7 @@ func (bs *ETPBlockScanner) extractTransaction(trx *Transaction, result *ExtractR
 						}
 						wxID := openwallet.GenTransactionWxID(extractData.Transaction)
 						extractData.Transaction.WxID = wxID
+						setTransactionMemo(extractData.Transaction, memo)
 					}
 
 					sourceKeyExtractData[sourceKey] = extractData
@@ -673,6 +815,13 @@ func (bs *ETPBlockScanner) extractTransaction(trx *Transaction, result *ExtractR
 				}
 			}
 
+			//交易池中的交易标记为未确认
+			for _, extractData := range result.extractData {
+				for _, data := range extractData {
+					setTransactionState(data.Transaction, trx.BlockHeight)
+				}
+			}
+
 		}
 	}
 	result.Success = success
@@ -707,9 +856,42 @@ func (bs *ETPBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 		if targetResult.Exist {
 
 			//填充主币
-			if output.IsToken {
+			if output.AssetMIT != nil {
+
+				coin := bs.wm.mitCoin(output.AssetMIT.Symbol)
+
+				input := openwallet.TxInput{}
+				input.SourceTxID = txid
+				input.SourceIndex = vout
+				input.TxID = result.TxID
+				input.Address = addr
+				input.Amount = "1"
+				input.Coin = coin
+				input.Index = output.N
+				input.Sid = openwallet.GenTxInputSID(txid, bs.wm.Symbol(), coin.ContractID, uint64(i))
+				input.CreateAt = createAt
+				//在哪个区块高度时消费
+				input.BlockHeight = trx.BlockHeight
+				input.BlockHash = trx.BlockHash
+
+				sourceKeyExtractInput := tokenExtractInput[coin.Contract.Address]
+				if sourceKeyExtractInput == nil {
+					sourceKeyExtractInput = make(ExtractInput)
+				}
+
+				extractInput := sourceKeyExtractInput[targetResult.SourceKey]
+				if extractInput == nil {
+					extractInput = make([]*openwallet.TxInput, 0)
+				}
+
+				extractInput = append(extractInput, &input)
+
+				sourceKeyExtractInput[targetResult.SourceKey] = extractInput
+				tokenExtractInput[coin.Contract.Address] = sourceKeyExtractInput
+
+			} else if output.IsToken {
 
-				contractId := openwallet.GenContractID(bs.wm.Symbol(), output.AssetAttachment.Symbol)
+				contractId := openwallet.GenContractID(bs.wm.Symbol(), output.Asset.Symbol)
 
 				input := openwallet.TxInput{}
 				input.SourceTxID = txid
@@ -717,14 +899,14 @@ func (bs *ETPBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 				input.TxID = result.TxID
 				input.Address = addr
 				//transaction.AccountID = a.AccountID
-				input.Amount = output.AssetAttachment.Quantity
+				input.Amount = output.Asset.Quantity
 				input.Coin = openwallet.Coin{
 					Symbol:     bs.wm.Symbol(),
 					IsContract: true,
 					ContractID: contractId,
 					Contract: openwallet.SmartContract{
 						ContractID: contractId,
-						Address:    output.AssetAttachment.Symbol,
+						Address:    output.Asset.Symbol,
 						Symbol:     bs.wm.Symbol(),
 					},
 				}
@@ -735,7 +917,7 @@ func (bs *ETPBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 				input.BlockHeight = trx.BlockHeight
 				input.BlockHash = trx.BlockHash
 
-				sourceKeyExtractInput := tokenExtractInput[output.AssetAttachment.Symbol]
+				sourceKeyExtractInput := tokenExtractInput[output.Asset.Symbol]
 				if sourceKeyExtractInput == nil {
 					sourceKeyExtractInput = make(ExtractInput)
 				}
@@ -748,7 +930,7 @@ func (bs *ETPBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 				extractInput = append(extractInput, &input)
 
 				sourceKeyExtractInput[targetResult.SourceKey] = extractInput
-				tokenExtractInput[output.AssetAttachment.Symbol] = sourceKeyExtractInput
+				tokenExtractInput[output.Asset.Symbol] = sourceKeyExtractInput
 
 			} else {
 
@@ -789,13 +971,16 @@ func (bs *ETPBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 			}
 		}
 
-		if output.IsToken {
-			af := from[output.AssetAttachment.Symbol]
+		if output.AssetMIT != nil {
+			key := mitAddressPrefix + output.AssetMIT.Symbol
+			from[key] = append(from[key], addr+":1")
+		} else if output.IsToken {
+			af := from[output.Asset.Symbol]
 			if af == nil {
 				af = make([]string, 0)
 			}
-			af = append(af, addr+":"+output.AssetAttachment.Quantity)
-			from[output.AssetAttachment.Symbol] = af
+			af = append(af, addr+":"+output.Asset.Quantity)
+			from[output.Asset.Symbol] = af
 		} else {
 			af := from[bs.wm.Symbol()]
 			if af == nil {
@@ -811,6 +996,17 @@ func (bs *ETPBlockScanner) extractTxInput(trx *Transaction, result *ExtractResul
 	return tokenExtractInput, from, totalAmount
 }
 
+//setOutputScript 非P2PKH输出在ExtParam中记录锁定脚本类型，锁仓输出同时记录锁定的区块数
+func setOutputScript(outPut *openwallet.TxOutPut, script *Script) {
+	if script == nil || script.Type == ScriptPubKeyHash {
+		return
+	}
+	outPut.SetExtParam("scriptType", string(script.Type))
+	if script.Type == ScriptLockHeight {
+		outPut.SetExtParam("lockHeight", script.LockHeight)
+	}
+}
+
 //ExtractTxInput 提取交易单输入部分
 func (bs *ETPBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResult, scanTargetFunc openwallet.BlockScanTargetFuncV2) (map[string]ExtractOutput, map[string][]string, decimal.Decimal) {
 
@@ -827,6 +1023,12 @@ func (bs *ETPBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 	createAt := time.Now().Unix()
 	for _, output := range vout {
 
+		//消息附件的输出没有金额，内容记录为交易单的备注
+		if output.Message != nil && output.Value == "0" {
+			continue
+		}
+
+		asset := output.Asset()
 		amount, _ := decimal.NewFromString(output.Value)
 		amount = amount.Shift(-bs.wm.Decimal())
 		n := output.N
@@ -837,32 +1039,70 @@ func (bs *ETPBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 			ScanTargetType: openwallet.ScanTargetTypeAccountAddress})
 		if targetResult.Exist {
 
-			if output.IsToken {
+			if output.AssetMIT != nil {
+
+				coin := bs.wm.mitCoin(output.AssetMIT.Symbol)
+
+				outPut := openwallet.TxOutPut{}
+				outPut.TxID = txid
+				outPut.Address = addr
+				outPut.Amount = "1"
+				outPut.Coin = coin
+				outPut.Index = n
+				outPut.Sid = openwallet.GenTxOutPutSID(txid, bs.wm.Symbol(), coin.ContractID, n)
+				outPut.SetExtParam("mitStatus", output.AssetMIT.Status)
+				if len(output.AssetMIT.Content) > 0 {
+					outPut.SetExtParam("mitContent", output.AssetMIT.Content)
+				}
+				setOutputScript(&outPut, output.Script)
+				setOutputLock(&outPut, output, trx.BlockHeight)
+				outPut.CreateAt = createAt
+				outPut.BlockHeight = trx.BlockHeight
+				outPut.BlockHash = trx.BlockHash
+
+				sourceKeyExtractOutput := tokenExtractOutput[coin.Contract.Address]
+				if sourceKeyExtractOutput == nil {
+					sourceKeyExtractOutput = make(ExtractOutput)
+				}
+
+				extractOutput := sourceKeyExtractOutput[targetResult.SourceKey]
+				if extractOutput == nil {
+					extractOutput = make([]*openwallet.TxOutPut, 0)
+				}
+
+				extractOutput = append(extractOutput, &outPut)
+
+				sourceKeyExtractOutput[targetResult.SourceKey] = extractOutput
+				tokenExtractOutput[coin.Contract.Address] = sourceKeyExtractOutput
+
+			} else if output.IsToken {
 
-				contractId := openwallet.GenContractID(bs.wm.Symbol(), output.AssetAttachment.Symbol)
+				contractId := openwallet.GenContractID(bs.wm.Symbol(), asset.Symbol)
 
 				outPut := openwallet.TxOutPut{}
 				outPut.TxID = txid
 				outPut.Address = addr
-				outPut.Amount = output.AssetAttachment.Quantity
+				outPut.Amount = asset.Quantity
 				outPut.Coin = openwallet.Coin{
 					Symbol:     bs.wm.Symbol(),
 					IsContract: true,
 					ContractID: contractId,
 					Contract: openwallet.SmartContract{
 						ContractID: contractId,
-						Address:    output.AssetAttachment.Symbol,
+						Address:    asset.Symbol,
 						Symbol:     bs.wm.Symbol(),
 					},
 				}
 				outPut.Index = n
 				outPut.Sid = openwallet.GenTxOutPutSID(txid, bs.wm.Symbol(), contractId, n)
+				setOutputScript(&outPut, output.Script)
+				setOutputLock(&outPut, output, trx.BlockHeight)
 				outPut.CreateAt = createAt
 				//在哪个区块高度时消费
 				outPut.BlockHeight = trx.BlockHeight
 				outPut.BlockHash = trx.BlockHash
 
-				sourceKeyExtractOutput := tokenExtractOutput[output.AssetAttachment.Symbol]
+				sourceKeyExtractOutput := tokenExtractOutput[asset.Symbol]
 				if sourceKeyExtractOutput == nil {
 					sourceKeyExtractOutput = make(ExtractOutput)
 				}
@@ -875,7 +1115,7 @@ func (bs *ETPBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 				extractOutput = append(extractOutput, &outPut)
 
 				sourceKeyExtractOutput[targetResult.SourceKey] = extractOutput
-				tokenExtractOutput[output.AssetAttachment.Symbol] = sourceKeyExtractOutput
+				tokenExtractOutput[asset.Symbol] = sourceKeyExtractOutput
 
 			} else {
 
@@ -890,6 +1130,8 @@ func (bs *ETPBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 				}
 				outPut.Index = n
 				outPut.Sid = openwallet.GenTxOutPutSID(txid, bs.wm.Symbol(), "", n)
+				setOutputScript(&outPut, output.Script)
+				setOutputLock(&outPut, output, trx.BlockHeight)
 				outPut.CreateAt = createAt
 				outPut.BlockHeight = trx.BlockHeight
 				outPut.BlockHash = trx.BlockHash
@@ -913,13 +1155,16 @@ func (bs *ETPBlockScanner) extractTxOutput(trx *Transaction, result *ExtractResu
 
 		}
 
-		if output.IsToken {
-			af := to[output.AssetAttachment.Symbol]
+		if output.AssetMIT != nil {
+			key := mitAddressPrefix + output.AssetMIT.Symbol
+			to[key] = append(to[key], addr+":1")
+		} else if output.IsToken {
+			af := to[asset.Symbol]
 			if af == nil {
 				af = make([]string, 0)
 			}
-			af = append(af, addr+":"+output.AssetAttachment.Quantity)
-			to[output.AssetAttachment.Symbol] = af
+			af = append(af, addr+":"+asset.Quantity)
+			to[asset.Symbol] = af
 		} else {
 			af := to[bs.wm.Symbol()]
 			if af == nil {
@@ -965,7 +1210,7 @@ func (bs *ETPBlockScanner) newExtractDataNotify(height uint64, tokenExtractData
 //GetCurrentBlockHeader 获取当前区块高度
 func (bs *ETPBlockScanner) GetCurrentBlockHeader() (*openwallet.BlockHeader, error) {
 
-	header, err := bs.wm.GetBlockHeader()
+	header, err := bs.wm.GetBlockHeader(context.Background())
 	if err != nil {
 		return nil, err
 	}
@@ -974,7 +1219,7 @@ func (bs *ETPBlockScanner) GetCurrentBlockHeader() (*openwallet.BlockHeader, err
 }
 
 func (bs *ETPBlockScanner) GetGlobalMaxBlockHeight() uint64 {
-	header, err := bs.wm.GetBlockHeader()
+	header, err := bs.wm.GetBlockHeader(context.Background())
 	if err != nil {
 		bs.wm.Log.Std.Info("get global max block height error;unexpected error:%v", err)
 		return 0
@@ -990,12 +1235,12 @@ func (bs *ETPBlockScanner) GetScannedBlockHeight() uint64 {
 
 func (bs *ETPBlockScanner) ExtractTransactionData(txid string, scanTargetFunc openwallet.BlockScanTargetFunc) (map[string][]*openwallet.TxExtractData, error) {
 
-	trx, err := bs.wm.GetTransaction(txid)
+	trx, err := bs.wm.GetTransaction(context.Background(), txid)
 	if err != nil {
 		return nil, err
 	}
 
-	header, err := bs.wm.GetBlockHeader(trx.BlockHeight)
+	header, err := bs.wm.GetBlockHeader(context.Background(), trx.BlockHeight)
 	if err != nil {
 		return nil, err
 	}
@@ -1042,26 +1287,60 @@ func (bs *ETPBlockScanner) ExtractTransactionData(txid string, scanTargetFunc op
 //	}
 //}
 
-//GetAssetsAccountBalanceByAddress 查询账户相关地址的交易记录
+//GetAssetsAccountBalanceByAddress 查询账户相关地址的交易记录，余额不包括锁仓存款冻结的数量
 func (bs *ETPBlockScanner) GetBalanceByAddress(address ...string) ([]*openwallet.Balance, error) {
 
-	addrBalanceArr := make([]*openwallet.Balance, 0)
+	balances, err := bs.GetAddressBalances(address...)
+	if err != nil {
+		return nil, err
+	}
+
+	addrBalanceArr := make([]*openwallet.Balance, 0, len(balances))
+	for _, balance := range balances {
+		obj := balance.Balance
+		addrBalanceArr = append(addrBalanceArr, &obj)
+	}
+
+	return addrBalanceArr, nil
+
+}
+
+//AddressBalance 地址的ETP余额，Balance为可用余额，Frozen为锁仓存款冻结的数量
+type AddressBalance struct {
+	openwallet.Balance
+	Frozen string
+}
+
+//GetAddressBalances 查询地址的可用余额和冻结数量
+func (bs *ETPBlockScanner) GetAddressBalances(address ...string) ([]*AddressBalance, error) {
+
+	etpBalances, err := bs.wm.GetAddressETPs(context.Background(), address...)
+	if err != nil {
+		return nil, err
+	}
+
+	addrBalanceArr := make([]*AddressBalance, 0)
 	for _, addr := range address {
 
-		obj := &openwallet.Balance{
-			Symbol:           bs.wm.Symbol(),
-			Address:          addr,
-			Balance:          "0",
-			UnconfirmBalance: "0",
-			ConfirmBalance:   "0",
+		obj := &AddressBalance{
+			Balance: openwallet.Balance{
+				Symbol:           bs.wm.Symbol(),
+				Address:          addr,
+				Balance:          "0",
+				UnconfirmBalance: "0",
+				ConfirmBalance:   "0",
+			},
+			Frozen: "0",
 		}
 
-		etpBalance, err := bs.wm.GetAddressETP(addr)
-		if err == nil {
-			available, _ := decimal.NewFromString(etpBalance.Confirmed)
-			available = available.Shift(-bs.wm.Decimal())
-			obj.Balance = available.String()
+		etpBalance, ok := etpBalances[addr]
+		if ok {
+			confirmed, _ := decimal.NewFromString(etpBalance.Confirmed)
+			frozen, _ := decimal.NewFromString(etpBalance.Frozen)
+			available := confirmed.Sub(frozen).Shift(-bs.wm.Decimal())
+			obj.Balance.Balance = available.String()
 			obj.ConfirmBalance = available.String()
+			obj.Frozen = frozen.Shift(-bs.wm.Decimal()).String()
 		}
 
 		addrBalanceArr = append(addrBalanceArr, obj)
@@ -16,7 +16,13 @@
 package metaverse
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/blocktree/openwallet/v2/openwallet"
 )
 
@@ -119,3 +125,56 @@ func (bs *ETPBlockScanner) GetUnscanRecords() ([]*openwallet.UnscanRecord, error
 
 	return bs.BlockchainDAI.GetUnscanRecords(bs.wm.Symbol())
 }
+
+//saveScannerStates 保存有变化的提取记录和等待确认的交易
+func (bs *ETPBlockScanner) saveScannerStates() {
+	bs.saveExtractRecords()
+	bs.saveConfirmWaits()
+}
+
+//scannerStatePath 扫描器状态文件的路径，与本地数据库放在同一目录
+func (bs *ETPBlockScanner) scannerStatePath(name string) string {
+	return filepath.Join(bs.wm.Config.DBPath, strings.ToLower(bs.wm.Symbol())+"_"+name+".json")
+}
+
+//saveScannerState 保存扫描器的内存状态，重启后用loadScannerState恢复。
+//先写临时文件再替换，写入中断时保留上次的状态
+func (bs *ETPBlockScanner) saveScannerState(name string, state interface{}) error {
+
+	if len(bs.wm.Config.DBPath) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	path := bs.scannerStatePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+//loadScannerState 读取保存的扫描器状态，没有保存过时不修改state
+func (bs *ETPBlockScanner) loadScannerState(name string, state interface{}) error {
+
+	if len(bs.wm.Config.DBPath) == 0 {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(bs.scannerStatePath(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, state)
+}
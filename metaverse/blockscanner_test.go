@@ -16,11 +16,16 @@
 package metaverse
 
 import (
-	"github.com/blocktree/openwallet/v2/log"
+	"context"
+	"sync"
 	"testing"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/openwallet"
 )
 
 func TestETPBlockScanner_GetCurrentBlockHeader(t *testing.T) {
+	testRequireNode(t)
 	scanner := tw.GetBlockScanner()
 	header, err := scanner.GetCurrentBlockHeader()
 	if err != nil {
@@ -29,3 +34,78 @@ func TestETPBlockScanner_GetCurrentBlockHeader(t *testing.T) {
 	}
 	log.Infof("GetBlockHeader = %+v", header)
 }
+
+//txOrderObserver 按通知顺序记录交易单
+type txOrderObserver struct {
+	mu    sync.Mutex
+	txids []string
+}
+
+func (o *txOrderObserver) BlockScanNotify(header *openwallet.BlockHeader) error {
+	return nil
+}
+
+func (o *txOrderObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.txids = append(o.txids, data.Transaction.TxID)
+	return nil
+}
+
+func (o *txOrderObserver) BlockExtractSmartContractDataNotify(sourceKey string, data *openwallet.SmartContractReceipt) error {
+	return nil
+}
+
+func TestSimNode_BatchExtractTransaction(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+
+	bs, _ := newSimBlockScanner(wm, wallet)
+	bs.extractingSize = 3
+	order := &txOrderObserver{}
+	bs.AddObserver(order)
+
+	//空区块视为成功
+	results, err := bs.BatchExtractTransaction(1, "", nil)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("BatchExtractTransaction empty block = %v, %v", results, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		node.Fund(wallet.addresses[i%2].Address, uint64(i+1)*100000000)
+	}
+	node.Mine(1)
+	block, blockErr := wm.GetBlockByHeight(context.Background(), 1)
+	if blockErr != nil {
+		t.Fatalf("GetBlockByHeight unexpected error: %v", blockErr)
+	}
+
+	results, err = bs.BatchExtractTransaction(block.Height, block.Hash, block.transactions)
+	if err != nil || len(results) != len(block.transactions) {
+		t.Fatalf("BatchExtractTransaction = %d results, %v, want %d", len(results), err, len(block.transactions))
+	}
+
+	//结果和通知与区块中的交易顺序相同
+	notified := make([]string, 0)
+	for i, result := range results {
+		if !result.Success || result.TxID != block.transactions[i].TxID || result.BlockHeight != 1 {
+			t.Fatalf("result %d = %+v, want txid %s", i, result, block.transactions[i].TxID)
+		}
+		if len(result.extractData) > 0 {
+			notified = append(notified, result.TxID)
+		}
+	}
+	if len(notified) != 10 {
+		t.Fatalf("extracted %d transactions, want 10", len(notified))
+	}
+	order.mu.Lock()
+	defer order.mu.Unlock()
+	if len(order.txids) != len(notified) {
+		t.Fatalf("notified %v, want %v", order.txids, notified)
+	}
+	for i := range notified {
+		if order.txids[i] != notified[i] {
+			t.Fatalf("notified %v, want %v", order.txids, notified)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	//DefaultCallTimeout 默认单次请求超时
+	DefaultCallTimeout = 30 * time.Second
+	//DefaultCallMaxRetries 默认连接失败后的重试次数
+	DefaultCallMaxRetries = 3
+	//DefaultCallBackoff 默认第一次重试前的等待时间，之后每次翻倍
+	DefaultCallBackoff = 500 * time.Millisecond
+	//DefaultCallMaxBackoff 默认重试等待时间上限
+	DefaultCallMaxBackoff = 8 * time.Second
+)
+
+//CallPolicy RPC方法的超时和重试策略。
+//只有连接失败、超时和节点返回不带json-rpc错误的5xx会重试，节点返回的错误（如交易无效）不重试。
+type CallPolicy struct {
+	Timeout    time.Duration //单次请求超时，0不限制
+	MaxRetries int           //最多重试次数
+	Backoff    time.Duration //第一次重试前的等待时间，之后每次翻倍
+	MaxBackoff time.Duration //重试等待时间上限
+}
+
+//NewCallPolicy 默认的调用策略
+func NewCallPolicy() *CallPolicy {
+	return &CallPolicy{
+		Timeout:    DefaultCallTimeout,
+		MaxRetries: DefaultCallMaxRetries,
+		Backoff:    DefaultCallBackoff,
+		MaxBackoff: DefaultCallMaxBackoff,
+	}
+}
+
+//defaultCallPolicies 各方法默认的调用策略。
+//sendrawtx默认不重试，连接中断时交易可能已经广播，重发会收到重复交易的错误。
+//getinfo只用于启动检查，失败时不阻塞，也不重试。
+func defaultCallPolicies() map[string]*CallPolicy {
+	sendPolicy := NewCallPolicy()
+	sendPolicy.MaxRetries = 0
+	infoPolicy := NewCallPolicy()
+	infoPolicy.MaxRetries = 0
+	return map[string]*CallPolicy{
+		"sendrawtx": sendPolicy,
+		"getinfo":   infoPolicy,
+	}
+}
+
+//rpcMethods 适配器使用的RPC方法
+var rpcMethods = []string{
+	"getinfo", "getblockheader", "getblock", "gettx", "getaddressetp",
+	"getaddressasset", "createrawtx", "decoderawtx", "sendrawtx",
+}
+
+//loadCallPolicy 从配置读取调用策略，key加上".方法名"为该方法的配置，例如rpcTimeout.sendrawtx
+//rpcTimeout: 单次请求超时秒数
+//rpcMaxRetries: 最多重试次数
+//rpcRetryBackoff: 第一次重试前等待的毫秒数
+//rpcMaxBackoff: 重试等待毫秒数上限
+func loadCallPolicy(r *configReader, suffix string, base *CallPolicy) *CallPolicy {
+	p := *base
+	p.Timeout = time.Duration(r.Int64("rpcTimeout"+suffix, int64(base.Timeout/time.Second), 0, 3600)) * time.Second
+	p.MaxRetries = int(r.Int64("rpcMaxRetries"+suffix, int64(base.MaxRetries), 0, 100))
+	p.Backoff = time.Duration(r.Int64("rpcRetryBackoff"+suffix, int64(base.Backoff/time.Millisecond), 0, 600000)) * time.Millisecond
+	p.MaxBackoff = time.Duration(r.Int64("rpcMaxBackoff"+suffix, int64(base.MaxBackoff/time.Millisecond), 0, 3600000)) * time.Millisecond
+	return &p
+}
+
+//loadCallPolicies 读取默认和各方法的调用策略，方法没有单独配置的项继承默认策略
+func loadCallPolicies(r *configReader) (*CallPolicy, map[string]*CallPolicy) {
+	defaults := defaultCallPolicies()
+	policy := loadCallPolicy(r, "", NewCallPolicy())
+	policies := make(map[string]*CallPolicy)
+	for _, method := range rpcMethods {
+		base := policy
+		if p, ok := defaults[method]; ok {
+			//内置的方法策略只覆盖重试次数，其他项继承默认配置
+			merged := *policy
+			merged.MaxRetries = p.MaxRetries
+			base = &merged
+		}
+		policies[method] = loadCallPolicy(r, "."+method, base)
+	}
+	return policy, policies
+}
+
+//backoff 第attempt次重试前的等待时间
+func (p *CallPolicy) backoff(attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 0; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	return wait
+}
+
+//withTimeout 单次请求的ctx
+func (p *CallPolicy) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.Timeout)
+}
+
+//sleepContext 等待d，ctx取消时提前返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/config"
+)
+
+//testFlakyNode 在节点前面返回指定次数的502，之后转发到节点
+type testFlakyNode struct {
+	mu       sync.Mutex
+	failures int
+	delay    time.Duration
+	requests int
+	next     http.Handler
+}
+
+func (f *testFlakyNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	fail := f.failures > 0
+	if fail {
+		f.failures--
+	}
+	delay := f.delay
+	f.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fail {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+	f.next.ServeHTTP(w, r)
+}
+
+func (f *testFlakyNode) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func newTestFlakyClient(t *testing.T, failures int, delay time.Duration) (*Client, *testFlakyNode) {
+	node, _ := testStartSimNode(t)
+	node.Mine(2)
+	flaky := &testFlakyNode{failures: failures, delay: delay, next: node}
+	server := httptest.NewServer(flaky)
+	t.Cleanup(server.Close)
+	client := NewClient(server.URL, false)
+	client.Policy = &CallPolicy{Timeout: time.Second, MaxRetries: 3, Backoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+	return client, flaky
+}
+
+func TestCallPolicy_Backoff(t *testing.T) {
+	p := &CallPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := p.backoff(i); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestClient_Call_RetryTransient(t *testing.T) {
+	client, flaky := newTestFlakyClient(t, 2, 0)
+
+	result, err := client.Call(context.Background(), "getblockheader", nil)
+	if err != nil {
+		t.Fatalf("Call unexpected error: %v", err)
+	}
+	if result.Get("number").Uint() != 2 || flaky.count() != 3 {
+		t.Fatalf("Call should succeed on the third attempt, requests = %d", flaky.count())
+	}
+
+	//超过重试次数
+	flaky.failures = 10
+	if _, err := client.Call(context.Background(), "getblockheader", nil); err == nil {
+		t.Fatalf("Call should fail after retries are exhausted")
+	}
+	if flaky.count() != 3+4 {
+		t.Fatalf("requests = %d, want %d", flaky.count(), 3+4)
+	}
+}
+
+func TestClient_Call_NoRetryRPCError(t *testing.T) {
+	client, flaky := newTestFlakyClient(t, 0, 0)
+
+	_, err := client.Call(context.Background(), "gettx", []interface{}{"0000000000000000000000000000000000000000000000000000000000000001"})
+	if err == nil || err.Code() != ErrTxNotFound {
+		t.Fatalf("gettx unknown tx should fail with ErrTxNotFound: %v", err)
+	}
+	if _, err := client.Call(context.Background(), "sendrawtx", []interface{}{"00"}); err == nil {
+		t.Fatalf("sendrawtx invalid tx should fail")
+	}
+	if flaky.count() != 2 {
+		t.Fatalf("node errors should not be retried, requests = %d", flaky.count())
+	}
+}
+
+func TestClient_Call_PerMethodPolicy(t *testing.T) {
+	client, flaky := newTestFlakyClient(t, 1, 0)
+	client.SetPolicy("getblock", &CallPolicy{Timeout: time.Second, MaxRetries: 0})
+
+	if _, err := client.Call(context.Background(), "getblock", []interface{}{1}); err == nil {
+		t.Fatalf("getblock without retries should fail")
+	}
+	if _, err := client.Call(context.Background(), "getblock", []interface{}{1}); err != nil {
+		t.Fatalf("getblock unexpected error: %v", err)
+	}
+	if flaky.count() != 2 {
+		t.Fatalf("requests = %d, want 2", flaky.count())
+	}
+
+	//sendrawtx默认不重试
+	if NewClient("", false).policy("sendrawtx").MaxRetries != 0 {
+		t.Fatalf("sendrawtx should not be retried by default")
+	}
+}
+
+func TestClient_Call_Timeout(t *testing.T) {
+	client, flaky := newTestFlakyClient(t, 0, 300*time.Millisecond)
+	client.Policy.Timeout = 50 * time.Millisecond
+	client.Policy.MaxRetries = 1
+
+	start := time.Now()
+	if _, err := client.Call(context.Background(), "getblockheader", nil); err == nil {
+		t.Fatalf("Call should time out")
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("Call took %v, timeout not applied", elapsed)
+	}
+	if flaky.count() != 2 {
+		t.Fatalf("timeouts should be retried, requests = %d", flaky.count())
+	}
+}
+
+func TestClient_Call_Cancel(t *testing.T) {
+	client, flaky := newTestFlakyClient(t, 0, 300*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	if _, err := client.Call(ctx, "getblockheader", nil); err == nil {
+		t.Fatalf("Call should be canceled")
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("Call took %v, cancel not applied", elapsed)
+	}
+	if flaky.count() != 1 {
+		t.Fatalf("canceled call should not be retried, requests = %d", flaky.count())
+	}
+}
+
+func TestLoadCallPolicies(t *testing.T) {
+	c, err := config.NewConfigData("ini", []byte(`
+rpcTimeout = 10
+rpcMaxRetries = 5
+rpcRetryBackoff = 100
+rpcTimeout.getblock = 60
+rpcMaxRetries.sendrawtx = 1
+`))
+	if err != nil {
+		t.Fatalf("NewConfigData unexpected error: %v", err)
+	}
+
+	r := newConfigReader(c)
+	policy, policies := loadCallPolicies(r)
+	if err := r.Err(); err != nil {
+		t.Fatalf("loadCallPolicies unexpected error: %v", err)
+	}
+	if policy.Timeout != 10*time.Second || policy.MaxRetries != 5 || policy.Backoff != 100*time.Millisecond || policy.MaxBackoff != DefaultCallMaxBackoff {
+		t.Fatalf("unexpected default policy: %+v", policy)
+	}
+	if p := policies["getblock"]; p.Timeout != time.Minute || p.MaxRetries != 5 {
+		t.Fatalf("unexpected getblock policy: %+v", p)
+	}
+	if p := policies["sendrawtx"]; p.Timeout != 10*time.Second || p.MaxRetries != 1 {
+		t.Fatalf("unexpected sendrawtx policy: %+v", p)
+	}
+	if p := policies["gettx"]; *p != *policy {
+		t.Fatalf("unexpected gettx policy: %+v", p)
+	}
+}
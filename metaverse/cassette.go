@@ -0,0 +1,154 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+//CassetteMode 客户端录制回放模式
+type CassetteMode int
+
+const (
+	CassetteOff    CassetteMode = iota //直接请求节点
+	CassetteRecord                     //请求节点并录制响应
+	CassetteReplay                     //从录制文件回放响应，不连接节点
+)
+
+//CassetteInteraction 一次RPC请求和节点的原始响应
+type CassetteInteraction struct {
+	Method   string          `json:"method"`
+	Params   json.RawMessage `json:"params"`
+	Response json.RawMessage `json:"response"`
+}
+
+//Cassette RPC录制文件
+type Cassette struct {
+	Path         string                 `json:"-"`
+	Interactions []*CassetteInteraction `json:"interactions"`
+
+	mu     sync.Mutex
+	played map[string]int //每个请求已回放的次数
+}
+
+//NewCassette 创建空的录制文件，录制时写入path
+func NewCassette(path string) *Cassette {
+	return &Cassette{
+		Path:         path,
+		Interactions: make([]*CassetteInteraction, 0),
+		played:       make(map[string]int),
+	}
+}
+
+//LoadCassette 加载录制文件
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := NewCassette(path)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("cassette %s is invalid: %v", path, err)
+	}
+	return c, nil
+}
+
+//Record 记录一次请求和响应，并保存到文件
+func (c *Cassette) Record(method string, params []interface{}, response []byte) error {
+	if !json.Valid(response) {
+		return fmt.Errorf("response of %s is not valid json", method)
+	}
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Interactions = append(c.Interactions, &CassetteInteraction{
+		Method:   method,
+		Params:   rawParams,
+		Response: append(json.RawMessage{}, response...),
+	})
+	return c.save()
+}
+
+//Replay 查找录制的响应。
+//相同的请求录制了多次时，按录制顺序依次返回，用完后一直返回最后一次的响应。
+func (c *Cassette) Replay(method string, params []interface{}) ([]byte, error) {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	key := cassetteKey(method, rawParams)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	matches := make([]*CassetteInteraction, 0)
+	for _, it := range c.Interactions {
+		if cassetteKey(it.Method, it.Params) == key {
+			matches = append(matches, it)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("cassette has no recorded response for %s %s", method, rawParams)
+	}
+
+	i := c.played[key]
+	if i >= len(matches) {
+		i = len(matches) - 1
+	}
+	c.played[key] = i + 1
+	return matches[i].Response, nil
+}
+
+//Save 保存录制文件
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+func (c *Cassette) save() error {
+	if len(c.Path) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Path, data, 0644)
+}
+
+//cassetteKey 请求的匹配键，参数压缩为紧凑的json
+func cassetteKey(method string, params []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, params); err != nil {
+		return method + " " + string(params)
+	}
+	return method + " " + buf.String()
+}
@@ -0,0 +1,385 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blocktree/metaverse-adapter/metaverse_simnode"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+// 重新录制testdata中的cassette和golden文件：go test ./metaverse -run Golden -record
+var recordCassette = flag.Bool("record", false, "re-record cassettes and golden files in testdata")
+
+const (
+	simnodeCassette = "testdata/simnode_cassette.json"
+	simnodeGolden   = "testdata/simnode_extract.golden.json"
+	mainnetCassette = "testdata/mainnet_cassette.json"
+	mainnetGolden   = "testdata/mainnet_extract.golden.json"
+
+	mainnetBlockHeight = uint64(3584831)
+	mainnetTxID        = "2b8e090dc8a12df7bd44a23bc797a63efb727f560f86ddf7d5de80336a115b20"
+)
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	node := metaverse_simnode.NewNode(false)
+	defer node.Close()
+	node.Mine(2)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := NewRecordClient(node.Start(), path, false)
+	first, err := recorder.Call(context.Background(), "getblockheader", nil)
+	if err != nil {
+		t.Fatalf("Call unexpected error: %v", err)
+	}
+	node.Mine(1)
+	second, _ := recorder.Call(context.Background(), "getblockheader", nil)
+	block, _ := recorder.Call(context.Background(), "getblock", []interface{}{1})
+	if _, err := recorder.Call(context.Background(), "getblock", []interface{}{100}); err == nil {
+		t.Fatalf("getblock out of range should fail")
+	}
+
+	player, loadErr := NewReplayClient(path, false)
+	if loadErr != nil {
+		t.Fatalf("NewReplayClient unexpected error: %v", loadErr)
+	}
+	node.Close()
+
+	//相同请求按录制顺序回放
+	if r, _ := player.Call(context.Background(), "getblockheader", nil); !testJSONEqual(r.Raw, first.Raw) {
+		t.Fatalf("first replay = %s, want %s", r.Raw, first.Raw)
+	}
+	if r, _ := player.Call(context.Background(), "getblockheader", []interface{}{}); !testJSONEqual(r.Raw, second.Raw) {
+		t.Fatalf("second replay = %s, want %s", r.Raw, second.Raw)
+	}
+	if r, _ := player.Call(context.Background(), "getblockheader", nil); !testJSONEqual(r.Raw, second.Raw) {
+		t.Fatalf("exhausted replay = %s, want %s", r.Raw, second.Raw)
+	}
+	if r, _ := player.Call(context.Background(), "getblock", []interface{}{1}); !testJSONEqual(r.Raw, block.Raw) {
+		t.Fatalf("getblock replay = %s, want %s", r.Raw, block.Raw)
+	}
+
+	//节点错误也会回放
+	if _, err := player.Call(context.Background(), "getblock", []interface{}{100}); err == nil || err.Code() != ErrBlockNotFound {
+		t.Fatalf("replay node error = %v", err)
+	}
+	if _, err := player.Call(context.Background(), "gettx", []interface{}{"unknown"}); err == nil {
+		t.Fatalf("replay unrecorded request should fail")
+	}
+}
+
+//testJSONEqual 忽略空白比较json，录制文件会重新缩进响应
+func testJSONEqual(a, b string) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, []byte(a)) != nil || json.Compact(&cb, []byte(b)) != nil {
+		return false
+	}
+	return ca.String() == cb.String()
+}
+
+func TestGolden_Simnode(t *testing.T) {
+	if *recordCassette {
+		recordSimnodeCassette(t)
+	}
+
+	wm := newReplayWalletManager(t, simnodeCassette)
+	wallet := newSimWallet(t, wm, 2)
+	scanTarget := func(target openwallet.ScanTargetParam) openwallet.ScanTargetResult {
+		if a, err := wallet.GetAddress(target.ScanTarget); err == nil {
+			return openwallet.ScanTargetResult{SourceKey: a.AccountID, Exist: true}
+		}
+		return openwallet.ScanTargetResult{}
+	}
+
+	blocks := testGoldenBlocks(t, wm, []uint64{1, 2, 3}, scanTarget)
+	testCompareGolden(t, simnodeGolden, blocks)
+
+	//资产发行交易：发行的数量作为DNA收款提取
+	issueTx := blocks[0].Txs[len(blocks[0].Txs)-1]
+	if issue := issueTx.Extract["DNA"][simAccountID]; issue == nil || len(issue.Outputs) != 1 || issue.Outputs[0] != wallet.addresses[0].Address+":5000000" {
+		t.Fatalf("unexpected asset issue extraction: %+v", issueTx.Extract)
+	}
+
+	//资产转账交易：收款方获得12.5 DNA，找零回到钱包
+	tokenTx := blocks[1].Txs[1]
+	if len(tokenTx.Vouts) != 3 || tokenTx.Vouts[0].Type != "asset-transfer" || tokenTx.Vouts[0].Quantity != "125000" {
+		t.Fatalf("unexpected asset transfer outputs: %+v", tokenTx.Vouts)
+	}
+	if change := tokenTx.Extract["DNA"][simAccountID]; change == nil || len(change.Outputs) != 1 || change.Outputs[0] != wallet.addresses[0].Address+":4875000" {
+		t.Fatalf("unexpected asset change extraction: %+v", change)
+	}
+}
+
+func TestGolden_Mainnet(t *testing.T) {
+	if *recordCassette && tw != nil {
+		recordMainnetCassette(t)
+	}
+	if _, err := os.Stat(mainnetCassette); err != nil {
+		t.Skipf("%s is not recorded, run with -record and conf/ETP.ini to capture it", mainnetCassette)
+	}
+
+	wm := newReplayWalletManager(t, mainnetCassette)
+	scanTarget := func(target openwallet.ScanTargetParam) openwallet.ScanTargetResult {
+		return openwallet.ScanTargetResult{SourceKey: target.ScanTarget, Exist: true}
+	}
+	blocks := testGoldenBlocks(t, wm, []uint64{mainnetBlockHeight}, scanTarget)
+
+	tx, err := wm.GetTransaction(context.Background(), mainnetTxID)
+	if err != nil {
+		t.Fatalf("GetTransaction unexpected error: %v", err)
+	}
+	decoded, err := wm.DecodeRawTx(context.Background(), testAssetTransferRawHex)
+	if err != nil {
+		t.Fatalf("DecodeRawTx unexpected error: %v", err)
+	}
+	blocks = append(blocks, &goldenBlock{Txs: []*goldenTx{newGoldenTx(wm, tx, nil), newGoldenTx(wm, decoded, nil)}})
+	testCompareGolden(t, mainnetGolden, blocks)
+}
+
+//recordSimnodeCassette 在模拟节点上构造ETP和资产转账，录制扫描需要的请求
+func recordSimnodeCassette(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+	from := wallet.addresses[0].Address
+	to := wallet.addresses[1].Address
+	external := "MUsTC2PCF52yNvAeGNXJUKy9CfLVHV9yYj"
+
+	node.Fund(from, 200000000)
+	node.IssueAsset(from, "DNA", 5000000, 4)
+	node.Mine(1)
+
+	contract := openwallet.SmartContract{
+		ContractID: openwallet.GenContractID(wm.Symbol(), "DNA"),
+		Address:    "DNA",
+		Symbol:     wm.Symbol(),
+		Token:      "DNA",
+		Decimals:   4,
+	}
+	testSimTransfer(t, wm, wallet, &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol(), IsContract: true, ContractID: contract.ContractID, Contract: contract},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{external: "12.5"},
+	})
+	node.Mine(1)
+
+	testSimTransfer(t, wm, wallet, &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{to: "0.5", external: "0.3"},
+	})
+	node.Mine(1)
+
+	os.Remove(simnodeCassette)
+	wm.WalletClient.Mode = CassetteRecord
+	wm.WalletClient.Cassette = NewCassette(simnodeCassette)
+	testGoldenBlocks(t, wm, []uint64{1, 2, 3}, func(target openwallet.ScanTargetParam) openwallet.ScanTargetResult {
+		return openwallet.ScanTargetResult{}
+	})
+}
+
+//recordMainnetCassette 从conf/ETP.ini配置的节点录制主网数据
+func recordMainnetCassette(t *testing.T) {
+	wm := NewWalletManager()
+	wm.WalletClient = NewRecordClient(tw.Config.ServerAPI, mainnetCassette, false)
+	os.Remove(mainnetCassette)
+
+	if _, err := wm.GetBlockByHeight(context.Background(), mainnetBlockHeight); err != nil {
+		t.Fatalf("GetBlockByHeight unexpected error: %v", err)
+	}
+	testGoldenBlocks(t, wm, []uint64{mainnetBlockHeight}, func(target openwallet.ScanTargetParam) openwallet.ScanTargetResult {
+		return openwallet.ScanTargetResult{}
+	})
+	if _, err := wm.GetTransaction(context.Background(), mainnetTxID); err != nil {
+		t.Fatalf("GetTransaction unexpected error: %v", err)
+	}
+	if _, err := wm.DecodeRawTx(context.Background(), testAssetTransferRawHex); err != nil {
+		t.Fatalf("DecodeRawTx unexpected error: %v", err)
+	}
+}
+
+func testSimTransfer(t *testing.T, wm *WalletManager, wallet *simWallet, rawTx *openwallet.RawTransaction) {
+	decoder := wm.GetTransactionDecoder()
+	if err := decoder.CreateRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("CreateRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.SignRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("SignRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.VerifyRawTransaction(wallet, rawTx); err != nil || !rawTx.IsCompleted {
+		t.Fatalf("VerifyRawTransaction failed: %v", err)
+	}
+	if _, err := decoder.SubmitRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("SubmitRawTransaction unexpected error: %v", err)
+	}
+}
+
+func newReplayWalletManager(t *testing.T, cassette string) *WalletManager {
+	client, err := NewReplayClient(cassette, false)
+	if err != nil {
+		t.Fatalf("NewReplayClient unexpected error: %v", err)
+	}
+	wm := NewWalletManager()
+	wm.WalletClient = client
+	return wm
+}
+
+//goldenBlock golden文件中的区块
+type goldenBlock struct {
+	Height   uint64      `json:"height"`
+	Hash     string      `json:"hash"`
+	PrevHash string      `json:"prevHash"`
+	Time     uint64      `json:"time"`
+	Txs      []*goldenTx `json:"txs"`
+}
+
+//goldenTx golden文件中的交易单和提取结果
+type goldenTx struct {
+	TxID    string                                  `json:"txid"`
+	Height  uint64                                  `json:"height"`
+	Vins    []*goldenVin                            `json:"vins"`
+	Vouts   []*goldenVout                           `json:"vouts"`
+	Extract map[string]map[string]*goldenExtraction `json:"extract,omitempty"`
+}
+
+type goldenVin struct {
+	TxID     string `json:"txid"`
+	Vout     uint64 `json:"vout"`
+	Addr     string `json:"addr"`
+	Value    string `json:"value"`
+	IsToken  bool   `json:"isToken"`
+	Symbol   string `json:"symbol,omitempty"`
+	Quantity string `json:"quantity,omitempty"`
+}
+
+type goldenVout struct {
+	N        uint64 `json:"n"`
+	Addr     string `json:"addr"`
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+	IsToken  bool   `json:"isToken"`
+	Symbol   string `json:"symbol,omitempty"`
+	Quantity string `json:"quantity,omitempty"`
+	Script   string `json:"script"`
+}
+
+type goldenExtraction struct {
+	Inputs  []string `json:"inputs"`
+	Outputs []string `json:"outputs"`
+	From    []string `json:"from"`
+	To      []string `json:"to"`
+	Fees    string   `json:"fees"`
+}
+
+//testGoldenBlocks 获取区块并提取每笔交易单
+func testGoldenBlocks(t *testing.T, wm *WalletManager, heights []uint64, scanTarget openwallet.BlockScanTargetFuncV2) []*goldenBlock {
+	bs := wm.Blockscanner.(*ETPBlockScanner)
+	blocks := make([]*goldenBlock, 0, len(heights))
+	for _, height := range heights {
+		block, err := wm.GetBlockByHeight(context.Background(), height)
+		if err != nil {
+			t.Fatalf("GetBlockByHeight(%d) unexpected error: %v", height, err)
+		}
+		gb := &goldenBlock{
+			Height:   block.Height,
+			Hash:     block.Hash,
+			PrevHash: block.Previousblockhash,
+			Time:     block.Time,
+		}
+		for _, tx := range block.transactions {
+			result := bs.ExtractTransaction(block.Height, block.Hash, tx, scanTarget)
+			if !result.Success {
+				t.Fatalf("ExtractTransaction %s failed", tx.TxID)
+			}
+			gb.Txs = append(gb.Txs, newGoldenTx(wm, tx, &result))
+		}
+		blocks = append(blocks, gb)
+	}
+	return blocks
+}
+
+func newGoldenTx(wm *WalletManager, tx *Transaction, result *ExtractResult) *goldenTx {
+	gtx := &goldenTx{TxID: tx.TxID, Height: tx.BlockHeight}
+	for _, in := range tx.Vins {
+		vin := &goldenVin{TxID: in.TxID, Vout: in.Vout, Addr: in.Addr, Value: in.Value, IsToken: in.IsToken}
+		if asset := in.Asset; asset != nil {
+			vin.Symbol = asset.Symbol
+			vin.Quantity = asset.Quantity
+		}
+		gtx.Vins = append(gtx.Vins, vin)
+	}
+	for _, out := range tx.Vouts {
+		vout := &goldenVout{N: out.N, Addr: out.Addr, Value: out.Value, Type: out.Type, IsToken: out.IsToken, Script: out.LockScript}
+		if asset := out.Asset(); asset != nil {
+			vout.Symbol = asset.Symbol
+			vout.Quantity = asset.Quantity
+		}
+		gtx.Vouts = append(gtx.Vouts, vout)
+	}
+	if result == nil {
+		return gtx
+	}
+	gtx.Extract = make(map[string]map[string]*goldenExtraction)
+	for token, data := range result.extractData {
+		gtx.Extract[token] = make(map[string]*goldenExtraction)
+		for sourceKey, d := range data {
+			ge := &goldenExtraction{
+				Inputs:  make([]string, 0),
+				Outputs: make([]string, 0),
+				From:    d.Transaction.From,
+				To:      d.Transaction.To,
+				Fees:    d.Transaction.Fees,
+			}
+			for _, in := range d.TxInputs {
+				ge.Inputs = append(ge.Inputs, in.Address+":"+in.Amount)
+			}
+			for _, out := range d.TxOutputs {
+				ge.Outputs = append(ge.Outputs, out.Address+":"+out.Amount)
+			}
+			gtx.Extract[token][sourceKey] = ge
+		}
+	}
+	return gtx
+}
+
+//testCompareGolden 与golden文件比较，-record时重写golden文件
+func testCompareGolden(t *testing.T, path string, v interface{}) {
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal golden unexpected error: %v", err)
+	}
+	if *recordCassette {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("write golden unexpected error: %v", err)
+		}
+		t.Logf("golden file %s is written", path)
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden unexpected error: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("golden mismatch %s:\n%s", path, got)
+	}
+}
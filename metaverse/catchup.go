@@ -0,0 +1,130 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+)
+
+//catchUpBlock 追块时预取并提取完成的区块
+type catchUpBlock struct {
+	block   *Block
+	results []ExtractResult
+	err     error
+}
+
+//catchUp 落后节点超过CatchUpWorkers个区块时，多个线程并行获取和提取区块，按高度顺序通知和保存。
+//最后CatchUpWorkers个区块、获取失败的区块和分叉由逐块扫描处理，返回追块后的本地高度和hash
+func (bs *ETPBlockScanner) catchUp(currentHeight uint64, currentHash string) (uint64, string) {
+
+	workers := uint64(bs.CatchUpWorkers)
+	if workers <= 1 {
+		return currentHeight, currentHash
+	}
+
+	//退出追块时取消还在获取和等待获取的区块
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for bs.Scanning {
+
+		maxHeader, err := bs.wm.GetBlockHeader(ctx)
+		if err != nil {
+			bs.wm.Log.Std.Info("block scanner can not get rpc-server block height; unexpected error: %v", err)
+			return currentHeight, currentHash
+		}
+		if maxHeader.Height <= currentHeight+workers {
+			return currentHeight, currentHash
+		}
+
+		stopHeight := maxHeader.Height - workers
+		bs.wm.Log.Std.Info("block scanner catching up from height: %d to %d with %d workers", currentHeight+1, stopHeight, workers)
+
+		var (
+			next    = currentHeight + 1
+			pending = make(map[uint64]chan catchUpBlock)
+			tokens  = make(chan struct{}, workers)
+		)
+
+		//预取的区块数，已获取的区块等待前面的区块提交
+		dispatch := func() {
+			for ; next <= stopHeight && next <= currentHeight+2*workers; next++ {
+				ch := make(chan catchUpBlock, 1)
+				pending[next] = ch
+				go func(height uint64, ch chan<- catchUpBlock) {
+					select {
+					case tokens <- struct{}{}:
+					case <-ctx.Done():
+						ch <- catchUpBlock{err: ctx.Err()}
+						return
+					}
+					defer func() { <-tokens }()
+					ch <- bs.fetchCatchUpBlock(ctx, height)
+				}(next, ch)
+			}
+		}
+
+		for currentHeight < stopHeight {
+
+			if !bs.Scanning {
+				return currentHeight, currentHash
+			}
+
+			dispatch()
+			fetched := <-pending[currentHeight+1]
+			delete(pending, currentHeight+1)
+
+			if fetched.err != nil {
+				//由逐块扫描重试或记录未扫区块
+				bs.wm.Log.Std.Info("block scanner catch up stopped on height: %d; unexpected error: %v", currentHeight+1, fetched.err)
+				return currentHeight, currentHash
+			}
+
+			block := fetched.block
+			if block.Previousblockhash != currentHash {
+				//由逐块扫描处理分叉
+				bs.wm.Log.Std.Info("block scanner catch up stopped, block has been fork on height: %d.", block.Height)
+				return currentHeight, currentHash
+			}
+
+			for _, result := range fetched.results {
+				bs.saveExtractResult(block.Height, block.Hash, maxHeader.Height, result)
+			}
+
+			currentHeight = block.Height
+			currentHash = block.Hash
+			bs.commitBlock(block)
+		}
+	}
+
+	return currentHeight, currentHash
+}
+
+//fetchCatchUpBlock 获取区块并提取其中的交易，ctx取消时不再提取
+func (bs *ETPBlockScanner) fetchCatchUpBlock(ctx context.Context, height uint64) catchUpBlock {
+
+	block, err := bs.wm.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return catchUpBlock{err: err}
+	}
+	if ctx.Err() != nil {
+		return catchUpBlock{err: ctx.Err()}
+	}
+
+	results := bs.extractTransactions(block.Height, block.Hash, block.transactions)
+
+	return catchUpBlock{block: block, results: results}
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+//heightObserver 按通知顺序记录区块和交易的高度
+type heightObserver struct {
+	mu        sync.Mutex
+	blocks    []uint64
+	txHeights []uint64
+}
+
+func (o *heightObserver) BlockScanNotify(header *openwallet.BlockHeader) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !header.Fork {
+		o.blocks = append(o.blocks, header.Height)
+	}
+	return nil
+}
+
+func (o *heightObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.txHeights = append(o.txHeights, data.Transaction.BlockHeight)
+	return nil
+}
+
+func (o *heightObserver) BlockExtractSmartContractDataNotify(sourceKey string, data *openwallet.SmartContractReceipt) error {
+	return nil
+}
+
+//waitBlocks 等待区块通知异步送达
+func (o *heightObserver) waitBlocks(count int) []uint64 {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		o.mu.Lock()
+		n := len(o.blocks)
+		o.mu.Unlock()
+		if n >= count {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]uint64{}, o.blocks...)
+}
+
+func TestSimNode_BlockScanner_CatchUp(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	node.Mine(1)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.CatchUpWorkers = 4
+	heights := &heightObserver{}
+	bs.AddObserver(heights)
+	bs.ScanBlockTask()
+
+	//停机期间产生的区块
+	txids := make(map[uint64]string)
+	for h := uint64(2); h <= 31; h++ {
+		if h%3 == 0 {
+			txids[h], _ = node.Fund(addr, 100000000)
+		}
+		node.Mine(1)
+	}
+	bs.ScanBlockTask()
+
+	if h, hash, _ := bs.GetLocalBlockHead(); h != 31 || hash != node.BlockHash(31) {
+		t.Fatalf("local head = %d %s, want 31 %s", h, hash, node.BlockHash(31))
+	}
+	for h := uint64(2); h <= 31; h++ {
+		if block, err := bs.GetLocalBlock(h); err != nil || block.Hash != node.BlockHash(h) {
+			t.Fatalf("local block %d is not saved: %v", h, err)
+		}
+	}
+	for h, txid := range txids {
+		data := observer.extractData(txid)
+		if len(data) == 0 || data[0].Transaction.BlockHeight != h {
+			t.Fatalf("transaction on height %d is not notified: %+v", h, data)
+		}
+	}
+
+	//按高度顺序通知
+	blocks := heights.waitBlocks(31)
+	if len(blocks) != 31 {
+		t.Fatalf("notified %d blocks, want 31", len(blocks))
+	}
+	for i, h := range blocks {
+		if h != uint64(i+1) {
+			t.Fatalf("block notifications are out of order: %v", blocks)
+		}
+	}
+	heights.mu.Lock()
+	defer heights.mu.Unlock()
+	for i := 1; i < len(heights.txHeights); i++ {
+		if heights.txHeights[i] < heights.txHeights[i-1] {
+			t.Fatalf("transaction notifications are out of order: %v", heights.txHeights)
+		}
+	}
+}
+
+func TestSimNode_BlockScanner_CatchUpFork(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	node.Mine(3)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.CatchUpWorkers = 4
+	bs.ScanBlockTask()
+	orphanHash := node.BlockHash(3)
+
+	//停机期间本地最新区块被分叉
+	if err := node.Reorg(1); err != nil {
+		t.Fatalf("Reorg unexpected error: %v", err)
+	}
+	node.Mine(20)
+	bs.ScanBlockTask()
+
+	if forks := observer.waitForkHeaders(1); forks[3] != orphanHash {
+		t.Fatalf("fork notification on height 3 = %q, want %s", forks[3], orphanHash)
+	}
+	if h, hash, _ := bs.GetLocalBlockHead(); h != node.Height() || hash != node.BlockHash(h) {
+		t.Fatalf("local head = %d %s, want %d %s", h, hash, node.Height(), node.BlockHash(node.Height()))
+	}
+}
+
+//testBlockingNode 转发请求到模拟节点，高于allowHeight的getblock请求阻塞到调用方取消
+type testBlockingNode struct {
+	node        http.Handler
+	allowHeight uint64
+	release     chan struct{}
+
+	mu       sync.Mutex
+	blocked  int //正在阻塞的请求数
+	canceled int //调用方取消的请求数
+}
+
+func (h *testBlockingNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	req := gjson.ParseBytes(body)
+	if req.Get("method").String() == "getblock" && req.Get("params.0").Uint() > h.allowHeight {
+		h.mu.Lock()
+		h.blocked++
+		h.mu.Unlock()
+		select {
+		case <-r.Context().Done():
+			h.mu.Lock()
+			h.blocked--
+			h.canceled++
+			h.mu.Unlock()
+			return
+		case <-h.release:
+			h.mu.Lock()
+			h.blocked--
+			h.mu.Unlock()
+		}
+	}
+	h.node.ServeHTTP(w, r)
+}
+
+func TestSimNode_BlockScanner_CatchUpCancel(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	node.Mine(30)
+
+	handler := &testBlockingNode{node: node, allowHeight: 2, release: make(chan struct{})}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(handler.release) })
+	wm.WalletClient = NewClient(server.URL+"/rpc/v3", false)
+
+	bs, _ := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.CatchUpWorkers = 4
+
+	//高度2的上一区块hash与本地不一致，追块马上结束
+	if h, hash := bs.catchUp(1, "local-hash"); h != 1 || hash != "local-hash" {
+		t.Fatalf("catchUp = %d %s, want 1 local-hash", h, hash)
+	}
+
+	//退出追块后取消还在获取的区块
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		handler.mu.Lock()
+		blocked, canceled := handler.blocked, handler.canceled
+		handler.mu.Unlock()
+		if blocked == 0 && canceled > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	t.Fatalf("prefetch requests are not canceled after catchUp returned: blocked = %d, canceled = %d", handler.blocked, handler.canceled)
+}
@@ -16,11 +16,17 @@
 package metaverse
 
 import (
+	"encoding/hex"
+	"fmt"
+	"github.com/astaxie/beego/config"
 	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
 	"github.com/blocktree/openwallet/v2/common/file"
 	"github.com/shopspring/decimal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -28,8 +34,85 @@ const (
 	Symbol    = "ETP"
 	CurveType = owcrypt.ECC_CURVE_SECP256K1
 	Decimals  = int32(8)
+	//MinNodeVersion 支持的最低节点版本，v3 RPC从0.8.0开始提供
+	MinNodeVersion = "0.8.0"
+	//DefaultServerAPI 默认的节点API
+	DefaultServerAPI = "http://127.0.0.1:8820/rpc/v3"
+	//DefaultMaxTxInputs 默认一笔交易最多的输入数量
+	DefaultMaxTxInputs = 50
+	//DefaultScanPeriod 默认定时扫描的间隔
+	DefaultScanPeriod = 5 * time.Second
+	//DefaultDIDCacheTTL 默认DID解析结果的缓存时间
+	DefaultDIDCacheTTL = 60 * time.Second
+	//DefaultMaxReorgDepth 默认允许的最大分叉深度
+	DefaultMaxReorgDepth = uint64(100)
 )
 
+//DefaultAssetsConfig 默认配置模板，InitAssetsConfig返回该模板
+const DefaultAssetsConfig = `
+# node api url, multiple nodes are separated by ";"
+serverAPI = "http://127.0.0.1:8820/rpc/v3"
+# print rpc requests and responses
+rpcDebug = false
+# mark a node unhealthy when it is more than nodeMaxLag blocks behind the best node
+nodeMaxLag = 10
+# node health probe interval in seconds
+nodeProbeInterval = 30
+# broadcast transactions to every healthy node
+broadcastToAll = false
+# rpc timeout in seconds, 0 means no timeout
+rpcTimeout = 30
+# retries for transport failures, sendrawtx and getinfo are not retried by default
+rpcMaxRetries = 3
+# backoff before the first retry in milliseconds, doubled on every retry
+rpcRetryBackoff = 500
+# maximum backoff in milliseconds
+rpcMaxBackoff = 8000
+# per-method override, e.g.
+# rpcTimeout.getblock = 60
+# rpcMaxRetries.sendrawtx = 1
+# block scan interval in seconds
+scanPeriod = 5
+# number of transactions extracted in parallel
+maxExtractingSize = 10
+# rescan the last N blocks after every scan
+rescanLastBlockCount = 0
+# scan the memory pool
+scanMemPool = true
+# halt the scanner and raise an alarm when a fork is deeper than N blocks, 0 means no limit
+maxReorgDepth = 100
+# notify extracted transactions when the block has N confirmations, 0 or 1 notifies at the tip
+minConfirmations = 0
+# notify transactions as seen at the tip before they reach minConfirmations
+notifySeen = false
+# number of blocks fetched and extracted in parallel when the scanner is far behind the node, 0 or 1 scans block by block
+catchUpWorkers = 0
+# listen address of the new block callback, empty to scan by polling only
+blockNotifyListen = ""
+blockNotifyPath = "/notify/block"
+# refuse to start when the node network differs from isTestNet or its version is older than minNodeVersion
+checkNode = true
+minNodeVersion = "0.8.0"
+# network profile: mainnet, testnet or regtest, default is mainnet or testnet by isTestNet
+network = "mainnet"
+# refuse to start when the genesis block hash of the node is different, empty to use the built-in mainnet hash or skip the check
+genesisHash = ""
+# address and wif versions of a regtest network
+# p2pkhVersion = 127
+# p2shVersion = 196
+# wifVersion = 239
+# Is network test?
+isTestNet = false
+# maximum inputs of one transaction
+maxTxInputs = 50
+# seconds to cache the address bound to a DID recipient, 0 to query the node every time
+didCacheTTL = 60
+# minimum transaction fees
+minFees = "0.0001"
+# Cache data file directory, default = "", current directory: ./data
+dataDir = ""
+`
+
 type WalletConfig struct {
 
 	//币种
@@ -40,6 +123,44 @@ type WalletConfig struct {
 	DBPath string
 	//钱包服务API
 	ServerAPI string
+	//打印RPC请求和响应
+	RPCDebug bool
+	//多节点API，第一个与ServerAPI相同
+	ServerAPIs []string
+	//节点高度落后最高节点超过该区块数时标记为不可用
+	NodeMaxLag uint64
+	//节点健康检查间隔
+	NodeProbeInterval time.Duration
+	//广播交易到所有可用节点
+	BroadcastToAll bool
+	//定时扫描的间隔
+	ScanPeriod time.Duration
+	//并发提取交易的线程数
+	MaxExtractingSize int
+	//每次扫描后重扫最近N个区块
+	RescanLastBlockCount uint64
+	//是否扫描交易池
+	IsScanMemPool bool
+	//允许的最大分叉深度，超过时停止扫描并报警，为0时不限制
+	MaxReorgDepth uint64
+	//区块确认数达到N后才通知提取的交易，为0或1时在最新区块通知
+	MinConfirmations uint64
+	//未达到确认数的交易是否在最新区块时先通知已发现
+	NotifySeen bool
+	//落后较多时并行追块的线程数，为0或1时逐块扫描
+	CatchUpWorkers int
+	//新区块回调的监听地址，为空时只定时扫描
+	BlockNotifyListen string
+	//新区块回调的路径
+	BlockNotifyPath string
+	//支持的最低节点版本
+	MinNodeVersion string
+	//启动时检查节点的网络和版本
+	CheckNode bool
+	//RPC默认的超时和重试策略
+	CallPolicy *CallPolicy
+	//各RPC方法的超时和重试策略
+	CallPolicies map[string]*CallPolicy
 	//最低手续费
 	MinFees decimal.Decimal
 	//数据目录
@@ -48,6 +169,10 @@ type WalletConfig struct {
 	CurveType uint32
 	//是否测试网
 	IsTestNet bool
+	//网络配置，为nil时按IsTestNet使用主网或测试网的地址版本
+	Network *Network
+	//DID解析结果的缓存时间，为0时不缓存
+	DIDCacheTTL time.Duration
 }
 
 func NewConfig(symbol string) *WalletConfig {
@@ -58,15 +183,44 @@ func NewConfig(symbol string) *WalletConfig {
 	c.Symbol = symbol
 	c.CurveType = CurveType
 	//最大的输入数量
-	c.MaxTxInputs = 50
+	c.MaxTxInputs = DefaultMaxTxInputs
 	//本地数据库文件路径
 	c.DBPath = filepath.Join("data", strings.ToLower(c.Symbol), "db")
 	//最低手续费
 	c.MinFees = decimal.Zero
+	//节点池
+	c.NodeMaxLag = DefaultNodeMaxLag
+	c.NodeProbeInterval = DefaultNodeProbeInterval
+	//区块扫描
+	c.ScanPeriod = DefaultScanPeriod
+	c.MaxExtractingSize = maxExtractingSize
+	c.RescanLastBlockCount = 0
+	c.IsScanMemPool = true
+	c.MaxReorgDepth = DefaultMaxReorgDepth
+	c.BlockNotifyPath = DefaultBlockNotifyPath
+	//节点检查
+	c.MinNodeVersion = MinNodeVersion
+	c.CheckNode = true
+	//RPC超时和重试
+	c.CallPolicy = NewCallPolicy()
+	c.CallPolicies = defaultCallPolicies()
+	//DID解析
+	c.DIDCacheTTL = DefaultDIDCacheTTL
 
 	return &c
 }
 
+//networkParams 地址和WIF版本
+func (wc *WalletConfig) networkParams() metaverse_addrdec.NetworkParams {
+	if wc.Network != nil {
+		return wc.Network.Params
+	}
+	if wc.IsTestNet {
+		return metaverse_addrdec.TestNetParams
+	}
+	return metaverse_addrdec.MainNetParams
+}
+
 //创建文件夹
 func (wc *WalletConfig) makeDataDir() {
 
@@ -81,3 +235,131 @@ func (wc *WalletConfig) makeDataDir() {
 	//创建目录
 	file.MkdirAll(wc.DBPath)
 }
+
+//configReader 读取配置并检查取值范围，所有错误在Err中一起返回
+type configReader struct {
+	c    config.Configer
+	errs []string
+}
+
+func newConfigReader(c config.Configer) *configReader {
+	return &configReader{c: c}
+}
+
+//value 配置的原始值，没有配置时返回空
+func (r *configReader) value(key string) string {
+	return strings.TrimSpace(r.c.String(key))
+}
+
+func (r *configReader) fail(key, value, format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf("%s = %q: %s", key, value, fmt.Sprintf(format, args...)))
+}
+
+//String 字符串配置，没有配置时返回def
+func (r *configReader) String(key, def string) string {
+	if v := r.value(key); len(v) > 0 {
+		return v
+	}
+	return def
+}
+
+//Int64 整数配置，没有配置时返回def，取值必须在[min, max]之间
+func (r *configReader) Int64(key string, def, min, max int64) int64 {
+	v := r.value(key)
+	if len(v) == 0 {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		r.fail(key, v, "not an integer")
+		return def
+	}
+	if n < min || n > max {
+		r.fail(key, v, "must be between %d and %d", min, max)
+		return def
+	}
+	return n
+}
+
+//Bool 布尔配置，没有配置时返回def
+func (r *configReader) Bool(key string, def bool) bool {
+	v := r.value(key)
+	if len(v) == 0 {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		r.fail(key, v, "not a boolean")
+		return def
+	}
+	return b
+}
+
+//Network 网络配置，network没有配置时按isTestNet选择主网或测试网
+func (r *configReader) Network() *Network {
+
+	isTestNet := r.Bool("isTestNet", false)
+	name := r.String("network", NetworkMainnet)
+	if len(r.value("network")) == 0 && isTestNet {
+		name = NetworkTestnet
+	}
+
+	network, err := NewNetwork(name)
+	if err != nil {
+		r.fail("network", name, "must be %s, %s or %s", NetworkMainnet, NetworkTestnet, NetworkRegtest)
+		network, _ = NewNetwork(NetworkMainnet)
+	}
+
+	if network.Name == NetworkRegtest {
+		network.IsTestNet = r.Bool("isTestNet", network.IsTestNet)
+		params := network.Params
+		network.Params = metaverse_addrdec.NewNetworkParams(network.Name,
+			byte(r.Int64("p2pkhVersion", int64(params.P2PKH.Prefix[0]), 0, 255)),
+			byte(r.Int64("p2shVersion", int64(params.P2SH.Prefix[0]), 0, 255)),
+			byte(r.Int64("wifVersion", int64(params.WIFPrefix), 0, 255)))
+	} else {
+		if len(r.value("isTestNet")) > 0 && isTestNet != network.IsTestNet {
+			r.fail("isTestNet", r.value("isTestNet"), "conflicts with network = %s", network.Name)
+		}
+		for _, key := range []string{"p2pkhVersion", "p2shVersion", "wifVersion"} {
+			if v := r.value(key); len(v) > 0 {
+				r.fail(key, v, "only for network = %s", NetworkRegtest)
+			}
+		}
+	}
+
+	network.GenesisHash = strings.ToLower(r.String("genesisHash", network.GenesisHash))
+	if len(network.GenesisHash) > 0 {
+		if hash, err := hex.DecodeString(network.GenesisHash); err != nil || len(hash) != 32 {
+			r.fail("genesisHash", network.GenesisHash, "not a block hash")
+		}
+	}
+
+	return network
+}
+
+//Decimal 非负的数值配置，没有配置时返回def
+func (r *configReader) Decimal(key string, def decimal.Decimal) decimal.Decimal {
+	v := r.value(key)
+	if len(v) == 0 {
+		return def
+	}
+	d, err := decimal.NewFromString(v)
+	if err != nil {
+		r.fail(key, v, "not a number")
+		return def
+	}
+	if d.IsNegative() {
+		r.fail(key, v, "must not be negative")
+		return def
+	}
+	return d
+}
+
+//Err 读取过程中的所有错误
+func (r *configReader) Err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid config: %s", strings.Join(r.errs, "; "))
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/config"
+	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func TestWalletManager_InitAssetsConfig(t *testing.T) {
+	wm := NewWalletManager()
+	c, err := wm.InitAssetsConfig()
+	if err != nil {
+		t.Fatalf("InitAssetsConfig unexpected error: %v", err)
+	}
+	if c.String("serverAPI") != DefaultServerAPI {
+		t.Fatalf("template serverAPI = %s, want %s", c.String("serverAPI"), DefaultServerAPI)
+	}
+
+	c.Set("dataDir", t.TempDir())
+	c.Set("checkNode", "false")
+	if err := wm.LoadAssetsConfig(c); err != nil {
+		t.Fatalf("LoadAssetsConfig with the default template unexpected error: %v", err)
+	}
+
+	//模板的取值与默认配置一致
+	defaults := NewConfig(Symbol)
+	cfg := wm.Config
+	if cfg.ServerAPI != DefaultServerAPI || cfg.RPCDebug || cfg.NodeMaxLag != defaults.NodeMaxLag ||
+		cfg.NodeProbeInterval != defaults.NodeProbeInterval || cfg.BroadcastToAll ||
+		*cfg.CallPolicy != *defaults.CallPolicy || cfg.CallPolicies["sendrawtx"].MaxRetries != 0 ||
+		cfg.ScanPeriod != defaults.ScanPeriod || cfg.MaxExtractingSize != defaults.MaxExtractingSize ||
+		cfg.RescanLastBlockCount != defaults.RescanLastBlockCount || cfg.IsScanMemPool != defaults.IsScanMemPool ||
+		cfg.MaxReorgDepth != defaults.MaxReorgDepth || cfg.MinConfirmations != 0 || cfg.NotifySeen || cfg.CatchUpWorkers != 0 ||
+		cfg.BlockNotifyListen != "" || cfg.BlockNotifyPath != defaults.BlockNotifyPath ||
+		cfg.MinNodeVersion != defaults.MinNodeVersion || cfg.IsTestNet || cfg.MaxTxInputs != defaults.MaxTxInputs ||
+		cfg.DIDCacheTTL != defaults.DIDCacheTTL ||
+		!cfg.MinFees.Equal(decimal.New(1, -4)) {
+		t.Fatalf("unexpected config from the default template: %+v", cfg)
+	}
+
+	bs := wm.Blockscanner.(*ETPBlockScanner)
+	if bs.PeriodOfTask != DefaultScanPeriod || bs.extractingSize != maxExtractingSize || !bs.IsScanMemPool ||
+		bs.MaxReorgDepth != DefaultMaxReorgDepth {
+		t.Fatalf("scan config is not applied to the block scanner")
+	}
+}
+
+func TestWalletManager_LoadAssetsConfig_Options(t *testing.T) {
+	wm := NewWalletManager()
+	c, _ := config.NewConfigData("ini", []byte(`
+serverAPI = http://127.0.0.1:8820/rpc/v3
+checkNode = false
+rpcDebug = true
+scanPeriod = 20
+maxExtractingSize = 4
+rescanLastBlockCount = 3
+scanMemPool = false
+maxReorgDepth = 5
+minConfirmations = 6
+notifySeen = true
+catchUpWorkers = 8
+maxTxInputs = 10
+minFees = 0.001
+`))
+	c.Set("dataDir", t.TempDir())
+	if err := wm.LoadAssetsConfig(c); err != nil {
+		t.Fatalf("LoadAssetsConfig unexpected error: %v", err)
+	}
+
+	bs := wm.Blockscanner.(*ETPBlockScanner)
+	if !wm.WalletClient.Debug || wm.Config.MaxTxInputs != 10 || !wm.Config.MinFees.Equal(decimal.New(1, -3)) ||
+		bs.PeriodOfTask != 20*time.Second || bs.extractingSize != 4 || bs.RescanLastBlockCount != 3 || bs.IsScanMemPool ||
+		bs.MaxReorgDepth != 5 || bs.MinConfirmations != 6 || !bs.NotifySeen || bs.CatchUpWorkers != 8 {
+		t.Fatalf("unexpected config: %+v", wm.Config)
+	}
+}
+
+func TestWalletManager_LoadAssetsConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"serverAPI", ""},
+		{"serverAPI", "127.0.0.1:8820"},
+		{"minFees", "abc"},
+		{"minFees", "-1"},
+		{"isTestNet", "maybe"},
+		{"rpcDebug", "2"},
+		{"nodeMaxLag", "-1"},
+		{"nodeProbeInterval", "0"},
+		{"rpcTimeout", "ten"},
+		{"rpcMaxRetries.getblock", "1000"},
+		{"scanPeriod", "0"},
+		{"maxExtractingSize", "0"},
+		{"maxExtractingSize", "1000"},
+		{"rescanLastBlockCount", "-1"},
+		{"maxReorgDepth", "-1"},
+		{"minConfirmations", "-1"},
+		{"notifySeen", "maybe"},
+		{"catchUpWorkers", "100"},
+		{"maxTxInputs", "0"},
+		{"didCacheTTL", "-1"},
+		{"blockNotifyPath", "notify"},
+		{"network", "devnet"},
+		{"genesisHash", "1234"},
+		{"p2pkhVersion", "50"},
+	}
+
+	for _, test := range tests {
+		wm := NewWalletManager()
+		c, _ := wm.InitAssetsConfig()
+		c.Set("checkNode", "false")
+		c.Set("dataDir", t.TempDir())
+		c.Set(test.key, test.value)
+
+		err := wm.LoadAssetsConfig(c)
+		if err == nil {
+			t.Errorf("%s = %q should be rejected", test.key, test.value)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.key) {
+			t.Errorf("%s = %q error should name the key: %v", test.key, test.value, err)
+		}
+		//配置错误时不修改当前配置
+		if wm.Config.ServerAPI != "" || wm.WalletClient != nil {
+			t.Errorf("%s = %q should not change the config", test.key, test.value)
+		}
+	}
+
+	//所有错误一起返回
+	wm := NewWalletManager()
+	c, _ := wm.InitAssetsConfig()
+	c.Set("minFees", "abc")
+	c.Set("scanPeriod", "0")
+	err := wm.LoadAssetsConfig(c)
+	if err == nil || !strings.Contains(err.Error(), "minFees") || !strings.Contains(err.Error(), "scanPeriod") {
+		t.Fatalf("all invalid values should be reported: %v", err)
+	}
+}
+
+func TestWalletManager_LoadAssetsConfig_Network(t *testing.T) {
+	load := func(values map[string]string) (*WalletManager, error) {
+		wm := NewWalletManager()
+		c, _ := wm.InitAssetsConfig()
+		c.Set("checkNode", "false")
+		c.Set("dataDir", t.TempDir())
+		c.Set("network", "")
+		c.Set("minFees", "")
+		for k, v := range values {
+			c.Set(k, v)
+		}
+		return wm, wm.LoadAssetsConfig(c)
+	}
+
+	//没有配置network时按isTestNet选择
+	wm, err := load(map[string]string{"isTestNet": "true"})
+	if err != nil {
+		t.Fatalf("LoadAssetsConfig unexpected error: %v", err)
+	}
+	if wm.Config.Network.Name != NetworkTestnet || !wm.Config.IsTestNet || !wm.Config.MinFees.Equal(wm.Config.Network.DefaultFee) {
+		t.Fatalf("unexpected network: %+v", wm.Config.Network)
+	}
+
+	if _, err := load(map[string]string{"network": "mainnet", "isTestNet": "true"}); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Fatalf("isTestNet conflicting with network should be rejected: %v", err)
+	}
+	if _, err := load(map[string]string{"network": "regtest", "p2pkhVersion": "256"}); err == nil {
+		t.Fatalf("p2pkhVersion out of range should be rejected")
+	}
+
+	//私有链使用自定义的地址版本
+	wm, err = load(map[string]string{"network": "regtest", "isTestNet": "true", "p2pkhVersion": "65", "p2shVersion": "66", "wifVersion": "67"})
+	if err != nil {
+		t.Fatalf("LoadAssetsConfig unexpected error: %v", err)
+	}
+	params := wm.Config.networkParams()
+	if params.P2PKH.Prefix[0] != 65 || params.P2SH.Prefix[0] != 66 || params.WIFPrefix != 67 || !wm.Config.IsTestNet {
+		t.Fatalf("unexpected regtest params: %+v", params)
+	}
+	address, _ := wm.Decoder.PublicKeyToAddress(make([]byte, 33), wm.Config.IsTestNet)
+	if hash, err := wm.DecoderV2.AddressDecode(address); err != nil || len(hash) != 20 || !wm.DecoderV2.AddressVerify(address) {
+		t.Fatalf("regtest address %s should be decoded with the regtest version: %v", address, err)
+	}
+	if wm.DecoderV2.AddressVerify("MTDcfh43xT93odL1Y2uULhRLeWED2fDvBX") {
+		t.Fatalf("mainnet address should be invalid on regtest")
+	}
+}
+
+func TestWalletManager_DecoderPerInstance(t *testing.T) {
+	load := func(network string) *WalletManager {
+		wm := NewWalletManager()
+		c, _ := wm.InitAssetsConfig()
+		c.Set("checkNode", "false")
+		c.Set("dataDir", t.TempDir())
+		c.Set("network", network)
+		c.Set("isTestNet", "")
+		if err := wm.LoadAssetsConfig(c); err != nil {
+			t.Fatalf("LoadAssetsConfig unexpected error: %v", err)
+		}
+		return wm
+	}
+	mainnet := load(NetworkMainnet)
+	testnet := load(NetworkTestnet)
+
+	if mainnet.DecoderV2 == testnet.DecoderV2 || mainnet.DecoderV2 == openwallet.AddressDecoderV2(&metaverse_addrdec.Default) {
+		t.Fatalf("every wallet manager should own its address decoder")
+	}
+
+	//两个网络同时生成地址
+	pub, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wm := mainnet
+		if i%2 == 1 {
+			wm = testnet
+		}
+		wg.Add(1)
+		go func(wm *WalletManager) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				address, err := wm.Decoder.PublicKeyToAddress(pub, wm.Config.IsTestNet)
+				if err != nil || !wm.DecoderV2.AddressVerify(address) {
+					t.Errorf("%s address %s is invalid: %v", wm.Config.Network.Name, address, err)
+					return
+				}
+			}
+		}(wm)
+	}
+	wg.Wait()
+
+	mainAddr, _ := mainnet.Decoder.PublicKeyToAddress(pub, false)
+	testAddr, _ := testnet.Decoder.PublicKeyToAddress(pub, true)
+	if mainAddr == testAddr || testnet.DecoderV2.AddressVerify(mainAddr) || mainnet.DecoderV2.AddressVerify(testAddr) {
+		t.Fatalf("mainnet %s and testnet %s addresses should not be valid on the other network", mainAddr, testAddr)
+	}
+
+	//P2SH地址按各自网络的版本校验
+	mainP2SH, _ := mainnet.Decoder.RedeemScriptToAddress([][]byte{pub}, 1, false)
+	testP2SH, _ := testnet.Decoder.RedeemScriptToAddress([][]byte{pub}, 1, true)
+	if !mainnet.DecoderV2.AddressVerify(mainP2SH) || mainnet.DecoderV2.AddressVerify(testP2SH) ||
+		!testnet.DecoderV2.AddressVerify(testP2SH) || testnet.DecoderV2.AddressVerify(mainP2SH) {
+		t.Fatalf("p2sh addresses should only be valid on their own network: %s, %s", mainP2SH, testP2SH)
+	}
+
+	if metaverse_addrdec.Default.IsTestNet || metaverse_addrdec.Default.Params != nil {
+		t.Fatalf("LoadAssetsConfig should not modify the shared default decoder")
+	}
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"sort"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+const (
+	//TxStateSeen 交易已打包但未达到确认数，记录在ExtParam的txState
+	TxStateSeen = "seen"
+	//ExtParamConfirmations 交易单ExtParam中通知时区块确认数的字段
+	ExtParamConfirmations = "confirmations"
+
+	//confirmStateName 本地保存等待确认的交易的状态名称
+	confirmStateName = "confirmations"
+)
+
+//confirmWait 区块中等待达到确认数的交易
+type confirmWait struct {
+	blockExtractRecord
+	Seen map[string]bool `json:"seen"` //已通知已发现的交易，同一区块中的交易只通知一次
+}
+
+//waitingRecords 等待确认的交易，第一次使用时读取本地保存的记录。调用时需要持有confirmMu
+func (bs *ETPBlockScanner) waitingRecords() map[uint64]*confirmWait {
+	if bs.confirmWaits == nil {
+		bs.confirmWaits = make(map[uint64]*confirmWait)
+		if err := bs.loadScannerState(confirmStateName, &bs.confirmWaits); err != nil {
+			bs.wm.Log.Std.Error("can not load waiting confirmations; unexpected error: %v", err)
+		}
+	}
+	return bs.confirmWaits
+}
+
+//saveConfirmWaits 保存有变化的等待确认的交易，重启后继续等待
+func (bs *ETPBlockScanner) saveConfirmWaits() {
+
+	bs.confirmMu.Lock()
+	defer bs.confirmMu.Unlock()
+
+	if !bs.confirmDirty {
+		return
+	}
+	if err := bs.saveScannerState(confirmStateName, bs.confirmWaits); err != nil {
+		bs.wm.Log.Std.Error("can not save waiting confirmations; unexpected error: %v", err)
+		return
+	}
+	bs.confirmDirty = false
+}
+
+//confirmationsOf 区块在最新高度tipHeight时的确认数，最新区块为1
+func confirmationsOf(height, tipHeight uint64) uint64 {
+	if height == 0 || tipHeight < height {
+		return 0
+	}
+	return tipHeight - height + 1
+}
+
+//notifyBlockExtractData 通知区块中提取的交易。设置MinConfirmations时确认数不足的交易等待确认后再通知，
+//NotifySeen时先通知已发现
+func (bs *ETPBlockScanner) notifyBlockExtractData(height uint64, blockHash string, tipHeight uint64, result ExtractResult) error {
+
+	if len(result.extractData) == 0 {
+		return nil
+	}
+
+	if bs.MinConfirmations <= 1 {
+		notifyErr := bs.newExtractDataNotify(height, result.extractData)
+		bs.recordExtractData(height, blockHash, result.TxID, result.extractData)
+		return notifyErr
+	}
+
+	confirmations := confirmationsOf(height, tipHeight)
+	if confirmations >= bs.MinConfirmations {
+		confirmed := confirmedExtractData(result.extractData, confirmations, false)
+		notifyErr := bs.newExtractDataNotify(height, confirmed)
+		bs.recordExtractData(height, blockHash, result.TxID, confirmed)
+		return notifyErr
+	}
+
+	bs.confirmMu.Lock()
+	waits := bs.waitingRecords()
+	record := waits[height]
+	if record == nil || record.Hash != blockHash {
+		record = &confirmWait{
+			blockExtractRecord: blockExtractRecord{Hash: blockHash, Txs: make(map[string]map[string]ExtractData)},
+			Seen:               make(map[string]bool),
+		}
+		waits[height] = record
+	}
+	record.Txs[result.TxID] = result.extractData
+	//重扫最新区块时不重复通知已发现
+	notifySeen := bs.NotifySeen && !record.Seen[result.TxID]
+	if notifySeen {
+		record.Seen[result.TxID] = true
+	}
+	bs.confirmDirty = true
+	bs.confirmMu.Unlock()
+
+	if !notifySeen {
+		return nil
+	}
+
+	seen := confirmedExtractData(result.extractData, confirmations, true)
+	notifyErr := bs.newExtractDataNotify(height, seen)
+	bs.recordExtractData(height, blockHash, result.TxID, seen)
+	return notifyErr
+}
+
+//WaitingConfirmations 等待达到确认数的交易数量
+func (bs *ETPBlockScanner) WaitingConfirmations() int {
+	bs.confirmMu.Lock()
+	defer bs.confirmMu.Unlock()
+	count := 0
+	for _, record := range bs.waitingRecords() {
+		count += len(record.Txs)
+	}
+	return count
+}
+
+//notifyConfirmedExtractData 通知达到确认数的交易，区块已不在主链上的交易不再通知，由分叉处理通知
+func (bs *ETPBlockScanner) notifyConfirmedExtractData() {
+
+	bs.confirmMu.Lock()
+	waiting := len(bs.waitingRecords())
+	bs.confirmMu.Unlock()
+	if waiting == 0 {
+		return
+	}
+
+	tip, err := bs.wm.GetBlockHeader(context.Background())
+	if err != nil {
+		bs.wm.Log.Std.Info("block scanner can not get rpc-server block height; unexpected error: %v", err)
+		return
+	}
+
+	bs.confirmMu.Lock()
+	heights := make([]uint64, 0)
+	for height := range bs.confirmWaits {
+		if confirmationsOf(height, tip.Height) >= bs.MinConfirmations {
+			heights = append(heights, height)
+		}
+	}
+	bs.confirmMu.Unlock()
+
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	for _, height := range heights {
+
+		header, headerErr := bs.wm.GetBlockHeader(context.Background(), height)
+		if headerErr != nil {
+			//下次任务再检查
+			bs.wm.Log.Std.Info("block scanner can not get block header on height: %d; unexpected error: %v", height, headerErr)
+			continue
+		}
+
+		bs.confirmMu.Lock()
+		record := bs.confirmWaits[height]
+		delete(bs.confirmWaits, height)
+		bs.confirmDirty = true
+		bs.confirmMu.Unlock()
+
+		if record == nil {
+			continue
+		}
+		if record.Hash != header.Hash {
+			bs.wm.Log.Std.Info("block on height: %d is orphaned before confirmed, hash: %s", height, record.Hash)
+			continue
+		}
+
+		confirmations := confirmationsOf(height, tip.Height)
+		for txid, tokenExtractData := range record.Txs {
+			bs.wm.Log.Std.Info("transaction confirmed on height: %d, confirmations: %d, txid: %s", height, confirmations, txid)
+			confirmed := confirmedExtractData(tokenExtractData, confirmations, false)
+			bs.newExtractDataNotify(height, confirmed)
+			bs.recordExtractData(height, record.Hash, txid, confirmed)
+		}
+	}
+	bs.saveScannerStates()
+}
+
+//dropForkedConfirmations 删除分叉区块中等待确认的交易，从height开始的区块已被孤立
+func (bs *ETPBlockScanner) dropForkedConfirmations(height uint64) {
+	bs.confirmMu.Lock()
+	defer bs.confirmMu.Unlock()
+	for h := range bs.waitingRecords() {
+		if h >= height {
+			delete(bs.confirmWaits, h)
+			bs.confirmDirty = true
+		}
+	}
+}
+
+//confirmedExtractData 带确认数的提取结果，未达到确认数的交易单状态为TxStatusPending，txState为seen
+func confirmedExtractData(tokenExtractData map[string]ExtractData, confirmations uint64, seen bool) map[string]ExtractData {
+
+	notified := make(map[string]ExtractData)
+	for token, extractData := range tokenExtractData {
+		notified[token] = make(ExtractData)
+		for sourceKey, data := range extractData {
+			tx := *data.Transaction
+			tx.SetExtParam(ExtParamConfirmations, confirmations)
+			if seen {
+				tx.Status = TxStatusPending
+				tx.SetExtParam(ExtParamTxState, TxStateSeen)
+			}
+			notified[token][sourceKey] = &openwallet.TxExtractData{
+				TxInputs:    data.TxInputs,
+				TxOutputs:   data.TxOutputs,
+				Transaction: &tx,
+			}
+		}
+	}
+	return notified
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+func TestSimNode_BlockScanner_MinConfirmations(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	node.Mine(1)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.MinConfirmations = 3
+	bs.NotifySeen = true
+	bs.ScanBlockTask()
+
+	txid, _ := node.Fund(addr, 100000000)
+	node.Mine(1)
+	bs.ScanBlockTask()
+	node.Mine(1)
+	bs.ScanBlockTask()
+
+	//未达到确认数时只通知一次已发现，重扫最新区块不重复通知
+	data := observer.extractData(txid)
+	if states := txStates(data); len(states) != 1 || states[0] != TxStateSeen {
+		t.Fatalf("unexpected transaction states before confirmed: %v", states)
+	}
+	seen := data[0].Transaction
+	if seen.Status != TxStatusPending || seen.BlockHeight != 2 || seen.GetExtParam().Get(ExtParamConfirmations).Uint() != 1 {
+		t.Fatalf("unexpected seen transaction: %+v", seen)
+	}
+	if bs.WaitingConfirmations() != 1 {
+		t.Fatalf("WaitingConfirmations = %d, want 1", bs.WaitingConfirmations())
+	}
+
+	node.Mine(1)
+	bs.ScanBlockTask()
+	data = observer.extractData(txid)
+	confirmed := data[len(data)-1].Transaction
+	if confirmed.Status != openwallet.TxStatusSuccess || confirmed.WxID != seen.WxID ||
+		confirmed.GetExtParam().Get(ExtParamTxState).String() != TxStateConfirmed ||
+		confirmed.GetExtParam().Get(ExtParamConfirmations).Uint() != 3 {
+		t.Fatalf("unexpected confirmed transaction: %+v", confirmed)
+	}
+	if bs.WaitingConfirmations() != 0 {
+		t.Fatalf("confirmed transaction is still waiting")
+	}
+
+	//扫描已达到确认数的区块时直接通知
+	bs.ScanBlock(2)
+	data = observer.extractData(txid)
+	if rescanned := data[len(data)-1].Transaction; rescanned.GetExtParam().Get(ExtParamConfirmations).Uint() != 3 ||
+		rescanned.Status != openwallet.TxStatusSuccess {
+		t.Fatalf("unexpected rescanned transaction: %+v", rescanned)
+	}
+}
+
+func TestSimNode_BlockScanner_MinConfirmationsFork(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	node.Mine(1)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.MinConfirmations = 3
+	bs.ScanBlockTask()
+
+	txid, _ := node.Fund(addr, 100000000)
+	node.Mine(1)
+	bs.ScanBlockTask()
+	if data := observer.extractData(txid); len(data) != 0 {
+		t.Fatalf("transaction is notified before confirmed: %v", txStates(data))
+	}
+
+	//等待确认的区块被分叉，交易不再通知
+	if err := node.Reorg(1); err != nil {
+		t.Fatalf("Reorg unexpected error: %v", err)
+	}
+	bs.ScanBlockTask()
+	node.Mine(3)
+	bs.ScanBlockTask()
+	if data := observer.extractData(txid); len(data) != 0 {
+		t.Fatalf("orphaned transaction is notified: %v", txStates(data))
+	}
+	if bs.WaitingConfirmations() != 0 {
+		t.Fatalf("orphaned transaction is still waiting")
+	}
+}
+
+func TestSimNode_BlockScanner_MinConfirmationsRestart(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	node.Mine(1)
+
+	bs, _ := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.MinConfirmations = 3
+	bs.NotifySeen = true
+	bs.ScanBlockTask()
+
+	txid, _ := node.Fund(addr, 100000000)
+	node.Mine(1)
+	bs.ScanBlockTask()
+	if bs.WaitingConfirmations() != 1 {
+		t.Fatalf("WaitingConfirmations = %d, want 1", bs.WaitingConfirmations())
+	}
+
+	//重启后继续等待停机前扫描的交易
+	restarted, observer := restartSimBlockScanner(bs, wallet)
+	if restarted.WaitingConfirmations() != 1 {
+		t.Fatalf("WaitingConfirmations after restart = %d, want 1", restarted.WaitingConfirmations())
+	}
+
+	restarted.ScanBlockTask()
+	if data := observer.extractData(txid); len(data) != 0 {
+		t.Fatalf("seen transaction is notified again after restart: %v", txStates(data))
+	}
+
+	node.Mine(2)
+	restarted.ScanBlockTask()
+	data := observer.extractData(txid)
+	if len(data) != 1 {
+		t.Fatalf("transaction is not confirmed after restart: %v", txStates(data))
+	}
+	if confirmed := data[0].Transaction; confirmed.Status != openwallet.TxStatusSuccess ||
+		confirmed.GetExtParam().Get(ExtParamTxState).String() != TxStateConfirmed ||
+		confirmed.GetExtParam().Get(ExtParamConfirmations).Uint() != 3 {
+		t.Fatalf("unexpected confirmed transaction: %+v", confirmed)
+	}
+	if restarted.WaitingConfirmations() != 0 {
+		t.Fatalf("confirmed transaction is still waiting")
+	}
+}
@@ -16,6 +16,7 @@
 package metaverse
 
 import (
+	"context"
 	"github.com/blocktree/openwallet/v2/openwallet"
 	"github.com/shopspring/decimal"
 )
@@ -36,9 +37,18 @@ func (decoder *ContractDecoder) GetTokenBalanceByAddress(contract openwallet.Sma
 
 	var tokenBalanceList []*openwallet.TokenBalance
 
+	if symbol, ok := MITSymbol(&contract); ok {
+		return decoder.getMITBalanceByAddress(contract, symbol, address...)
+	}
+
+	assets, err := decoder.wm.GetAddressAssets(context.Background(), contract.Address, address...)
+	if err != nil {
+		return nil, err
+	}
+
 	for i := 0; i < len(address); i++ {
 
-		asset, _ := decoder.wm.GetAddressAsset(address[i], contract.Address)
+		asset := assets[address[i]]
 		assetBalance, _ := decimal.NewFromString(asset.Quantity)
 		assetBalance = assetBalance.Shift(-int32(contract.Decimals))
 
@@ -58,3 +68,40 @@ func (decoder *ContractDecoder) GetTokenBalanceByAddress(contract openwallet.Sma
 
 	return tokenBalanceList, nil
 }
+
+//getMITBalanceByAddress MIT合约的余额，持有MIT的地址余额为1
+func (decoder *ContractDecoder) getMITBalanceByAddress(contract openwallet.SmartContract, symbol string, address ...string) ([]*openwallet.TokenBalance, error) {
+
+	var tokenBalanceList []*openwallet.TokenBalance
+
+	for _, addr := range address {
+
+		mits, err := decoder.wm.GetAddressMITs(context.Background(), addr)
+		if err != nil {
+			return nil, err
+		}
+
+		balance := "0"
+		for _, mit := range mits {
+			if mit.Symbol == symbol {
+				balance = "1"
+				break
+			}
+		}
+
+		tokenBalance := &openwallet.TokenBalance{
+			Contract: &contract,
+			Balance: &openwallet.Balance{
+				Address:          addr,
+				Symbol:           contract.Symbol,
+				Balance:          balance,
+				ConfirmBalance:   balance,
+				UnconfirmBalance: "0",
+			},
+		}
+
+		tokenBalanceList = append(tokenBalanceList, tokenBalance)
+	}
+
+	return tokenBalanceList, nil
+}
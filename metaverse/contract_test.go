@@ -22,6 +22,7 @@ import (
 )
 
 func TestContractDecoder_GetTokenBalanceByAddress(t *testing.T) {
+	testRequireNode(t)
 	addr := "MUsTC2PCF52yNvAeGNXJUKy9CfLVHV9yYj"
 	contract := openwallet.SmartContract{
 		Address:  "DNA",
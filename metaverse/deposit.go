@@ -0,0 +1,215 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"fmt"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	//ExtParamLocked 提取的输出ExtParam中是否锁仓存款的字段
+	ExtParamLocked = "locked"
+	//ExtParamUnlockHeight 提取的输出ExtParam中锁仓存款解锁高度的字段
+	ExtParamUnlockHeight = "unlockHeight"
+
+	//TxTypeDepositUnlock 锁仓存款到期通知的交易类型
+	TxTypeDepositUnlock = uint64(101)
+	//TxActionDepositUnlock 锁仓存款到期通知的执行事件
+	TxActionDepositUnlock = "depositUnlock"
+
+	//depositStateName 本地保存等待到期的锁仓存款的状态名称
+	depositStateName = "deposits"
+)
+
+//LockHeight 输出锁仓的区块数，优先使用节点返回的locked_height_range，没有锁仓返回0
+func (obj *Vout) LockHeight() uint64 {
+	if obj.LockedHeightRange > 0 {
+		return uint64(obj.LockedHeightRange)
+	}
+	if obj.Script != nil && obj.Script.Type == ScriptLockHeight {
+		return obj.Script.LockHeight
+	}
+	return 0
+}
+
+//UnlockHeight 锁仓存款的解锁高度，区块高度达到解锁高度后可以花费。没有锁仓或未上链返回0
+func (obj *Vout) UnlockHeight(blockHeight uint64) uint64 {
+	lock := obj.LockHeight()
+	if lock == 0 || blockHeight == 0 {
+		return 0
+	}
+	return blockHeight + lock
+}
+
+//setOutputLock 提取的输出在ExtParam中记录是否锁仓，已上链的锁仓输出同时记录解锁高度
+func setOutputLock(outPut *openwallet.TxOutPut, output *Vout, blockHeight uint64) {
+	locked := output.LockHeight() > 0
+	outPut.SetExtParam(ExtParamLocked, locked)
+	if unlockHeight := output.UnlockHeight(blockHeight); unlockHeight > 0 {
+		outPut.SetExtParam(ExtParamUnlockHeight, unlockHeight)
+	}
+}
+
+//lockedDeposit 等待到期的锁仓存款
+type lockedDeposit struct {
+	SourceKey    string               `json:"sourceKey"`
+	Output       *openwallet.TxOutPut `json:"output"`
+	UnlockHeight uint64               `json:"unlockHeight"`
+}
+
+//lockedDeposits 等待到期的锁仓存款，第一次使用时读取本地保存的记录。调用时需要持有depositMu
+func (bs *ETPBlockScanner) lockedDeposits() map[string]*lockedDeposit {
+	if bs.deposits == nil {
+		bs.deposits = make(map[string]*lockedDeposit)
+		if err := bs.loadScannerState(depositStateName, &bs.deposits); err != nil {
+			bs.wm.Log.Std.Error("can not load locked deposits; unexpected error: %v", err)
+		}
+	}
+	return bs.deposits
+}
+
+//saveLockedDeposits 保存等待到期的锁仓存款，重启后继续关注。调用时需要持有depositMu
+func (bs *ETPBlockScanner) saveLockedDeposits() {
+	if err := bs.saveScannerState(depositStateName, bs.deposits); err != nil {
+		bs.wm.Log.Std.Error("can not save locked deposits; unexpected error: %v", err)
+	}
+}
+
+//WatchLockedDeposit 关注锁仓存款的输出，扫描到解锁高度的区块时通知到期。
+//关注的存款保存在DBPath中，重启后继续关注
+func (bs *ETPBlockScanner) WatchLockedDeposit(sourceKey string, output *openwallet.TxOutPut) bool {
+	ext := output.GetExtParam()
+	unlockHeight := ext.Get(ExtParamUnlockHeight).Uint()
+	if !ext.Get(ExtParamLocked).Bool() || unlockHeight == 0 {
+		return false
+	}
+
+	bs.depositMu.Lock()
+	defer bs.depositMu.Unlock()
+
+	bs.lockedDeposits()[output.Sid] = &lockedDeposit{
+		SourceKey:    sourceKey,
+		Output:       output,
+		UnlockHeight: unlockHeight,
+	}
+	bs.saveLockedDeposits()
+	return true
+}
+
+//LockedDeposits 关注中尚未到期的锁仓存款数量
+func (bs *ETPBlockScanner) LockedDeposits() int {
+	bs.depositMu.Lock()
+	defer bs.depositMu.Unlock()
+	return len(bs.lockedDeposits())
+}
+
+//watchExtractedDeposits 关注提取结果中的锁仓存款，重扫已扫区块时跳过已经到期的存款
+func (bs *ETPBlockScanner) watchExtractedDeposits(tokenExtractData map[string]ExtractData) {
+
+	scannedHeight, _, _ := bs.GetLocalBlockHead()
+
+	for _, extractData := range tokenExtractData {
+		for sourceKey, data := range extractData {
+			for _, output := range data.TxOutputs {
+				if output.GetExtParam().Get(ExtParamUnlockHeight).Uint() <= scannedHeight {
+					continue
+				}
+				bs.WatchLockedDeposit(sourceKey, output)
+			}
+		}
+	}
+}
+
+//dropForkedDeposits 删除分叉区块中的锁仓存款
+func (bs *ETPBlockScanner) dropForkedDeposits(height uint64) {
+	bs.depositMu.Lock()
+	defer bs.depositMu.Unlock()
+
+	dropped := 0
+	for sid, deposit := range bs.lockedDeposits() {
+		if deposit.Output.BlockHeight >= height {
+			delete(bs.deposits, sid)
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		bs.saveLockedDeposits()
+	}
+}
+
+//notifyMaturedDeposits 通知在该区块到期的锁仓存款。
+//通知的输出与存款时提取的输出Sid相同，ExtParam的locked为false，观测者按Sid更新为可用余额
+func (bs *ETPBlockScanner) notifyMaturedDeposits(block *Block) {
+
+	bs.depositMu.Lock()
+	matured := make([]*lockedDeposit, 0)
+	for sid, deposit := range bs.lockedDeposits() {
+		if deposit.UnlockHeight <= block.Height {
+			matured = append(matured, deposit)
+			delete(bs.deposits, sid)
+		}
+	}
+	if len(matured) > 0 {
+		bs.saveLockedDeposits()
+	}
+	bs.depositMu.Unlock()
+
+	for _, deposit := range matured {
+
+		output := *deposit.Output
+		output.SetExtParam(ExtParamLocked, false)
+
+		amount, _ := decimal.NewFromString(output.Amount)
+		decimals := int32(0)
+		if !output.Coin.IsContract {
+			decimals = bs.wm.Decimal()
+		}
+
+		tx := &openwallet.Transaction{
+			TxID:        output.TxID,
+			Coin:        output.Coin,
+			From:        []string{},
+			To:          []string{output.Address + ":" + amount.String()},
+			Amount:      amount.String(),
+			Decimal:     decimals,
+			TxType:      TxTypeDepositUnlock,
+			TxAction:    TxActionDepositUnlock,
+			BlockHash:   block.Hash,
+			BlockHeight: block.Height,
+			Fees:        "0",
+			ConfirmTime: int64(block.Time),
+			Status:      openwallet.TxStatusSuccess,
+		}
+		//与存款交易单区分，避免覆盖存款的交易记录
+		tx.WxID = openwallet.GenTransactionWxID2(fmt.Sprintf("%s_%s_%d", TxActionDepositUnlock, output.TxID, output.Index), output.Coin.Symbol, output.Coin.ContractID)
+		tx.SetExtParam(ExtParamUnlockHeight, deposit.UnlockHeight)
+
+		bs.wm.Log.Std.Info("locked deposit matured; txid: %s, index: %d, address: %s, unlock height: %d", output.TxID, output.Index, output.Address, deposit.UnlockHeight)
+
+		for o := range bs.Observers {
+			err := o.BlockExtractDataNotify(deposit.SourceKey, &openwallet.TxExtractData{
+				TxOutputs:   []*openwallet.TxOutPut{&output},
+				Transaction: tx,
+			})
+			if err != nil {
+				bs.wm.Log.Error("BlockExtractDataNotify unexpected error:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestVout_LockHeight(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		lockHeight   uint64
+		unlockHeight uint64
+	}{
+		{
+			name:         "locked height range",
+			output:       `{"locked_height_range":25200,"script":"[ 7062 ] numequalverify dup hash160 [ 71f8f5732d9af26486b2afe4c8bc13a60b2c0db6 ] equalverify checksig"}`,
+			lockHeight:   25200,
+			unlockHeight: 1025200,
+		},
+		{
+			name:         "lock height script",
+			output:       `{"locked_height_range":0,"script":"[ 7062 ] numequalverify dup hash160 [ 71f8f5732d9af26486b2afe4c8bc13a60b2c0db6 ] equalverify checksig"}`,
+			lockHeight:   25200,
+			unlockHeight: 1025200,
+		},
+		{
+			name:   "p2pkh",
+			output: `{"locked_height_range":0,"script":"dup hash160 [ 71f8f5732d9af26486b2afe4c8bc13a60b2c0db6 ] equalverify checksig"}`,
+		},
+	}
+
+	for _, test := range tests {
+		json := gjson.Parse(test.output)
+		out := NewTxOut(&json)
+		if out.LockHeight() != test.lockHeight || out.UnlockHeight(1000000) != test.unlockHeight {
+			t.Errorf("%s: LockHeight = %d, UnlockHeight = %d", test.name, out.LockHeight(), out.UnlockHeight(1000000))
+		}
+		if out.UnlockHeight(0) != 0 {
+			t.Errorf("%s: unconfirmed output has unlock height", test.name)
+		}
+	}
+}
+
+func TestSimNode_BlockScanner_Deposit(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	address := wallet.addresses[0].Address
+	node.Mine(1)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.ScanBlockTask()
+
+	fundTxID, _ := node.Fund(address, 100000000)
+	depositTxID, _ := node.Deposit(address, 200000000, 3)
+	node.Mine(1)
+	bs.ScanBlockTask()
+
+	//普通输出也记录锁仓状态
+	data := observer.extractData(fundTxID)
+	if len(data) == 0 || data[0].TxOutputs[0].GetExtParam().Get(ExtParamLocked).Bool() {
+		t.Fatalf("unexpected fund outputs: %+v", data)
+	}
+
+	data = observer.extractData(depositTxID)
+	if len(data) == 0 || len(data[0].TxOutputs) != 1 {
+		t.Fatalf("deposit is not extracted: %+v", data)
+	}
+	deposit := data[0].TxOutputs[0]
+	ext := deposit.GetExtParam()
+	if !ext.Get(ExtParamLocked).Bool() || ext.Get(ExtParamUnlockHeight).Uint() != 5 || deposit.Amount != "2" {
+		t.Fatalf("unexpected deposit output: %+v", deposit)
+	}
+	if bs.LockedDeposits() != 1 {
+		t.Fatalf("LockedDeposits = %d, want 1", bs.LockedDeposits())
+	}
+
+	//可用余额不包括冻结的存款
+	balances, err := bs.GetAddressBalances(address)
+	if err != nil || len(balances) != 1 || balances[0].Balance.Balance != "1" || balances[0].ConfirmBalance != "1" || balances[0].Frozen != "2" {
+		t.Fatalf("GetAddressBalances = %+v, %v", balances, err)
+	}
+	if list, _ := bs.GetBalanceByAddress(address); len(list) != 1 || list[0].Balance != "1" {
+		t.Fatalf("GetBalanceByAddress = %+v", list)
+	}
+
+	//解锁高度前不通知
+	node.Mine(2)
+	bs.ScanBlockTask()
+	if matured := depositUnlocks(observer.extractData(depositTxID)); len(matured) != 0 {
+		t.Fatalf("deposit matured before unlock height: %+v", matured)
+	}
+
+	node.Mine(1)
+	bs.ScanBlockTask()
+	unlocks := depositUnlocks(observer.extractData(depositTxID))
+	if len(unlocks) != 1 {
+		t.Fatalf("deposit maturity notified %d times, want 1", len(unlocks))
+	}
+	matured := unlocks[0]
+	if tx := matured.Transaction; tx.TxType != TxTypeDepositUnlock || tx.TxAction != TxActionDepositUnlock || tx.BlockHeight != 5 ||
+		tx.WxID == data[0].Transaction.WxID || len(tx.To) != 1 || tx.To[0] != address+":2" {
+		t.Fatalf("unexpected maturity transaction: %+v", tx)
+	}
+	if out := matured.TxOutputs[0]; out.Sid != deposit.Sid || out.GetExtParam().Get(ExtParamLocked).Bool() {
+		t.Fatalf("unexpected maturity output: %+v", out)
+	}
+	if bs.LockedDeposits() != 0 {
+		t.Fatalf("LockedDeposits = %d after maturity", bs.LockedDeposits())
+	}
+
+	balances, _ = bs.GetAddressBalances(address)
+	if balances[0].Balance.Balance != "3" || balances[0].Frozen != "0" {
+		t.Fatalf("unexpected matured balance: %+v", balances[0])
+	}
+
+	//重扫已到期的存款不再关注
+	bs.ScanBlock(2)
+	if bs.LockedDeposits() != 0 {
+		t.Fatalf("rescan watches matured deposit again")
+	}
+}
+
+func TestSimNode_BlockScanner_DepositRestart(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	address := wallet.addresses[0].Address
+	node.Mine(1)
+
+	bs, _ := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.ScanBlockTask()
+
+	depositTxID, _ := node.Deposit(address, 200000000, 3)
+	node.Mine(1)
+	bs.ScanBlockTask()
+	if bs.LockedDeposits() != 1 {
+		t.Fatalf("LockedDeposits = %d, want 1", bs.LockedDeposits())
+	}
+
+	//重启后继续关注停机前提取的存款
+	restarted, observer := restartSimBlockScanner(bs, wallet)
+	if restarted.LockedDeposits() != 1 {
+		t.Fatalf("LockedDeposits after restart = %d, want 1", restarted.LockedDeposits())
+	}
+
+	node.Mine(3)
+	restarted.ScanBlockTask()
+	unlocks := depositUnlocks(observer.extractData(depositTxID))
+	if len(unlocks) != 1 || unlocks[0].Transaction.BlockHeight != 5 || unlocks[0].TxOutputs[0].Address != address {
+		t.Fatalf("deposit maturity after restart = %+v", unlocks)
+	}
+	if restarted.LockedDeposits() != 0 {
+		t.Fatalf("LockedDeposits = %d after maturity", restarted.LockedDeposits())
+	}
+
+	//到期后的状态也已保存
+	if again, _ := restartSimBlockScanner(restarted, wallet); again.LockedDeposits() != 0 {
+		t.Fatalf("matured deposit is watched again after restart")
+	}
+}
+
+//depositUnlocks 锁仓存款到期的通知
+func depositUnlocks(data []*openwallet.TxExtractData) []*openwallet.TxExtractData {
+	unlocks := make([]*openwallet.TxExtractData, 0)
+	for _, d := range data {
+		if d.Transaction.TxType == TxTypeDepositUnlock {
+			unlocks = append(unlocks, d)
+		}
+	}
+	return unlocks
+}
+
+func TestSimNode_BlockScanner_DepositFork(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	address := wallet.addresses[0].Address
+	node.Mine(2)
+
+	bs, _ := newSimBlockScanner(wm, wallet)
+	bs.ScanBlockTask()
+
+	node.Deposit(address, 200000000, 10)
+	node.Mine(1)
+	bs.ScanBlockTask()
+	if bs.LockedDeposits() != 1 {
+		t.Fatalf("LockedDeposits = %d, want 1", bs.LockedDeposits())
+	}
+
+	//存款所在的区块被分叉，生成交易被丢弃
+	if err := node.Reorg(1); err != nil {
+		t.Fatalf("Reorg unexpected error: %v", err)
+	}
+	bs.ScanBlockTask()
+	if bs.LockedDeposits() != 0 {
+		t.Fatalf("forked deposit is still watched")
+	}
+
+	//重启后用保存的输出重新关注
+	output := &openwallet.TxOutPut{}
+	output.Sid = "restored"
+	output.SetExtParam(ExtParamLocked, true)
+	output.SetExtParam(ExtParamUnlockHeight, 100)
+	if !bs.WatchLockedDeposit(simAccountID, output) || bs.LockedDeposits() != 1 {
+		t.Fatalf("WatchLockedDeposit failed")
+	}
+	output.SetExtParam(ExtParamLocked, false)
+	output.Sid = "unlocked"
+	if bs.WatchLockedDeposit(simAccountID, output) {
+		t.Fatalf("WatchLockedDeposit accepted unlocked output")
+	}
+}
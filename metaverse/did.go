@@ -0,0 +1,171 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+//didSymbolPattern DID（数字身份）名称，3~64个字母、数字或.@_-
+var didSymbolPattern = regexp.MustCompile(`^[A-Za-z0-9.@_-]{3,64}$`)
+
+//IsDIDSymbol 是否符合DID名称的格式，地址也可能符合，需要先检查是否为地址
+func IsDIDSymbol(symbol string) bool {
+	return didSymbolPattern.MatchString(symbol)
+}
+
+//didEntry DID解析结果的缓存
+type didEntry struct {
+	address string
+	expire  time.Time
+}
+
+//didCache DID解析结果缓存，DID可以转移到其他地址，缓存时间不宜过长
+type didCache struct {
+	mu      sync.Mutex
+	entries map[string]*didEntry
+}
+
+func newDIDCache() *didCache {
+	return &didCache{entries: make(map[string]*didEntry)}
+}
+
+//get 未过期的缓存地址
+func (c *didCache) get(symbol string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[symbol]
+	if !ok {
+		return "", false
+	}
+	if !now.Before(entry.expire) {
+		delete(c.entries, symbol)
+		return "", false
+	}
+	return entry.address, true
+}
+
+func (c *didCache) set(symbol, address string, expire time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[symbol] = &didEntry{address: address, expire: expire}
+}
+
+//didCurrentAddress 解析getdid的结果，返回DID当前绑定的地址。
+//节点返回DID的地址历史：[{"address": "...", "status": "current"}, {"address": "...", "status": "history"}]
+func didCurrentAddress(result *gjson.Result) string {
+	if address := result.Get("address"); address.Exists() {
+		return address.String()
+	}
+	list := *result
+	if addresses := result.Get("addresses"); addresses.Exists() {
+		list = addresses
+	}
+	for _, obj := range list.Array() {
+		if obj.Get("status").String() == "current" {
+			return obj.Get("address").String()
+		}
+	}
+	return ""
+}
+
+//ResolveDID 查询DID当前绑定的地址，结果缓存DIDCacheTTL
+func (wm *WalletManager) ResolveDID(ctx context.Context, symbol string) (string, *openwallet.Error) {
+
+	if !IsDIDSymbol(symbol) {
+		return "", openwallet.Errorf(ErrDIDNotFound, "invalid did symbol: %s", symbol)
+	}
+
+	now := time.Now()
+	if address, ok := wm.dids.get(symbol, now); ok {
+		return address, nil
+	}
+
+	result, err := wm.WalletClient.Call(ctx, "getdid", []interface{}{symbol})
+	if err != nil {
+		return "", err
+	}
+
+	address := didCurrentAddress(result)
+	if len(address) == 0 {
+		return "", openwallet.Errorf(ErrDIDNotFound, "did %s is not bound to any address", symbol)
+	}
+	if !wm.DecoderV2.AddressVerify(address) {
+		return "", openwallet.Errorf(openwallet.ErrAdressDecodeFailed, "did %s is bound to an invalid address: %s", symbol, address)
+	}
+
+	if ttl := wm.Config.DIDCacheTTL; ttl > 0 {
+		wm.dids.set(symbol, address, now.Add(ttl))
+	}
+
+	return address, nil
+}
+
+//resolveRecipient 接收方是地址时直接返回，是DID时返回当前绑定的地址和DID
+func (wm *WalletManager) resolveRecipient(ctx context.Context, to string) (string, string, *openwallet.Error) {
+
+	if dec, ok := wm.DecoderV2.(*metaverse_addrdec.AddressDecoderV2); ok {
+		_, err := dec.ValidateAddress(to)
+		if err == nil {
+			return to, "", nil
+		}
+		//其他网络的地址不作为DID解析
+		if errors.Is(err, metaverse_addrdec.ErrAddressNetwork) {
+			return "", "", openwallet.Errorf(openwallet.ErrAdressDecodeFailed, "receiver %s: %v", to, err)
+		}
+	} else if wm.DecoderV2.AddressVerify(to) {
+		return to, "", nil
+	}
+
+	if !IsDIDSymbol(to) {
+		return "", "", openwallet.Errorf(openwallet.ErrAdressDecodeFailed, "receiver %s is neither an address nor a did", to)
+	}
+
+	address, err := wm.ResolveDID(ctx, to)
+	if err != nil {
+		return "", "", err
+	}
+	return address, to, nil
+}
+
+//resolveReceivers 解析交易单的接收方，DID转换为地址，同一地址的金额合并。
+//返回地址对应的金额和地址对应的DID。
+func (wm *WalletManager) resolveReceivers(ctx context.Context, to map[string]string) (map[string]decimal.Decimal, map[string]string, *openwallet.Error) {
+
+	amounts := make(map[string]decimal.Decimal, len(to))
+	dids := make(map[string]string)
+	for receiver, amount := range to {
+		address, did, err := wm.resolveRecipient(ctx, receiver)
+		if err != nil {
+			return nil, nil, err
+		}
+		sendAmount, _ := decimal.NewFromString(amount)
+		amounts[address] = amounts[address].Add(sendAmount)
+		if len(did) > 0 {
+			dids[address] = did
+		}
+	}
+	return amounts, dids, nil
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestIsDIDSymbol(t *testing.T) {
+	tests := []struct {
+		symbol string
+		valid  bool
+	}{
+		{"alice", true},
+		{"Alice.ETP", true},
+		{"user@mvs_01-a", true},
+		{"ab", false},
+		{"alice bob", false},
+		{"alice:1", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if valid := IsDIDSymbol(test.symbol); valid != test.valid {
+			t.Errorf("IsDIDSymbol(%q) = %v, want %v", test.symbol, valid, test.valid)
+		}
+	}
+}
+
+func TestDIDCurrentAddress(t *testing.T) {
+	tests := []struct {
+		json    string
+		address string
+	}{
+		{`[{"address":"MOld","status":"history"},{"address":"MNew","status":"current"}]`, "MNew"},
+		{`{"addresses":[{"address":"MNew","status":"current"}]}`, "MNew"},
+		{`{"symbol":"alice","address":"MNew"}`, "MNew"},
+		{`[{"address":"MOld","status":"history"}]`, ""},
+		{`[]`, ""},
+	}
+	for _, test := range tests {
+		result := gjson.Parse(test.json)
+		if address := didCurrentAddress(&result); address != test.address {
+			t.Errorf("didCurrentAddress(%s) = %s, want %s", test.json, address, test.address)
+		}
+	}
+}
+
+func TestSimNode_ResolveDID(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+	first, second := wallet.addresses[0].Address, wallet.addresses[1].Address
+	ctx := context.Background()
+
+	node.RegisterDID("alice", first)
+
+	address, err := wm.ResolveDID(ctx, "alice")
+	if err != nil || address != first {
+		t.Fatalf("ResolveDID = %s, %v, want %s", address, err, first)
+	}
+
+	//缓存期间不再查询节点，DID转移后仍返回缓存的地址
+	node.RegisterDID("alice", second)
+	requests := node.Requests()
+	if address, _ := wm.ResolveDID(ctx, "alice"); address != first || node.Requests() != requests {
+		t.Fatalf("ResolveDID should return the cached address %s, got %s", first, address)
+	}
+
+	//缓存过期后返回新地址
+	wm.dids.set("alice", first, time.Now().Add(-time.Second))
+	if address, _ := wm.ResolveDID(ctx, "alice"); address != second {
+		t.Fatalf("ResolveDID after the cache expired = %s, want %s", address, second)
+	}
+
+	//不缓存时每次查询节点
+	wm.Config.DIDCacheTTL = 0
+	node.RegisterDID("bob", first)
+	wm.ResolveDID(ctx, "bob")
+	node.RegisterDID("bob", second)
+	if address, _ := wm.ResolveDID(ctx, "bob"); address != second {
+		t.Fatalf("ResolveDID without cache = %s, want %s", address, second)
+	}
+
+	if _, err := wm.ResolveDID(ctx, "nobody"); !IsErrorCode(err, ErrDIDNotFound) {
+		t.Fatalf("ResolveDID unknown did error = %v, want ErrDIDNotFound", err)
+	}
+	if _, err := wm.ResolveDID(ctx, "a b"); !IsErrorCode(err, ErrDIDNotFound) {
+		t.Fatalf("ResolveDID invalid symbol error = %v, want ErrDIDNotFound", err)
+	}
+}
+
+func TestSimNode_TransactionDecoder_DID(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	from := wallet.addresses[0].Address
+	to := "MUsTC2PCF52yNvAeGNXJUKy9CfLVHV9yYj"
+	didAddress := "MTDcfh43xT93odL1Y2uULhRLeWED2fDvBX"
+
+	node.Fund(from, 100000000)
+	node.IssueAsset(from, "DNA", 5000000, 4)
+	node.Mine(1)
+	node.RegisterDID("alice", didAddress)
+
+	decoder := wm.GetTransactionDecoder()
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{to: "0.1", "alice": "0.2"},
+	}
+	if err := decoder.CreateRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("CreateRawTransaction unexpected error: %v", err)
+	}
+	if !containsString(rawTx.TxTo, didAddress+"(alice):0.2") || !containsString(rawTx.TxTo, to+":0.1") {
+		t.Fatalf("TxTo should record the did and its address: %v", rawTx.TxTo)
+	}
+	if err := decoder.SignRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("SignRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.VerifyRawTransaction(wallet, rawTx); err != nil || !rawTx.IsCompleted {
+		t.Fatalf("VerifyRawTransaction failed: %v", err)
+	}
+	if _, err := decoder.SubmitRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("SubmitRawTransaction unexpected error: %v", err)
+	}
+	node.Mine(1)
+	if balance, _ := wm.GetAddressETP(context.Background(), didAddress); balance.Confirmed != "20000000" {
+		t.Fatalf("did address balance = %s, want 20000000", balance.Confirmed)
+	}
+
+	//Token交易
+	contract := openwallet.SmartContract{
+		ContractID: openwallet.GenContractID(wm.Symbol(), "DNA"),
+		Address:    "DNA",
+		Symbol:     wm.Symbol(),
+		Token:      "DNA",
+		Decimals:   4,
+	}
+	tokenTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol(), IsContract: true, ContractID: contract.ContractID, Contract: contract},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{"alice": "12.5"},
+	}
+	if err := decoder.CreateRawTransaction(wallet, tokenTx); err != nil {
+		t.Fatalf("CreateRawTransaction token unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(tokenTx.TxTo[0], didAddress+"(alice):") {
+		t.Fatalf("token TxTo should record the did and its address: %v", tokenTx.TxTo)
+	}
+
+	//不存在的DID和其他网络的地址
+	testnet := metaverse_addrdec.NewAddressDecoderV2(metaverse_addrdec.TestNetParams, true)
+	testnetAddress, _ := testnet.AddressEncode(make([]byte, 20))
+	for _, receiver := range []string{"nobody", testnetAddress, "not a did"} {
+		rawTx := &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+			Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+			To:      map[string]string{receiver: "0.1"},
+		}
+		if err := decoder.CreateRawTransaction(wallet, rawTx); err == nil {
+			t.Errorf("CreateRawTransaction to %s should fail", receiver)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"strings"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//适配器的错误码，openwallet没有对应的错误时使用
+const (
+	ErrNodeRPCFailed     = 7000 //节点返回的其他错误
+	ErrTxAlreadyExists   = 7001 //交易已在交易池或区块链中
+	ErrTxDoubleSpend     = 7002 //交易输入已被其他交易花费
+	ErrTxNotFound        = 7003 //交易不存在
+	ErrBlockNotFound     = 7004 //区块不存在或高度超出范围
+	ErrRPCInvalidRequest = 7005 //请求格式、方法或参数无效
+	ErrRequestCanceled   = 7006 //请求被调用方取消
+	ErrNodeIncompatible  = 7007 //节点网络或版本与配置不符
+	ErrDIDNotFound       = 7009 //DID不存在或没有绑定地址
+	ErrReorgTooDeep      = 7010 //分叉深度超过maxReorgDepth，扫描已停止
+)
+
+//Metaverse节点的错误码
+const (
+	NodeErrParseError     = -32700
+	NodeErrInvalidRequest = -32600
+	NodeErrMethodNotFound = -32601
+	NodeErrInvalidParams  = -32602
+	NodeErrInternal       = -32603
+
+	NodeErrBalanceLack    = 3302 //余额不足
+	NodeErrAddressInvalid = 4010 //地址无效
+	NodeErrAssetLack      = 5002 //资产余额不足
+	NodeErrBlockHeight    = 5101 //区块高度错误
+	NodeErrTxValidate     = 5301 //交易验证失败
+	NodeErrTxBroadcast    = 5302 //交易广播失败
+	NodeErrTxNotFound     = 5304 //交易不存在
+	NodeErrDIDNotFound    = 7006 //DID不存在
+)
+
+//nodeErrorMessages 按错误信息识别的节点错误，优先于错误码匹配。
+//节点广播失败时都返回5302，需要根据信息区分重复交易、双花和手续费不足。
+var nodeErrorMessages = []struct {
+	match string
+	code  uint64
+}{
+	{"already exist", ErrTxAlreadyExists},
+	{"duplicate", ErrTxAlreadyExists},
+	{"matching transaction with unspent outputs", ErrTxAlreadyExists},
+	{"double spen", ErrTxDoubleSpend},
+	{"insufficient fee", openwallet.ErrInsufficientFees},
+	{"fees out of range", openwallet.ErrInsufficientFees},
+}
+
+//nodeErrorCodes 节点错误码对应的openwallet错误码
+var nodeErrorCodes = map[int64]uint64{
+	NodeErrParseError:     ErrRPCInvalidRequest,
+	NodeErrInvalidRequest: ErrRPCInvalidRequest,
+	NodeErrMethodNotFound: ErrRPCInvalidRequest,
+	NodeErrInvalidParams:  ErrRPCInvalidRequest,
+	NodeErrInternal:       openwallet.ErrCallFullNodeAPIFailed,
+
+	NodeErrBalanceLack:    openwallet.ErrInsufficientBalanceOfAccount,
+	NodeErrAddressInvalid: openwallet.ErrAdressDecodeFailed,
+	NodeErrAssetLack:      openwallet.ErrInsufficientTokenBalanceOfAddress,
+	NodeErrBlockHeight:    ErrBlockNotFound,
+	NodeErrTxValidate:     openwallet.ErrVerifyRawTransactionFailed,
+	NodeErrTxBroadcast:    openwallet.ErrSubmitRawTransactionFailed,
+	NodeErrTxNotFound:     ErrTxNotFound,
+	NodeErrDIDNotFound:    ErrDIDNotFound,
+}
+
+//retryableErrors 可重试的错误码，节点或网络暂时不可用，稍后重试可能成功。
+//其他错误是确定的结果（如余额不足、交易无效），重试不会改变。
+var retryableErrors = map[uint64]bool{
+	openwallet.ErrCallFullNodeAPIFailed: true,
+	openwallet.ErrNetworkRequestFailed:  true,
+}
+
+//NewNodeError 把节点返回的错误转换为openwallet错误，错误信息保留节点的错误码
+func NewNodeError(nodeCode int64, message string) *openwallet.Error {
+
+	code := uint64(ErrNodeRPCFailed)
+
+	lower := strings.ToLower(message)
+	matched := false
+	for _, m := range nodeErrorMessages {
+		if strings.Contains(lower, m.match) {
+			code = m.code
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		if c, ok := nodeErrorCodes[nodeCode]; ok {
+			code = c
+		}
+	}
+
+	return openwallet.Errorf(code, "%s (node error %d)", message, nodeCode)
+}
+
+//IsRetryable 错误是否可重试
+func IsRetryable(err error) bool {
+	owErr, ok := err.(*openwallet.Error)
+	if !ok || owErr == nil {
+		return false
+	}
+	return retryableErrors[owErr.Code()]
+}
+
+//IsPermanent 错误是否为确定的结果，重试不会成功
+func IsPermanent(err error) bool {
+	owErr, ok := err.(*openwallet.Error)
+	if !ok || owErr == nil {
+		return false
+	}
+	return !retryableErrors[owErr.Code()]
+}
+
+//IsErrorCode 错误是否为指定的错误码
+func IsErrorCode(err error, code uint64) bool {
+	owErr, ok := err.(*openwallet.Error)
+	if !ok || owErr == nil {
+		return false
+	}
+	return owErr.Code() == code
+}
@@ -0,0 +1,265 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+func TestNewNodeError(t *testing.T) {
+	tests := []struct {
+		nodeCode  int64
+		message   string
+		code      uint64
+		retryable bool
+	}{
+		{NodeErrBalanceLack, "not enough balance, unspent = 1, payment = 2", openwallet.ErrInsufficientBalanceOfAccount, false},
+		{NodeErrAssetLack, "not enough asset amount", openwallet.ErrInsufficientTokenBalanceOfAddress, false},
+		{NodeErrAddressInvalid, "invalid address: 123", openwallet.ErrAdressDecodeFailed, false},
+		{NodeErrBlockHeight, "block height 100 out of range", ErrBlockNotFound, false},
+		{NodeErrTxNotFound, "transaction not found", ErrTxNotFound, false},
+		{NodeErrTxValidate, "outputs value exceeds inputs value", openwallet.ErrVerifyRawTransactionFailed, false},
+		{NodeErrTxBroadcast, "broadcast transaction failure", openwallet.ErrSubmitRawTransactionFailed, false},
+		{NodeErrTxBroadcast, "transaction already exists in memory pool", ErrTxAlreadyExists, false},
+		{NodeErrTxBroadcast, "broadcast transaction failure : matching transaction with unspent outputs exists", ErrTxAlreadyExists, false},
+		{NodeErrTxBroadcast, "input 00:0 is double spent by 11", ErrTxDoubleSpend, false},
+		{NodeErrTxBroadcast, "Insufficient fee", openwallet.ErrInsufficientFees, false},
+		{NodeErrMethodNotFound, "method not found: foo", ErrRPCInvalidRequest, false},
+		{NodeErrInvalidParams, "invalid params", ErrRPCInvalidRequest, false},
+		{NodeErrInternal, "internal error", openwallet.ErrCallFullNodeAPIFailed, true},
+		{1234, "something else", ErrNodeRPCFailed, false},
+	}
+
+	for _, test := range tests {
+		err := NewNodeError(test.nodeCode, test.message)
+		if err.Code() != test.code {
+			t.Errorf("NewNodeError(%d, %q) code = %d, want %d", test.nodeCode, test.message, err.Code(), test.code)
+		}
+		if IsRetryable(err) != test.retryable || IsPermanent(err) == test.retryable {
+			t.Errorf("NewNodeError(%d, %q) retryable = %v, want %v", test.nodeCode, test.message, IsRetryable(err), test.retryable)
+		}
+		if !strings.Contains(err.Error(), fmt.Sprintf("node error %d", test.nodeCode)) {
+			t.Errorf("NewNodeError(%d, %q) should keep the node code: %v", test.nodeCode, test.message, err)
+		}
+	}
+
+	var nilErr *openwallet.Error
+	if IsRetryable(nilErr) || IsPermanent(nilErr) || IsRetryable(fmt.Errorf("plain")) {
+		t.Errorf("nil and non openwallet errors should not be classified")
+	}
+}
+
+func TestClient_Call_TransportErrors(t *testing.T) {
+	client := NewClient(testDeadNodeURL(), false)
+	client.Policy.MaxRetries = 0
+
+	_, err := client.Call(context.Background(), "getblockheader", nil)
+	if err == nil || err.Code() != openwallet.ErrNetworkRequestFailed || !IsRetryable(err) {
+		t.Fatalf("connection refused should be a retryable network error: %v", err)
+	}
+
+	badGateway, _ := newTestFlakyClient(t, 1, 0)
+	badGateway.Policy.MaxRetries = 0
+	_, err = badGateway.Call(context.Background(), "getblockheader", nil)
+	if err == nil || err.Code() != openwallet.ErrCallFullNodeAPIFailed || !IsRetryable(err) {
+		t.Fatalf("http 502 should be a retryable node error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = badGateway.Call(ctx, "getblockheader", nil)
+	if err == nil || err.Code() != ErrRequestCanceled || IsRetryable(err) {
+		t.Fatalf("canceled request should be permanent: %v", err)
+	}
+}
+
+//testRPCErrorNode 以HTTP 500返回json-rpc错误的节点
+type testRPCErrorNode struct {
+	mu       sync.Mutex
+	body     string
+	requests int
+}
+
+func (f *testRPCErrorNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	body := f.body
+	f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(body))
+}
+
+func (f *testRPCErrorNode) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func TestClient_Call_RPCErrorWithHTTP500(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		code      uint64
+		retryable bool
+	}{
+		{
+			name: "tx not found",
+			body: `{"jsonrpc":"2.0","id":"1","error":{"code":5304,"message":"transaction not found"}}`,
+			code: ErrTxNotFound,
+		},
+		{
+			name: "invalid tx",
+			body: `{"jsonrpc":"2.0","id":"1","error":{"code":5301,"message":"validate transaction failure"}}`,
+			code: openwallet.ErrVerifyRawTransactionFailed,
+		},
+		{
+			name:      "not json-rpc",
+			body:      `<html>internal server error</html>`,
+			code:      openwallet.ErrCallFullNodeAPIFailed,
+			retryable: true,
+		},
+	}
+
+	for _, test := range tests {
+		node := &testRPCErrorNode{body: test.body}
+		server := httptest.NewServer(node)
+		client := NewClient(server.URL, false)
+		client.Policy = &CallPolicy{MaxRetries: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+		_, err := client.Call(context.Background(), "gettx", []interface{}{"0000000000000000000000000000000000000000000000000000000000000001"})
+		server.Close()
+
+		if err == nil || err.Code() != test.code || IsRetryable(err) != test.retryable {
+			t.Errorf("%s: Call error = %v, want code %d", test.name, err, test.code)
+			continue
+		}
+		//节点确定的错误不重试
+		want := 1
+		if test.retryable {
+			want = 1 + 3
+		}
+		if node.count() != want {
+			t.Errorf("%s: requests = %d, want %d", test.name, node.count(), want)
+		}
+	}
+}
+
+//testMethodFailNode 指定方法返回502，其他方法转发到节点
+type testMethodFailNode struct {
+	mu     sync.Mutex
+	method string
+	next   http.Handler
+}
+
+func (f *testMethodFailNode) setMethod(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.method = method
+}
+
+func (f *testMethodFailNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	f.mu.Lock()
+	method := f.method
+	f.mu.Unlock()
+	if len(method) > 0 && bytes.Contains(body, []byte(`"method":"`+method+`"`)) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	f.next.ServeHTTP(w, r)
+}
+
+func TestSimNode_BlockScanner_RetryableError(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	node.Mine(2)
+
+	proxy := &testMethodFailNode{next: node}
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+	wm.WalletClient = NewClient(server.URL, false)
+	wm.WalletClient.Policy.MaxRetries = 0
+
+	bs, _ := newSimBlockScanner(wm, wallet)
+	bs.ScanBlockTask()
+	if h := bs.GetScannedBlockHeight(); h != 2 {
+		t.Fatalf("scanned height = %d, want 2", h)
+	}
+
+	//节点暂时不可用时不跳过区块
+	node.Mine(1)
+	proxy.setMethod("getblock")
+	bs.ScanBlockTask()
+	if h := bs.GetScannedBlockHeight(); h != 2 {
+		t.Fatalf("scanned height = %d, want 2 while getblock is failing", h)
+	}
+	records, _ := bs.GetUnscanRecords()
+	for _, r := range records {
+		if r.BlockHeight == 3 {
+			t.Fatalf("retryable error should not record block 3 as unscanned")
+		}
+	}
+
+	proxy.setMethod("")
+	bs.ScanBlockTask()
+	if h := bs.GetScannedBlockHeight(); h != 3 {
+		t.Fatalf("scanned height = %d, want 3", h)
+	}
+}
+
+func TestSimNode_SubmitRawTransaction_AlreadyExists(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+
+	node.Fund(wallet.addresses[0].Address, 100000000)
+	node.Mine(1)
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{wallet.addresses[1].Address: "0.1"},
+	}
+	testSimTransfer(t, wm, wallet, rawTx)
+
+	//重复提交返回ErrTxAlreadyExists，不标记为已提交
+	rawTx.TxID = ""
+	rawTx.IsSubmit = false
+	tx, err := wm.GetTransactionDecoder().SubmitRawTransaction(wallet, rawTx)
+	if tx != nil || !IsErrorCode(err, ErrTxAlreadyExists) || IsRetryable(err) {
+		t.Fatalf("resubmit = %v, %v, want ErrTxAlreadyExists", tx, err)
+	}
+	if rawTx.TxID != "" || rawTx.IsSubmit {
+		t.Fatalf("resubmit marks transaction as submitted: %s %v", rawTx.TxID, rawTx.IsSubmit)
+	}
+
+	_, sendErr := wm.SendRawTx(context.Background(), rawTx.RawHex)
+	if sendErr == nil || sendErr.Code() != ErrTxAlreadyExists || IsRetryable(sendErr) {
+		t.Fatalf("SendRawTx duplicate should fail with ErrTxAlreadyExists: %v", sendErr)
+	}
+}
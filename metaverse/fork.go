@@ -0,0 +1,228 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+const (
+	//TxStateForked 交易所在的区块被分叉，记录在ExtParam的txState
+	TxStateForked = "forked"
+	//ExtParamForkedBlockHash 分叉交易单ExtParam中被孤立区块hash的字段
+	ExtParamForkedBlockHash = "forkedBlockHash"
+
+	//forkRecordBlocks 不限制分叉深度时保留提取记录的区块数，也是查找共同祖先时最多跳过的本地没有记录的区块数
+	forkRecordBlocks = DefaultMaxReorgDepth
+
+	//forkStateName 本地保存最近区块提取记录的状态名称
+	forkStateName = "forks"
+)
+
+//blockExtractRecord 区块中提取的交易，分叉时通知观测者
+type blockExtractRecord struct {
+	Hash string                            `json:"hash"`
+	Txs  map[string]map[string]ExtractData `json:"txs"` //txid -> 提取结果
+}
+
+//extractRecords 最近区块中提取的交易，第一次使用时读取本地保存的记录。调用时需要持有forkMu
+func (bs *ETPBlockScanner) extractRecords() map[uint64]*blockExtractRecord {
+	if bs.forkRecords == nil {
+		bs.forkRecords = make(map[uint64]*blockExtractRecord)
+		if err := bs.loadScannerState(forkStateName, &bs.forkRecords); err != nil {
+			bs.wm.Log.Std.Error("can not load block extract records; unexpected error: %v", err)
+		}
+	}
+	return bs.forkRecords
+}
+
+//saveExtractRecords 保存有变化的提取记录，重启后仍可以通知分叉区块中的交易
+func (bs *ETPBlockScanner) saveExtractRecords() {
+
+	bs.forkMu.Lock()
+	defer bs.forkMu.Unlock()
+
+	if !bs.forkDirty {
+		return
+	}
+	if err := bs.saveScannerState(forkStateName, bs.forkRecords); err != nil {
+		bs.wm.Log.Std.Error("can not save block extract records; unexpected error: %v", err)
+		return
+	}
+	bs.forkDirty = false
+}
+
+//recordExtractData 记录区块中提取的交易，只保留分叉可能涉及的最近区块
+func (bs *ETPBlockScanner) recordExtractData(height uint64, hash string, txid string, tokenExtractData map[string]ExtractData) {
+
+	bs.forkMu.Lock()
+	defer bs.forkMu.Unlock()
+
+	records := bs.extractRecords()
+	record := records[height]
+	if record == nil || record.Hash != hash {
+		record = &blockExtractRecord{Hash: hash, Txs: make(map[string]map[string]ExtractData)}
+		records[height] = record
+	}
+	record.Txs[txid] = tokenExtractData
+	bs.forkDirty = true
+
+	keep := bs.MaxReorgDepth
+	if keep == 0 {
+		keep = forkRecordBlocks
+	}
+	for h := range records {
+		if h+keep < height {
+			delete(records, h)
+		}
+	}
+}
+
+//localBlockHash 本地扫描过的区块hash，依次使用本地区块记录、提取记录和本地扫描高度，都没有时返回false
+func (bs *ETPBlockScanner) localBlockHash(height uint64) (string, bool) {
+
+	if local, err := bs.GetLocalBlock(height); err == nil && len(local.Hash) > 0 {
+		return local.Hash, true
+	}
+
+	bs.forkMu.Lock()
+	record := bs.extractRecords()[height]
+	bs.forkMu.Unlock()
+	if record != nil {
+		return record.Hash, true
+	}
+
+	if headHeight, headHash, err := bs.GetLocalBlockHead(); err == nil && headHeight == height && len(headHash) > 0 {
+		return headHash, true
+	}
+
+	return "", false
+}
+
+//ReorgHalted 分叉深度超过MaxReorgDepth时停止扫描的原因，正常扫描时返回nil
+func (bs *ETPBlockScanner) ReorgHalted() *openwallet.Error {
+	bs.forkMu.Lock()
+	defer bs.forkMu.Unlock()
+	return bs.reorgHalt
+}
+
+//haltReorg 停止扫描直到重新设置扫描高度，并通知ReorgAlarm
+func (bs *ETPBlockScanner) haltReorg(haltErr *openwallet.Error) *openwallet.Error {
+	bs.forkMu.Lock()
+	bs.reorgHalt = haltErr
+	bs.forkMu.Unlock()
+	bs.wm.Log.Std.Error("%v", haltErr)
+	if bs.ReorgAlarm != nil {
+		bs.ReorgAlarm(haltErr)
+	}
+	return haltErr
+}
+
+//rollbackFork 从forkHeight向前比较本地区块和节点区块的hash，找到共同祖先。
+//本地没有记录的区块无法判断是否被孤立，继续向前比较，从共同祖先重扫。
+//每个被孤立的区块通知分叉和其中提取过的交易，扫描高度回滚到共同祖先
+func (bs *ETPBlockScanner) rollbackFork(forkHeight uint64) (*Block, error) {
+
+	var (
+		orphans  = make([]*Block, 0)
+		missing  = make([]uint64, 0)
+		ancestor *Block
+	)
+
+	for height := forkHeight; ; height-- {
+
+		remote, err := bs.wm.GetBlockHeader(context.Background(), height)
+		if err != nil {
+			return nil, err
+		}
+
+		localHash, known := bs.localBlockHash(height)
+		if (known && localHash == remote.Hash) || height == 0 {
+			ancestor = &Block{Hash: remote.Hash, Height: remote.Height}
+			break
+		}
+
+		if known {
+			orphans = append(orphans, &Block{Hash: localHash, Height: height})
+		} else {
+			missing = append(missing, height)
+		}
+
+		if bs.MaxReorgDepth > 0 && uint64(len(orphans)+len(missing)) > bs.MaxReorgDepth {
+			return nil, bs.haltReorg(openwallet.Errorf(ErrReorgTooDeep, "fork on height %d is deeper than maxReorgDepth %d, block scanner is halted", forkHeight, bs.MaxReorgDepth))
+		}
+		if uint64(len(missing)) > forkRecordBlocks {
+			return nil, bs.haltReorg(openwallet.Errorf(ErrReorgTooDeep, "can not find common ancestor of fork on height %d, %d local blocks are missing, block scanner is halted", forkHeight, len(missing)))
+		}
+	}
+
+	bs.wm.Log.Std.Info("block scanner found common ancestor on height: %d, hash: %s, orphaned blocks: %d, missing local blocks: %d", ancestor.Height, ancestor.Hash, len(orphans), len(missing))
+
+	for _, orphan := range orphans {
+		//删除分叉区块的未扫记录
+		bs.DeleteUnscanRecord(orphan.Height)
+		bs.notifyOrphanedBlock(orphan)
+	}
+	for _, height := range missing {
+		bs.wm.Log.Std.Warning("local block on height: %d is missing, it can not be notified as orphaned and will be rescanned", height)
+		bs.DeleteUnscanRecord(height)
+	}
+
+	//删除分叉区块中的锁仓存款和等待确认的交易
+	bs.dropForkedDeposits(ancestor.Height + 1)
+	bs.dropForkedConfirmations(ancestor.Height + 1)
+	bs.saveScannerStates()
+
+	//重新记录一个新扫描起点
+	bs.SaveLocalBlockHead(ancestor.Height, ancestor.Hash)
+
+	return ancestor, nil
+}
+
+//notifyOrphanedBlock 通知被孤立的区块，再通知区块中提取过的交易，交易单与提取时的WxID相同
+func (bs *ETPBlockScanner) notifyOrphanedBlock(orphan *Block) {
+
+	bs.forkMu.Lock()
+	records := bs.extractRecords()
+	record := records[orphan.Height]
+	if record != nil {
+		delete(records, orphan.Height)
+		bs.forkDirty = true
+	}
+	bs.forkMu.Unlock()
+
+	bs.wm.Log.Std.Info("block orphaned on height: %d, hash: %s", orphan.Height, orphan.Hash)
+
+	//通知分叉区块给观测者，异步处理
+	bs.newBlockNotify(orphan, true)
+
+	if record == nil || record.Hash != orphan.Hash {
+		return
+	}
+
+	for txid, tokenExtractData := range record.Txs {
+
+		bs.wm.Log.Std.Info("transaction orphaned on height: %d, txid: %s", orphan.Height, txid)
+
+		forked := failedExtractData(tokenExtractData, "block is orphaned by a fork", map[string]string{
+			ExtParamTxState:         TxStateForked,
+			ExtParamForkedBlockHash: orphan.Hash,
+		})
+		bs.newExtractDataNotify(orphan.Height, forked)
+	}
+}
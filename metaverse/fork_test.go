@@ -0,0 +1,236 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//forkHeaders 观测者收到的分叉区块通知，key为区块高度
+func (o *simObserver) forkHeaders() map[uint64]string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	forks := make(map[uint64]string)
+	for _, header := range o.headers {
+		if header.Fork {
+			forks[header.Height] = header.Hash
+		}
+	}
+	return forks
+}
+
+//waitForkHeaders 等待区块通知异步送达观测者
+func (o *simObserver) waitForkHeaders(count int) map[uint64]string {
+	deadline := time.Now().Add(2 * time.Second)
+	forks := o.forkHeaders()
+	for len(forks) < count && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		forks = o.forkHeaders()
+	}
+	return forks
+}
+
+func TestSimNode_BlockScanner_DeepFork(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	node.Mine(2)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.ScanBlockTask()
+
+	//高度3到5各有一笔充值
+	txids := make(map[uint64]string)
+	for h := uint64(3); h <= 5; h++ {
+		txids[h], _ = node.Fund(addr, 100000000)
+		node.Mine(1)
+	}
+	bs.ScanBlockTask()
+	orphanHashes := map[uint64]string{3: node.BlockHash(3), 4: node.BlockHash(4), 5: node.BlockHash(5)}
+
+	//断开3个区块，新分支不包含原来的充值
+	if err := node.Reorg(3); err != nil {
+		t.Fatalf("Reorg unexpected error: %v", err)
+	}
+	bs.ScanBlockTask()
+
+	forks := observer.waitForkHeaders(len(orphanHashes))
+	for h, hash := range orphanHashes {
+		if forks[h] != hash {
+			t.Fatalf("fork notification on height %d = %q, want %s", h, forks[h], hash)
+		}
+
+		data := observer.extractData(txids[h])
+		if len(data) < 2 {
+			t.Fatalf("orphaned transaction %s is not notified: %v", txids[h], txStates(data))
+		}
+		forked := data[len(data)-1].Transaction
+		if forked.Status != openwallet.TxStatusFail || forked.WxID != data[0].Transaction.WxID ||
+			forked.GetExtParam().Get(ExtParamTxState).String() != TxStateForked ||
+			forked.GetExtParam().Get(ExtParamForkedBlockHash).String() != hash {
+			t.Fatalf("unexpected orphaned transaction: %+v", forked)
+		}
+	}
+	if _, ok := forks[2]; ok {
+		t.Fatalf("common ancestor is notified as fork")
+	}
+
+	if h, hash, _ := bs.GetLocalBlockHead(); h != node.Height() || hash != node.BlockHash(h) {
+		t.Fatalf("local head = %d %s, want %d %s", h, hash, node.Height(), node.BlockHash(node.Height()))
+	}
+}
+
+func TestSimNode_BlockScanner_ReorgTooDeep(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	node.Mine(1)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.MaxReorgDepth = 2
+	var alarm *openwallet.Error
+	bs.ReorgAlarm = func(err *openwallet.Error) {
+		alarm = err
+	}
+	bs.ScanBlockTask()
+	node.Mine(4)
+	bs.ScanBlockTask()
+	headHeight, headHash, _ := bs.GetLocalBlockHead()
+
+	if err := node.Reorg(3); err != nil {
+		t.Fatalf("Reorg unexpected error: %v", err)
+	}
+	bs.ScanBlockTask()
+
+	if alarm == nil || alarm.Code() != ErrReorgTooDeep || !IsErrorCode(bs.ReorgHalted(), ErrReorgTooDeep) {
+		t.Fatalf("reorg alarm = %v, halted = %v", alarm, bs.ReorgHalted())
+	}
+	if len(observer.forkHeaders()) != 0 {
+		t.Fatalf("fork is notified when the scanner is halted")
+	}
+
+	//停止后不再扫描
+	node.Mine(1)
+	bs.ScanBlockTask()
+	if h, hash, _ := bs.GetLocalBlockHead(); h != headHeight || hash != headHash {
+		t.Fatalf("halted scanner moved local head to %d %s", h, hash)
+	}
+
+	//重新设置扫描高度后继续扫描
+	if err := bs.SetRescanBlockHeight(2); err != nil {
+		t.Fatalf("SetRescanBlockHeight unexpected error: %v", err)
+	}
+	if bs.ReorgHalted() != nil {
+		t.Fatalf("SetRescanBlockHeight does not resume the scanner")
+	}
+	bs.ScanBlockTask()
+	if h, hash, _ := bs.GetLocalBlockHead(); h != node.Height() || hash != node.BlockHash(h) {
+		t.Fatalf("local head = %d %s, want %d %s", h, hash, node.Height(), node.BlockHash(node.Height()))
+	}
+}
+
+func TestSimNode_BlockScanner_ForkMissingLocalBlock(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	node.Mine(2)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.ScanBlockTask()
+
+	txid, _ := node.Fund(addr, 100000000)
+	node.Mine(3)
+	bs.ScanBlockTask()
+	orphanHashes := map[uint64]string{3: node.BlockHash(3), 5: node.BlockHash(5)}
+
+	//没有提取数据的区块不保存提取记录
+	bs.forkMu.Lock()
+	_, has3 := bs.extractRecords()[3]
+	_, has4 := bs.extractRecords()[4]
+	bs.forkMu.Unlock()
+	if !has3 || has4 {
+		t.Fatalf("extract records on height 3 and 4 = %v %v, want true false", has3, has4)
+	}
+
+	//高度4的本地区块记录丢失
+	dai := bs.BlockchainDAI.(*simBlockchainDAI)
+	dai.mu.Lock()
+	delete(dai.blocks, 4)
+	dai.mu.Unlock()
+
+	if err := node.Reorg(3); err != nil {
+		t.Fatalf("Reorg unexpected error: %v", err)
+	}
+	bs.ScanBlockTask()
+
+	//跳过没有记录的区块继续比较，高度3的充值也通知分叉
+	forks := observer.waitForkHeaders(len(orphanHashes))
+	for h, hash := range orphanHashes {
+		if forks[h] != hash {
+			t.Fatalf("fork notification on height %d = %q, want %s", h, forks[h], hash)
+		}
+	}
+	if _, ok := forks[4]; ok {
+		t.Fatalf("missing local block is notified as fork")
+	}
+	data := observer.extractData(txid)
+	if states := txStates(data); len(states) < 2 || states[len(states)-1] != TxStateForked {
+		t.Fatalf("orphaned transaction %s is not notified: %v", txid, states)
+	}
+	if h, hash, _ := bs.GetLocalBlockHead(); h != node.Height() || hash != node.BlockHash(h) {
+		t.Fatalf("local head = %d %s, want %d %s", h, hash, node.Height(), node.BlockHash(node.Height()))
+	}
+}
+
+func TestSimNode_BlockScanner_ForkRestart(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	node.Mine(2)
+
+	bs, _ := newSimBlockScanner(wm, wallet)
+	bs.IsScanMemPool = false
+	bs.ScanBlockTask()
+
+	txid, _ := node.Fund(addr, 100000000)
+	node.Mine(1)
+	bs.ScanBlockTask()
+	orphanHash := node.BlockHash(3)
+
+	//重启后仍然通知停机前提取的交易被分叉
+	restarted, observer := restartSimBlockScanner(bs, wallet)
+	if err := node.Reorg(1); err != nil {
+		t.Fatalf("Reorg unexpected error: %v", err)
+	}
+	restarted.ScanBlockTask()
+
+	if forks := observer.waitForkHeaders(1); forks[3] != orphanHash {
+		t.Fatalf("fork notification on height 3 = %q, want %s", forks[3], orphanHash)
+	}
+	data := observer.extractData(txid)
+	if len(data) == 0 {
+		t.Fatalf("orphaned transaction %s is not notified after restart", txid)
+	}
+	forked := data[0].Transaction
+	if forked.GetExtParam().Get(ExtParamTxState).String() != TxStateForked || forked.BlockHash != orphanHash {
+		t.Fatalf("unexpected orphaned transaction: %+v", forked)
+	}
+}
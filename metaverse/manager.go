@@ -16,6 +16,7 @@
 package metaverse
 
 import (
+	"context"
 	"fmt"
 	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
 	"github.com/blocktree/openwallet/v2/log"
@@ -33,32 +34,59 @@ type WalletManager struct {
 	Log             *log.OWLogger                   //日志工具
 	Blockscanner    openwallet.BlockScanner         //区块扫描器
 	ContractDecoder openwallet.SmartContractDecoder //智能合约解析器
+
+	dids *didCache //DID解析结果缓存
 }
 
 func NewWalletManager() *WalletManager {
 	wm := WalletManager{}
 	wm.Config = NewConfig(Symbol)
 	wm.Decoder = NewAddressDecoder(&wm)
-	wm.DecoderV2 = &metaverse_addrdec.Default
+	wm.DecoderV2 = metaverse_addrdec.NewAddressDecoderV2(wm.Config.networkParams(), wm.Config.IsTestNet)
 	wm.Log = log.NewOWLogger(wm.Symbol())
 	wm.Blockscanner = NewETPBlockScanner(&wm)
 	wm.TxDecoder = NewTransactionDecoder(&wm)
 	wm.ContractDecoder = NewContractDecoder(&wm)
+	wm.dids = newDIDCache()
 	return &wm
 }
 
-func (wm *WalletManager) GetInfo() (*gjson.Result, error) {
+//GetInfo 获取节点信息
+func (wm *WalletManager) GetInfo(ctx context.Context) (*NodeInfo, *openwallet.Error) {
 
-	result, err := wm.WalletClient.Call("getinfo", nil)
+	result, err := wm.WalletClient.Call(ctx, "getinfo", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	return NewNodeInfo(result), nil
+}
+
+//CheckNode 检查节点的网络与isTestNet一致，版本不低于MinNodeVersion，且创世区块与网络配置一致
+func (wm *WalletManager) CheckNode(ctx context.Context) (*NodeInfo, *openwallet.Error) {
+
+	info, err := wm.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsTestNet != wm.Config.IsTestNet {
+		return info, openwallet.Errorf(ErrNodeIncompatible, "node network is %s, but isTestNet = %v", info.Network(), wm.Config.IsTestNet)
+	}
+
+	if len(wm.Config.MinNodeVersion) > 0 && compareVersion(info.WalletVersion, wm.Config.MinNodeVersion) < 0 {
+		return info, openwallet.Errorf(ErrNodeIncompatible, "node version %s is not supported, minimum version is %s", info.WalletVersion, wm.Config.MinNodeVersion)
+	}
+
+	if err := wm.VerifyGenesis(ctx); err != nil {
+		return info, err
+	}
+
+	return info, nil
 }
 
 //GetBlockHeight 获取区块链高度
-func (wm *WalletManager) GetBlockHeader(height ...uint64) (*openwallet.BlockHeader, *openwallet.Error) {
+func (wm *WalletManager) GetBlockHeader(ctx context.Context, height ...uint64) (*openwallet.BlockHeader, *openwallet.Error) {
 
 	var request []interface{}
 
@@ -66,7 +94,7 @@ func (wm *WalletManager) GetBlockHeader(height ...uint64) (*openwallet.BlockHead
 		request = append(request, map[string]interface{}{"height": height[0]})
 	}
 
-	result, err := wm.WalletClient.Call("getblockheader", request)
+	result, err := wm.WalletClient.Call(ctx, "getblockheader", request)
 	if err != nil {
 		return nil, err
 	}
@@ -86,13 +114,13 @@ func (wm *WalletManager) GetBlockHeader(height ...uint64) (*openwallet.BlockHead
 }
 
 //GetBlockByHeight 获取区块数据
-func (wm *WalletManager) GetBlockByHeight(height uint64) (*Block, *openwallet.Error) {
+func (wm *WalletManager) GetBlockByHeight(ctx context.Context, height uint64) (*Block, *openwallet.Error) {
 
 	request := []interface{}{
 		height,
 	}
 
-	result, err := wm.WalletClient.Call("getblock", request)
+	result, err := wm.WalletClient.Call(ctx, "getblock", request)
 	if err != nil {
 		return nil, err
 	}
@@ -101,13 +129,13 @@ func (wm *WalletManager) GetBlockByHeight(height uint64) (*Block, *openwallet.Er
 }
 
 //GetTransaction 获取交易单
-func (wm *WalletManager) GetTransaction(txid string) (*Transaction, *openwallet.Error) {
+func (wm *WalletManager) GetTransaction(ctx context.Context, txid string) (*Transaction, *openwallet.Error) {
 
 	request := []interface{}{
 		txid,
 	}
 
-	result, err := wm.WalletClient.Call("gettx", request)
+	result, err := wm.WalletClient.Call(ctx, "gettx", request)
 	if err != nil {
 		return nil, err
 	}
@@ -115,13 +143,65 @@ func (wm *WalletManager) GetTransaction(txid string) (*Transaction, *openwallet.
 	return wm.NewTransaction(result), nil
 }
 
+//GetTransactions 批量获取交易单，返回获取成功的交易单和每笔获取失败的错误，只有请求本身失败时才返回错误
+func (wm *WalletManager) GetTransactions(ctx context.Context, txids ...string) (map[string]*Transaction, map[string]*openwallet.Error, *openwallet.Error) {
+
+	requests := make([]*BatchRequest, 0, len(txids))
+	for _, txid := range txids {
+		requests = append(requests, NewBatchRequest("gettx", txid))
+	}
+
+	results, err := wm.WalletClient.CallBatch(ctx, requests)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txs := make(map[string]*Transaction)
+	failed := make(map[string]*openwallet.Error)
+	for i, r := range results {
+		if r.Err != nil {
+			failed[txids[i]] = r.Err
+			continue
+		}
+		txs[txids[i]] = wm.NewTransaction(r.Result)
+	}
+
+	return txs, failed, nil
+}
+
+//GetMemoryPool 获取交易池中等待打包的交易单
+func (wm *WalletManager) GetMemoryPool(ctx context.Context) ([]*Transaction, *openwallet.Error) {
+
+	request := []interface{}{
+		map[string]interface{}{"json": true},
+	}
+
+	result, err := wm.WalletClient.Call(ctx, "getmemorypool", request)
+	if err != nil {
+		return nil, err
+	}
+
+	//旧版本节点直接返回交易数组
+	list := result.Get("transactions")
+	if result.IsArray() {
+		list = *result
+	}
+
+	txs := make([]*Transaction, 0)
+	for _, obj := range list.Array() {
+		txs = append(txs, wm.NewTransaction(&obj))
+	}
+
+	return txs, nil
+}
+
 // GetAddressETP
-func (wm *WalletManager) GetAddressETP(address string) (*ETPBalance, *openwallet.Error) {
+func (wm *WalletManager) GetAddressETP(ctx context.Context, address string) (*ETPBalance, *openwallet.Error) {
 	request := []interface{}{
 		address,
 	}
 
-	result, err := wm.WalletClient.Call("getaddressetp", request)
+	result, err := wm.WalletClient.Call(ctx, "getaddressetp", request)
 	if err != nil {
 		return nil, err
 	}
@@ -130,13 +210,13 @@ func (wm *WalletManager) GetAddressETP(address string) (*ETPBalance, *openwallet
 }
 
 // GetAddressAsset
-func (wm *WalletManager) GetAddressAsset(address, symbol string) (*TokenBalance, *openwallet.Error) {
+func (wm *WalletManager) GetAddressAsset(ctx context.Context, address, symbol string) (*TokenBalance, *openwallet.Error) {
 	request := []interface{}{
 		address,
 		map[string]string{"symbol": symbol},
 	}
 
-	result, err := wm.WalletClient.Call("getaddressasset", request)
+	result, err := wm.WalletClient.Call(ctx, "getaddressasset", request)
 
 	tokenBalance := &TokenBalance{
 		Address:        address,
@@ -161,8 +241,67 @@ func (wm *WalletManager) GetAddressAsset(address, symbol string) (*TokenBalance,
 	return tokenBalance, nil
 }
 
-// CreateRawTx
-func (wm *WalletManager) CreateRawTx(sender []string, receivers map[string]string, change, fees, symbol string, isToken bool) (string, *openwallet.Error) {
+//GetAddressETPs 批量获取地址的ETP余额，任一地址查询失败时返回第一个错误，避免把查询失败当作余额为0
+func (wm *WalletManager) GetAddressETPs(ctx context.Context, address ...string) (map[string]*ETPBalance, *openwallet.Error) {
+
+	requests := make([]*BatchRequest, 0, len(address))
+	for _, addr := range address {
+		requests = append(requests, NewBatchRequest("getaddressetp", addr))
+	}
+
+	results, err := wm.WalletClient.CallBatch(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]*ETPBalance)
+	for i, r := range results {
+		if r.Err != nil {
+			return nil, openwallet.Errorf(r.Err.Code(), "get address %s balance failed: %v", address[i], r.Err)
+		}
+		balances[address[i]] = NewETPBalance(r.Result)
+	}
+
+	return balances, nil
+}
+
+//GetAddressAssets 批量获取地址的资产余额，查询失败的地址余额为0
+func (wm *WalletManager) GetAddressAssets(ctx context.Context, symbol string, address ...string) (map[string]*TokenBalance, *openwallet.Error) {
+
+	requests := make([]*BatchRequest, 0, len(address))
+	for _, addr := range address {
+		requests = append(requests, NewBatchRequest("getaddressasset", addr, map[string]string{"symbol": symbol}))
+	}
+
+	results, err := wm.WalletClient.CallBatch(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]*TokenBalance)
+	for i, r := range results {
+		tokenBalance := &TokenBalance{
+			Address:        address[i],
+			Decimals:       0,
+			Symbol:         symbol,
+			Quantity:       "0",
+			Status:         "",
+			LockedQuantity: "0",
+		}
+		if r.Err == nil && r.Result.IsArray() {
+			for _, obj := range r.Result.Array() {
+				tokenBalance = NewTokenBalance(&obj)
+				break
+			}
+		}
+		balances[address[i]] = tokenBalance
+	}
+
+	return balances, nil
+}
+
+// CreateRawTx message不为空时节点增加一个消息附件的输出
+func (wm *WalletManager) CreateRawTx(ctx context.Context, sender []string, receivers map[string]string, change, fees, symbol string, isToken bool, message string) (string, *openwallet.Error) {
 
 	request := map[string]interface{}{
 		"senders": sender,
@@ -193,7 +332,11 @@ func (wm *WalletManager) CreateRawTx(sender []string, receivers map[string]strin
 		request["type"] = 0
 	}
 
-	result, err := wm.WalletClient.Call("createrawtx", []interface{}{request})
+	if len(message) > 0 {
+		request["message"] = message
+	}
+
+	result, err := wm.WalletClient.Call(ctx, "createrawtx", []interface{}{request})
 	if err != nil {
 		return "", err
 	}
@@ -204,13 +347,13 @@ func (wm *WalletManager) CreateRawTx(sender []string, receivers map[string]strin
 }
 
 // DecodeRawTx
-func (wm *WalletManager) DecodeRawTx(rawHex string) (*Transaction, *openwallet.Error) {
+func (wm *WalletManager) DecodeRawTx(ctx context.Context, rawHex string) (*Transaction, *openwallet.Error) {
 
 	request := []interface{}{
 		rawHex,
 	}
 
-	result, err := wm.WalletClient.Call("decoderawtx", request)
+	result, err := wm.WalletClient.Call(ctx, "decoderawtx", request)
 	if err != nil {
 		return nil, err
 	}
@@ -218,7 +361,7 @@ func (wm *WalletManager) DecodeRawTx(rawHex string) (*Transaction, *openwallet.E
 	tx := wm.NewTransaction(result)
 	tx.RawHex = rawHex
 
-	err = wm.FillInputFields(tx)
+	err = wm.FillInputFields(ctx, tx)
 	if err != nil {
 		return nil, err
 	}
@@ -227,13 +370,22 @@ func (wm *WalletManager) DecodeRawTx(rawHex string) (*Transaction, *openwallet.E
 }
 
 // SendRawTx
-func (wm *WalletManager) SendRawTx(rawHex string) (string, *openwallet.Error) {
+func (wm *WalletManager) SendRawTx(ctx context.Context, rawHex string) (string, *openwallet.Error) {
 
 	request := []interface{}{
 		rawHex,
 	}
 
-	result, err := wm.WalletClient.Call("sendrawtx", request)
+	var (
+		result *gjson.Result
+		err    *openwallet.Error
+	)
+
+	if wm.Config.BroadcastToAll {
+		result, err = wm.WalletClient.CallAll(ctx, "sendrawtx", request)
+	} else {
+		result, err = wm.WalletClient.Call(ctx, "sendrawtx", request)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -243,31 +395,67 @@ func (wm *WalletManager) SendRawTx(rawHex string) (string, *openwallet.Error) {
 	return txid, nil
 }
 
-// FillInputFields
-func (wm *WalletManager) FillInputFields(tx *Transaction) *openwallet.Error {
-	for _, input := range tx.Vins {
+// FillInputFields 从上一笔交易的输出填充输入信息，所有交易的上一笔交易单合并为一次批量请求。
+// 部分上一笔交易获取失败时仍填充其他输入，返回第一笔失败的错误，失败的输入在下次调用时重新获取
+func (wm *WalletManager) FillInputFields(ctx context.Context, txs ...*Transaction) *openwallet.Error {
 
-		if input.isCoinbase {
-			break
+	txids := make([]string, 0)
+	exist := make(map[string]bool)
+	for _, tx := range txs {
+		for _, input := range tx.Vins {
+			if input.isCoinbase {
+				break
+			}
+			if input.filled || exist[input.TxID] {
+				continue
+			}
+			exist[input.TxID] = true
+			txids = append(txids, input.TxID)
 		}
+	}
+
+	if len(txids) == 0 {
+		return nil
+	}
 
-		intxid := input.TxID
-		vout := input.Vout
-		preTx, txErr := wm.GetTransaction(intxid)
-		if txErr != nil {
-			return txErr
-		} else {
+	preTxs, failed, txErr := wm.GetTransactions(ctx, txids...)
+	if txErr != nil {
+		return txErr
+	}
+
+	for _, tx := range txs {
+		for _, input := range tx.Vins {
+
+			if input.isCoinbase {
+				break
+			}
+
+			preTx, ok := preTxs[input.TxID]
+			if !ok {
+				continue
+			}
+
+			vout := input.Vout
 			preVouts := preTx.Vouts
 			if len(preVouts) > int(vout) {
 				preOut := preVouts[vout]
 				input.Addr = preOut.Addr
 				input.Value = preOut.Value
-				input.AssetAttachment = preOut.AssetAttachment
+				input.Asset = preOut.Asset()
+				input.AssetMIT = preOut.AssetMIT
 				input.IsToken = preOut.IsToken
 				input.LockScript = preOut.LockScript
+				input.Script = preOut.Script
+				input.filled = true
 			}
 		}
 	}
 
+	for _, txid := range txids {
+		if failErr, ok := failed[txid]; ok {
+			return openwallet.Errorf(failErr.Code(), "previous transaction %s: %v", txid, failErr)
+		}
+	}
+
 	return nil
 }
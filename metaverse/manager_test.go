@@ -16,6 +16,7 @@
 package metaverse
 
 import (
+	"context"
 	"github.com/astaxie/beego/config"
 	"github.com/blocktree/go-owcdrivers/mateverseTransaction"
 	"github.com/blocktree/openwallet/v2/log"
@@ -27,6 +28,9 @@ var (
 	tw *WalletManager
 )
 
+//testAssetTransferRawHex 主网的一笔DNA资产转账交易单
+const testAssetTransferRawHex = "04000000029358bd3c7d4e99c6c6f81c27297ed1f0a38a080fb7090b80cfbb06903da64c890100000000ffffffff1e103ef2915af1dc50f701e79ccd35f04271155413fb4f081a74260358839b350100000000ffffffff0280969800000000001976a9144b434fa2be94c828419e708f848e3eca3e911aa988ac0100000000000000c08bec08000000001976a914e607f73ea755a41b4b649114a9bed5dba1ca8da088ac010000000000000000000000"
+
 func init() {
 
 	tw = testNewWalletManager()
@@ -46,13 +50,22 @@ func testNewWalletManager() *WalletManager {
 	return wm
 }
 
+//testRequireNode 没有配置conf/ETP.ini时跳过需要真实节点的测试
+func testRequireNode(t *testing.T) {
+	if tw == nil {
+		t.Skip("conf/ETP.ini is not found, skip tests against a live node")
+	}
+}
+
 func TestWalletManager_GetInfo(t *testing.T) {
-	tw.GetInfo()
+	testRequireNode(t)
+	tw.GetInfo(context.Background())
 }
 
 func TestWalletManager_GetBlockHeader(t *testing.T) {
+	testRequireNode(t)
 	//height := GetLocalBlockHeight()
-	header, err := tw.GetBlockHeader()
+	header, err := tw.GetBlockHeader(context.Background())
 	if err != nil {
 		t.Errorf("GetBlockHeader failed unexpected error: %v\n", err)
 		return
@@ -61,7 +74,8 @@ func TestWalletManager_GetBlockHeader(t *testing.T) {
 }
 
 func TestWalletManager_GetBlockByHeight(t *testing.T) {
-	block, err := tw.GetBlockByHeight(3584831)
+	testRequireNode(t)
+	block, err := tw.GetBlockByHeight(context.Background(), 3584831)
 
 	if err != nil {
 		t.Errorf("GetBlockByHeight failed unexpected error: %v\n", err)
@@ -94,7 +108,7 @@ func TestWalletManager_GetBlockByHeight(t *testing.T) {
 			t.Logf("Value[%d] = %v \n", i, out.Value)
 			t.Logf("Value[%d] = %v \n", i, out.Type)
 			t.Logf("Value[%d] = %v \n", i, out.IsToken)
-			t.Logf("Value[%d] = %v \n", i, out.AssetAttachment)
+			t.Logf("Value[%d] = %v \n", i, out.Asset())
 		}
 
 	}
@@ -102,8 +116,9 @@ func TestWalletManager_GetBlockByHeight(t *testing.T) {
 }
 
 func TestWalletManager_GetTransaction(t *testing.T) {
+	testRequireNode(t)
 
-	tx, err := tw.GetTransaction("2b8e090dc8a12df7bd44a23bc797a63efb727f560f86ddf7d5de80336a115b20")
+	tx, err := tw.GetTransaction(context.Background(), "2b8e090dc8a12df7bd44a23bc797a63efb727f560f86ddf7d5de80336a115b20")
 
 	if err != nil {
 		t.Errorf("GetTransaction failed unexpected error: %v\n", err)
@@ -130,12 +145,13 @@ func TestWalletManager_GetTransaction(t *testing.T) {
 		t.Logf("Value[%d] = %v \n", i, out.Value)
 		t.Logf("Value[%d] = %v \n", i, out.Type)
 		t.Logf("Value[%d] = %v \n", i, out.IsToken)
-		t.Logf("Value[%d] = %v \n", i, out.AssetAttachment)
+		t.Logf("Value[%d] = %v \n", i, out.Asset())
 	}
 }
 
 func TestWalletManager_GetAddressETP(t *testing.T) {
-	balance, err := tw.GetAddressETP("MUsTC2PCF52yNvAeGNXJUKy9CfLVHV9yYj")
+	testRequireNode(t)
+	balance, err := tw.GetAddressETP(context.Background(), "MUsTC2PCF52yNvAeGNXJUKy9CfLVHV9yYj")
 
 	if err != nil {
 		t.Errorf("GetAddressETP failed unexpected error: %v\n", err)
@@ -145,7 +161,8 @@ func TestWalletManager_GetAddressETP(t *testing.T) {
 }
 
 func TestWalletManager_GetAddressAsset(t *testing.T) {
-	balance, err := tw.GetAddressAsset("33434", "DNA")
+	testRequireNode(t)
+	balance, err := tw.GetAddressAsset(context.Background(), "33434", "DNA")
 
 	if err != nil {
 		t.Errorf("GetAddressAsset failed unexpected error: %v\n", err)
@@ -155,6 +172,7 @@ func TestWalletManager_GetAddressAsset(t *testing.T) {
 }
 
 func TestWalletManager_CreateRawTx(t *testing.T) {
+	testRequireNode(t)
 	sender := "MNbuDxFiLY349YgDxpoe7uEWwYnyNKQHEP"
 	feeSupport := "MNbuDxFiLY349YgDxpoe7uEWwYnyNKQHEP"
 	//receiver := "MHr2w1nQ2aiGuh7McpAvi5TMvqmzVLJeNC"
@@ -165,7 +183,7 @@ func TestWalletManager_CreateRawTx(t *testing.T) {
 	}
 
 	fees := tw.Config.MinFees.Shift(tw.Decimal()).String()
-	rawHex, err := tw.CreateRawTx([]string{sender, feeSupport}, receivers, feeSupport, fees, "DNA", true)
+	rawHex, err := tw.CreateRawTx(context.Background(), []string{sender, feeSupport}, receivers, feeSupport, fees, "DNA", true, "")
 	if err != nil {
 		t.Errorf("CreateRawTx failed unexpected error: %v\n", err)
 		return
@@ -174,9 +192,10 @@ func TestWalletManager_CreateRawTx(t *testing.T) {
 }
 
 func TestWalletManager_DecodeRawTx2(t *testing.T) {
+	testRequireNode(t)
 	rawHex := "04000000fdb301e2f4bab40151dc4e078b78e7a5e81be15f09487d44d0c605b4b76da299d3336a0000000000ffffffff9e61415e9b3513f5fb9e8a6acd18535b983405b2579a1c1f4db87c7fb8287c090000000000ffffffff6fd1923386c4f388e238631e9867b58b2109fd97050cbc98b936c85be17ed3f10000000000ffffffffa6f903d9d0741bc00ebd89b814021a75b2dcd99c0c681b7ec30eb6b4f320a6f00000000000ffffffff6833ad92edfc8073e5d4186c0fcf35694c69397803b176408922ea1ebd14e56e0000000000ffffffff12aad62a49b611f76cf8a0ed03abebbbd4f1393c3eaeb9de5c58b85a15221bf70000000000ffffffff90fee37b4f0f8812e63d5f1e01babab21770955bfa3b73d3b1a683a2bd0546a20000000000ffffffff5c406020c5d2f82efe2e4c836ef1c252051633e968691e53aad352a6c3aaf8cb0000000000ffffffff43f10db70b608756679ecc214900fbd1cf6f936ce5db8b8655bd5a8a885580140000000000ffffffff27c48b57c75b6d0e9f5434284673331bc0855f2e7891c49ebf918e8d4e4bc86c0000000000ffffffff10e5521825d0ab08ecdbf7f895ab5ec45e5299df13bacbba8b2152b917beb2cc0000000000ffffffffbad18fb562df572fca90ce9c909b2c49bc5f5262e210f168a2286d1f6a9f86be0000000000ffffffff7365b8c127a37bcdf3839c1405aa62de8941fd5fc664ae3725813d5eaa9842e20000000000ffffffffc260a71b4a160c4508ca8ff7de04fc501f5d1a4ce375313abda10b007062ed3a0000000000ffffffff8ccf841e767cdc005d0bb227af1eec8229272ddb6d1fe76cb1f42e7c50c47a000000000000ffffffffd1f41a0bc19b4f843c376aaae42b899d751146169483f685971ba2170e3fb2740000000000ffffffff487e2120e52bdc680b37af506378951bebb24bb2ed7af455adc426a9018a1b880000000000ffffffff2959998c53ff899be03dac445bd4a196145c35c6e258cf7b22828f6f3834b62b0000000000ffffffff59914b22f6c16444a9442291b7ba065cc36c70a29fca6adee44314de0f3dee120000000000ffffffffcc03384970df345c50602e7593f74eaf86d05b580f98b9c38bc830f7833d22180200000000ffffffffcc03384970df345c50602e7593f74eaf86d05b580f98b9c38bc830f7833d22180100000000ffffffff398e4665ba77004571441ae2ed4c692362d206c1dcd310072870ff3f7fda3c620200000000ffffffff01866f4d88c6e77fc21d9d604d536f60f57061100e8796495cfd74c75f6e9a1d0000000000ffffffffc46fb6cbb4eb85813392d86d8ebe5d43027e12aed66d4648d56c39d3b0fb87b20000000000ffffffff7c0e3176362760be55606e9ce6e7957863f2f67c48d865aeefd31d1db694e44f0000000000ffffffff7bbf3773d359c1a10148f0021e67860326aa6c785ae3074c4c5643fe2d73d8a00000000000ffffffffcae70421b5804934c4e65d2d667f7e1bc0e6a0e4ddda230cef6aff8a9c4a0e870000000000ffffffff20382763d0943ea37da73afa234aedda13b863b15d68cf5fff37cbc5fca4c17c0000000000ffffffffdb215f927ddb61ac83980d97e5d937b36889fbd873818931cc6df9940a47944d0200000000ffffffff08a067f89ccf6ed8cea1ed13bf5f8ae06c543a71f6bbbb618a959d9ca29311a80200000000ffffffff04d6fddb5dc817bc27e2ad7d5fe3daa5180b72acf17679a01ec2cfc0710cd5070000000000ffffffffac9acad2dfd12e542640f3a65c3c8230cc610ae7fb10d027656b2767ae5ee5f10000000000ffffffffc532afea8cd94eed161282c9cce5a5dbf40f42ca198804c4dcff1a953816da7a0000000000ffffffff33d8f36fe8f841b3f1adc305151801891f300761bcb00ce758dee95147a245590000000000ffffffff08f2d400192a749adf9d164faa1cc602b6dbab03a67f2b9bf76f04d5597992b80000000000ffffffffc1b100664f0977bb7b526fd679ecc4f18565d39c92e62b4db593ebe719949f490000000000ffffffffdec653491b404236ba93d891ad54e650610f04ee9a138e6c34ea00cdc6ee0aa10000000000ffffffffdf4f9868b13742b80acbb1d05aa49a45e56696c27a9e5de3f4157af8ad6b3f3a0200000000ffffffffc68a491d4be083cab07c540045f2a80a01f93b9ab083488c71c4fff424057c0b0200000000ffffffff4b3821b3b82eadfe2e58079f54785f1806905cd97d1f4432afdd4d4674e1ac7b0200000000ffffffff28bb2ae7ffe1264003150af57201bfa3c447ce1cfd45e9f4936fed8cf9412c4d0000000000ffffffff5f1d3e38d8c16eb22340da21406f3a858b6c249f9fa0d791dc5c4f21737ff8cd0000000000ffffffff305569fba1aa5ac038a1bf173d1fd385f7210ca6105a687338ad98a8ad914ded0000000000ffffffff1b80a97af5b8c6686deee7db9ecb65f9fc61e796f9ea9e204497a8a7562ccba00000000000ffffffff9f0daa644ce983d6c3c4c19543367208f439af8e9fa11a37883b28a8822a16bb0000000000ffffffff68ee625c2810afab7f5f3d79e6017f4fae047632bd36be35035ed22a6e12cc850000000000ffffffffbea5ce5fb59f7ac6c2371ca74d9784306468b5c0e67124f9e4d229e54a4d4d890000000000ffffffffd6ee2df789ba69edc7ea999de4ab230f6ae2b6a076fc853562aec2479bba168c0000000000ffffffff14e6486cae8488e5bac856696d6a56ede3f788fbb482b4aa7642658e039ff6480000000000ffffffff83b8a2c8a94d0ddac5a694916a51e6bf4bb50554ed9e0569e5f044b056f4845b0200000000ffffffff3e665506c2a575f2abecc1b7ba729ab58a138d74b152e0dccf4fe92a22cbc9890000000000ffffffff8bbd1791bc23b65f963d24a80f1bf35d57f86bd0caae95c1281297be0cf41ff90000000000ffffffff219014201a8c86de38ded0f973079a403195a780ea7cce26d3bd79722310b1dd0200000000ffffffffb1235229d252e0c6b914a6d72cff731a9dd7f4cf1037631371c6f8647730d45e0000000000ffffffffc0e76779faf9bdf7281871338dee1b68ecde3a3408137b5caf5fbdd63a2b03ad0000000000ffffffff83601aefb627e73a72aa487483e56818ba9ce0de244b65497fb1edd3b45ade870000000000ffffffffd7033466f82a5198a2c50404fb858531f052e31e7996fbee2fbf2eeb47516ebe0200000000ffffffffd6f955681f447960e3923709ec05a4958105f4e69e7b7cde5cda99303ab710c20000000000ffffffff155cde01c49e66e9032aabbed58f431e31668292b90414b7576ac8472c067f880000000000ffffffff077a38ec3c4eb7975c15ac6550051bcf1e0d0364b3ada87eda4a12ca86c9b5c70000000000ffffffff1254a7bfb02f7b91c7f6220f089469613b84307d2caf055dc5f6e74d197bcc990000000000ffffffff0e1416d4b7ef8f53b94e669200f8590d02fd5187bec0c184ab3ce1f89976b2c50000000000ffffffff49536c90ede727239cf7319c857ce1e40e836b7ee77baef751088a228bba95660000000000ffffffffda5c3777c11779eeca1c3e0a6ae7c7e811788a6597c298e40f682d5763ad471e0000000000ffffffff501dc85c72469f10b7d4f2ff09199660c0247ab1f4f28ffd2fce64d6e3dbb2020200000000ffffffff9e0992e3f573f29e1e7dc985a20ae1d47dbc0c8c0218ff2323722a4007bec8d60200000000ffffffffa4cb162b4da987e56d77e445f7b0234454eb2071704f239e5ea98437948aea6d0000000000ffffffff10b70065e6d3c9a4266df53095dc100b5c6745318c0e18fccf757674120b146d0000000000ffffffff1584a57384a4c3d9fa4fdcbf3f13906557f708cbbbdbc4f42704d916c42ab6200000000000ffffffff19e2ba0bb385c4e9bd52932202b06162d1864fccb403a7d5ba1b7fee20b619560200000000ffffffff9c936fc07a4646e7e17f89aa04ef263d7012d66c22b6b9440f9811cd8777a3ee0000000000ffffffff3607c5c73f67bc73df41b4df73a52791884d22424e23a7dd43d61896601b5b470000000000ffffffff08796e71da9fe40697dcb5805a54c26615020584222d99731b8b7d925761df590000000000ffffffffcf87e6e61586b069a80ea101a66aea029170a82775e7a84ec59beb009355bdcb0200000000ffffffff322161a4dbbe1d2b31c0ba2d49ab0418d52060041cb013e12225a302ed8a641b0000000000ffffffff8783c119bfce6d48db7835ac3831017a0dbcbf226edab7112479207aa8c53a4d0000000000ffffffff211920c28455c6147230e052f72a658476c114dac7c81b85a790da1832f75d5e0000000000ffffffff46ba9f75fbbfd5da0eee04cf1af294107a5c26be94c1c4f657fc74de7370cae40200000000ffffffff9534b13823204d256a38864ff44bbe96aa6a0bc578bca0e397ed144db77460330200000000ffffffff4aee9f622d053cd99ff6689d49ddb1cfcaa394b98f3e0ffbf80b1d6f8b00ebe50000000000ffffffff5c30115f666d8b78e03fed7ecbce97aba80a22974ca5f89cb12fd97ccf0525660000000000fffffffff2fde752544ea135faa511669ed8ebb140360d5fd25615f74f42f6d9d03f46c50000000000ffffffff9f8ff5e72e84ffe09aa14da0868d4c6adf3bd37d96616b643520e68daee1c6680000000000ffffffffeec22862253729313f2ee3c768d9f77909fbadc2b512a34a4edc04c601a84b730000000000ffffffff18316e5b8e46a717f6126446c630070326882615da6f739f2ece19e2e8949c610000000000ffffffff8c55c3aa185f1f24ae191d1850b7b18e23ed3c3abf4291653cd86078fa34d0b60000000000ffffffff39b107f0a2d1252750c8775ce1d87aad85e9994b0031ece078bc1a73a7db22920000000000ffffffff75c2c647f8bcfd7bce7ea2f3643acb77676dab9740508876a9b9aa82f5ce7fea0000000000ffffffff2d85efc14a02b6bb42a6872c362e790e1e473cd84aedfa9cbcf41535577d64ad0000000000ffffffff4a75c044d855a97705b55f0b385e6168864f51f697257e373b1284f6c8183f720000000000ffffffff08f2c4034e801765d6cceda445b89d6ec54a9e560bfd705aad5d238afcccd3ab0000000000ffffffffd4cc1893d493016c772c36f19c1711bd4c711b670794202de4b3839ec29a4c230000000000ffffffff47838cd5d483d53a62e0963d400c032bd0e35ffd701fa24d8b42e3066b64a28c0000000000ffffffffe1092df37be995638e6f39d0f2e01a679ad54844cbfb60383d0d5de5cf33cf170000000000ffffffffc7a7ed6e85871e67a477ad7f702756c163217d48fff8d7b11b9ccb98bfaa500c0000000000ffffffffc1c79db585c1a702ca47faedad3209e054a263513bbe9a2fe8ee4eb1b259696a0000000000ffffffff13ca169ab6db5824e10f42a675e7cbf1bd7e79138a5d64e6c0ac7d773d8f05140000000000ffffffff79a0c07e023d54727c7f99a4bdad67dd525f2812d53e5a8c9bf8971ddda994290000000000ffffffffa4674cf9d83770e34c2527f44489f3a4486d14703428ce6ad4ccdb76250550400000000000ffffffff0c629d7dccbbb566ff8d2308d6f36a33f9df06bb2347762c4c61b28a457b00a70000000000ffffffff382b756a9d9cf93a248da94e97bc7d5ed7d35d376cb2295ec7539251762bb9810000000000ffffffff421080a3632470d9366d55f72fa1dc326e611c6380f9b12d4ff0229d769865530000000000fffffffff486b56ce51e3c832c90157f6804de52cdde1ac7fc013e2ab4f6ce750767b6ed0000000000ffffffffbbc0a95f6a0e7f571226773e3726875efe2796569174e8d6ef3ef904bd889cb20000000000ffffffff02c0a57c4d37742a47e458f6b4b64945803eef0e5d70d703752b57cc062f4ccd0000000000ffffffff39279b90491f706a78a897f5be164063945db57dd9816e5e73b986558ec1f32b0000000000ffffffff844c302a43b369105cb0d93d58d3602b4f8a861bce0ac0f7bb9c6577095d33980000000000ffffffff6410f837016b13931cd0ca18fd21c03de604d020636d7b0ad5de5f0ec7c169130000000000ffffffff626be40019fc0b61aae658187ca92b39cdcd63e18a7e139689b886680631993b0000000000ffffffff510a42c640ff87b520a39fd09ca648f5e525accfa3904b06f90ce7beafb6781d0000000000ffffffff103f476762a8a66d7958ce39db6bf3a137078c862f985cf3807646d01c181bb40000000000ffffffff22d31ccb5bd01eace3645ca2f453d765e2595ddf03513203c6a712f64e52e6460000000000ffffffff6821af8d1429686a441130dedc7a7bcdef2c14ea7a96cfb4d07d12ded658dedf0000000000ffffffffa04f263f5344ec981eeea13233cda7fb0049c28cfedc83426f9e68cc141600c10000000000ffffffff242b5fcea6ab3be1244b3dad6cdc39ded037a77c5d4f8e7415b113bf75d9af8d0000000000ffffffffc824bc441ba2644d8b1de4b52b2486700ee92b5b2d6bf20f64b8d74cdd1bf1cc0000000000fffffffffe3e2fd291e1e68b19d4a9af4040766fa6dd7bdf3e49fc041aa23530f95173f90000000000ffffffff2e0d298744229d2ca699e1b33e3b2420b0597795bf23a50c6e84d30dd9ee488e0000000000ffffffff542f0d4d5ec7007d98cb1918834759cf14a3bb7bb40b5e53087379d9ab6986120000000000ffffffffc6e39a7a88ccb0c4789cd9c53955cfeb703633ded395b82777b501476d1c29860000000000ffffffff7becdc8a99e626e96279dc587a5c064c9e1d164032c8451ec91faca105c47dee0000000000ffffffff2f88d1e9898c9098c24600b9f1179165d6ed4fe8a7bf7bfd1693483a396c41050000000000ffffffff4927ded50e2caf6b042bea05e64a3cc05c1fd795779add413fa3e2af6aa395100000000000ffffffff7d5ac771dc0ce4a87d88581ea26006dc96ebd4ea14bc352fcad9ecd96f2e288e0000000000ffffffff5f96b21f4c7aa5b7861945f4c108d852a8b9f176b45c395e91124e6434efb98e0000000000ffffffffc30324b90cd49883bca02b33e7fa4db4476fe7ccf763a8fc7a6e20afd6f14d5b0000000000ffffffff49352c5d113d6de54523557a834a1e900e7cf6232be577d49d261b37c3d1f1fa0000000000ffffffffcc33ce5261ed3491a5fbf179c5952bb0ffc2b585bff52d9b52baa5b1626736bf0000000000ffffffff3fe2de8d463463300b2a585e52de45263016789fd041dbbaa719414e4ff4cd030000000000ffffffffb4c1f481af4d2dd2dcc5457dc21cfa4c4f085949fe00b294ce0414ead9d285d30000000000ffffffffbd45ecfa8411dff26784ce0918b637ee7b27723d1748ac5a777172e89abbcc880000000000ffffffffb989ab3271afbfa6916611662c826b7711680ae0242ee61f5376886c6846078c0000000000ffffffff1e39ffc8099de17cb939d371f6ce48a4eb9f4c977d64aef1e7cd43fd039a6c110000000000ffffffffb6c5b208b253efb948485f9464912798eb3e10ab81f04b5e229c7c600d3a09b00000000000ffffffff24bb93f64d71fce1ddc1cc78ea3cdd887adad2543a650335ab82d00bbb46a5700000000000ffffffff3837ccd5b4c92a2d5a87ed3b177cbcc6d540bce8dc672081ca959f3d827b53760000000000ffffffffbb2a0ea5cf45ed254f16cd389fc3218baad644e2d0f0fe20b7a55cc6d0bbd14f0000000000ffffffff6235bf031530b46a412655103e9412797a8aa8e8fd8d59ff93da43afc1c0941b0000000000ffffffff5bc5c302c8cc2be0a90131a533486baadf83009a391cd73cc50b1fbed802a9970000000000ffffffffcb0fde2ea7543816ea304904c32c91b8a22a228755b7c3bdb6d36021d343fe320000000000ffffffff59e48bbe0ae5596ef25b907bf45cdba4402471d21d05b80d3dcf5cd512c3f2930000000000ffffffff5662b3c145af66632cafb2847dfcce8fff29c10a87e2be29f384efab4e6443980000000000ffffffff083ba089b6207b19b9b14f9efd3085d2cb6cc3ef938fb586cd2b64172a12b3560000000000ffffffff40dc1be0ef0253ee0b9df3f17fd1846858a004ab7102e2a8eb723922a40964120000000000ffffffff5cf2068005fef3fa114a9380c0e01ce5a5c172e22fbd9cde8a28e3e42c1e00d10000000000ffffffff592ac33f39432a4fbd2f7a0c188c4aa6fcfc324ab987fc398cb95650794393850000000000fffffffffa70b29ec64267f86854b427d34e73ca340d3b0c7796b88fb1c0475e7ea2dd610000000000ffffffff460429db245703d5a80bf528ae6d111fd6efc63bde46fbd7adb5203ec1cd5c6b0000000000ffffffffa98fb0402c6468d78402061d7cf5a6bc50b7841664d975a4486902dfe4b489be0000000000ffffffff14a5e1dcc89385b6221cca9a7d225234d3fecf3be94c9a922b8da48eec0ead720000000000ffffffffeb70a28a59e3d4daea73a5ad5537a06587e367c8a3cfd5a3b7100c972dba99570000000000ffffffffc2d2146880371ee4758b7472db96c0f8fc8b8fcdf1f88c2ba3e95d8ed450656b0000000000ffffffffe23d7223198a6d0bdde1833c5dc792dcb19e0b9d9787e8970ae221c5de2c42090000000000ffffffff0302652a0247744a73d3dbbe084ba4c43c84ce7aec046e2898e016a5f5c642ef0000000000ffffffff18327797b2da9fe868239bc77a9f539e548e6f551f6cd08483d386ec107e37490000000000ffffffffb31db6eb62228125c756ebb84b0289bd06b6ec2059a4ca83406b05e3312561b90000000000ffffffff5922861d5c6d4a19faa21c03df856895889b86b494d9a117fcdaa8ca813be1bd0000000000ffffffff9dae35649f8af8a9580b1aef2e49c9841c59bd427335ebbdbfb0f452c7e4436a0000000000ffffffff92da8cc3446c3519a97d1afd9fe05bdd83ee119dbf35e562d68b8185fff0f6fb0000000000ffffffff5ceb17c4f63e86b76848aaa1290b02546d4c4807cb823e12c7e01269124735f50000000000ffffffff197779c6f51a53efaacc4c8e5be546798c1fa2d6cdf03e31923a46469e9717b90000000000ffffffffde3069c5f58e88549701857e373d04f5a16876145c96f21c7a5beea446b10c010000000000ffffffff387aeec296a3efc0fb3ac623ccd85c071ba15200f9e4546450e0612da89103c30000000000ffffffff3529a47d56f9d6a8df09502d2aed11a724c36e297ff0e1fe1cfa421ad51789120000000000ffffffff683d1aa4402b4c0fc9439b228b72b62a8e04d15c742bb409416937cbc8604d7d0000000000ffffffff185ae1c39d757f507398a2b28de0481a34c1d172a9ae2afd565fd6eaf08432360000000000ffffffff7efd093d7e85055d3f7263e93d04d0f75e39db5f2dea2955d379d8f6bd55698f0000000000ffffffffe0f0e89fbaef38fc64e5380b56cdc2117255622a6e2993341f9ebb7a35a00d310000000000ffffffffd1ea72ebbfe2f4007db25197530db3360890e300d504d7245c4707f7b9496e160000000000ffffffffdc3c718f091f362622f1a7b95d1c52fbd3a34c3c29b4bfc840821bddd11425930000000000ffffffffbc5ba7a0ece31dcde5668dd03eb3bf9d3f7acb02dc7177505f3a1cc51ef1d2660000000000ffffffffbe6b44a7d9ce1a0360c2d62076250775ee780d220d6d8006f740613d9ba29f7d0000000000ffffffff86ad7bacff638a7941a068f5b5efbf06dd88ba1f24a9b971ddef9e119da4d6970000000000fffffffffde614cd9a4445c2df54516b6ca564e14314a33b7f5f1606031ecc9acbe464050000000000ffffffffeee962b547ed9d843596773450a8fed35e07c2f2bf54c73b7d1c09d47d3a26950000000000ffffffff0fee4116a50a8c898666d0b7e2291d9f2e4cfa47b23a5d505762a4f01ca88e6c0000000000ffffffff6ee69b31ddabe9b2558fad687d49f86c68380b1b6b9e5bd7937819d0991effb20000000000ffffffffdcf6d65b854da76135749741ba0778377cee40ce47015bc29aceb98bfc21dccb0000000000ffffffff3cba07058396d2012918089c8768f77b2401e7a7b0bce91c0edd4e60f38745200000000000ffffffffc0966a9e9cea7f9e1be61893234e455e1aefcd4706d51c472c017e0e99e62f2a0000000000ffffffff8797358e618570801223331d4ab6db4bc9825866999df95fda4c296e51db9a920000000000ffffffffc70e79593fc2762d7f8ec99a6f974a03aaa384a27e5ac8867aba88366965eb040000000000ffffffff28dbf53915fe466527634bc0c5deb5541d15e3819af7f82b989a0e6faa5e13670000000000ffffffff9db4fb78742dd5b3e126ab24500b9c618e2e73f1be98b3e0764f91f4ef6746680000000000ffffffffb15e1803f65c0c411ffa4c28bc1eda50dd0d36b7d8801a37095de739dfeed1c80200000000fffffffff4f320105c3f735078b1105390d455efc46924bc0425a37de0b41a6d948c59790000000000ffffffff441400f1cc0b59251f24b7c3f980820f0d5edcce9058e5aa6487832f3f90294d0000000000ffffffff17541bff119b7bdab07621c585356a8a1aeac43f667759504cb1a0391e7294800000000000ffffffffd50a5fe263c97560265d3fc1b0e5bdd2cc6e39f169e07a6687a09e3bf616dc710000000000ffffffffee45b4e4f6c861ebb9c2c43a95f5ef931c3eaf7dbccd97806acf3fd73c8ec6280000000000ffffffff99dd992de7375a9c1a2ca4f592d694d24d6ade29908b4399c1b3491a7e4f73690000000000ffffffff3484c4366dec17c9baa0f32ec2c52f6d6514cf5aed54bfb662902215466cb45f0000000000ffffffffac26e52af452005dc9a49eab02bebe8e99cad274485a8714b8f80beedcc2e3000000000000ffffffff1444c157c20cc1d8880f1b72faa9405d86c3098ca92d1c5900fbb331ce5626640000000000ffffffff7adfde25fcb608abafdd9c63b66755f1ba5451f8bca813c77f64f0633529750c0000000000ffffffffeb96ff5009b1beb8c708ec0e539888ce3384b010a6d16f120a68ae8bab8542f50000000000ffffffff36e8f9f7f0fdda1806ae9d7935da46863df3a15cc670aafab2a8f8d59f08fb210000000000ffffffff3460eb66ddbeec5b7b1d2f400b0f678d3908e342581dfcb684e1d29588e328230000000000ffffffff6c6eba7b49e4cfc51185f4c7faa8f0d3474db23c22a2b68634083eaf602f79b70000000000ffffffffb3eebb845d0d5b8c586bc6394a2f95b600e206c0c3d71bcf1ebf7a478109e2c40000000000ffffffff7f8339026dbb70ef7a6fdf454ff6c999e029408b91fa8315a3a0f028bc98884e0000000000fffffffffc0593cbc54f8bfc69d45990a8048afc79219254ecef90c0bc60b0a33b1dd3140000000000ffffffffa37c4457e5864daff7800f3d791028b14a97382c6feaf029e549ef0bbd31a24a0000000000ffffffff8e1a8ecc6f3aa693a3f648a23d2be3f2703afaf5686cd8bd66cb48d79c4f1e3f0000000000ffffffffc8dccb8c3496d26e8ff024ac43f99df94dc9de6fff69fbb15a67cbfad214bd8f0000000000ffffffff52b72a5b124dda8b1f0ab4d0b20e27d89819d19bedd5a1c9d2027e420de570720000000000ffffffff5f1795ea4fa839aba902a506932135b23112645835dcad49bcd128b834678c7a0000000000ffffffff21e2d39227e7470ed43ae122352f0ebe5c6882f38c0e5d1ab2a00a4a5dc0b1120000000000ffffffffd2a69ac6ae7a595fbbee4a33b6e9dc9d41ae75fe19553a4143418d37731dd9da0000000000fffffffffe205582f96b26abe6772db7d531a4ba2a1171bc5ba0cdd8a4dbafb19da83bbe0000000000ffffffff650d80bbc5c24718d7f9c4100bc8d71d39868c0df28352292918b03e0c4aa7960000000000ffffffff5bb634093d781000abefebcc3a4d380e6be6cc8a9447db5720d2566b9e9b99fb0000000000ffffffffe9daaddf708ec520901d2a18178ae186c95b8576e9d5368a5f67bf48b6959ba80000000000ffffffffede8118cf49b8e65682f50535f7cee920ac72a35c747b0666fbc171163d5dbe90000000000ffffffff4ac899cfff37fe6ddf80ba35325431e42b54bdd608e5ec45e23f78cdd7ed3eeb0000000000ffffffff8fda7068f9d304d1a5bccbd4b95928aeb602789efb97fe79c9203fff3d1996610000000000ffffffff78c3fd299e5367f6972e5e92f653cab40ea5b76c0ff385b46a5220c51c0d96e60000000000ffffffffafb959e2e9798e15380ead1f82c51fae58d379aefb5bf6ff1c36e8d914b541b70000000000ffffffffda3297421808b2cfec7a3441eeb2b569f3fd863dccf05c9e3177421c46a738e70000000000ffffffff9136d6af70b46b9b77ffd1c00a89998e8853fc272d546892725d35af8e94e5360000000000ffffffffd81fddf3edfe78c6c8ca34388e4493e1bed60af214d161b7d1c4a361873183480000000000ffffffffc37df757814e91c3604d904bf45eab9d4a102bed73e1f281b4c064e89091bbf20000000000ffffffff5670b181b1ba8581c77b03a524375f047bc93fd4a4d58fbb8c8df3319b65198a0000000000ffffffff5438114a4333d7c53d7d33523fb1c518b9ff5c662da58bc6fc77ccc2eb4a260a0000000000ffffffff7ba82bd9f779bbd840810aa8ddc3d96b4f1e05f3c23766e7e6f22825f790fe000000000000ffffffffc5d6a11ffbb58412703f0aa03c2e6fd1ffdb1387674ca9904a437eaf38b54aee0000000000ffffffff7ec8c66080816fc3c1427619b6e12c61f337a1dfe58560aaba5f12a1273627630000000000ffffffff70e5e2f72feb2778465c3950955ea967acc6ccb12b019c333183bdb178aa9a9d0000000000ffffffff55309b9155cd338876c89d73c71d8caf2cef277c95b5c57c90d5a1901b00159e0000000000fffffffff9e08ebc576b729ac5027416527fff22bbaf5c37fdcb46e062a66e4e094f62110000000000ffffffff911d127fa65d28db0e5ab744f2478ccb8195b3702a608e28819e0b811b0639360000000000ffffffff9d95224d4ed134656e2ed968afb7cd1ccc5ca573cd3ba52eec28f76d7ce65fba0000000000ffffffff50811c4f85adaf815b1f4571c52af21972230150792881a027bd6d13f5bad7f20000000000ffffffffd110c6f2c4f3c34ae5a400995c620b67202f7f61ca2cc3c0c1a1859dd4b60aab0000000000ffffffffd09555873d2dfb7f5c34f46e20c51ac0a6b9faf06b27d6c814e6d28aec29bd8d0000000000ffffffff88c312d1d93975c37b6c1d731adbd78100220cf4d8429f349a2aa10754a847080000000000fffffffffb22879f77929cf39302b842d0e593fdc3aa9bd992e15299e16b645bceea4af50000000000ffffffffa8468a8b27030c32ce087cdb129a6ec700d35ef2efc544d74bb080903e89b0be0000000000ffffffffa4c2302f9464cc015aecbcb60a96d08a15391581fe699dedfeb688e28759bf250000000000ffffffffb48a0d6d5b17866029fcf334b1551763d0359ce5c0edf196b9ef0fecbebe27560000000000ffffffffac1a8e5fd398b1fdcbb2e37328f76634468fff4ff57e1fb2df28b297c68769770000000000ffffffff5dcf4cd6e6d415dc8b20e819c263621bc68f8464cb64ad25f7a0f4e972e702ec0000000000ffffffffe356882b10dd4f2893b363122b91cfbc8575af1405e22458b3f1b62dbd6cec070000000000ffffffff6a1999063a7e29bf82689dad80da8c492794653913caeca21ef21c8d1157fcf70000000000ffffffff607e7947c8ed097731ddbfa532ca22b369c388673f23daa6edf97079ab9c58050000000000ffffffffa06da8382772204a15205fd693c28b79336dd8a205fa6a9f40dc346cc52149fe0000000000ffffffffc44699eb17d73fb1399b01305cd80d47efc5a91936459cc16519dfef2c960c040000000000ffffffff740096cec11a4e34b0a5a79419299dfdaba3db8a34ee0027d5302d771e5459cf0000000000ffffffffa11b58986f372e6e87ae7f79747aeb10955e13f3204fb1370b5e62bf4d59cdd10200000000ffffffff0072257686a5deadec523f3a1230bd3f80ec00c57c4b91dd5b8bfdbe66faffa40000000000ffffffffbb6379594034cf393e535a9a7b261947cfec593d95621dc47d850b70f002770e0000000000ffffffffc98c499c47b77ef91e8f1b9a86d46851c7184a1f9b4ab1595eb836f9dfa70c030000000000ffffffff70360b5669c9d83928b9e89f8b1d4a74f4f4036420af1f85f9b438d95a07307c0000000000ffffffff1c9381283653228abceec170d8b94b77b83dbc08ec2a66053754ffa5a2b52d530000000000ffffffff43bbd10ab99cb14392f5992123c08d65a78c5edc15c2edd7dbc59b3f5bc85a350000000000ffffffffccce2adc07309af8f4435b8070261f5a91f6bdf049cbfaf18ac3a3255705bbde0000000000ffffffff6e76720a7a00570c8d24c6da139425bd1de0703575a334548d1b4aa0464154170000000000ffffffff4bce06f01275332182e8a805e2d032e7e40b665691f9f3199efecb3a3f778ea70000000000ffffffff9f76cd9446caac6ccb9dd7ed036f40ef41b20bc61063b61f117cdd2a676430140000000000ffffffff7579d3e779b8106ae26f64e913abc1ffe5e04ddfe59645168ea530e4e0e137510000000000ffffffffa040f0ce58f693a7bc6580e99e367ea1f0df2431c4f79ca9c7fd3703b07ed3a50000000000ffffffff5180e67e06d6fbc295178a4c3bc3b33b8d21189f0474dc2f5c5d9b0874a1fe970000000000ffffffff75c2120ac52254e92580ba4d62fb9013af3b7a02c0ad364d27f37fcfc3787bb40000000000ffffffff49df5b34e82b3417d4833dc149638b4ec713b0c5afd1ae5984a7d194ed2071ad0200000000ffffffff0d073c5b85aeafbbc460b570c021a69fc3ea87ea87faf6e6d9aedb3f80aefe0f0000000000ffffffffba2ed421a111644bb08482e8666ef91c02818fd521de8a4b19262cecfe8a3e860000000000ffffffffe4b545f871d0e9be5e062ac5bf82c770390cf10ce35755abb724a962747f50bd0000000000ffffffffd262d7f241d8496340f4a27985fd4e0719445453ffc4a525a37d226f3bbffc100000000000ffffffff69846d6d0017bcf166b2ea0f0fa3475d9e8098fa1f9d761c4c94932da1fb4dfe0000000000fffffffffecdd555119498f936b0933f3ea90332b557a504d0537c9b9db3f6ff13439e920000000000ffffffff03d8e85806429233928992180fd22c33d53318e98469a1564a12364161d75cac0000000000ffffffff02693a25a37d9122f0d489facd65afcb46afd54690a04556c778785e66fc9dea0000000000ffffffff8452cdb18ef4f4f4455a0aa1b96b5b93de08227e8ad64faa5a71c4b83964932f0000000000ffffffffe13a850018324bf9c2f2e8eff674cd23d3720a8de7f7012b107479c85a37e8b00000000000ffffffff088fe110a7b2a69c8014ab267cf062fb3213891776714f2590f4bb35d873d8490000000000ffffffff9e49add07b9ccc2c3c8b4dcfc25935b84759ebd6661d6931b60db21b6611fc2e0000000000ffffffff832762ef71229d3072573a321f3f840075817d90bd4fb0b2dd63406cb560e10b0000000000ffffffff0d14c438a1b86b4a814e308c230849b9bd25bd7a070f2c1299aa5994b9fbf6250000000000ffffffffedfaa972bb2644e9c4971ed3b5c267351a5ffb54d89a89d8f328117bc797062c0000000000ffffffffe661250aec4ba3568d5b1194d69615fe3c7e85d92ba51396bcd04db8ba137c8a0000000000ffffffff99e8b3d7e7b965679ed02f351b1127801ece09da9fca3ae8b1bd3d21d897b0c60000000000ffffffff805f854b8ee8a1d4f0e5b09188c2ec54141773c1415a55692d8653b6ec7230c20000000000fffffffff72ce33d42021e8a8563e3fac4f7ec53a69f3f550b84ab922b52301ffda4e58f0000000000ffffffff31ad01575b87ca0c1d0a387bcbcb84731497553ead32058cb1ad166272ce600b0000000000ffffffff720e872408c9c995cdeb18ef582e4983a7d00446f89a3b9409d544ccd02cf4cd0000000000ffffffff9b9b538b30570f94d15b5c94cde0f00d0956338d9490a460c900c07effecbc330000000000ffffffffde4b30bc6e40e2eb5f862b0fc4f079a6c610b9312f86d14baf34b3f2ce56070b0000000000ffffffff8332280e68793ff6f47f33fe20933757e6911cdcdde44deb66211064ce03afa30000000000ffffffff921dd7d1912e08060eb40d6d9bbcdb6ac9bdfcec0b365e7fa66ab049e12c5d2b0000000000ffffffff1a0f312286d2116c869d7f0b0274b8bca0196a055bb37eddc88a890983b740080000000000fffffffffef79ccedae7bf5429c6e048f0efd3482c1a959a60ca8fc5fc86c19a9cba3efe0000000000ffffffff639fa29f222a22c739a7e21fb4aa352f69ebd65c095ea013289fc3d609f8b50d0000000000ffffffff1443fd0f324f4b105f07c7a73e099b2a378ce9dfd2db8ada213295a20283a59c0000000000ffffffff32fc33195bb7e6605834744e7b7cbc10d669e141ef4516f25a19b060b44703a40000000000ffffffffc7a47f3723de19f1d61379cbbf3876895b4309ec6dd2dae87b11b6c41c7f28b90000000000ffffffff10ac790c85f83d1922d484822542a8166e1be577d9b5137b4845be032b0f4f690000000000ffffffff94de58e31578b0be4a4605902aaa2e79eb63833ca7b410e24599cc0326ee18a40000000000ffffffff989d90e2ecb404d4db91acd9aa4ee2a0a78b77a907f3a4668f9b4efe16e6da910000000000fffffffff6ae6a06bc65307a64aa5db2e10b5efcc05f72f88054b76e9bbdc6ffe068ce720000000000fffffffffe10d85c2e089ec10f015075fdb531b6a96dfc3fdb932c31bf17dc688d8f5f9c0000000000ffffffff7baee6e4ed912a729e61bfb53f7a208211c11da63c3d25c50dc2ac41811f9acb0000000000ffffffff0f2d48d31b2f498cdc9396137c042329be680388b030b1b77c67a1378109c3440000000000ffffffff8beba5df44ea570bfe95b35e7e10362a1e3d24f344a6c6803d6b1648530393c90000000000ffffffff8715eff0a69b0f009d06c662a58f586f039c11753782fc0d0cec525e6246bebe0000000000fffffffff5044c2fd196eecad41a10db6705a84c58f1acf9de4e8ba88558c47ed8ed37220000000000ffffffff3608bd53e3d67c829088d27b00d84e9b31bb90328372b6f7042dfa7276b3cb030000000000fffffffff8a37edf9b48cd54b4028d3618ce1834a97845c15b54ba5d9b5e0a8c953c29c00000000000ffffffff5414d0ae2efa1efc6abfadfede1f5ed6b95048abd722d23b9a92c2566acd00080000000000ffffffff29352fa9b2afce2844c09b4ed86dee1ba18fbd79c8aadaa99201f536255af7170000000000ffffffff6ab4d7262c3c430c18b66bd2edc1562de07c7f7d157c095b45a5d4f75e8833590000000000ffffffff140baf10c5314f77be5702467e127709ac614d3f7182fcb71ecf9b82ec4c65eb0000000000ffffffff136104e11428c2d4cc79a404a24cd17e162c2580237b73e40993e253bf2a368b0000000000ffffffff7f88730374444ad222d768cd2eb651763472609f8b07292e8ddfaa6ec63091fd0000000000ffffffff17f4e6a839ce772aec96c4eaf1f62f855991efe91bd3c1b19493aa201ef85e410000000000ffffffff0622d5062425e08c0e59ff0f0e4b32870e546b57fff55e74eefbaac2398edc600000000000ffffffff3856f5923ddfa3d2accfe3a4eee5b6f4386c53712fb10b57b093a2174e54a4a30000000000ffffffffa88362cbf79a2168e6d868fbb843cd40d0ae1182cfc91569507b7270ca58e3810000000000ffffffffb26a2c58ea5467470f78d723a645c6e2e40c60a19d9747aa1349b4ac1e7dd7910000000000ffffffff6a115d3f7bf397635369eb53c906ccabbd68d3dc3a58ad4daa5f8817b4e355140000000000ffffffff77cd1bf7c9ecb9809af5cc647af3fb2aeae580ef2c079e621d33adccaa48f08e0000000000ffffffff63994d000504ff9081926f01c4c9b695cbbe8092a2263064d63327bfefb1a2c90000000000ffffffff4960d9b26860054241d3f00786554be30a51c5f12e5da176579ab979a3e15d300000000000ffffffffa8407b094b5a400ded41a09e4112a9873e54ca328f0cd1025110fd5d6fe7b2bd0000000000ffffffffc6a9c0670e08bfdce6b5e3d23b3ac32c012475a1536389038f93521ece2f77b50000000000ffffffff74b8ee094ce7c5f98c4c1a49c5657de61c1739664ea7d8d7a76be09c21c76b5b0000000000fffffffff73a2a1dfdd39f2819c576a9ac76f56b74525a4fb008f89bab5f29b9d7bd64d60000000000fffffffffc2ee5e48278982f34a910b9c88fd5f86b9272e1a372a9e1ca095f66595c8ebc0000000000ffffffff2499f9f12f834de1d1883e07a9234388677838692149ff2271efa1a371fcd7220000000000ffffffff5a3287b9c506afbbfb8d0d68f3047fc214f8752f107e90b5814ce5e2cd7c3c320000000000ffffffff262b41343fcab97b9f1758cdd1f9801e4760bf9357d5c7179e486e5134e51fde0000000000ffffffff1df5497e7293491ca01b8d46274614dc1b9dbbab0bedf43558a86a5953ad7a110000000000ffffffffa036bf3c87df0f4d750e1583fb97a6f1edbef533768284291fd69b08ba9b4bf60000000000ffffffffa03b57d11395f7ca9654a5f66992e356398e438a281daee2faacd3c01ff4e65f0000000000ffffffff31986bf723a482d97d56fba3fde7afc4dc6d23740ada100f595a0b8f8339f8620000000000ffffffffe54321dd64ea96ab547b75d214cc575d36f2c2f6dac4e77a850d6402b947f5820000000000ffffffffc8074e783da354a7cb3df29eb2ec2b46a25fd3a78ecd72bf211ddd62b67aba620000000000ffffffffaf6ef93d836aa1cb2ce9acd1fc17f9942ed585b481de4863188f870e88ee257d0000000000fffffffff55195cdba8ae19daba717515b738354c920a2030c5e6811bfa5fa7ace7627900000000000ffffffffda3f031a44cc4d12eae714746cef19be4b8198dbaf73de8b76825047efa48cde0000000000ffffffff362bcbcd066ef88971a3dbff0cf92a51d413c0ef38374cd16d4dfa6f37db0da00000000000ffffffff275c389e22c941252607866a8883fcfb54ace3a9b9d35aaf9a8859fb7b0252e00000000000ffffffffaa9fb7326c6e8dbdc15fbefc9460bab272110ea207036b6c5c0d7001136d302d0000000000fffffffff59cc5d23cd3c1b3f96536e2b817158c880165bca465be3d240be3557ed46d040000000000ffffffff1a9b43dabd47bfab0799c441ff0ef97dd581ad72e5f9c567e30c30fb5a9e6c7b0000000000ffffffffc4a110c8fedc25e460d74ec2f2feb5834bdb9a095b8b4263f3e972b7519cb2390000000000ffffffff9e1aaa1f66932c0b85ba0f975d9f0ecba3d222ce532ae14090be9b8058ce49230000000000ffffffffabcf2f68cd38914cdab26c39be9b080293f8d7dea31b877c9ce18f8ebf6e6a6f0000000000ffffffff9346d0be73e74a589060430cbd03779b61fcc8d1d0860041f4fb6acd2cd748d40000000000ffffffff454d2b2a1e2fddb00929d2789f603f23c0e1031e1bac50adcc5372407b0f66510000000000ffffffff9714b13a86b4b93e24667b993c04ad94f1e2d0f1f9a8bc126d0498bb798d8bc50000000000fffffffff125c389992cb6c40383a9921177d593a8c0dcbf1403eec749ef68f78e937cb70000000000ffffffffd72bbbfd8163237b033d2817824ec22702500dd48ac1eb37a7f0a37fc42c98c80000000000ffffffffcd6b37e640c0b635d02ff4346ae9bd0be3bcd53f942ffc1bc9e73ef6453157610000000000ffffffff4579a71d348a1de60745310263f1067da2daccc1f06edfc167a21e37c8f4a44b0000000000fffffffffbf7fc19c417e8bf6b2b78e94542d0b8b4c8a5dc59b5ef6361fbd33ad4433d2c0000000000ffffffff95937389e6993020b44c14c2c342b485327dc93547970a834aebbc748e20c4770000000000fffffffffa38a61ddb59ffbf2c541f82c65c8e4815bbe8e2dbdfeeac4a6029453e4b1aaf0000000000ffffffff3f7b9223f6588773f1ee322915d9a1ef11de85dd63d4289cd43be49338ac8c780000000000ffffffffbbb9c3827a17b2784eb066f6654862a034b2b823b527588ff475abf8e0fe4ade0000000000ffffffff5e70a380e0b39116d73760df882d62595fc8c42400b3d389a2c26b5e893faa1d0000000000ffffffffde3b4185f09ad027018a1d73153be76a1b901dae40bf2c0cecd53558d39d2dd40000000000fffffffff1209825611dbc2c9db41b726eed5ecb82b9c0e952d3def3c59f77de000dc0620000000000ffffffff137096ec3e6eee594673c2155498197986e3e9dd6ea66c598b31dfc46e28cadc0000000000ffffffffa68f06d229233a07b53a535cad822292c11b4dbdfb4db427b99c3ff1e4ff4df50000000000ffffffff64d79860781ddee42acdee622a0b67ab539092bc4136c12df50dead9e1924bae0000000000ffffffff9600979d5f9fa82e8a8f15bbcdaffe56106c2b6776768f6ac66b4f21fd56e2c50000000000ffffffffd85fc190b7fc1fb2badd848a64967e338f28806f7174078bbfef86d2198d12c30000000000ffffffff98854b4cdac20e0e91e0f8ad0f9687fd7e8fa0cf750c1ace5a8aa82f216484950000000000ffffffff20f7413e8772db557448c496d7b58a2ced8c5831fa35ac2a08cd92cff510166a0000000000ffffffff09dfb666e82bf79b63450a7a0c77db7b557fb63f57366b717113dda3c656084e0000000000ffffffff478c0008db9603645ebb531daead36d6a6ab349106db05cdb5bd12cc52a39dd20000000000ffffffffc9af8b1b7a66b1862cad33d148ca496911f727bb3a0923fd35baf73d6f43c9240000000000ffffffffc9d24323744bb72062615ff69516a949a1bc17fab2dfa3918abf8b7e8cf27a410000000000fffffffffffe6ddb6bb4d6759dd7b42185dd871eccdeef766a97cb643903ae8a630e14ba0000000000ffffffff93c91463f36a9e98873f736cee091f8820d7935b19084cbaf26592553db2bba80000000000ffffffff7ea6b4a50f32cd93fb9d1d8df738c422e6fc3801c37dcd9b804cc82a2b55a7090000000000ffffffff8e9d678f50fe44a31cc1823abed7ca5789f4b7c711f31197b488b4fe6d35766f0000000000ffffffffffe1b7401e6e2efe2749de97e00aa1ccd69fae73fdd1c76167da4a9b6c87026a0000000000ffffffffd6c48755c5ec660b1886a3f250266ea28dae66bfe8ebf313d7c699eccd289f8b0000000000ffffffff16b1478cb7201c0de75ed553993cbbf33a0e7db858fb5aabb814d0ec2f1f51bf0000000000ffffffff8f4b523325c5b2af69652ce09709b5880fddc586c61760480c639fde2b26b4360000000000ffffffff8970c837a896b56bf83f8db0dfa968c8ad4da75547da44c9a4bf31b2a238d5400000000000ffffffff0516d89b96fc25f9712cb7c8a4f15c784191f96557bb8f3367919fc1fd926bbd0000000000ffffffff9ae2b90c1e3db657b4d57d367e98b534bb200e6db5e3341cbc8086b1f12c655a0000000000fffffffff15dfdaeb7332dcae37dc5812595d4c49caa83619a3f7378ed41c2fe12cfba4e0000000000ffffffffa504d7ceab1229e80ec529adf8ad8888f7aebc92812fb37e97f4daa8998356be0000000000ffffffff430d40c7a1a651cadc298aa3071ad40675cd64a76e04bfc4430c41203cea8a340200000000ffffffff3aeb7f92c52d27e889b829da55c98d87f35582e9e09149c48d94523b471ff9690000000000ffffffffa809bd5f57c4957bf6a29717fa324f7cb138e9bf0d745b0296fcc9b93a4f3af10000000000ffffffff8e9fba6e669ddef7d3419aa99c4facb37d77f4c05bff4d3e2e73e401bf166f600000000000ffffffff8d3632820a6580f715d392c1747e38cf6793228d6945430754a24c8b58be65550000000000ffffffff95a11de84fdde3b85755d85f519b375ae5391999cc19a7a177aed442f58c07190000000000ffffffff193259f360580d84c6dc669029abdcba992a1fe46317c70e3d0cb3dd65704a950000000000ffffffff62d1ec2d404aa64ba003f3e69b7eb61fe7c5e5aa31a9fc991ff40e5d7f4f2b580000000000ffffffff27fe5f2bfbc2818f43e9c54bc96ca8f26dbae19fea592b80da86ce32e3ea59ba0000000000ffffffffc0169f8f5afc6676af89104b04d22cf7027f2ac74d308a550d907b243ddd54a40000000000ffffffffcb996fc98c8887a5222c8704070551973558a7a62dfb8a63376735d3cd76dcc80000000000ffffffff79c3b8212ac33a34fd6eb3d44d79047d95d629da34493d7247ddeefc73d97f500000000000ffffffff4618906330b758641a8c1aa05b1b7ddbc1f36a72adf6b5c408c4eeeedf8e5ced0000000000fffffffffc98778907c1ba526cad1a2cccfaa5e4f12172d72b346f9071e7343033f6b78e0000000000ffffffff0eebcb87ef4f7225ac3ae2501c2b2671fa88e1f179999a16a6c4c972a901c3660000000000ffffffffda4a81eb4e8db7062bded8a92bd09e41e31c520d55009327ccf24f64f282c0470000000000ffffffff1c5debb7a5610ec0f81937f9ae66055b917e5fdd8497cc6ec930e864435342d80000000000ffffffff02d4fac68c6ec9d07383a18f1c4947585d351a5a79c9d9f7ae9de934773c05840000000000ffffffffe0f53a7245d066885e8044b4ee44fd562f59d695cf4569eb7b1aa0c94413d50e0000000000ffffffffadcac77606ae94dd674047b4de6d06da154d30078b36233cf6d3f024eada436c0000000000ffffffffa5a399ef20f3ade46b513f00e0f89ac5ecc10bc1d94c685ecfe48553bbabb3680000000000ffffffffc5b6b0f3b602112745ca27708437062402d8ad2df0e9190b7c98bab29d7f43b10000000000ffffffffe97a3331a3913ebd791a10b11275d4be2657cada3514a705190d4a12269c61ae0000000000ffffffffa72f802b8330729306980766ba7e88145469f0dbed95ae84f988cf0c391230d40000000000ffffffffee3c7c61b80c4a39d803ef6b6ca49d117735d2b1a47cedcc67f2dc2041f6b4330000000000ffffffff9341e365ec95ac26da1afb9ad6627a7b3e6968cd0f1480279963a1196f94b9ef0000000000ffffffffe435c43b157c457b79dea562e4c832a4fa3983c116f57d3cfba911a0843dddd90000000000ffffffff5ba9adfb3ace73fd037a90a1c0cb0b83a3c61e0b5bdb5811bec7b6fc9e11101e0000000000ffffffffe41a6b28bb44f784c4870984829ee38cbcba2503ff0917e0eb1f02e8ef13742f0000000000ffffffff365d011920e5ecf6a9e807f552644a8732eb24f7705194ff09524893325dca660000000000ffffffff5912f6edba50880842ea7f2918bf0d8d1e8a3601e8d0537c0129dceb53f246a40000000000ffffffffc10c1ec4a74232f384f8bd094d44569065c364b998c877dfdff26bf7725d4e130000000000ffffffff90544583057e1e6c0071479f068df4548e6967bd6bb5db50ce18b2031a748b530000000000ffffffff84c31958ca520563e8de58efcfbad5c5eae9d6d8743587377e60fa1bafb2641f0000000000ffffffffb9d5c54d92aafbaa91fecda029eee80e021e36f960b92f6414edef7ad338c5520000000000ffffffffdf6231b3ecc3d438c5bd0fee72fd284f1150880739e35b52299132568145e9720000000000ffffffff91a922d017d63432f59025c9f464faad85da114ca81fec35794e21f8797f0fd50000000000ffffffffe60828250b526992160144c8c9cec3c48472f58dc617134d11becfd0e26cc5be0000000000ffffffff77c1a17ab6c7dbac23f057abc177e1febff5466517b511e9a05d2bf000f7c2830000000000ffffffffa87019b57004a305f8e36e9f4e9975c6b7fbc2852826312db6ce9818a2489f9c0000000000ffffffff230ebd3add0f2dd8f79c1a5abcdc8b345fbf68d543475d6a18efc61a8884defa0200000000ffffffff82b06b4d7a77bdc5cceddd7f3671bdb1a74138cf1ec26c7fbc74920e1932bf130200000000ffffffff727178620ffdb29e5ecaf2af0dd834035810cc2a5a502c101dfdef213b7153a00000000000fffffffffc720a63421a6032f69d422d78cc85a677a26ae48f6c26289cef8b7bd8df3f6c0000000000ffffffff66af0d91791ffdde9b66730d1058cdfe46cd1a4d0b426bb9f5d58d3452a8248a0000000000ffffffff8c875e5595189a7e413b2e85cac7cefcd11779d8ac65b625ee3ee06989c490360000000000ffffffffe22951ff39843c20ed8e968512104c5962331d1b09d6cd4a2f7fe88358dbbf080200000000ffffffff3d437d42ec382df84d2b820d81f41dbe5fc32f5cd35890b52d6922bd4604d0170200000000ffffffffa05bb6c29960749bc04800c311985ac104592610244e1095814ce41bd3ebd7fd0200000000ffffffff0200000000000000001976a914b35be218fb71aad25401810aa759f00ff089347f88ac01000000020000000200000003444e413b89342bb61d00001020193d150000001976a914a146498458042bede92dab626dbc5d0ea030694088ac010000000000000000000000"
 
-	result, err := tw.WalletClient.Call("decoderawtx", []interface{}{rawHex})
+	result, err := tw.WalletClient.Call(context.Background(), "decoderawtx", []interface{}{rawHex})
 	if err != nil {
 		t.Errorf("DecodeRawTx failed unexpected error: %v\n", err)
 		return
@@ -200,8 +219,9 @@ func TestWalletManager_DecodeRawTx2(t *testing.T) {
 }
 
 func TestWalletManager_DecodeRawTx(t *testing.T) {
-	rawHex := "04000000029358bd3c7d4e99c6c6f81c27297ed1f0a38a080fb7090b80cfbb06903da64c890100000000ffffffff1e103ef2915af1dc50f701e79ccd35f04271155413fb4f081a74260358839b350100000000ffffffff0280969800000000001976a9144b434fa2be94c828419e708f848e3eca3e911aa988ac0100000000000000c08bec08000000001976a914e607f73ea755a41b4b649114a9bed5dba1ca8da088ac010000000000000000000000"
-	tx, err := tw.DecodeRawTx(rawHex)
+	testRequireNode(t)
+	rawHex := testAssetTransferRawHex
+	tx, err := tw.DecodeRawTx(context.Background(), rawHex)
 	if err != nil {
 		t.Errorf("DecodeRawTx failed unexpected error: %v\n", err)
 		return
@@ -229,17 +249,18 @@ func TestWalletManager_DecodeRawTx(t *testing.T) {
 		t.Logf("Value[%d] = %v \n", i, out.Value)
 		t.Logf("Value[%d] = %v \n", i, out.Type)
 		t.Logf("Value[%d] = %v \n", i, out.IsToken)
-		t.Logf("Value[%d] = %v \n", i, out.AssetAttachment)
+		t.Logf("Value[%d] = %v \n", i, out.Asset())
 	}
 }
 
 func TestWalletManager_SendRawTx(t *testing.T) {
+	testRequireNode(t)
 	rawHex := []string{
 		"040000000222118a7595c87242f63d0ad2bd1f5ef39bc236633295587857c9fbe9c2f5806f000000006a4730440220163ad12058f5c83fad889ff7b0788c647db3cdb8d029ab072e227938573cf38b02204d844644f0668b120657410d5ddf86981c556f7d9ab3b18b075170cc5e6d6f7b012102c300a2176941a7b7d1f4b77982295aaf395d68529b9914969022bff2462087ddffffffff845227eabdb945e60d19fde74c5d1712c00082f1586160677a72e071245c28b3010000006a47304402200bd3cd7020fc78ab51a14f04a79a4b4f880786d18811075b6b03a7551a106b5d02207eb73b462390df5ae3d1022285faa56e8ad4932d86d64eb401116d58022a4ec7012102c300a2176941a7b7d1f4b77982295aaf395d68529b9914969022bff2462087ddffffffff0300000000000000001976a9144d75e7ec524623e7aef948d8f61535006772bfeb88ac01000000020000000200000003444e411027000000000000c0b60600000000001976a914e607f73ea755a41b4b649114a9bed5dba1ca8da088ac010000000000000000000000000000001976a914e607f73ea755a41b4b649114a9bed5dba1ca8da088ac01000000020000000200000003444e41409c00000000000000000000",
 		"040000000222118a7595c87242f63d0ad2bd1f5ef39bc236633295587857c9fbe9c2f5806f000000006a47304402207c3d33577c714360fba16d7a7d55d0a4c834b3cc96106755d92c5706d14251ac02204c91115cf1086e5350b1f9902ab280ad3ad4ae762da6421b4931b3be60d93fa1012102c300a2176941a7b7d1f4b77982295aaf395d68529b9914969022bff2462087ddffffffff845227eabdb945e60d19fde74c5d1712c00082f1586160677a72e071245c28b3010000006a473044022004ed0e637eb5e87e6bf7d5564e64fb82b572d038341797599a055e5cb38e0841022058ad837bb82640848df0f3be7fe0792217c10ee31f6a7a6351ad2af64daccb13012102c300a2176941a7b7d1f4b77982295aaf395d68529b9914969022bff2462087ddffffffff0300000000000000001976a9144d75e7ec524623e7aef948d8f61535006772bfeb88ac01000000020000000200000003444e411027000000000000c0b60600000000001976a914e607f73ea755a41b4b649114a9bed5dba1ca8da088ac010000000000000000000000000000001976a914e607f73ea755a41b4b649114a9bed5dba1ca8da088ac01000000020000000200000003444e41409c00000000000000000000",
 	}
 	for _, raw := range rawHex {
-		txid, err := tw.SendRawTx(raw)
+		txid, err := tw.SendRawTx(context.Background(), raw)
 		if err != nil {
 			t.Errorf("SendRawTx failed unexpected error: %v\n", err)
 			return
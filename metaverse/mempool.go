@@ -0,0 +1,184 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+const (
+	//TxStatusPending 交易池中未确认的交易状态
+	TxStatusPending = "2"
+
+	//ExtParamTxState 提取的交易单ExtParam中交易状态的字段
+	ExtParamTxState = "txState"
+	//ExtParamReplacedBy 被替换的交易单ExtParam中替换交易的字段
+	ExtParamReplacedBy = "replacedBy"
+)
+
+//交易单状态，记录在ExtParam的txState
+const (
+	TxStatePending   = "pending"   //在交易池中等待确认
+	TxStateConfirmed = "confirmed" //已打包
+	TxStateDropped   = "dropped"   //从交易池中消失
+	TxStateReplaced  = "replaced"  //输入被交易池中的其他交易花费
+)
+
+//mempoolTx 已通知的未确认交易
+type mempoolTx struct {
+	trx         *Transaction
+	extractData map[string]ExtractData
+}
+
+//setTransactionState 按交易单是否已打包设置状态，未打包的交易状态为TxStatusPending
+func setTransactionState(tx *openwallet.Transaction, blockHeight uint64) {
+	if blockHeight == 0 {
+		tx.Status = TxStatusPending
+		tx.SetExtParam(ExtParamTxState, TxStatePending)
+	} else {
+		tx.SetExtParam(ExtParamTxState, TxStateConfirmed)
+	}
+}
+
+//ScanMemPool 扫描交易池，关注地址的未确认交易通知为TxStatusPending。
+//交易打包后由区块扫描通知确认，交易从交易池中消失或被替换时通知失败
+func (bs *ETPBlockScanner) ScanMemPool() {
+
+	txs, err := bs.wm.GetMemoryPool(context.Background())
+	if err != nil {
+		bs.wm.Log.Std.Info("block scanner can not get memory pool; unexpected error: %v", err)
+		return
+	}
+
+	var (
+		current = make(map[string]bool)
+		spentBy = make(map[string]string) //交易池中被花费的输出和花费的交易
+		seen    = make(map[string]bool)   //本次交易池中已提取过的交易
+	)
+
+	for _, trx := range txs {
+		current[trx.TxID] = true
+		for _, input := range trx.Vins {
+			spentBy[fmt.Sprintf("%s:%d", input.TxID, input.Vout)] = trx.TxID
+		}
+	}
+
+	//新的未确认交易，已提取过的交易不再重复获取输入和提取
+	for _, trx := range txs {
+
+		bs.mempoolMu.Lock()
+		_, notified := bs.mempoolTxs[trx.TxID]
+		extracted := bs.mempoolSeen[trx.TxID]
+		bs.mempoolMu.Unlock()
+		if notified || extracted {
+			seen[trx.TxID] = true
+			continue
+		}
+
+		result := bs.ExtractTransaction(0, "", trx, bs.ScanTargetFuncV2)
+		if !result.Success {
+			continue
+		}
+		seen[trx.TxID] = true
+		if len(result.extractData) == 0 {
+			continue
+		}
+
+		bs.wm.Log.Std.Info("block scanner found pending transaction: %s", trx.TxID)
+		bs.newExtractDataNotify(0, result.extractData)
+
+		bs.mempoolMu.Lock()
+		if bs.mempoolTxs == nil {
+			bs.mempoolTxs = make(map[string]*mempoolTx)
+		}
+		bs.mempoolTxs[trx.TxID] = &mempoolTx{trx: trx, extractData: result.extractData}
+		bs.mempoolMu.Unlock()
+	}
+
+	//从交易池中消失的交易
+	bs.mempoolMu.Lock()
+	bs.mempoolSeen = seen
+	missing := make([]*mempoolTx, 0)
+	for txid, pending := range bs.mempoolTxs {
+		if !current[txid] {
+			missing = append(missing, pending)
+		}
+	}
+	bs.mempoolMu.Unlock()
+
+	for _, pending := range missing {
+
+		txid := pending.trx.TxID
+
+		//已打包的交易等待区块扫描通知确认，无法确认时下次再检查
+		_, txErr := bs.wm.GetTransaction(context.Background(), txid)
+		if txErr == nil || txErr.Code() != ErrTxNotFound {
+			continue
+		}
+
+		state := TxStateDropped
+		replacedBy := ""
+		for _, input := range pending.trx.Vins {
+			if spender, ok := spentBy[fmt.Sprintf("%s:%d", input.TxID, input.Vout)]; ok {
+				state = TxStateReplaced
+				replacedBy = spender
+				break
+			}
+		}
+
+		bs.wm.Log.Std.Info("pending transaction %s is %s", txid, state)
+
+		ext := map[string]string{ExtParamTxState: state}
+		if len(replacedBy) > 0 {
+			ext[ExtParamReplacedBy] = replacedBy
+		}
+		bs.newExtractDataNotify(0, failedExtractData(pending.extractData, "transaction is "+state+" from memory pool", ext))
+		bs.confirmMemPoolTx(txid)
+	}
+}
+
+//confirmMemPoolTx 交易已打包或已通知失败，不再关注
+func (bs *ETPBlockScanner) confirmMemPoolTx(txid string) {
+	bs.mempoolMu.Lock()
+	defer bs.mempoolMu.Unlock()
+	delete(bs.mempoolTxs, txid)
+}
+
+//failedExtractData 失败的提取结果，交易单与原来的WxID相同，ExtParam记录失败的状态
+func failedExtractData(tokenExtractData map[string]ExtractData, reason string, ext map[string]string) map[string]ExtractData {
+
+	failed := make(map[string]ExtractData)
+	for token, extractData := range tokenExtractData {
+		failed[token] = make(ExtractData)
+		for sourceKey, data := range extractData {
+			tx := *data.Transaction
+			tx.Status = openwallet.TxStatusFail
+			tx.Reason = reason
+			for key, value := range ext {
+				tx.SetExtParam(key, value)
+			}
+			failed[token][sourceKey] = &openwallet.TxExtractData{
+				TxInputs:    data.TxInputs,
+				TxOutputs:   data.TxOutputs,
+				Transaction: &tx,
+			}
+		}
+	}
+	return failed
+}
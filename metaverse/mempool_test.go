@@ -0,0 +1,174 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//sendSimTx 创建、签名并广播ETP转账
+func sendSimTx(t *testing.T, wm *WalletManager, wallet *simWallet, to, amount string) string {
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{to: amount},
+	}
+	decoder := wm.GetTransactionDecoder()
+	if err := decoder.CreateRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("CreateRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.SignRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("SignRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.VerifyRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("VerifyRawTransaction unexpected error: %v", err)
+	}
+	tx, err := decoder.SubmitRawTransaction(wallet, rawTx)
+	if err != nil {
+		t.Fatalf("SubmitRawTransaction unexpected error: %v", err)
+	}
+	return tx.TxID
+}
+
+//txStates 交易单各次通知的状态
+func txStates(data []*openwallet.TxExtractData) []string {
+	states := make([]string, 0)
+	for _, d := range data {
+		states = append(states, d.Transaction.GetExtParam().Get(ExtParamTxState).String())
+	}
+	return states
+}
+
+func TestSimNode_BlockScanner_MemPool(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+	from := wallet.addresses[0].Address
+	to := wallet.addresses[1].Address
+
+	node.Fund(from, 100000000)
+	node.Mine(1)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.ScanBlockTask()
+
+	//关闭交易池扫描时未确认交易不通知
+	bs.IsScanMemPool = false
+	txid := sendSimTx(t, wm, wallet, to, "0.5")
+	bs.ScanBlockTask()
+	if data := observer.extractData(txid); len(data) != 0 {
+		t.Fatalf("pending transaction is notified when memory pool scan is off: %v", txStates(data))
+	}
+
+	//未确认交易只通知一次
+	bs.IsScanMemPool = true
+	bs.ScanBlockTask()
+	bs.ScanBlockTask()
+	data := observer.extractData(txid)
+	if len(data) != 1 {
+		t.Fatalf("pending transaction notified %d times, want 1", len(data))
+	}
+	pending := data[0].Transaction
+	if pending.Status != TxStatusPending || pending.GetExtParam().Get(ExtParamTxState).String() != TxStatePending || pending.BlockHeight != 0 {
+		t.Fatalf("unexpected pending transaction: %+v", pending)
+	}
+
+	//打包后通知确认
+	node.Mine(1)
+	bs.ScanBlockTask()
+	data = observer.extractData(txid)
+	confirmed := data[len(data)-1].Transaction
+	if confirmed.Status != openwallet.TxStatusSuccess || confirmed.GetExtParam().Get(ExtParamTxState).String() != TxStateConfirmed ||
+		confirmed.BlockHeight != 2 || confirmed.WxID != pending.WxID {
+		t.Fatalf("unexpected confirmed transaction: %+v", confirmed)
+	}
+	if len(bs.mempoolTxs) != 0 {
+		t.Fatalf("confirmed transaction is still watched in memory pool")
+	}
+
+	//从交易池中消失
+	droppedTxID := sendSimTx(t, wm, wallet, to, "0.1")
+	bs.ScanBlockTask()
+	if err := node.DropMempoolTx(droppedTxID); err != nil {
+		t.Fatalf("DropMempoolTx unexpected error: %v", err)
+	}
+	bs.ScanBlockTask()
+	data = observer.extractData(droppedTxID)
+	if states := txStates(data); len(states) != 2 || states[0] != TxStatePending || states[1] != TxStateDropped {
+		t.Fatalf("unexpected dropped transaction states: %v", states)
+	}
+	if dropped := data[1].Transaction; dropped.Status != openwallet.TxStatusFail || dropped.WxID != data[0].Transaction.WxID || len(dropped.Reason) == 0 {
+		t.Fatalf("unexpected dropped transaction: %+v", dropped)
+	}
+
+	//输入被其他交易花费
+	replacedTxID := sendSimTx(t, wm, wallet, to, "0.1")
+	bs.ScanBlockTask()
+	node.DropMempoolTx(replacedTxID)
+	replacementTxID := sendSimTx(t, wm, wallet, to, "0.2")
+	bs.ScanBlockTask()
+	data = observer.extractData(replacedTxID)
+	if states := txStates(data); len(states) != 2 || states[1] != TxStateReplaced {
+		t.Fatalf("unexpected replaced transaction states: %v", states)
+	}
+	if replacedBy := data[1].Transaction.GetExtParam().Get(ExtParamReplacedBy).String(); replacedBy != replacementTxID {
+		t.Fatalf("replacedBy = %s, want %s", replacedBy, replacementTxID)
+	}
+	if states := txStates(observer.extractData(replacementTxID)); len(states) != 1 || states[0] != TxStatePending {
+		t.Fatalf("unexpected replacement transaction states: %v", states)
+	}
+}
+
+func TestSimNode_BlockScanner_MemPoolSeen(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 3)
+	from := wallet.addresses[0].Address
+
+	node.Fund(from, 100000000)
+	node.Mine(1)
+
+	//只关注第三个地址，前两个地址之间的转账是不相关的交易
+	watched := &simWallet{key: wallet.key, addresses: wallet.addresses[2:]}
+	bs, _ := newSimBlockScanner(wm, watched)
+	bs.IsScanMemPool = false
+	bs.ScanBlockTask()
+
+	txid := sendSimTx(t, wm, wallet, wallet.addresses[1].Address, "0.5")
+
+	before := node.Requests()
+	bs.ScanMemPool()
+	if node.Requests()-before < 2 {
+		t.Fatalf("first memory pool scan sent %d requests, want the inputs to be fetched", node.Requests()-before)
+	}
+
+	//不相关的交易不重复获取输入和提取
+	before = node.Requests()
+	bs.ScanMemPool()
+	if node.Requests()-before != 1 {
+		t.Fatalf("second memory pool scan sent %d requests, want 1", node.Requests()-before)
+	}
+
+	//交易离开交易池后不再缓存
+	node.Mine(1)
+	bs.ScanMemPool()
+	bs.mempoolMu.Lock()
+	cached := bs.mempoolSeen[txid]
+	bs.mempoolMu.Unlock()
+	if cached {
+		t.Fatalf("transaction %s is still cached after leaving the memory pool", txid)
+	}
+}
@@ -1,10 +1,15 @@
 package metaverse
 
 import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
 	"github.com/astaxie/beego/config"
+	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/openwallet"
-	"github.com/shopspring/decimal"
 )
 
 //FullName 币种全名
@@ -47,26 +52,122 @@ func (wm *WalletManager) GetBlockScanner() openwallet.BlockScanner {
 	return wm.Blockscanner
 }
 
-//LoadAssetsConfig 加载外部配置
+//LoadAssetsConfig 加载外部配置，配置格式错误或超出范围时返回错误，不修改当前配置
 func (wm *WalletManager) LoadAssetsConfig(c config.Configer) error {
 
-	wm.Config.ServerAPI = c.String("serverAPI")
-	wm.Config.IsTestNet, _ = c.Bool("isTestNet")
-	wm.Config.MinFees, _ = decimal.NewFromString(c.String("minFees"))
-	wm.Config.MinFees = wm.Config.MinFees.Round(wm.Decimal())
-	wm.Config.DataDir = c.String("dataDir")
+	r := newConfigReader(c)
+	cfg := *wm.Config
+
+	//多个节点用;分隔
+	cfg.ServerAPIs = make([]string, 0)
+	for _, api := range c.Strings("serverAPI") {
+		api = strings.TrimSpace(api)
+		if len(api) == 0 {
+			continue
+		}
+		if u, err := url.Parse(api); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			r.fail("serverAPI", api, "not a http url")
+			continue
+		}
+		cfg.ServerAPIs = append(cfg.ServerAPIs, api)
+	}
+	if len(cfg.ServerAPIs) > 0 {
+		cfg.ServerAPI = cfg.ServerAPIs[0]
+	} else {
+		r.fail("serverAPI", c.String("serverAPI"), "at least one node api is required")
+	}
+	cfg.RPCDebug = r.Bool("rpcDebug", false)
+	cfg.NodeMaxLag = uint64(r.Int64("nodeMaxLag", int64(DefaultNodeMaxLag), 0, 1000000))
+	cfg.NodeProbeInterval = time.Duration(r.Int64("nodeProbeInterval", int64(DefaultNodeProbeInterval/time.Second), 1, 86400)) * time.Second
+	cfg.BroadcastToAll = r.Bool("broadcastToAll", false)
+	cfg.CallPolicy, cfg.CallPolicies = loadCallPolicies(r)
+	cfg.ScanPeriod = time.Duration(r.Int64("scanPeriod", int64(DefaultScanPeriod/time.Second), 1, 3600)) * time.Second
+	cfg.MaxExtractingSize = int(r.Int64("maxExtractingSize", maxExtractingSize, 1, 100))
+	cfg.RescanLastBlockCount = uint64(r.Int64("rescanLastBlockCount", 0, 0, 1000))
+	cfg.IsScanMemPool = r.Bool("scanMemPool", true)
+	cfg.MaxReorgDepth = uint64(r.Int64("maxReorgDepth", int64(DefaultMaxReorgDepth), 0, 100000))
+	cfg.MinConfirmations = uint64(r.Int64("minConfirmations", 0, 0, 10000))
+	cfg.NotifySeen = r.Bool("notifySeen", false)
+	cfg.CatchUpWorkers = int(r.Int64("catchUpWorkers", 0, 0, 64))
+	cfg.BlockNotifyListen = r.String("blockNotifyListen", "")
+	cfg.BlockNotifyPath = r.String("blockNotifyPath", DefaultBlockNotifyPath)
+	if !strings.HasPrefix(cfg.BlockNotifyPath, "/") {
+		r.fail("blockNotifyPath", cfg.BlockNotifyPath, "must start with /")
+	}
+	cfg.MinNodeVersion = r.String("minNodeVersion", MinNodeVersion)
+	cfg.CheckNode = r.Bool("checkNode", true)
+	cfg.Network = r.Network()
+	cfg.IsTestNet = cfg.Network.IsTestNet
+	cfg.MaxTxInputs = int(r.Int64("maxTxInputs", DefaultMaxTxInputs, 1, 2000))
+	cfg.DIDCacheTTL = time.Duration(r.Int64("didCacheTTL", int64(DefaultDIDCacheTTL/time.Second), 0, 3600)) * time.Second
+	cfg.MinFees = r.Decimal("minFees", cfg.Network.DefaultFee).Round(wm.Decimal())
+	cfg.DataDir = r.String("dataDir", "")
+
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	*wm.Config = cfg
 
 	//数据文件夹
 	wm.Config.makeDataDir()
 
-	wm.WalletClient = NewClient(wm.Config.ServerAPI, false)
+	if wm.WalletClient != nil {
+		wm.WalletClient.Close()
+	}
+
+	if len(wm.Config.ServerAPIs) > 1 {
+		client := NewClient(wm.Config.ServerAPI, wm.Config.RPCDebug)
+		client.Pool = NewNodePool(wm.Config.ServerAPIs, wm.Config.NodeMaxLag)
+		client.Pool.ProbeInterval = wm.Config.NodeProbeInterval
+		client.Pool.Start()
+		wm.WalletClient = client
+	} else {
+		wm.WalletClient = NewClient(wm.Config.ServerAPI, wm.Config.RPCDebug)
+	}
+	//地址版本，替换为新网络的解析器，不修改正在使用的解析器
+	if _, ok := wm.DecoderV2.(*metaverse_addrdec.AddressDecoderV2); ok {
+		wm.DecoderV2 = metaverse_addrdec.NewAddressDecoderV2(wm.Config.networkParams(), wm.Config.IsTestNet)
+	}
+
+	wm.WalletClient.Policy = wm.Config.CallPolicy
+	wm.WalletClient.Policies = wm.Config.CallPolicies
+
+	//区块扫描
+	if bs, ok := wm.Blockscanner.(*ETPBlockScanner); ok {
+		bs.setScanConfig(wm.Config)
+		//新区块回调
+		if len(wm.Config.BlockNotifyListen) > 0 {
+			bs.SetBlockNotifier(NewHTTPBlockNotifier(wm.Config.BlockNotifyListen, wm.Config.BlockNotifyPath))
+		} else {
+			bs.SetBlockNotifier(nil)
+		}
+	}
+
+	//检查节点的网络、版本和创世区块，节点暂时无法访问时不阻止启动
+	if wm.Config.CheckNode {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultCallTimeout)
+		defer cancel()
+		info, err := wm.CheckNode(ctx)
+		if err != nil {
+			if IsErrorCode(err, ErrNodeIncompatible) {
+				return err
+			}
+			wm.Log.Std.Warning("can not check node info; unexpected error: %v", err)
+		} else {
+			wm.Log.Std.Info("node %s version: %s, network: %s, height: %d, peers: %d", wm.Config.ServerAPI, info.WalletVersion, info.Network(), info.Height, info.Peers)
+			if len(wm.Config.Network.GenesisHash) == 0 {
+				wm.Log.Std.Warning("genesisHash is not configured, skip genesis block check of network %s", wm.Config.Network.Name)
+			}
+		}
+	}
 
 	return nil
 }
 
 //InitAssetsConfig 初始化默认配置
 func (wm *WalletManager) InitAssetsConfig() (config.Configer, error) {
-	return config.NewConfigData("ini", []byte(""))
+	return config.NewConfigData("ini", []byte(DefaultAssetsConfig))
 }
 
 //GetAssetsLogger 获取资产账户日志工具
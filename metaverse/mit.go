@@ -0,0 +1,112 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"strings"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//MITProtocol MIT合约的协议名
+	MITProtocol = "mit"
+	//mitAddressPrefix MIT合约地址的前缀，与同名的MST资产区分
+	mitAddressPrefix = MITProtocol + ":"
+)
+
+//MITContract MIT对应的合约，每个MIT是数量为1、没有小数位的独立合约
+func (wm *WalletManager) MITContract(symbol string) openwallet.SmartContract {
+	address := mitAddressPrefix + symbol
+	return openwallet.SmartContract{
+		ContractID: openwallet.GenContractID(wm.Symbol(), address),
+		Symbol:     wm.Symbol(),
+		Address:    address,
+		Token:      symbol,
+		Protocol:   MITProtocol,
+		Name:       symbol,
+		Decimals:   0,
+	}
+}
+
+//MITSymbol 从MIT合约地址中取出MIT名称，不是MIT合约时返回false
+func MITSymbol(contract *openwallet.SmartContract) (string, bool) {
+	if contract.Protocol != MITProtocol && !strings.HasPrefix(contract.Address, mitAddressPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(contract.Address, mitAddressPrefix), true
+}
+
+//mitCoin MIT输入输出的币种
+func (wm *WalletManager) mitCoin(symbol string) openwallet.Coin {
+	contract := wm.MITContract(symbol)
+	return openwallet.Coin{
+		Symbol:     wm.Symbol(),
+		IsContract: true,
+		ContractID: contract.ContractID,
+		Contract:   contract,
+	}
+}
+
+//MITInfo 地址持有的MIT
+type MITInfo struct {
+
+	/*
+		{
+			"address" : "MDxBVnDcFV3eZbTToV6Eb96MuhiM8GM7vW",
+			"content" : "hello",
+			"height" : 1930000,
+			"status" : "registered",
+			"symbol" : "ART.001"
+		}
+	*/
+
+	Symbol  string
+	Address string
+	Content string
+	Status  string
+	Height  uint64
+}
+
+func NewMITInfo(json *gjson.Result) *MITInfo {
+	obj := &MITInfo{}
+	//解析json
+	obj.Symbol = gjson.Get(json.Raw, "symbol").String()
+	obj.Address = gjson.Get(json.Raw, "address").String()
+	obj.Content = gjson.Get(json.Raw, "content").String()
+	obj.Status = gjson.Get(json.Raw, "status").String()
+	obj.Height = gjson.Get(json.Raw, "height").Uint()
+
+	return obj
+}
+
+//GetAddressMITs 查询地址当前持有的MIT
+func (wm *WalletManager) GetAddressMITs(ctx context.Context, address string) ([]*MITInfo, *openwallet.Error) {
+
+	result, err := wm.WalletClient.Call(ctx, "getaddressmit", []interface{}{address})
+	if err != nil {
+		return nil, err
+	}
+
+	mits := make([]*MITInfo, 0)
+	for _, obj := range result.Array() {
+		mits = append(mits, NewMITInfo(&obj))
+	}
+
+	return mits, nil
+}
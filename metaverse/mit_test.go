@@ -0,0 +1,110 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+func TestWalletManager_MITContract(t *testing.T) {
+	wm := NewWalletManager()
+	contract := wm.MITContract("DNA")
+	if contract.Address != "mit:DNA" || contract.Protocol != MITProtocol || contract.Decimals != 0 {
+		t.Fatalf("unexpected mit contract: %+v", contract)
+	}
+	//与同名MST资产的合约不同
+	if contract.ContractID == openwallet.GenContractID(wm.Symbol(), "DNA") {
+		t.Fatalf("mit contract id should differ from the asset contract id")
+	}
+	if symbol, ok := MITSymbol(&contract); !ok || symbol != "DNA" {
+		t.Fatalf("MITSymbol = %s, %v", symbol, ok)
+	}
+	if _, ok := MITSymbol(&openwallet.SmartContract{Address: "DNA"}); ok {
+		t.Fatalf("asset contract should not be a mit")
+	}
+}
+
+func TestSimNode_BlockScanner_MIT(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+	owner := wallet.addresses[0].Address
+	receiver := wallet.addresses[1].Address
+	node.Mine(1)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.ScanBlockTask()
+
+	contract := wm.MITContract("ART.001")
+
+	//注册MIT，只有输出
+	registerTxID, _ := node.RegisterMIT(owner, "ART.001", "hello")
+	node.Mine(1)
+	bs.ScanBlockTask()
+
+	data := observer.extractData(registerTxID)
+	if len(data) == 0 || len(data[0].TxInputs) != 0 || len(data[0].TxOutputs) != 1 {
+		t.Fatalf("mit register is not extracted: %+v", data)
+	}
+	out := data[0].TxOutputs[0]
+	if out.Address != owner || out.Amount != "1" || out.Coin.ContractID != contract.ContractID || out.Coin.Contract.Protocol != MITProtocol {
+		t.Fatalf("unexpected mit output: %+v", out)
+	}
+	if out.GetExtParam().Get("mitStatus").String() != "registered" || out.GetExtParam().Get("mitContent").String() != "hello" {
+		t.Fatalf("unexpected mit ext param: %s", out.ExtParam)
+	}
+	if tx := data[0].Transaction; tx.Coin.ContractID != contract.ContractID || len(tx.To) != 1 || tx.To[0] != owner+":1" {
+		t.Fatalf("unexpected mit transaction: %+v", tx)
+	}
+
+	//转移MIT，同一账户的输入和输出
+	transferTxID, err := node.TransferMIT("ART.001", receiver)
+	if err != nil {
+		t.Fatalf("TransferMIT unexpected error: %v", err)
+	}
+	node.Mine(1)
+	bs.ScanBlockTask()
+
+	data = observer.extractData(transferTxID)
+	if len(data) == 0 || len(data[0].TxInputs) != 1 || len(data[0].TxOutputs) != 1 {
+		t.Fatalf("mit transfer is not extracted: %+v", data)
+	}
+	if in := data[0].TxInputs[0]; in.Address != owner || in.Amount != "1" || in.Coin.ContractID != contract.ContractID {
+		t.Fatalf("unexpected mit input: %+v", in)
+	}
+	if out := data[0].TxOutputs[0]; out.Address != receiver || out.GetExtParam().Get("mitStatus").String() != "transfered" {
+		t.Fatalf("unexpected mit output: %+v", out)
+	}
+	if tx := data[0].Transaction; len(tx.From) != 1 || tx.From[0] != owner+":1" || len(tx.To) != 1 || tx.To[0] != receiver+":1" {
+		t.Fatalf("unexpected mit transaction: %+v", tx)
+	}
+
+	//持有的MIT
+	mits, mitErr := wm.GetAddressMITs(context.Background(), receiver)
+	if mitErr != nil || len(mits) != 1 || mits[0].Symbol != "ART.001" || mits[0].Content != "hello" || mits[0].Status != "transfered" {
+		t.Fatalf("GetAddressMITs = %+v, %v", mits, mitErr)
+	}
+	if mits, _ := wm.GetAddressMITs(context.Background(), owner); len(mits) != 0 {
+		t.Fatalf("owner still holds mits: %+v", mits)
+	}
+
+	balances, balanceErr := wm.ContractDecoder.GetTokenBalanceByAddress(contract, owner, receiver)
+	if balanceErr != nil || len(balances) != 2 || balances[0].Balance.Balance != "0" || balances[1].Balance.Balance != "1" {
+		t.Fatalf("GetTokenBalanceByAddress = %+v, %v", balances, balanceErr)
+	}
+}
@@ -16,13 +16,95 @@
 package metaverse
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/blocktree/openwallet/v2/openwallet"
 	"github.com/tidwall/gjson"
 )
 
-type AssetAttachment struct {
-	Quantity string `json:"quantity"`
-	Symbol   string `json:"symbol"`
+//NodeInfo 节点信息
+type NodeInfo struct {
+
+	/*
+		{
+			"database-version" : "0.9.0",
+			"difficulty" : "4291798869",
+			"hash-rate" : 0,
+			"height" : 3584831,
+			"is-mining" : false,
+			"network-assets-count" : 356,
+			"peers" : 23,
+			"protocol-version" : 70012,
+			"testnet" : false,
+			"wallet-account-count" : 0,
+			"wallet-version" : "0.9.0"
+		}
+	*/
+
+	ProtocolVersion uint64
+	WalletVersion   string
+	DatabaseVersion string
+	IsTestNet       bool
+	Height          uint64
+	Peers           uint64
+	IsMining        bool
+}
+
+func NewNodeInfo(json *gjson.Result) *NodeInfo {
+	obj := &NodeInfo{}
+	//解析json
+	obj.ProtocolVersion = gjson.Get(json.Raw, "protocol-version").Uint()
+	obj.WalletVersion = gjson.Get(json.Raw, "wallet-version").String()
+	obj.DatabaseVersion = gjson.Get(json.Raw, "database-version").String()
+	obj.IsTestNet = gjson.Get(json.Raw, "testnet").Bool()
+	obj.Height = gjson.Get(json.Raw, "height").Uint()
+	obj.Peers = gjson.Get(json.Raw, "peers").Uint()
+	obj.IsMining = gjson.Get(json.Raw, "is-mining").Bool()
+
+	return obj
+}
+
+//Network 节点的网络名称
+func (info *NodeInfo) Network() string {
+	if info.IsTestNet {
+		return "testnet"
+	}
+	return "mainnet"
+}
+
+//compareVersion 比较点分隔的版本号，a<b返回-1，a==b返回0，a>b返回1。
+//忽略前缀v和后缀（如0.9.0-beta）。
+func compareVersion(a, b string) int {
+	parse := func(v string) []int {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+		if i := strings.IndexAny(v, "-+ "); i >= 0 {
+			v = v[:i]
+		}
+		nums := make([]int, 0)
+		for _, p := range strings.Split(v, ".") {
+			n, _ := strconv.Atoi(p)
+			nums = append(nums, n)
+		}
+		return nums
+	}
+	va, vb := parse(a), parse(b)
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		x, y := 0, 0
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
 }
 
 type Block struct {
@@ -111,26 +193,39 @@ type Transaction struct {
 }
 
 type Vin struct {
-	isCoinbase      bool
-	TxID            string
-	Vout            uint64
-	N               uint64
-	Addr            string
-	Value           string
-	AssetAttachment *AssetAttachment
-	IsToken         bool
-	LockScript      string
+	isCoinbase   bool
+	filled       bool //已从上一笔交易的输出填充
+	TxID         string
+	Vout         uint64
+	N            uint64
+	Addr         string
+	Value        string
+	Asset        *AssetQuantity      //花费的资产，从上一笔交易的输出填充
+	AssetMIT     *AssetMITAttachment //花费的MIT，从上一笔交易的输出填充
+	IsToken      bool
+	LockScript   string
+	Script       *Script //解析后的锁定脚本，从上一笔交易的输出填充
+	UnlockScript *Script //解析后的解锁脚本
 }
 
 type Vout struct {
 	N                 uint64
 	Addr              string
 	Value             string
-	Type              string
-	AssetAttachment   *AssetAttachment
-	IsToken           bool
+	Type              string //附件类型
+	IsToken           bool   //资产发行或资产转账
 	LockedHeightRange int64
 	LockScript        string
+	Script            *Script //解析后的锁定脚本
+
+	//附件内容，只有Type对应的字段不为空
+	ETPAward      *ETPAwardAttachment
+	AssetIssue    *AssetIssueAttachment
+	AssetTransfer *AssetTransferAttachment
+	AssetCert     *AssetCertAttachment
+	AssetMIT      *AssetMITAttachment
+	DID           *DIDAttachment
+	Message       *MessageAttachment
 }
 
 func (wm *WalletManager) NewTransaction(json *gjson.Result) *Transaction {
@@ -177,6 +272,10 @@ func (wm *WalletManager) NewTransaction(json *gjson.Result) *Transaction {
 		for i, vin := range vins.Array() {
 			input := NewTxInput(&vin)
 			input.N = uint64(i)
+			//节点没有返回地址时从解锁脚本计算
+			if len(input.Addr) == 0 && !input.isCoinbase {
+				input.Addr = wm.ScriptAddress(input.UnlockScript)
+			}
 			obj.Vins = append(obj.Vins, input)
 		}
 	}
@@ -185,6 +284,10 @@ func (wm *WalletManager) NewTransaction(json *gjson.Result) *Transaction {
 	if vouts := gjson.Get(json.Raw, "outputs"); vouts.IsArray() {
 		for _, vout := range vouts.Array() {
 			output := NewTxOut(&vout)
+			//节点没有返回地址时从锁定脚本计算
+			if len(output.Addr) == 0 {
+				output.Addr = wm.ScriptAddress(output.Script)
+			}
 			obj.Vouts = append(obj.Vouts, output)
 		}
 	}
@@ -210,6 +313,7 @@ func NewTxInput(json *gjson.Result) *Vin {
 	obj.TxID = gjson.Get(json.Raw, "previous_output.hash").String()
 	obj.Vout = gjson.Get(json.Raw, "previous_output.index").Uint()
 	obj.Addr = gjson.Get(json.Raw, "address").String()
+	obj.UnlockScript = parseScriptText(gjson.Get(json.Raw, "script").String())
 
 	if obj.TxID == "0000000000000000000000000000000000000000000000000000000000000000" {
 		obj.isCoinbase = true
@@ -251,20 +355,12 @@ func NewTxOut(json *gjson.Result) *Vout {
 	obj.Value = gjson.Get(json.Raw, "value").String()
 	obj.N = gjson.Get(json.Raw, "index").Uint()
 	obj.Addr = gjson.Get(json.Raw, "address").String()
-	obj.Type = gjson.Get(json.Raw, "attachment.type").String()
 	obj.LockedHeightRange = gjson.Get(json.Raw, "locked_height_range").Int()
 	obj.LockScript = gjson.Get(json.Raw, "script").String()
+	obj.Script = parseScriptText(obj.LockScript)
 
-	if obj.Type == "etp" {
-		obj.IsToken = false
-	} else if obj.Type == "asset-transfer" {
-		obj.IsToken = true
-	}
-
-	obj.AssetAttachment = &AssetAttachment{
-		Quantity: gjson.Get(json.Raw, "attachment.quantity").String(),
-		Symbol:   gjson.Get(json.Raw, "attachment.symbol").String(),
-	}
+	attachment := gjson.Get(json.Raw, "attachment")
+	obj.parseAttachment(&attachment)
 
 	return &obj
 }
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//网络名称
+const (
+	NetworkMainnet = "mainnet"
+	NetworkTestnet = "testnet"
+	NetworkRegtest = "regtest" //私有链，地址版本和创世区块通过配置指定
+)
+
+//Network 网络配置
+type Network struct {
+	Name        string
+	IsTestNet   bool                            //节点getinfo报告的testnet标记
+	Params      metaverse_addrdec.NetworkParams //地址和WIF版本
+	GenesisHash string                          //创世区块hash，为空时不检查
+	DefaultFee  decimal.Decimal                 //没有配置minFees时使用的手续费
+}
+
+//MainNetGenesisHash 主网创世区块hash
+const MainNetGenesisHash = "b81848ef9ae86e84c3da26564bc6ab3a79efc628239d11471ab5cd25c0684c2d"
+
+//NewNetwork 预设的网络配置。
+//主网内置创世区块hash，测试网和私有链需要在配置中用genesisHash指定，否则启动时只检查节点报告的网络。
+func NewNetwork(name string) (*Network, error) {
+	switch strings.ToLower(name) {
+	case NetworkMainnet:
+		return &Network{
+			Name:        NetworkMainnet,
+			IsTestNet:   false,
+			Params:      metaverse_addrdec.MainNetParams,
+			GenesisHash: MainNetGenesisHash,
+			DefaultFee:  decimal.New(1, -4),
+		}, nil
+	case NetworkTestnet:
+		return &Network{
+			Name:       NetworkTestnet,
+			IsTestNet:  true,
+			Params:     metaverse_addrdec.TestNetParams,
+			DefaultFee: decimal.New(1, -4),
+		}, nil
+	case NetworkRegtest:
+		params := metaverse_addrdec.TestNetParams
+		params.Name = NetworkRegtest
+		return &Network{
+			Name:       NetworkRegtest,
+			IsTestNet:  true,
+			Params:     params,
+			DefaultFee: decimal.New(1, -4),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown network: %s", name)
+}
+
+//VerifyGenesis 检查节点的创世区块hash与网络配置一致
+func (wm *WalletManager) VerifyGenesis(ctx context.Context) *openwallet.Error {
+
+	network := wm.Config.Network
+	if network == nil || len(network.GenesisHash) == 0 {
+		return nil
+	}
+
+	genesis, err := wm.GetBlockHeader(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(genesis.Hash, network.GenesisHash) {
+		return openwallet.Errorf(ErrNodeIncompatible, "node genesis block hash is %s, but %s genesis block hash is %s", genesis.Hash, network.Name, network.GenesisHash)
+	}
+
+	return nil
+}
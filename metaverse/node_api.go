@@ -16,6 +16,10 @@
 package metaverse
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/openwallet"
 	"github.com/imroc/req"
@@ -23,22 +27,56 @@ import (
 )
 
 type ClientInterface interface {
-	Call(path string, request []interface{}) (*gjson.Result, error)
+	Call(ctx context.Context, path string, request []interface{}) (*gjson.Result, error)
 }
 
 // A Client is a Bitcoin RPC client. It performs RPCs over HTTP using JSON
 // request and responses. A Client must be configured with a secret token
 // to authenticate with other Cores on the network.
 type Client struct {
-	BaseURL string
-	Debug   bool
-	client  *req.Req
+	BaseURL   string
+	Debug     bool
+	client    *req.Req
+	Mode      CassetteMode           //录制回放模式
+	Cassette  *Cassette              //录制文件
+	BatchSize int                    //每个批量请求最多包含的调用数量，不大于1时逐个调用
+	Pool      *NodePool              //多节点池，不为空时请求由节点池发送
+	Policy    *CallPolicy            //默认的超时和重试策略
+	Policies  map[string]*CallPolicy //各方法的超时和重试策略，没有设置的方法使用Policy
+}
+
+const (
+	//DefaultBatchSize 默认每个批量请求最多包含的调用数量
+	DefaultBatchSize = 100
+)
+
+//BatchRequest 批量请求中的一个调用
+type BatchRequest struct {
+	Method string
+	Params []interface{}
+}
+
+//NewBatchRequest 创建批量请求中的一个调用
+func NewBatchRequest(method string, params ...interface{}) *BatchRequest {
+	if params == nil {
+		params = []interface{}{}
+	}
+	return &BatchRequest{Method: method, Params: params}
+}
+
+//BatchResult 批量请求中一个调用的结果，Err不为空时调用失败
+type BatchResult struct {
+	Result *gjson.Result
+	Err    *openwallet.Error
 }
 
 func NewClient(url string, debug bool) *Client {
 	c := Client{
-		BaseURL: url,
-		Debug:   debug,
+		BaseURL:   url,
+		Debug:     debug,
+		BatchSize: DefaultBatchSize,
+		Policy:    NewCallPolicy(),
+		Policies:  defaultCallPolicies(),
 	}
 
 	api := req.New()
@@ -47,37 +85,375 @@ func NewClient(url string, debug bool) *Client {
 	return &c
 }
 
+//NewPoolClient 创建多节点客户端，启动节点健康检查，请求失败时切换节点
+func NewPoolClient(urls []string, maxLag uint64, debug bool) *Client {
+	url := ""
+	if len(urls) > 0 {
+		url = urls[0]
+	}
+	c := NewClient(url, debug)
+	c.Pool = NewNodePool(urls, maxLag)
+	c.Pool.Start()
+	return c
+}
+
+//Close 停止节点池的健康检查
+func (c *Client) Close() {
+	if c.Pool != nil {
+		c.Pool.Stop()
+	}
+}
+
+//NewRecordClient 创建录制客户端，所有请求和节点的原始响应写入cassettePath
+func NewRecordClient(url, cassettePath string, debug bool) *Client {
+	c := NewClient(url, debug)
+	c.Mode = CassetteRecord
+	c.Cassette = NewCassette(cassettePath)
+	return c
+}
+
+//NewReplayClient 创建回放客户端，从cassettePath读取录制的响应，不连接节点
+func NewReplayClient(cassettePath string, debug bool) (*Client, error) {
+	cassette, err := LoadCassette(cassettePath)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		Debug:     debug,
+		Mode:      CassetteReplay,
+		Cassette:  cassette,
+		BatchSize: DefaultBatchSize,
+		Policy:    NewCallPolicy(),
+		Policies:  defaultCallPolicies(),
+	}
+	return c, nil
+}
+
+//SetPolicy 设置方法的超时和重试策略
+func (c *Client) SetPolicy(method string, policy *CallPolicy) {
+	if c.Policies == nil {
+		c.Policies = make(map[string]*CallPolicy)
+	}
+	c.Policies[method] = policy
+}
+
+//policy 方法的超时和重试策略
+func (c *Client) policy(method string) *CallPolicy {
+	if p, ok := c.Policies[method]; ok && p != nil {
+		return p
+	}
+	if c.Policy != nil {
+		return c.Policy
+	}
+	return NewCallPolicy()
+}
+
 // Call calls a remote procedure on another node, specified by the path.
-func (c *Client) Call(path string, request []interface{}) (*gjson.Result, *openwallet.Error) {
+func (c *Client) Call(ctx context.Context, path string, request []interface{}) (*gjson.Result, *openwallet.Error) {
+
+	if request == nil {
+		request = []interface{}{}
+	}
 
 	var (
-		body = make(map[string]interface{}, 0)
+		respBytes []byte
+		err       *openwallet.Error
 	)
 
-	if c.client == nil {
-		return nil, openwallet.Errorf(openwallet.ErrUnknownException, "API url is not setup. ")
+	if c.Mode == CassetteReplay {
+		respBytes, err = c.replay(ctx, path, request)
+	} else {
+		respBytes, err = c.post(ctx, c.policy(path), newRequestBody("1", path, request))
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	authHeader := req.Header{
-		"Accept":       "application/json",
-		"Content-Type": "application/json",
+	if c.Mode == CassetteRecord && c.Cassette != nil {
+		if recErr := c.Cassette.Record(path, request, respBytes); recErr != nil {
+			log.Std.Warning("cassette record %s failed: %v", path, recErr)
+		}
+	}
+
+	resp := gjson.ParseBytes(respBytes)
+	respErr := isError(&resp)
+	if respErr != nil {
+		return nil, respErr
+	}
+
+	result := resp.Get("result")
+
+	return &result, nil
+}
+
+//CallAll 发送请求到节点池中所有可用的节点，返回第一个成功的结果，全部失败时返回第一个错误。
+//没有节点池时等同于Call。
+func (c *Client) CallAll(ctx context.Context, path string, request []interface{}) (*gjson.Result, *openwallet.Error) {
+
+	if c.Pool == nil || c.Mode == CassetteReplay {
+		return c.Call(ctx, path, request)
 	}
 
 	if request == nil {
 		request = []interface{}{}
 	}
 
-	//json-rpc
-	body["jsonrpc"] = "2.0"
-	body["id"] = "1"
-	body["method"] = path
-	body["params"] = request
+	var (
+		result   *gjson.Result
+		firstErr *openwallet.Error
+	)
+
+	responses := c.Pool.PostAll(ctx, c.policy(path).Timeout, newRequestBody("1", path, request))
+
+	for _, endpoint := range c.Pool.Endpoints {
+		respBytes, ok := responses[endpoint.URL]
+		if !ok {
+			continue
+		}
+		if respBytes == nil {
+			if firstErr == nil {
+				firstErr = openwallet.Errorf(openwallet.ErrNetworkRequestFailed, "node %s request failed", endpoint.URL)
+			}
+			continue
+		}
+
+		resp := gjson.ParseBytes(respBytes)
+		if respErr := isError(&resp); respErr != nil {
+			if c.Debug {
+				log.Std.Info("node %s %s failed: %v", endpoint.URL, path, respErr)
+			}
+			if firstErr == nil {
+				firstErr = respErr
+			}
+			continue
+		}
+
+		if result == nil {
+			if c.Mode == CassetteRecord && c.Cassette != nil {
+				if recErr := c.Cassette.Record(path, request, respBytes); recErr != nil {
+					log.Std.Warning("cassette record %s failed: %v", path, recErr)
+				}
+			}
+			r := resp.Get("result")
+			result = &r
+		}
+	}
+
+	if result != nil {
+		return result, nil
+	}
+
+	if firstErr == nil {
+		//没有可用节点时按普通请求依次尝试
+		return c.Call(ctx, path, request)
+	}
+
+	return nil, firstErr
+}
+
+//CallBatch 以json-rpc 2.0批量数组发送多个调用，结果按id匹配，与requests顺序一致。
+//单个调用的错误记录在对应的BatchResult.Err，只有请求本身失败时才返回错误。
+//调用数量超过BatchSize时分多次请求；只有一个调用、BatchSize不大于1或节点拒绝批量请求时逐个调用。
+func (c *Client) CallBatch(ctx context.Context, requests []*BatchRequest) ([]*BatchResult, *openwallet.Error) {
+
+	if len(requests) == 1 || c.BatchSize <= 1 {
+		return c.callEach(ctx, requests), nil
+	}
+
+	results := make([]*BatchResult, 0, len(requests))
+
+	for start := 0; start < len(requests); start += c.BatchSize {
+		end := start + c.BatchSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+		chunk := requests[start:end]
+
+		responses, err := c.batchResponses(ctx, chunk)
+		if err != nil {
+			if !IsErrorCode(err, ErrRPCInvalidRequest) {
+				return nil, err
+			}
+			//节点或代理不支持批量请求
+			log.Std.Warning("batch request is rejected: %v, call one by one", err)
+			results = append(results, c.callEach(ctx, chunk)...)
+			continue
+		}
+
+		for i, r := range chunk {
+			if responses[i] == nil {
+				results = append(results, &BatchResult{
+					Err: openwallet.Errorf(openwallet.ErrCallFullNodeAPIFailed, "%s has no response in batch", r.Method),
+				})
+				continue
+			}
+
+			if c.Mode == CassetteRecord && c.Cassette != nil {
+				if recErr := c.Cassette.Record(r.Method, r.Params, responses[i]); recErr != nil {
+					log.Std.Warning("cassette record %s failed: %v", r.Method, recErr)
+				}
+			}
+
+			resp := gjson.ParseBytes(responses[i])
+			if respErr := isError(&resp); respErr != nil {
+				results = append(results, &BatchResult{Err: respErr})
+				continue
+			}
+			result := resp.Get("result")
+			results = append(results, &BatchResult{Result: &result})
+		}
+	}
+
+	return results, nil
+}
+
+//callEach 逐个发送调用，每个调用的错误记录在对应的BatchResult.Err
+func (c *Client) callEach(ctx context.Context, requests []*BatchRequest) []*BatchResult {
+	results := make([]*BatchResult, 0, len(requests))
+	for _, r := range requests {
+		result, err := c.Call(ctx, r.Method, r.Params)
+		results = append(results, &BatchResult{Result: result, Err: err})
+	}
+	return results
+}
+
+//batchResponses 获取批量调用的原始响应，按requests顺序排列，缺少响应的位置为nil
+func (c *Client) batchResponses(ctx context.Context, requests []*BatchRequest) ([][]byte, *openwallet.Error) {
+
+	responses := make([][]byte, len(requests))
+
+	//回放时逐个查找录制的响应，录制文件不区分单个请求和批量请求
+	if c.Mode == CassetteReplay {
+		for i, r := range requests {
+			respBytes, err := c.replay(ctx, r.Method, r.Params)
+			if err != nil {
+				respBytes = []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":"%d","error":{"code":%d,"message":%q}}`, i, err.Code(), err.Error()))
+			}
+			responses[i] = respBytes
+		}
+		return responses, nil
+	}
+
+	body := make([]map[string]interface{}, 0, len(requests))
+	for i, r := range requests {
+		body = append(body, newRequestBody(strconv.Itoa(i), r.Method, r.Params))
+	}
+
+	//批量请求中的方法相同时使用该方法的策略，否则使用默认策略
+	policy := c.policy(requests[0].Method)
+	for _, r := range requests {
+		if r.Method != requests[0].Method {
+			policy = c.policy("")
+			break
+		}
+	}
+
+	//不支持批量请求的节点或代理可能返回5xx，只重试连接失败和超时，其它错误视为拒绝批量请求
+	respBytes, err := c.postRetry(ctx, policy, body, isTransportError)
+	if err != nil {
+		if isTransportError(err) || IsErrorCode(err, ErrRequestCanceled) {
+			return nil, err
+		}
+		return nil, openwallet.Errorf(ErrRPCInvalidRequest, "batch request is rejected: %v", err)
+	}
+
+	resp := gjson.ParseBytes(respBytes)
+	if !resp.IsArray() {
+		//节点不支持批量请求时可能返回单个错误对象、纯文本或空响应
+		if respErr := isError(&resp); respErr != nil && resp.Get("error").Exists() {
+			return nil, openwallet.Errorf(ErrRPCInvalidRequest, "batch request is rejected: %v", respErr)
+		}
+		return nil, openwallet.Errorf(ErrRPCInvalidRequest, "batch response is not an array")
+	}
+
+	for _, item := range resp.Array() {
+		i, convErr := strconv.Atoi(item.Get("id").String())
+		if convErr != nil || i < 0 || i >= len(requests) || responses[i] != nil {
+			continue
+		}
+		responses[i] = []byte(item.Raw)
+	}
+
+	return responses, nil
+}
+
+//newRequestBody json-rpc请求体
+func newRequestBody(id, method string, params []interface{}) map[string]interface{} {
+	if params == nil {
+		params = []interface{}{}
+	}
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	}
+}
+
+//post 发送json-rpc请求，返回节点的原始响应。
+//连接失败、超时或节点返回不带json-rpc错误的5xx时按policy退避重试，ctx取消时立即返回。
+func (c *Client) post(ctx context.Context, policy *CallPolicy, body interface{}) ([]byte, *openwallet.Error) {
+	return c.postRetry(ctx, policy, body, IsRetryable)
+}
+
+//postRetry 发送json-rpc请求，retryable判断的错误按policy退避重试
+func (c *Client) postRetry(ctx context.Context, policy *CallPolicy, body interface{}, retryable func(err error) bool) ([]byte, *openwallet.Error) {
+
+	for attempt := 0; ; attempt++ {
+
+		respBytes, err := c.postOnce(ctx, policy, body)
+		if err == nil {
+			return respBytes, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, openwallet.Errorf(ErrRequestCanceled, "request canceled: %v", ctx.Err())
+		}
+
+		if !retryable(err) || attempt >= policy.MaxRetries {
+			if attempt > 0 {
+				log.Std.Warning("request failed after %d retries", attempt)
+			}
+			return nil, err
+		}
+
+		wait := policy.backoff(attempt)
+		log.Std.Warning("request failed: %v, retry after %v", err, wait)
+		if !sleepContext(ctx, wait) {
+			return nil, openwallet.Errorf(ErrRequestCanceled, "request canceled: %v", ctx.Err())
+		}
+	}
+}
+
+//isTransportError 连接失败或超时，节点没有返回响应
+func isTransportError(err error) bool {
+	return IsErrorCode(err, openwallet.ErrNetworkRequestFailed)
+}
+
+//postOnce 发送一次请求，每个节点的请求超时时间为policy.Timeout
+func (c *Client) postOnce(ctx context.Context, policy *CallPolicy, body interface{}) ([]byte, *openwallet.Error) {
+
+	if c.Pool != nil {
+		return c.Pool.Post(ctx, policy.Timeout, body, c.Debug)
+	}
+
+	if c.client == nil {
+		return nil, openwallet.Errorf(openwallet.ErrUnknownException, "API url is not setup. ")
+	}
+
+	ctx, cancel := policy.withTimeout(ctx)
+	defer cancel()
+
+	authHeader := req.Header{
+		"Accept":       "application/json",
+		"Content-Type": "application/json",
+	}
 
 	if c.Debug {
 		log.Std.Info("Start Request API...")
 	}
 
-	r, err := c.client.Post(c.BaseURL, req.BodyJSON(&body), authHeader)
+	r, err := c.client.Post(c.BaseURL, req.BodyJSON(body), authHeader, ctx)
 
 	if c.Debug {
 		log.Std.Info("Request API Completed")
@@ -88,18 +464,52 @@ func (c *Client) Call(path string, request []interface{}) (*gjson.Result, *openw
 	}
 
 	if err != nil {
-		return nil, openwallet.ConvertError(err)
+		return nil, openwallet.Errorf(openwallet.ErrNetworkRequestFailed, "%v", err)
 	}
 
-	resp := gjson.ParseBytes(r.Bytes())
-	respErr := isError(&resp)
-	if respErr != nil {
-		return nil, respErr
+	//节点返回5xx时响应体可能是json-rpc错误，由调用方按节点的错误码处理
+	respBytes := r.Bytes()
+	if status := r.Response().StatusCode; status >= 500 && !isRPCResponse(respBytes) {
+		return nil, openwallet.Errorf(openwallet.ErrCallFullNodeAPIFailed, "node returned http status %d", status)
 	}
 
-	result := resp.Get("result")
+	return respBytes, nil
+}
 
-	return &result, nil
+//isRPCResponse 响应体是否为json-rpc响应或批量响应
+func isRPCResponse(body []byte) bool {
+	if !gjson.ValidBytes(body) {
+		return false
+	}
+	resp := gjson.ParseBytes(body)
+	if resp.IsArray() {
+		items := resp.Array()
+		return len(items) > 0 && (items[0].Get("error").IsObject() || items[0].Get("result").Exists())
+	}
+	return resp.Get("error").IsObject() || resp.Get("result").Exists()
+}
+
+//replay 从录制文件读取响应
+func (c *Client) replay(ctx context.Context, path string, request []interface{}) ([]byte, *openwallet.Error) {
+
+	if ctx.Err() != nil {
+		return nil, openwallet.Errorf(ErrRequestCanceled, "request canceled: %v", ctx.Err())
+	}
+
+	if c.Cassette == nil {
+		return nil, openwallet.Errorf(openwallet.ErrUnknownException, "cassette is not setup. ")
+	}
+
+	respBytes, err := c.Cassette.Replay(path, request)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrUnknownException, err.Error())
+	}
+
+	if c.Debug {
+		log.Std.Info("Replay API %s: %s", path, respBytes)
+	}
+
+	return respBytes, nil
 }
 
 //isError 是否报错
@@ -123,13 +533,13 @@ func isError(result *gjson.Result) *openwallet.Error {
 	if !result.Get("error").IsObject() {
 
 		if !result.Get("result").Exists() {
-			return openwallet.Errorf(openwallet.ErrUnknownException, "Response is empty! ")
+			return openwallet.Errorf(openwallet.ErrCallFullNodeAPIFailed, "Response is empty! ")
 		}
 
 		return nil
 	}
 
-	err = openwallet.Errorf(result.Get("error.code").Uint(), result.Get("error.message").String())
+	err = NewNodeError(result.Get("error.code").Int(), result.Get("error.message").String())
 
 	return err
 }
@@ -0,0 +1,309 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/imroc/req"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//DefaultNodeMaxLag 节点高度落后最高节点超过该区块数时标记为不可用
+	DefaultNodeMaxLag = uint64(10)
+	//DefaultNodeProbeInterval 节点健康检查的间隔
+	DefaultNodeProbeInterval = 30 * time.Second
+)
+
+//NodeEndpoint 节点池中的一个节点
+type NodeEndpoint struct {
+	URL       string
+	Height    uint64    //最近一次检查的区块高度
+	Healthy   bool      //是否可用
+	LastError error     //最近一次请求失败的原因
+	LastProbe time.Time //最近一次检查的时间
+}
+
+//NodePool 多节点池，定期用getblockheader检查节点高度，请求失败时切换到其他节点
+type NodePool struct {
+	Endpoints     []*NodeEndpoint
+	MaxLag        uint64        //允许落后最高节点的区块数
+	ProbeInterval time.Duration //健康检查间隔
+
+	mu     sync.RWMutex
+	client *req.Req
+	quit   chan struct{}
+}
+
+//NewNodePool 创建节点池，节点按urls的顺序优先使用
+func NewNodePool(urls []string, maxLag uint64) *NodePool {
+	pool := &NodePool{
+		Endpoints:     make([]*NodeEndpoint, 0, len(urls)),
+		MaxLag:        maxLag,
+		ProbeInterval: DefaultNodeProbeInterval,
+		client:        req.New(),
+	}
+	//提前创建http客户端，req延迟创建时并发请求会竞争
+	pool.client.Client()
+	for _, url := range urls {
+		pool.Endpoints = append(pool.Endpoints, &NodeEndpoint{URL: url, Healthy: true})
+	}
+	return pool
+}
+
+//Start 启动定期健康检查，立即执行一次检查
+func (pool *NodePool) Start() {
+	pool.mu.Lock()
+	if pool.quit != nil {
+		pool.mu.Unlock()
+		return
+	}
+	quit := make(chan struct{})
+	pool.quit = quit
+	interval := pool.ProbeInterval
+	if interval <= 0 {
+		interval = DefaultNodeProbeInterval
+	}
+	pool.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			pool.Probe()
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+//Stop 停止健康检查
+func (pool *NodePool) Stop() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.quit != nil {
+		close(pool.quit)
+		pool.quit = nil
+	}
+}
+
+//Probe 检查所有节点的区块高度，请求失败或落后最高节点超过MaxLag的节点标记为不可用
+func (pool *NodePool) Probe() {
+
+	type probeResult struct {
+		height uint64
+		err    error
+	}
+
+	results := make([]probeResult, len(pool.Endpoints))
+
+	var wg sync.WaitGroup
+	for i, endpoint := range pool.Endpoints {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			height, err := pool.probeHeight(url)
+			results[i] = probeResult{height: height, err: err}
+		}(i, endpoint.URL)
+	}
+	wg.Wait()
+
+	best := uint64(0)
+	for _, r := range results {
+		if r.err == nil && r.height > best {
+			best = r.height
+		}
+	}
+
+	now := time.Now()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for i, endpoint := range pool.Endpoints {
+		r := results[i]
+		endpoint.LastProbe = now
+		if r.err != nil {
+			endpoint.Healthy = false
+			endpoint.LastError = r.err
+			continue
+		}
+		endpoint.Height = r.height
+		if best-r.height > pool.MaxLag {
+			endpoint.Healthy = false
+			endpoint.LastError = fmt.Errorf("node height %d is %d blocks behind best height %d", r.height, best-r.height, best)
+			continue
+		}
+		endpoint.Healthy = true
+		endpoint.LastError = nil
+	}
+}
+
+//probeHeight 获取节点的最新区块高度
+func (pool *NodePool) probeHeight(url string) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCallTimeout)
+	defer cancel()
+	respBytes, err := pool.post(ctx, url, newRequestBody("1", "getblockheader", nil))
+	if err != nil {
+		return 0, err
+	}
+	resp := gjson.ParseBytes(respBytes)
+	if respErr := isError(&resp); respErr != nil {
+		return 0, respErr
+	}
+	return resp.Get("result.number").Uint(), nil
+}
+
+//Healthy 返回可用的节点
+func (pool *NodePool) Healthy() []*NodeEndpoint {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	endpoints := make([]*NodeEndpoint, 0, len(pool.Endpoints))
+	for _, endpoint := range pool.Endpoints {
+		if endpoint.Healthy {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+//candidates 请求节点的顺序，可用的节点在前，全部不可用时仍然依次尝试
+func (pool *NodePool) candidates() []string {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	healthy := make([]string, 0, len(pool.Endpoints))
+	unhealthy := make([]string, 0)
+	for _, endpoint := range pool.Endpoints {
+		if endpoint.Healthy {
+			healthy = append(healthy, endpoint.URL)
+		} else {
+			unhealthy = append(unhealthy, endpoint.URL)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+//markFailed 标记节点请求失败，等待下一次健康检查恢复
+func (pool *NodePool) markFailed(url string, err error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for _, endpoint := range pool.Endpoints {
+		if endpoint.URL == url {
+			endpoint.Healthy = false
+			endpoint.LastError = err
+		}
+	}
+}
+
+//withNodeTimeout 单个节点请求的超时，timeout不大于0时只跟随调用方的ctx
+func withNodeTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+//Post 发送请求，每个节点的请求超时时间为timeout，节点连接失败或超时时标记不可用并切换到下一个节点
+func (pool *NodePool) Post(ctx context.Context, timeout time.Duration, body interface{}, debug bool) ([]byte, *openwallet.Error) {
+
+	var lastErr error
+
+	for _, url := range pool.candidates() {
+		nodeCtx, cancel := withNodeTimeout(ctx, timeout)
+		respBytes, err := pool.post(nodeCtx, url, body)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				//调用方取消，不是节点的问题
+				return nil, openwallet.Errorf(ErrRequestCanceled, "request canceled: %v", ctx.Err())
+			}
+			log.Std.Warning("node %s request failed, unexpected error: %v", url, err)
+			pool.markFailed(url, err)
+			lastErr = err
+			continue
+		}
+		if debug {
+			log.Std.Info("node %s response: %s", url, respBytes)
+		}
+		return respBytes, nil
+	}
+
+	if lastErr == nil {
+		return nil, openwallet.Errorf(openwallet.ErrUnknownException, "API url is not setup. ")
+	}
+	return nil, openwallet.Errorf(openwallet.ErrNetworkRequestFailed, "all nodes failed: %v", lastErr)
+}
+
+//PostAll 发送请求到所有可用的节点，返回每个节点的响应，节点连接失败或超时时响应为nil
+func (pool *NodePool) PostAll(ctx context.Context, timeout time.Duration, body interface{}) map[string][]byte {
+
+	endpoints := pool.Healthy()
+	responses := make(map[string][]byte)
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			nodeCtx, cancel := withNodeTimeout(ctx, timeout)
+			respBytes, err := pool.post(nodeCtx, url, body)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				log.Std.Warning("node %s request failed, unexpected error: %v", url, err)
+				pool.markFailed(url, err)
+			}
+			mu.Lock()
+			responses[url] = respBytes
+			mu.Unlock()
+		}(endpoint.URL)
+	}
+	wg.Wait()
+
+	return responses
+}
+
+//post 发送请求到指定节点，连接失败或节点返回不是json-rpc响应的5xx时返回错误
+func (pool *NodePool) post(ctx context.Context, url string, body interface{}) ([]byte, error) {
+
+	authHeader := req.Header{
+		"Accept":       "application/json",
+		"Content-Type": "application/json",
+	}
+
+	r, err := pool.client.Post(url, req.BodyJSON(body), authHeader, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	respBytes := r.Bytes()
+	if status := r.Response().StatusCode; status >= 500 && !isRPCResponse(respBytes) {
+		return nil, fmt.Errorf("node returned http status %d", status)
+	}
+
+	return respBytes, nil
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/config"
+	"github.com/blocktree/metaverse-adapter/metaverse_simnode"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//testDeadNodeURL 已关闭的节点地址，请求会连接失败
+func testDeadNodeURL() string {
+	node := metaverse_simnode.NewNode(false)
+	url := node.Start()
+	node.Close()
+	return url
+}
+
+func testStartSimNode(t *testing.T) (*metaverse_simnode.Node, string) {
+	node := metaverse_simnode.NewNode(false)
+	url := node.Start()
+	t.Cleanup(node.Close)
+	return node, url
+}
+
+func TestNodePool_Failover(t *testing.T) {
+	node, url := testStartSimNode(t)
+	node.Mine(3)
+
+	dead := testDeadNodeURL()
+	client := NewClient(dead, false)
+	client.Pool = NewNodePool([]string{dead, url}, DefaultNodeMaxLag)
+
+	result, err := client.Call(context.Background(), "getblockheader", nil)
+	if err != nil {
+		t.Fatalf("Call unexpected error: %v", err)
+	}
+	if result.Get("number").Uint() != 3 {
+		t.Fatalf("unexpected header: %s", result.Raw)
+	}
+
+	healthy := client.Pool.Healthy()
+	if len(healthy) != 1 || healthy[0].URL != url {
+		t.Fatalf("dead node should be marked unhealthy: %+v", client.Pool.Endpoints[0])
+	}
+
+	//所有节点都不可用时仍然依次尝试
+	client.Pool.markFailed(url, fmt.Errorf("test"))
+	if _, err := client.Call(context.Background(), "getblockheader", nil); err != nil {
+		t.Fatalf("Call with all nodes unhealthy unexpected error: %v", err)
+	}
+
+	client.Pool = NewNodePool([]string{dead}, DefaultNodeMaxLag)
+	client.Policy.Backoff = time.Millisecond
+	if _, err := client.Call(context.Background(), "getblockheader", nil); err == nil {
+		t.Fatalf("Call with only dead node should fail")
+	}
+}
+
+//testHungNodeURL 不返回响应的节点地址，请求阻塞到调用方超时或取消
+func testHungNodeURL(t *testing.T) string {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	return server.URL
+}
+
+func TestNodePool_FailoverTimeout(t *testing.T) {
+	node, url := testStartSimNode(t)
+	node.Mine(3)
+
+	hung := testHungNodeURL(t)
+	client := NewClient(hung, false)
+	client.Pool = NewNodePool([]string{hung, url}, DefaultNodeMaxLag)
+	client.Policy.Timeout = 300 * time.Millisecond
+	client.Policy.Backoff = time.Millisecond
+
+	//超时的节点标记为不可用，切换到下一个节点
+	result, err := client.Call(context.Background(), "getblockheader", nil)
+	if err != nil {
+		t.Fatalf("Call unexpected error: %v", err)
+	}
+	if result.Get("number").Uint() != 3 {
+		t.Fatalf("unexpected header: %s", result.Raw)
+	}
+	if endpoint := client.Pool.Endpoints[0]; endpoint.Healthy || endpoint.LastError == nil {
+		t.Fatalf("timed out node should be marked unhealthy: %+v", endpoint)
+	}
+	if healthy := client.Pool.Healthy(); len(healthy) != 1 || healthy[0].URL != url {
+		t.Fatalf("healthy nodes = %+v, want %s", healthy, url)
+	}
+
+	//广播到所有节点时超时的节点同样标记为不可用
+	client.Pool = NewNodePool([]string{hung, url}, DefaultNodeMaxLag)
+	if _, err := client.CallAll(context.Background(), "getblockheader", nil); err != nil {
+		t.Fatalf("CallAll unexpected error: %v", err)
+	}
+	if endpoint := client.Pool.Endpoints[0]; endpoint.Healthy || endpoint.LastError == nil {
+		t.Fatalf("timed out node should be marked unhealthy by CallAll: %+v", endpoint)
+	}
+
+	//所有节点都超时时返回可重试的网络错误
+	client.Pool = NewNodePool([]string{hung}, DefaultNodeMaxLag)
+	client.Policy.MaxRetries = 0
+	_, err = client.Call(context.Background(), "getblockheader", nil)
+	if err == nil || err.Code() != openwallet.ErrNetworkRequestFailed || !IsRetryable(err) {
+		t.Fatalf("Call with only a hung node = %v, want retryable ErrNetworkRequestFailed", err)
+	}
+
+	//调用方取消时不标记节点不可用
+	client.Pool = NewNodePool([]string{hung, url}, DefaultNodeMaxLag)
+	client.Policy.Timeout = 5 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = client.Call(ctx, "getblockheader", nil)
+	if err == nil || err.Code() != ErrRequestCanceled {
+		t.Fatalf("Call with canceled ctx = %v, want ErrRequestCanceled", err)
+	}
+	if !client.Pool.Endpoints[0].Healthy {
+		t.Fatalf("node should not be marked unhealthy when the caller cancels")
+	}
+}
+
+func TestNodePool_Probe(t *testing.T) {
+	lagging, laggingURL := testStartSimNode(t)
+	best, bestURL := testStartSimNode(t)
+	dead := testDeadNodeURL()
+
+	lagging.Mine(1)
+	best.Mine(5)
+
+	pool := NewNodePool([]string{laggingURL, bestURL, dead}, 2)
+	pool.Probe()
+
+	if pool.Endpoints[0].Healthy || pool.Endpoints[0].Height != 1 || pool.Endpoints[0].LastError == nil {
+		t.Fatalf("lagging node should be unhealthy: %+v", pool.Endpoints[0])
+	}
+	if !pool.Endpoints[1].Healthy || pool.Endpoints[1].Height != 5 {
+		t.Fatalf("best node should be healthy: %+v", pool.Endpoints[1])
+	}
+	if pool.Endpoints[2].Healthy {
+		t.Fatalf("dead node should be unhealthy: %+v", pool.Endpoints[2])
+	}
+
+	client := NewClient(laggingURL, false)
+	client.Pool = pool
+	before := best.Requests()
+	if _, err := client.Call(context.Background(), "getblockheader", nil); err != nil {
+		t.Fatalf("Call unexpected error: %v", err)
+	}
+	if best.Requests() != before+1 {
+		t.Fatalf("Call should go to the best node")
+	}
+
+	//追上后恢复可用
+	lagging.Mine(3)
+	pool.Probe()
+	if !pool.Endpoints[0].Healthy || pool.Endpoints[0].LastError != nil {
+		t.Fatalf("lagging node should be healthy again: %+v", pool.Endpoints[0])
+	}
+}
+
+func TestNodePool_Start(t *testing.T) {
+	node, url := testStartSimNode(t)
+	node.Mine(2)
+
+	client := NewPoolClient([]string{url}, DefaultNodeMaxLag, false)
+	defer client.Close()
+
+	height := uint64(0)
+	for i := 0; i < 100 && height == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		client.Pool.mu.RLock()
+		height = client.Pool.Endpoints[0].Height
+		client.Pool.mu.RUnlock()
+	}
+	if len(client.Pool.Healthy()) != 1 {
+		t.Fatalf("node should be healthy")
+	}
+	if height != 2 {
+		t.Fatalf("probe height = %d, want 2", height)
+	}
+}
+
+func TestNodePool_BroadcastToAll(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+	other, otherURL := testStartSimNode(t)
+
+	node.Fund(wallet.addresses[0].Address, 100000000)
+	node.Mine(1)
+
+	primary := wm.WalletClient.BaseURL
+	wm.WalletClient.Pool = NewNodePool([]string{primary, otherURL}, DefaultNodeMaxLag)
+	wm.Config.BroadcastToAll = true
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{wallet.addresses[1].Address: "0.1"},
+	}
+
+	before := other.Requests()
+	testSimTransfer(t, wm, wallet, rawTx)
+
+	//另一个节点没有该交易的输入，广播失败不影响结果
+	if other.Requests() != before+1 {
+		t.Fatalf("sendrawtx should be broadcast to every healthy node")
+	}
+	if len(node.Mempool()) != 1 {
+		t.Fatalf("primary node mempool = %d, want 1", len(node.Mempool()))
+	}
+
+	wm.Config.BroadcastToAll = false
+	if _, err := wm.SendRawTx(context.Background(), "00"); err == nil {
+		t.Fatalf("SendRawTx with invalid tx should fail")
+	}
+}
+
+func TestWalletManager_LoadAssetsConfig_ServerAPIs(t *testing.T) {
+	wm := NewWalletManager()
+	c, err := config.NewConfigData("ini", []byte(`
+serverAPI = "http://127.0.0.1:8090/rpc/v3;http://127.0.0.1:8091/rpc/v3"
+nodeMaxLag = 5
+nodeProbeInterval = 60
+broadcastToAll = true
+dataDir = "`+t.TempDir()+`"
+`))
+	if err != nil {
+		t.Fatalf("NewConfigData unexpected error: %v", err)
+	}
+	if err := wm.LoadAssetsConfig(c); err != nil {
+		t.Fatalf("LoadAssetsConfig unexpected error: %v", err)
+	}
+	defer wm.WalletClient.Close()
+
+	if len(wm.Config.ServerAPIs) != 2 || wm.Config.ServerAPI != "http://127.0.0.1:8090/rpc/v3" {
+		t.Fatalf("unexpected server apis: %v", wm.Config.ServerAPIs)
+	}
+	if wm.WalletClient.Pool == nil || len(wm.WalletClient.Pool.Endpoints) != 2 || wm.WalletClient.Pool.MaxLag != 5 {
+		t.Fatalf("client should use a node pool")
+	}
+	if wm.Config.NodeProbeInterval.Seconds() != 60 || !wm.Config.BroadcastToAll {
+		t.Fatalf("unexpected config: %+v", wm.Config)
+	}
+}
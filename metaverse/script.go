@@ -0,0 +1,431 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blocktree/go-owcdrivers/addressEncoder"
+	"github.com/blocktree/go-owcrypt"
+)
+
+//ScriptType 脚本类型
+type ScriptType string
+
+const (
+	ScriptNonStandard ScriptType = "nonstandard" //无法识别的脚本
+	ScriptCoinstake   ScriptType = "coinstake"   //空脚本，coinstake交易的第一个输出
+	ScriptPubKey      ScriptType = "pubkey"      //[ pubkey ] checksig
+	ScriptPubKeyHash  ScriptType = "pubkeyhash"  //dup hash160 [ hash ] equalverify checksig
+	ScriptScriptHash  ScriptType = "scripthash"  //hash160 [ hash ] equal
+	ScriptMultisig    ScriptType = "multisig"    //m [ pubkey ]... n checkmultisig
+	ScriptLockHeight  ScriptType = "lockheight"  //[ height ] numequalverify + P2PKH，锁仓存款
+	ScriptAttenuation ScriptType = "attenuation" //[ model ] checkattenuationverify + P2PKH，MST衰减模型
+
+	ScriptSignPubKey     ScriptType = "sign_pubkey"     //[ sig ]，花费ScriptPubKey
+	ScriptSignPubKeyHash ScriptType = "sign_pubkeyhash" //[ sig ] [ pubkey ]，花费P2PKH、锁仓和衰减模型，锁仓再压入[ height ]
+	ScriptSignMultisig   ScriptType = "sign_multisig"   //zero [ sig ]... [ redeem script ]，花费多重签名P2SH
+)
+
+//脚本操作码，节点可读形式中的名称
+const (
+	opZero                   = "zero"
+	opDup                    = "dup"
+	opHash160                = "hash160"
+	opEqual                  = "equal"
+	opEqualVerify            = "equalverify"
+	opCheckSig               = "checksig"
+	opCheckMultiSig          = "checkmultisig"
+	opNumEqualVerify         = "numequalverify"
+	opCheckAttenuationVerify = "checkattenuationverify"
+)
+
+//opCodeNames 二进制脚本操作码对应的名称，只包含解析标准脚本需要的操作码
+var opCodeNames = map[byte]string{
+	0x00: opZero,
+	0x76: opDup,
+	0x87: opEqual,
+	0x88: opEqualVerify,
+	0x9d: opNumEqualVerify,
+	0xa9: opHash160,
+	0xac: opCheckSig,
+	0xae: opCheckMultiSig,
+	0xb2: opCheckAttenuationVerify,
+}
+
+//ScriptOp 脚本的一个操作，Data不为nil时是压入的数据
+type ScriptOp struct {
+	Name string
+	Data []byte
+}
+
+//IsPush 是否压入数据
+func (op ScriptOp) IsPush() bool {
+	return op.Data != nil
+}
+
+//smallInt 1~16的数字操作码
+func (op ScriptOp) smallInt() (int, bool) {
+	if op.IsPush() {
+		return 0, false
+	}
+	n, err := strconv.Atoi(op.Name)
+	if err != nil || n < 1 || n > 16 {
+		return 0, false
+	}
+	return n, true
+}
+
+func (op ScriptOp) is(name string) bool {
+	return !op.IsPush() && op.Name == name
+}
+
+func (op ScriptOp) pushLen(n int) bool {
+	return op.IsPush() && len(op.Data) == n
+}
+
+//Script 解析后的脚本
+type Script struct {
+	Type ScriptType
+	Ops  []ScriptOp
+
+	Hash             []byte   //hash160，P2PKH、锁仓和衰减模型是公钥hash，P2SH和多重签名花费是赎回脚本hash
+	PubKeys          [][]byte //公钥
+	Signatures       [][]byte //签名
+	Required         int      //多重签名需要的签名数
+	LockHeight       uint64   //锁仓的区块数
+	AttenuationModel string   //衰减模型参数，例如PN=0;LH=20000;TYPE=1;LQ=9000;LP=60000;UN=3
+	RedeemScript     []byte   //多重签名花费的赎回脚本
+}
+
+//ParseScript 解析节点返回的可读形式脚本，例如：dup hash160 [ 74b5... ] equalverify checksig。
+//格式错误时返回错误，能拆分但不是标准脚本时类型为ScriptNonStandard。
+func ParseScript(text string) (*Script, error) {
+
+	ops := make([]ScriptOp, 0)
+	tokens := strings.Fields(text)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch token {
+		case "[":
+			//空数据：[ ]
+			if i+1 < len(tokens) && tokens[i+1] == "]" {
+				ops = append(ops, ScriptOp{Data: []byte{}})
+				i++
+				continue
+			}
+			if i+2 >= len(tokens) || tokens[i+2] != "]" {
+				return nil, fmt.Errorf("invalid script: unclosed push at token %d", i)
+			}
+			data, err := hex.DecodeString(tokens[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid script: push data %s is not hex", tokens[i+1])
+			}
+			ops = append(ops, ScriptOp{Data: data})
+			i += 2
+		case "]":
+			return nil, fmt.Errorf("invalid script: unexpected ] at token %d", i)
+		default:
+			ops = append(ops, ScriptOp{Name: token})
+		}
+	}
+
+	return classifyScript(ops), nil
+}
+
+//parseScriptText 解析节点返回的脚本，格式错误时作为无法识别的脚本
+func parseScriptText(text string) *Script {
+	script, err := ParseScript(text)
+	if err != nil {
+		return &Script{Type: ScriptNonStandard}
+	}
+	return script
+}
+
+//ParseScriptBytes 解析二进制脚本，用于多重签名花费中的赎回脚本
+func ParseScriptBytes(script []byte) (*Script, error) {
+
+	ops := make([]ScriptOp, 0)
+	for pos := 0; pos < len(script); {
+		code := script[pos]
+		pos++
+
+		size := -1
+		switch {
+		case code > 0x00 && code < 0x4c:
+			size = int(code)
+		case code == 0x4c:
+			if pos+1 > len(script) {
+				return nil, fmt.Errorf("invalid script: unexpected end")
+			}
+			size = int(script[pos])
+			pos++
+		case code == 0x4d:
+			if pos+2 > len(script) {
+				return nil, fmt.Errorf("invalid script: unexpected end")
+			}
+			size = int(binary.LittleEndian.Uint16(script[pos:]))
+			pos += 2
+		case code == 0x4e:
+			if pos+4 > len(script) {
+				return nil, fmt.Errorf("invalid script: unexpected end")
+			}
+			size = int(binary.LittleEndian.Uint32(script[pos:]))
+			pos += 4
+		}
+
+		if size >= 0 {
+			if size > len(script)-pos {
+				return nil, fmt.Errorf("invalid script: push of %d bytes exceeds script", size)
+			}
+			data := make([]byte, size)
+			copy(data, script[pos:pos+size])
+			ops = append(ops, ScriptOp{Data: data})
+			pos += size
+			continue
+		}
+
+		switch {
+		case code >= 0x51 && code <= 0x60:
+			ops = append(ops, ScriptOp{Name: strconv.Itoa(int(code - 0x50))})
+		default:
+			name, ok := opCodeNames[code]
+			if !ok {
+				name = fmt.Sprintf("<%02x>", code)
+			}
+			ops = append(ops, ScriptOp{Name: name})
+		}
+	}
+
+	return classifyScript(ops), nil
+}
+
+//isPubKey 33字节压缩公钥或65字节非压缩公钥
+func isPubKey(data []byte) bool {
+	return (len(data) == 33 && (data[0] == 0x02 || data[0] == 0x03)) || (len(data) == 65 && data[0] == 0x04)
+}
+
+//isSignature DER编码的签名加1字节sighash类型
+func isSignature(data []byte) bool {
+	return len(data) >= 9 && len(data) <= 73 && data[0] == 0x30
+}
+
+//isPayKeyHash ops是否为 dup hash160 [ hash ] equalverify checksig
+func isPayKeyHash(ops []ScriptOp) bool {
+	return len(ops) == 5 && ops[0].is(opDup) && ops[1].is(opHash160) && ops[2].pushLen(20) &&
+		ops[3].is(opEqualVerify) && ops[4].is(opCheckSig)
+}
+
+//scriptNumber 脚本中的小端序数字，最高位是符号位
+func scriptNumber(data []byte) (int64, bool) {
+	if len(data) == 0 {
+		return 0, true
+	}
+	if len(data) > 8 {
+		return 0, false
+	}
+	var n int64
+	for i, b := range data {
+		n |= int64(b) << (8 * uint(i))
+	}
+	if data[len(data)-1]&0x80 != 0 {
+		n &= ^(int64(0x80) << (8 * uint(len(data)-1)))
+		n = -n
+	}
+	return n, true
+}
+
+//classifyScript 识别脚本类型并提取hash、公钥和签名
+func classifyScript(ops []ScriptOp) *Script {
+
+	s := &Script{Type: ScriptNonStandard, Ops: ops}
+	n := len(ops)
+
+	switch {
+	case n == 0:
+		s.Type = ScriptCoinstake
+
+	case isPayKeyHash(ops):
+		s.Type = ScriptPubKeyHash
+		s.Hash = ops[2].Data
+
+	case n == 3 && ops[0].is(opHash160) && ops[1].pushLen(20) && ops[2].is(opEqual):
+		s.Type = ScriptScriptHash
+		s.Hash = ops[1].Data
+
+	case n == 2 && ops[0].IsPush() && isPubKey(ops[0].Data) && ops[1].is(opCheckSig):
+		s.Type = ScriptPubKey
+		s.PubKeys = [][]byte{ops[0].Data}
+		s.Hash = owcrypt.Hash(ops[0].Data, 0, owcrypt.HASH_ALG_HASH160)
+
+	case n == 7 && ops[0].IsPush() && ops[1].is(opNumEqualVerify) && isPayKeyHash(ops[2:]):
+		height, ok := scriptNumber(ops[0].Data)
+		if !ok || height < 0 {
+			break
+		}
+		s.Type = ScriptLockHeight
+		s.LockHeight = uint64(height)
+		s.Hash = ops[4].Data
+
+	case n == 7 && ops[0].IsPush() && ops[1].is(opCheckAttenuationVerify) && isPayKeyHash(ops[2:]):
+		s.Type = ScriptAttenuation
+		s.AttenuationModel = string(ops[0].Data)
+		s.Hash = ops[4].Data
+
+	case n >= 4 && ops[n-1].is(opCheckMultiSig):
+		m, okM := ops[0].smallInt()
+		total, okN := ops[n-2].smallInt()
+		if !okM || !okN || total != n-3 || m > total {
+			break
+		}
+		pubs := make([][]byte, 0, total)
+		for _, op := range ops[1 : n-2] {
+			if !op.IsPush() || !isPubKey(op.Data) {
+				return s
+			}
+			pubs = append(pubs, op.Data)
+		}
+		s.Type = ScriptMultisig
+		s.Required = m
+		s.PubKeys = pubs
+
+	case n == 1 && ops[0].IsPush() && isSignature(ops[0].Data):
+		s.Type = ScriptSignPubKey
+		s.Signatures = [][]byte{ops[0].Data}
+
+	//花费锁仓存款时签名和公钥之后压入锁定的区块数
+	case (n == 2 || n == 3 && ops[2].IsPush()) && ops[0].IsPush() && isSignature(ops[0].Data) && ops[1].IsPush() && isPubKey(ops[1].Data):
+		s.Type = ScriptSignPubKeyHash
+		s.Signatures = [][]byte{ops[0].Data}
+		s.PubKeys = [][]byte{ops[1].Data}
+		s.Hash = owcrypt.Hash(ops[1].Data, 0, owcrypt.HASH_ALG_HASH160)
+
+	case n >= 3 && ops[0].is(opZero) && ops[n-1].IsPush():
+		redeem, err := ParseScriptBytes(ops[n-1].Data)
+		if err != nil || redeem.Type != ScriptMultisig {
+			break
+		}
+		sigs := make([][]byte, 0, n-2)
+		for _, op := range ops[1 : n-1] {
+			if !op.IsPush() || !isSignature(op.Data) {
+				return s
+			}
+			sigs = append(sigs, op.Data)
+		}
+		s.Type = ScriptSignMultisig
+		s.Signatures = sigs
+		s.PubKeys = redeem.PubKeys
+		s.Required = redeem.Required
+		s.RedeemScript = ops[n-1].Data
+		s.Hash = owcrypt.Hash(s.RedeemScript, 0, owcrypt.HASH_ALG_HASH160)
+	}
+
+	return s
+}
+
+//IsP2SH 锁定脚本是否为脚本hash地址
+func (s *Script) IsP2SH() bool {
+	return s.Type == ScriptScriptHash
+}
+
+//IsPayKeyHash 锁定脚本是否可以用单个私钥签名花费（P2PKH、锁仓和衰减模型）
+func (s *Script) IsPayKeyHash() bool {
+	switch s.Type {
+	case ScriptPubKeyHash, ScriptLockHeight, ScriptAttenuation:
+		return true
+	}
+	return false
+}
+
+//signable 能用单个私钥签名的锁定脚本：P2PKH、衰减模型和到期的锁仓存款
+func (s *Script) signable() bool {
+	return s != nil && (s.Type == ScriptPubKeyHash || s.Type == ScriptAttenuation || s.Type == ScriptLockHeight)
+}
+
+//Bytes 二进制脚本，数据按最短的方式压入
+func (s *Script) Bytes() ([]byte, error) {
+	script := make([]byte, 0)
+	for _, op := range s.Ops {
+		if op.IsPush() {
+			script = append(script, pushData(op.Data)...)
+			continue
+		}
+		if n, ok := op.smallInt(); ok {
+			script = append(script, byte(0x50+n))
+			continue
+		}
+		code, ok := opCodes[op.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown script op: %s", op.Name)
+		}
+		script = append(script, code)
+	}
+	return script, nil
+}
+
+//opCodes 操作码名称对应的二进制操作码
+var opCodes = func() map[string]byte {
+	codes := make(map[string]byte, len(opCodeNames))
+	for code, name := range opCodeNames {
+		codes[name] = code
+	}
+	return codes
+}()
+
+//pushData 压入数据的脚本片段
+func pushData(data []byte) []byte {
+	n := len(data)
+	switch {
+	case n < 0x4c:
+		return append([]byte{byte(n)}, data...)
+	case n <= 0xff:
+		return append([]byte{0x4c, byte(n)}, data...)
+	default:
+		return append([]byte{0x4d, byte(n), byte(n >> 8)}, data...)
+	}
+}
+
+//String 节点可读形式的脚本
+func (s *Script) String() string {
+	parts := make([]string, 0, len(s.Ops))
+	for _, op := range s.Ops {
+		if op.IsPush() {
+			parts = append(parts, "[ "+hex.EncodeToString(op.Data)+" ]")
+		} else {
+			parts = append(parts, op.Name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+//ScriptAddress 锁定脚本或解锁脚本对应的地址，裸多重签名等没有地址的脚本返回空
+func (wm *WalletManager) ScriptAddress(s *Script) string {
+	if s == nil || len(s.Hash) == 0 {
+		return ""
+	}
+	params := wm.Config.networkParams()
+	switch {
+	case s.IsPayKeyHash(), s.Type == ScriptPubKey, s.Type == ScriptSignPubKeyHash:
+		return addressEncoder.AddressEncode(s.Hash, params.P2PKH)
+	case s.IsP2SH(), s.Type == ScriptSignMultisig:
+		return addressEncoder.AddressEncode(s.Hash, params.P2SH)
+	}
+	return ""
+}
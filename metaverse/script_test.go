@@ -0,0 +1,225 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+const (
+	testScriptHash    = "74b57910184277f877886301eaa3358af56c0a47"
+	testScriptSig     = "3045022100e36fe4e2c254a0dbb59176e8056411983c82fcfc999a99e242e2f720b00f4daf02200f03526b5105b1cf5678b40b98f84f50f3520aa293322a57a6af1919522a174401"
+	testScriptPubKey  = "033a67f19bad4eab86ffade1bd050885e205562e07f8ebb50a114eb15b233a3b86"
+	testScriptPubKey2 = "02d3e7f1c96a7be7903867a17f18e16cae8fad8d4d1406b6c5e35c62b425c62736"
+)
+
+func TestParseScript(t *testing.T) {
+	model := "PN=0;LH=20000;TYPE=1;LQ=9000;LP=60000;UN=3"
+	redeem, _ := metaverse_addrdec.MultisigRedeemScript(1, [][]byte{mustHex(testScriptPubKey), mustHex(testScriptPubKey2)})
+
+	tests := []struct {
+		name     string
+		text     string
+		typ      ScriptType
+		hash     string
+		pubs     int
+		sigs     int
+		required int
+	}{
+		{"p2pkh", "dup hash160 [ " + testScriptHash + " ] equalverify checksig", ScriptPubKeyHash, testScriptHash, 0, 0, 0},
+		{"p2sh", "hash160 [ 4ddc128cb6cf0d51baa74b8ea5f05ed6adcdf4ba ] equal", ScriptScriptHash, "4ddc128cb6cf0d51baa74b8ea5f05ed6adcdf4ba", 0, 0, 0},
+		{"pubkey", "[ " + testScriptPubKey + " ] checksig", ScriptPubKey, "", 1, 0, 0},
+		{"multisig", "1 [ " + testScriptPubKey + " ] [ " + testScriptPubKey2 + " ] 2 checkmultisig", ScriptMultisig, "", 2, 0, 1},
+		{"lock height", "[ 40420f ] numequalverify dup hash160 [ " + testScriptHash + " ] equalverify checksig", ScriptLockHeight, testScriptHash, 0, 0, 0},
+		{"attenuation", "[ " + hex.EncodeToString([]byte(model)) + " ] checkattenuationverify dup hash160 [ " + testScriptHash + " ] equalverify checksig", ScriptAttenuation, testScriptHash, 0, 0, 0},
+		{"coinstake", "", ScriptCoinstake, "", 0, 0, 0},
+		{"sign pubkey", "[ " + testScriptSig + " ]", ScriptSignPubKey, "", 0, 1, 0},
+		{"sign pubkey hash", "[ " + testScriptSig + " ] [ " + testScriptPubKey + " ]", ScriptSignPubKeyHash, "", 1, 1, 0},
+		{"sign multisig", "zero [ " + testScriptSig + " ] [ " + hex.EncodeToString(redeem) + " ]", ScriptSignMultisig, "", 2, 1, 1},
+		{"nonstandard", "return [ 00 ]", ScriptNonStandard, "", 0, 0, 0},
+		{"bad multisig", "3 [ " + testScriptPubKey + " ] [ " + testScriptPubKey2 + " ] 2 checkmultisig", ScriptNonStandard, "", 0, 0, 0},
+		{"short hash", "dup hash160 [ 74b5 ] equalverify checksig", ScriptNonStandard, "", 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		s, err := ParseScript(test.text)
+		if err != nil {
+			t.Errorf("%s: ParseScript unexpected error: %v", test.name, err)
+			continue
+		}
+		if s.Type != test.typ || len(s.PubKeys) != test.pubs || len(s.Signatures) != test.sigs || s.Required != test.required {
+			t.Errorf("%s: ParseScript = %s, %d pubkeys, %d signatures, %d required", test.name, s.Type, len(s.PubKeys), len(s.Signatures), s.Required)
+		}
+		if len(test.hash) > 0 && hex.EncodeToString(s.Hash) != test.hash {
+			t.Errorf("%s: hash = %x, want %s", test.name, s.Hash, test.hash)
+		}
+		if s.String() != test.text {
+			t.Errorf("%s: String() = %q, want %q", test.name, s.String(), test.text)
+		}
+	}
+
+	lock, _ := ParseScript(tests[4].text)
+	if lock.LockHeight != 1000000 {
+		t.Errorf("lock height = %d, want 1000000", lock.LockHeight)
+	}
+	attenuation, _ := ParseScript(tests[5].text)
+	if attenuation.AttenuationModel != model {
+		t.Errorf("attenuation model = %s, want %s", attenuation.AttenuationModel, model)
+	}
+	sign, _ := ParseScript(tests[8].text)
+	if hex.EncodeToString(sign.Signatures[0]) != testScriptSig || hex.EncodeToString(sign.PubKeys[0]) != testScriptPubKey {
+		t.Errorf("unexpected signature or pubkey: %x %x", sign.Signatures[0], sign.PubKeys[0])
+	}
+
+	for _, text := range []string{"dup hash160 [ 74b5", "[ zz ]", "dup ] checksig"} {
+		if _, err := ParseScript(text); err == nil {
+			t.Errorf("ParseScript(%q) should fail", text)
+		}
+	}
+}
+
+func TestParseScriptBytes(t *testing.T) {
+	redeem, _ := metaverse_addrdec.MultisigRedeemScript(2, [][]byte{mustHex(testScriptPubKey), mustHex(testScriptPubKey2)})
+	s, err := ParseScriptBytes(redeem)
+	if err != nil || s.Type != ScriptMultisig || s.Required != 2 || len(s.PubKeys) != 2 {
+		t.Fatalf("ParseScriptBytes = %+v, %v", s, err)
+	}
+	if s.String() != "2 [ "+testScriptPubKey+" ] [ "+testScriptPubKey2+" ] 2 checkmultisig" {
+		t.Fatalf("String() = %s", s.String())
+	}
+	if _, err := ParseScriptBytes([]byte{0x21, 0x02}); err == nil {
+		t.Fatalf("ParseScriptBytes with a truncated push should fail")
+	}
+}
+
+func TestWalletManager_ScriptAddress(t *testing.T) {
+	wm := NewWalletManager()
+	pub := mustHex(testScriptPubKey)
+
+	p2pkh, _ := wm.Decoder.PublicKeyToAddress(pub, false)
+	sign, _ := ParseScript("[ " + testScriptSig + " ] [ " + testScriptPubKey + " ]")
+	if address := wm.ScriptAddress(sign); address != p2pkh {
+		t.Fatalf("sign pubkey hash address = %s, want %s", address, p2pkh)
+	}
+
+	pubs := [][]byte{pub, mustHex(testScriptPubKey2)}
+	p2sh, _ := wm.Decoder.RedeemScriptToAddress(pubs, 1, false)
+	redeem, _ := metaverse_addrdec.MultisigRedeemScript(1, metaverse_addrdec.SortPublicKeys(pubs))
+	spend, _ := ParseScript("zero [ " + testScriptSig + " ] [ " + hex.EncodeToString(redeem) + " ]")
+	lock, _ := ParseScript("hash160 [ " + hex.EncodeToString(spend.Hash) + " ] equal")
+	if address := wm.ScriptAddress(lock); address != p2sh {
+		t.Fatalf("p2sh address = %s, want %s", address, p2sh)
+	}
+
+	multisig, _ := ParseScript("1 [ " + testScriptPubKey + " ] 1 checkmultisig")
+	if address := wm.ScriptAddress(multisig); address != "" {
+		t.Fatalf("bare multisig should not have an address: %s", address)
+	}
+}
+
+func TestSimNode_TransactionDecoder_UnsignableInput(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+
+	//钱包地址替换为多重签名地址，适配器无法签名P2SH输入
+	pub, _ := hex.DecodeString(wallet.addresses[0].PublicKey)
+	multisig, err := wm.NewMultisigAddress([][]byte{pub, mustHex(testScriptPubKey2)}, 1)
+	if err != nil {
+		t.Fatalf("NewMultisigAddress unexpected error: %v", err)
+	}
+	wallet.addresses[0].Address = multisig.Address
+	node.Fund(multisig.Address, 100000000)
+	node.Mine(1)
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{"MUsTC2PCF52yNvAeGNXJUKy9CfLVHV9yYj": "0.1"},
+	}
+	err = wm.GetTransactionDecoder().CreateRawTransaction(wallet, rawTx)
+	if !IsErrorCode(err, openwallet.ErrCreateRawTransactionFailed) {
+		t.Fatalf("CreateRawTransaction with a p2sh input error = %v, want ErrCreateRawTransactionFailed", err)
+	}
+}
+
+func TestSimNode_TransactionDecoder_MaturedDepositInput(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+	from := wallet.addresses[0].Address
+	to := wallet.addresses[1].Address
+
+	//地址只有一笔到期的锁仓存款，节点选择它作为输入
+	depositTxID, _ := node.Deposit(from, 100000000, 2)
+	node.Mine(3)
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{to: "0.1"},
+	}
+	testSimTransfer(t, wm, wallet, rawTx)
+	node.Mine(1)
+
+	tx, err := wm.GetTransaction(context.Background(), rawTx.TxID)
+	if err != nil || len(tx.Vins) != 1 || tx.Vins[0].TxID != depositTxID {
+		t.Fatalf("transaction does not spend the deposit: %+v, %v", tx, err)
+	}
+	if tx.Vins[0].UnlockScript == nil || tx.Vins[0].UnlockScript.Type != ScriptSignPubKeyHash || len(tx.Vins[0].UnlockScript.Ops) != 3 {
+		t.Fatalf("unexpected deposit unlock script: %+v", tx.Vins[0].UnlockScript)
+	}
+	if balance, _ := wm.GetAddressETP(context.Background(), to); balance.Confirmed != "10000000" {
+		t.Fatalf("receiver balance = %s, want 10000000", balance.Confirmed)
+	}
+}
+
+func TestScript_Bytes(t *testing.T) {
+	lock, _ := ParseScript("[ 40420f ] numequalverify dup hash160 [ " + testScriptHash + " ] equalverify checksig")
+	b, err := lock.Bytes()
+	if err != nil || hex.EncodeToString(b) != "0340420f9d76a914"+testScriptHash+"88ac" {
+		t.Fatalf("Bytes() = %x, %v", b, err)
+	}
+	if s, _ := ParseScriptBytes(b); s.Type != ScriptLockHeight || s.LockHeight != 1000000 {
+		t.Fatalf("ParseScriptBytes(Bytes()) = %+v", s)
+	}
+}
+
+func TestSetOutputScript(t *testing.T) {
+	p2pkh, _ := ParseScript("dup hash160 [ " + testScriptHash + " ] equalverify checksig")
+	out := &openwallet.TxOutPut{}
+	setOutputScript(out, p2pkh)
+	if out.ExtParam != "" {
+		t.Fatalf("p2pkh output should not have ExtParam: %s", out.ExtParam)
+	}
+
+	lock, _ := ParseScript("[ 40420f ] numequalverify dup hash160 [ " + testScriptHash + " ] equalverify checksig")
+	setOutputScript(out, lock)
+	if out.ExtParam != `{"lockHeight":1000000,"scriptType":"lockheight"}` {
+		t.Fatalf("lock height output ExtParam = %s", out.ExtParam)
+	}
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
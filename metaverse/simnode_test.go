@@ -0,0 +1,915 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/astaxie/beego/config"
+	"github.com/blocktree/metaverse-adapter/metaverse_simnode"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+const simAccountID = "simAccount"
+
+// simWallet 内存钱包，实现测试需要的WalletDAI接口
+type simWallet struct {
+	openwallet.WalletDAIBase
+	key       *hdkeystore.HDKey
+	addresses []*openwallet.Address
+}
+
+func newSimWallet(t *testing.T, wm *WalletManager, count int) *simWallet {
+	key, err := hdkeystore.NewHDKey([]byte("metaverse simnode test wallet seed"), "simnode", "m/44'/88'/0'")
+	if err != nil {
+		t.Fatalf("NewHDKey unexpected error: %v", err)
+	}
+	w := &simWallet{key: key}
+	for i := 0; i < count; i++ {
+		path := fmt.Sprintf("m/44'/88'/0'/0/%d", i)
+		childKey, err := key.DerivedKeyWithPath(path, wm.CurveType())
+		if err != nil {
+			t.Fatalf("DerivedKeyWithPath unexpected error: %v", err)
+		}
+		pub := childKey.GetPublicKeyBytes()
+		address, err := wm.Decoder.PublicKeyToAddress(pub, wm.Config.IsTestNet)
+		if err != nil {
+			t.Fatalf("PublicKeyToAddress unexpected error: %v", err)
+		}
+		w.addresses = append(w.addresses, &openwallet.Address{
+			AccountID: simAccountID,
+			Address:   address,
+			PublicKey: hex.EncodeToString(pub),
+			Index:     uint64(i),
+			HDPath:    path,
+			Symbol:    wm.Symbol(),
+		})
+	}
+	return w
+}
+
+func (w *simWallet) HDKey(password ...string) (*hdkeystore.HDKey, error) {
+	return w.key, nil
+}
+
+func (w *simWallet) GetAddress(address string) (*openwallet.Address, error) {
+	for _, a := range w.addresses {
+		if a.Address == address {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("address %s not found", address)
+}
+
+func (w *simWallet) GetAddressList(offset, limit int, cols ...interface{}) ([]*openwallet.Address, error) {
+	list := make([]*openwallet.Address, 0)
+	for _, a := range w.addresses {
+		match := true
+		for i := 0; i+1 < len(cols); i += 2 {
+			switch cols[i] {
+			case "AccountID":
+				match = match && a.AccountID == cols[i+1]
+			case "Address":
+				match = match && a.Address == cols[i+1]
+			}
+		}
+		if match {
+			list = append(list, a)
+		}
+	}
+	if offset >= len(list) {
+		return []*openwallet.Address{}, nil
+	}
+	list = list[offset:]
+	if limit >= 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+// simBlockchainDAI 内存区块链数据
+type simBlockchainDAI struct {
+	openwallet.BlockchainDAIBase
+	mu      sync.Mutex
+	current map[string]*openwallet.BlockHeader
+	blocks  map[uint64]*openwallet.BlockHeader
+	unscans []*openwallet.UnscanRecord
+}
+
+func newSimBlockchainDAI() *simBlockchainDAI {
+	return &simBlockchainDAI{
+		current: make(map[string]*openwallet.BlockHeader),
+		blocks:  make(map[uint64]*openwallet.BlockHeader),
+	}
+}
+
+func (dai *simBlockchainDAI) SaveCurrentBlockHead(header *openwallet.BlockHeader) error {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	dai.current[header.Symbol] = header
+	return nil
+}
+
+func (dai *simBlockchainDAI) GetCurrentBlockHead(symbol string) (*openwallet.BlockHeader, error) {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	if header, ok := dai.current[symbol]; ok {
+		return header, nil
+	}
+	return &openwallet.BlockHeader{Symbol: symbol}, nil
+}
+
+func (dai *simBlockchainDAI) SaveLocalBlockHead(header *openwallet.BlockHeader) error {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	dai.blocks[header.Height] = header
+	return nil
+}
+
+func (dai *simBlockchainDAI) GetLocalBlockHeadByHeight(height uint64, symbol string) (*openwallet.BlockHeader, error) {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	if header, ok := dai.blocks[height]; ok {
+		return header, nil
+	}
+	return nil, fmt.Errorf("block %d not found", height)
+}
+
+func (dai *simBlockchainDAI) SaveUnscanRecord(record *openwallet.UnscanRecord) error {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	dai.unscans = append(dai.unscans, record)
+	return nil
+}
+
+func (dai *simBlockchainDAI) DeleteUnscanRecordByHeight(height uint64, symbol string) error {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	list := make([]*openwallet.UnscanRecord, 0)
+	for _, r := range dai.unscans {
+		if r.BlockHeight != height {
+			list = append(list, r)
+		}
+	}
+	dai.unscans = list
+	return nil
+}
+
+func (dai *simBlockchainDAI) GetUnscanRecords(symbol string) ([]*openwallet.UnscanRecord, error) {
+	dai.mu.Lock()
+	defer dai.mu.Unlock()
+	return append([]*openwallet.UnscanRecord{}, dai.unscans...), nil
+}
+
+// simObserver 记录扫描器通知
+type simObserver struct {
+	mu      sync.Mutex
+	headers []*openwallet.BlockHeader
+	data    map[string][]*openwallet.TxExtractData
+}
+
+func newSimObserver() *simObserver {
+	return &simObserver{data: make(map[string][]*openwallet.TxExtractData)}
+}
+
+func (o *simObserver) BlockScanNotify(header *openwallet.BlockHeader) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.headers = append(o.headers, header)
+	return nil
+}
+
+func (o *simObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.data[data.Transaction.TxID] = append(o.data[data.Transaction.TxID], data)
+	return nil
+}
+
+func (o *simObserver) BlockExtractSmartContractDataNotify(sourceKey string, data *openwallet.SmartContractReceipt) error {
+	return nil
+}
+
+func (o *simObserver) extractData(txid string) []*openwallet.TxExtractData {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.data[txid]
+}
+
+// newSimWalletManager 连接模拟节点的钱包管理者
+func newSimWalletManager(t *testing.T) (*WalletManager, *metaverse_simnode.Node) {
+	node := metaverse_simnode.NewNode(false)
+	wm := NewWalletManager()
+	wm.Config.IsTestNet = false
+	wm.Config.MinFees = decimal.New(1, -4)
+	wm.Config.DBPath = t.TempDir()
+	wm.WalletClient = NewClient(node.Start(), false)
+	t.Cleanup(node.Close)
+	return wm, node
+}
+
+// newSimBlockScanner 使用内存数据的扫描器，只扫描钱包地址
+func newSimBlockScanner(wm *WalletManager, wallet *simWallet) (*ETPBlockScanner, *simObserver) {
+	bs := wm.Blockscanner.(*ETPBlockScanner)
+	bs.SetBlockchainDAI(newSimBlockchainDAI())
+	bs.SetBlockScanTargetFuncV2(func(target openwallet.ScanTargetParam) openwallet.ScanTargetResult {
+		if a, err := wallet.GetAddress(target.ScanTarget); err == nil {
+			return openwallet.ScanTargetResult{SourceKey: a.AccountID, Exist: true}
+		}
+		return openwallet.ScanTargetResult{}
+	})
+	observer := newSimObserver()
+	bs.AddObserver(observer)
+	bs.Scanning = true
+	return bs, observer
+}
+
+//restartSimBlockScanner 使用同一个数据目录和本地区块记录创建新的扫描器，模拟重启
+func restartSimBlockScanner(bs *ETPBlockScanner, wallet *simWallet) (*ETPBlockScanner, *simObserver) {
+	wm := NewWalletManager()
+	wm.Config.IsTestNet = bs.wm.Config.IsTestNet
+	wm.Config.MinFees = bs.wm.Config.MinFees
+	wm.Config.DBPath = bs.wm.Config.DBPath
+	wm.WalletClient = bs.wm.WalletClient
+	bs.Scanning = false
+
+	restarted, observer := newSimBlockScanner(wm, wallet)
+	restarted.SetBlockchainDAI(bs.BlockchainDAI)
+	restarted.IsScanMemPool = bs.IsScanMemPool
+	restarted.MaxReorgDepth = bs.MaxReorgDepth
+	restarted.MinConfirmations = bs.MinConfirmations
+	restarted.NotifySeen = bs.NotifySeen
+	return restarted, observer
+}
+
+func TestSimNode_WalletManager(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+
+	txid, _ := node.Fund(addr, 150000000)
+	node.IssueAsset(addr, "DNA", 5000000, 4)
+	node.Mine(2)
+
+	header, err := wm.GetBlockHeader(context.Background())
+	if err != nil {
+		t.Fatalf("GetBlockHeader unexpected error: %v", err)
+	}
+	if header.Height != 2 || header.Hash != node.BlockHash(2) {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+
+	block, err := wm.GetBlockByHeight(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetBlockByHeight unexpected error: %v", err)
+	}
+	if block.Hash != node.BlockHash(1) || block.Previousblockhash != node.BlockHash(0) || len(block.transactions) != 3 {
+		t.Fatalf("unexpected block: %+v", block)
+	}
+
+	tx, err := wm.GetTransaction(context.Background(), txid)
+	if err != nil {
+		t.Fatalf("GetTransaction unexpected error: %v", err)
+	}
+	if tx.BlockHeight != 1 || len(tx.Vouts) != 1 || tx.Vouts[0].Addr != addr || tx.Vouts[0].Value != "150000000" {
+		t.Fatalf("unexpected tx: %+v", tx)
+	}
+
+	etp, err := wm.GetAddressETP(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("GetAddressETP unexpected error: %v", err)
+	}
+	if etp.Confirmed != "150000000" || etp.Available != "150000000" {
+		t.Fatalf("unexpected etp balance: %+v", etp)
+	}
+
+	asset, _ := wm.GetAddressAsset(context.Background(), addr, "DNA")
+	if asset.Quantity != "5000000" || asset.Decimals != 4 {
+		t.Fatalf("unexpected asset balance: %+v", asset)
+	}
+
+	if _, err := wm.GetBlockByHeight(context.Background(), 100); err == nil {
+		t.Fatalf("GetBlockByHeight beyond tip should fail")
+	}
+}
+
+func TestSimNode_GetInfo(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	node.Mine(3)
+
+	info, err := wm.GetInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetInfo unexpected error: %v", err)
+	}
+	if info.Height != 3 || info.IsTestNet || info.Network() != "mainnet" || info.WalletVersion != metaverse_simnode.WalletVersion ||
+		info.ProtocolVersion != metaverse_simnode.ProtocolVersion || info.Peers == 0 {
+		t.Fatalf("unexpected node info: %+v", info)
+	}
+
+	if _, err := wm.CheckNode(context.Background()); err != nil {
+		t.Fatalf("CheckNode unexpected error: %v", err)
+	}
+
+	wm.Config.IsTestNet = true
+	if _, err := wm.CheckNode(context.Background()); err == nil || err.Code() != ErrNodeIncompatible {
+		t.Fatalf("CheckNode with network mismatch should fail: %v", err)
+	}
+	wm.Config.IsTestNet = false
+
+	node.SetVersion("0.7.3")
+	if _, err := wm.CheckNode(context.Background()); err == nil || err.Code() != ErrNodeIncompatible {
+		t.Fatalf("CheckNode with old version should fail: %v", err)
+	}
+}
+
+func TestSimNode_LoadAssetsConfig_CheckNode(t *testing.T) {
+	node := metaverse_simnode.NewNode(true)
+	url := node.Start()
+	t.Cleanup(node.Close)
+
+	load := func(isTestNet bool, extra string) error {
+		wm := NewWalletManager()
+		c, err := config.NewConfigData("ini", []byte(fmt.Sprintf("serverAPI = %s\nisTestNet = %v\ndataDir = %s\n%s", url, isTestNet, t.TempDir(), extra)))
+		if err != nil {
+			t.Fatalf("NewConfigData unexpected error: %v", err)
+		}
+		return wm.LoadAssetsConfig(c)
+	}
+
+	if err := load(true, ""); err != nil {
+		t.Fatalf("LoadAssetsConfig unexpected error: %v", err)
+	}
+	if err := load(false, ""); err == nil {
+		t.Fatalf("LoadAssetsConfig should refuse a testnet node with isTestNet = false")
+	}
+	if err := load(true, "minNodeVersion = 1.0.0"); err == nil {
+		t.Fatalf("LoadAssetsConfig should refuse an unsupported node version")
+	}
+	if err := load(false, "checkNode = false"); err != nil {
+		t.Fatalf("LoadAssetsConfig with checkNode = false unexpected error: %v", err)
+	}
+}
+
+func TestSimNode_LoadAssetsConfig_GenesisHash(t *testing.T) {
+	node := metaverse_simnode.NewNode(false)
+	url := node.Start()
+	t.Cleanup(node.Close)
+
+	load := func(genesisHash string) error {
+		wm := NewWalletManager()
+		c, err := config.NewConfigData("ini", []byte(fmt.Sprintf("serverAPI = %s\nnetwork = mainnet\ngenesisHash = %s\ndataDir = %s\n", url, genesisHash, t.TempDir())))
+		if err != nil {
+			t.Fatalf("NewConfigData unexpected error: %v", err)
+		}
+		return wm.LoadAssetsConfig(c)
+	}
+
+	if err := load(node.BlockHash(0)); err != nil {
+		t.Fatalf("LoadAssetsConfig unexpected error: %v", err)
+	}
+
+	//其他链的创世区块
+	err := load(strings.Repeat("ab", 32))
+	if err == nil || !IsErrorCode(err, ErrNodeIncompatible) {
+		t.Fatalf("LoadAssetsConfig with a different genesis block should fail: %v", err)
+	}
+}
+
+func TestSimNode_VerifyGenesis_MainNet(t *testing.T) {
+	network, err := NewNetwork(NetworkMainnet)
+	if err != nil {
+		t.Fatalf("NewNetwork unexpected error: %v", err)
+	}
+	if network.GenesisHash != MainNetGenesisHash {
+		t.Fatalf("mainnet genesis hash = %q, want %s", network.GenesisHash, MainNetGenesisHash)
+	}
+
+	//测试网节点的创世区块不是主网的创世区块
+	node := metaverse_simnode.NewNode(true)
+	wm := NewWalletManager()
+	wm.Config.Network = network
+	wm.WalletClient = NewClient(node.Start(), false)
+	t.Cleanup(node.Close)
+
+	verifyErr := wm.VerifyGenesis(context.Background())
+	if verifyErr == nil || verifyErr.Code() != ErrNodeIncompatible {
+		t.Fatalf("VerifyGenesis with the testnet genesis block should fail: %v", verifyErr)
+	}
+	if !strings.Contains(verifyErr.Error(), node.BlockHash(0)) || !strings.Contains(verifyErr.Error(), MainNetGenesisHash) {
+		t.Fatalf("unexpected VerifyGenesis error: %v", verifyErr)
+	}
+
+	//没有配置genesisHash时使用内置的主网创世区块hash，报告主网但创世区块不同的节点也拒绝启动
+	other := metaverse_simnode.NewNode(false)
+	t.Cleanup(other.Close)
+	c, cfgErr := config.NewConfigData("ini", []byte(fmt.Sprintf("serverAPI = %s\nnetwork = mainnet\ndataDir = %s\n", other.Start(), t.TempDir())))
+	if cfgErr != nil {
+		t.Fatalf("NewConfigData unexpected error: %v", cfgErr)
+	}
+	loadErr := NewWalletManager().LoadAssetsConfig(c)
+	if loadErr == nil || !IsErrorCode(loadErr, ErrNodeIncompatible) || !strings.Contains(loadErr.Error(), MainNetGenesisHash) {
+		t.Fatalf("LoadAssetsConfig should refuse a node that is not on mainnet: %v", loadErr)
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.9.0", "0.8.0", 1},
+		{"0.8.0", "0.8.0", 0},
+		{"v0.8", "0.8.0", 0},
+		{"0.7.3", "0.8.0", -1},
+		{"0.10.1", "0.9.9", 1},
+		{"0.9.0-beta", "0.9.0", 0},
+		{"", "0.8.0", -1},
+	}
+	for _, test := range tests {
+		if got := compareVersion(test.a, test.b); got != test.want {
+			t.Errorf("compareVersion(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSimNode_CallBatch(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 3)
+	addr := wallet.addresses[0].Address
+
+	txid, _ := node.Fund(addr, 150000000)
+	node.IssueAsset(addr, "DNA", 5000000, 4)
+	node.Mine(1)
+
+	requests := []*BatchRequest{
+		NewBatchRequest("gettx", txid),
+		NewBatchRequest("gettx", "0000000000000000000000000000000000000000000000000000000000000001"),
+		NewBatchRequest("getaddressetp", addr),
+	}
+
+	before := node.Requests()
+	results, err := wm.WalletClient.CallBatch(context.Background(), requests)
+	if err != nil {
+		t.Fatalf("CallBatch unexpected error: %v", err)
+	}
+	if node.Requests()-before != 1 {
+		t.Fatalf("CallBatch sent %d requests, want 1", node.Requests()-before)
+	}
+	if len(results) != 3 {
+		t.Fatalf("CallBatch returned %d results, want 3", len(results))
+	}
+	if results[0].Err != nil || results[0].Result.Get("hash").String() != txid {
+		t.Fatalf("unexpected gettx result: %+v", results[0])
+	}
+	if results[1].Err == nil || results[1].Err.Code() != ErrTxNotFound {
+		t.Fatalf("unknown tx should fail with ErrTxNotFound, got: %+v", results[1])
+	}
+	if results[2].Err != nil || results[2].Result.Get("confirmed").String() != "150000000" {
+		t.Fatalf("unexpected getaddressetp result: %+v", results[2])
+	}
+
+	//超过BatchSize时分多次请求
+	wm.WalletClient.BatchSize = 2
+	before = node.Requests()
+	results, err = wm.WalletClient.CallBatch(context.Background(), requests)
+	if err != nil {
+		t.Fatalf("CallBatch unexpected error: %v", err)
+	}
+	if node.Requests()-before != 2 || len(results) != 3 || results[2].Err != nil {
+		t.Fatalf("CallBatch with BatchSize 2 sent %d requests and returned %d results", node.Requests()-before, len(results))
+	}
+	wm.WalletClient.BatchSize = DefaultBatchSize
+
+	//单笔交易获取失败不影响其他交易
+	unknown := "0000000000000000000000000000000000000000000000000000000000000001"
+	txs, failed, err := wm.GetTransactions(context.Background(), txid, unknown)
+	if err != nil || txs[txid] == nil || txs[unknown] != nil || failed[unknown] == nil || failed[unknown].Code() != ErrTxNotFound {
+		t.Fatalf("GetTransactions = %v, %v, %v", txs, failed, err)
+	}
+
+	addresses := []string{wallet.addresses[0].Address, wallet.addresses[1].Address, wallet.addresses[2].Address}
+
+	bs, _ := newSimBlockScanner(wm, wallet)
+	before = node.Requests()
+	balances, balErr := bs.GetBalanceByAddress(addresses...)
+	if balErr != nil {
+		t.Fatalf("GetBalanceByAddress unexpected error: %v", balErr)
+	}
+	if node.Requests()-before != 1 {
+		t.Fatalf("GetBalanceByAddress sent %d requests, want 1", node.Requests()-before)
+	}
+	if len(balances) != 3 || balances[0].Balance != "1.5" || balances[1].Balance != "0" {
+		t.Fatalf("unexpected balances: %+v", balances)
+	}
+
+	//任一地址查询失败时返回错误，不当作余额为0
+	if balances, balErr = bs.GetBalanceByAddress(addresses[0], "invalid-address"); balErr == nil {
+		t.Fatalf("GetBalanceByAddress with invalid address = %+v, want error", balances)
+	}
+
+	contract := openwallet.SmartContract{Symbol: "ETP", Address: "DNA", Decimals: 4}
+	before = node.Requests()
+	tokenBalances, tokenErr := wm.ContractDecoder.GetTokenBalanceByAddress(contract, addresses...)
+	if tokenErr != nil {
+		t.Fatalf("GetTokenBalanceByAddress unexpected error: %v", tokenErr)
+	}
+	if node.Requests()-before != 1 {
+		t.Fatalf("GetTokenBalanceByAddress sent %d requests, want 1", node.Requests()-before)
+	}
+	if len(tokenBalances) != 3 || tokenBalances[0].Balance.Balance != "500" || tokenBalances[2].Balance.Balance != "0" {
+		t.Fatalf("unexpected token balances: %+v", tokenBalances)
+	}
+}
+
+//testNoBatchNode 拒绝批量数组请求的代理，单个请求转发到节点。
+//status为0时返回400和json-rpc错误对象，否则返回status和body
+type testNoBatchNode struct {
+	mu      sync.Mutex
+	batches int
+	next    http.Handler
+	status  int
+	body    string
+}
+
+func (f *testNoBatchNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if len(bytes.TrimSpace(body)) > 0 && bytes.TrimSpace(body)[0] == '[' {
+		f.mu.Lock()
+		f.batches++
+		f.mu.Unlock()
+		if f.status != 0 {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(f.status)
+			w.Write([]byte(f.body))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch requests are not supported"}}`))
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	f.next.ServeHTTP(w, r)
+}
+
+func (f *testNoBatchNode) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.batches
+}
+
+func TestSimNode_CallBatch_Fallback(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	txid, _ := node.Fund(addr, 150000000)
+	node.Mine(1)
+
+	proxy := &testNoBatchNode{next: node}
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+	wm.WalletClient = NewClient(server.URL, false)
+
+	requests := []*BatchRequest{
+		NewBatchRequest("gettx", txid),
+		NewBatchRequest("gettx", "0000000000000000000000000000000000000000000000000000000000000001"),
+		NewBatchRequest("getaddressetp", addr),
+	}
+
+	//节点拒绝批量请求时逐个调用
+	before := node.Requests()
+	results, err := wm.WalletClient.CallBatch(context.Background(), requests)
+	if err != nil || len(results) != 3 {
+		t.Fatalf("CallBatch = %v, %v", results, err)
+	}
+	if proxy.count() != 1 || node.Requests()-before != 3 {
+		t.Fatalf("CallBatch sent %d batches and %d calls, want 1 and 3", proxy.count(), node.Requests()-before)
+	}
+	if results[0].Err != nil || results[0].Result.Get("hash").String() != txid ||
+		results[1].Err == nil || results[1].Err.Code() != ErrTxNotFound || results[2].Err != nil {
+		t.Fatalf("unexpected results: %+v %+v %+v", results[0], results[1], results[2])
+	}
+
+	//单个调用和BatchSize为1时不发送批量请求
+	if results, err = wm.WalletClient.CallBatch(context.Background(), requests[:1]); err != nil || results[0].Err != nil {
+		t.Fatalf("CallBatch single call = %v, %v", results, err)
+	}
+	wm.WalletClient.BatchSize = 1
+	if results, err = wm.WalletClient.CallBatch(context.Background(), requests); err != nil || len(results) != 3 || results[2].Err != nil {
+		t.Fatalf("CallBatch with BatchSize 1 = %v, %v", results, err)
+	}
+	if proxy.count() != 1 {
+		t.Fatalf("batch requests sent = %d, want 1", proxy.count())
+	}
+}
+
+func TestSimNode_CallBatch_FallbackNonArray(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	txid, _ := node.Fund(addr, 150000000)
+	node.Mine(1)
+
+	requests := []*BatchRequest{
+		NewBatchRequest("gettx", txid),
+		NewBatchRequest("getaddressetp", addr),
+	}
+
+	cases := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"plain text", http.StatusOK, "batch requests are not supported"},
+		{"empty body", http.StatusOK, ""},
+		{"server error", http.StatusInternalServerError, "internal server error"},
+	}
+	for _, c := range cases {
+		proxy := &testNoBatchNode{next: node, status: c.status, body: c.body}
+		server := httptest.NewServer(proxy)
+		client := NewClient(server.URL, false)
+
+		//不是数组的响应视为拒绝批量请求，批量请求不重试，逐个调用
+		results, err := client.CallBatch(context.Background(), requests)
+		server.Close()
+		if err != nil || len(results) != 2 || results[0].Err != nil || results[1].Err != nil {
+			t.Fatalf("%s: CallBatch = %v, %v", c.name, results, err)
+		}
+		if results[0].Result.Get("hash").String() != txid {
+			t.Fatalf("%s: unexpected result: %s", c.name, results[0].Result.Raw)
+		}
+		if proxy.count() != 1 {
+			t.Fatalf("%s: batch requests sent = %d, want 1", c.name, proxy.count())
+		}
+	}
+}
+
+func TestSimNode_FillInputFields(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+	from := wallet.addresses[0].Address
+
+	node.Fund(from, 100000000)
+	node.Fund(from, 100000000)
+	node.Mine(1)
+
+	for i := 0; i < 2; i++ {
+		rawTx := &openwallet.RawTransaction{
+			Coin:     openwallet.Coin{Symbol: "ETP"},
+			Account:  &openwallet.AssetsAccount{AccountID: simAccountID, Symbol: "ETP"},
+			To:       map[string]string{wallet.addresses[1].Address: "0.1"},
+			FeeRate:  "0.0001",
+			Required: 1,
+		}
+		testSimTransfer(t, wm, wallet, rawTx)
+	}
+	node.Mine(1)
+
+	block, err := wm.GetBlockByHeight(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("GetBlockByHeight unexpected error: %v", err)
+	}
+
+	before := node.Requests()
+	if err := wm.FillInputFields(context.Background(), block.transactions...); err != nil {
+		t.Fatalf("FillInputFields unexpected error: %v", err)
+	}
+	if node.Requests()-before != 1 {
+		t.Fatalf("FillInputFields sent %d requests, want 1", node.Requests()-before)
+	}
+
+	inputs := 0
+	for _, tx := range block.transactions {
+		for _, input := range tx.Vins {
+			if input.isCoinbase {
+				break
+			}
+			inputs++
+			if input.Addr != from || input.Value != "100000000" {
+				t.Fatalf("unexpected input: %+v", input)
+			}
+		}
+	}
+	if inputs == 0 {
+		t.Fatalf("block has no inputs to fill")
+	}
+
+	//已填充的输入不再请求节点
+	before = node.Requests()
+	if err := wm.FillInputFields(context.Background(), block.transactions...); err != nil {
+		t.Fatalf("FillInputFields unexpected error: %v", err)
+	}
+	if node.Requests() != before {
+		t.Fatalf("FillInputFields refetched filled inputs")
+	}
+
+	//上一笔交易获取失败时仍填充其他交易的输入
+	block, _ = wm.GetBlockByHeight(context.Background(), 2)
+	unknown := &Transaction{TxID: "unknown", Vins: []*Vin{{TxID: "0000000000000000000000000000000000000000000000000000000000000001"}}}
+	err = wm.FillInputFields(context.Background(), append(block.transactions, unknown)...)
+	if err == nil || err.Code() != ErrTxNotFound {
+		t.Fatalf("FillInputFields with unknown previous tx = %v, want ErrTxNotFound", err)
+	}
+	for _, tx := range block.transactions {
+		for _, input := range tx.Vins {
+			if !input.isCoinbase && input.Addr != from {
+				t.Fatalf("input is not filled when another previous tx is unknown: %+v", input)
+			}
+		}
+	}
+}
+
+func TestSimNode_TransactionDecoder_ETP(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 2)
+	from := wallet.addresses[0].Address
+	to := "MUsTC2PCF52yNvAeGNXJUKy9CfLVHV9yYj"
+
+	node.Fund(from, 100000000)
+	node.Fund(from, 20000000)
+	node.Mine(1)
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol()},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{to: "1.1"},
+	}
+
+	decoder := wm.GetTransactionDecoder()
+	if err := decoder.CreateRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("CreateRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.SignRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("SignRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.VerifyRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("VerifyRawTransaction unexpected error: %v", err)
+	}
+	if !rawTx.IsCompleted {
+		t.Fatalf("raw transaction is not completed")
+	}
+
+	tx, err := decoder.SubmitRawTransaction(wallet, rawTx)
+	if err != nil {
+		t.Fatalf("SubmitRawTransaction unexpected error: %v", err)
+	}
+	if mempool := node.Mempool(); len(mempool) != 1 || mempool[0] != tx.TxID {
+		t.Fatalf("mempool = %v, want [%s]", mempool, tx.TxID)
+	}
+
+	node.Mine(1)
+
+	toBalance, _ := wm.GetAddressETP(context.Background(), to)
+	if toBalance.Confirmed != "110000000" {
+		t.Fatalf("receiver balance = %s, want 110000000", toBalance.Confirmed)
+	}
+	fromBalance, _ := wm.GetAddressETP(context.Background(), from)
+	if fromBalance.Confirmed != "9990000" {
+		t.Fatalf("sender balance = %s, want 9990000", fromBalance.Confirmed)
+	}
+
+	//重复广播，交易已在区块链中返回ErrTxAlreadyExists
+	if resubmit, err := decoder.SubmitRawTransaction(wallet, rawTx); resubmit != nil || !IsErrorCode(err, ErrTxAlreadyExists) {
+		t.Fatalf("SubmitRawTransaction duplicate tx = %+v, %v", resubmit, err)
+	}
+}
+
+func TestSimNode_TransactionDecoder_Token(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	from := wallet.addresses[0].Address
+	to := "MUsTC2PCF52yNvAeGNXJUKy9CfLVHV9yYj"
+
+	node.Fund(from, 100000)
+	node.IssueAsset(from, "DNA", 5000000, 4)
+	node.Mine(1)
+
+	contract := openwallet.SmartContract{
+		ContractID: openwallet.GenContractID(wm.Symbol(), "DNA"),
+		Address:    "DNA",
+		Symbol:     wm.Symbol(),
+		Token:      "DNA",
+		Decimals:   4,
+	}
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: wm.Symbol(), IsContract: true, ContractID: contract.ContractID, Contract: contract},
+		Account: &openwallet.AssetsAccount{AccountID: simAccountID},
+		To:      map[string]string{to: "12.5"},
+	}
+
+	decoder := wm.GetTransactionDecoder()
+	if err := decoder.CreateRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("CreateRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.SignRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("SignRawTransaction unexpected error: %v", err)
+	}
+	if err := decoder.VerifyRawTransaction(wallet, rawTx); err != nil || !rawTx.IsCompleted {
+		t.Fatalf("VerifyRawTransaction failed: %v", err)
+	}
+	if _, err := decoder.SubmitRawTransaction(wallet, rawTx); err != nil {
+		t.Fatalf("SubmitRawTransaction unexpected error: %v", err)
+	}
+	node.Mine(1)
+
+	balances, err := wm.GetSmartContractDecoder().GetTokenBalanceByAddress(contract, from, to)
+	if err != nil {
+		t.Fatalf("GetTokenBalanceByAddress unexpected error: %v", err)
+	}
+	if balances[0].Balance.Balance != "487.5" || balances[1].Balance.Balance != "12.5" {
+		t.Fatalf("unexpected token balances: %s, %s", balances[0].Balance.Balance, balances[1].Balance.Balance)
+	}
+}
+
+func TestSimNode_BlockScanner(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	node.Mine(2)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.ScanBlockTask()
+	if h := bs.GetScannedBlockHeight(); h != 2 {
+		t.Fatalf("scanned height = %d, want 2", h)
+	}
+
+	etpTxID, _ := node.Fund(addr, 250000000)
+	assetTxID, _ := node.IssueAsset(addr, "DNA", 70000, 4)
+	node.Mine(1)
+	bs.ScanBlockTask()
+
+	if h := bs.GetScannedBlockHeight(); h != 3 {
+		t.Fatalf("scanned height = %d, want 3", h)
+	}
+
+	//扫描器会重扫最高区块，同一交易可能通知多次
+	data := observer.extractData(etpTxID)
+	if len(data) == 0 || len(data[0].TxOutputs) != 1 {
+		t.Fatalf("ETP deposit is not extracted: %+v", data)
+	}
+	if out := data[0].TxOutputs[0]; out.Address != addr || out.Amount != "2.5" || out.Coin.IsContract {
+		t.Fatalf("unexpected ETP output: %+v", out)
+	}
+	if data[0].Transaction.BlockHeight != 3 || data[0].Transaction.BlockHash != node.BlockHash(3) {
+		t.Fatalf("unexpected transaction: %+v", data[0].Transaction)
+	}
+
+	//发行资产按代币收款提取
+	if data := observer.extractData(assetTxID); len(data) == 0 || len(data[0].TxOutputs) != 1 || data[0].TxOutputs[0].Amount != "70000" || !data[0].TxOutputs[0].Coin.IsContract {
+		t.Fatalf("asset issue tx is not extracted: %+v", data)
+	}
+
+	balances, err := bs.GetBalanceByAddress(addr)
+	if err != nil || balances[0].Balance != "2.5" {
+		t.Fatalf("GetBalanceByAddress = %+v, %v", balances, err)
+	}
+}
+
+func TestSimNode_BlockScanner_Fork(t *testing.T) {
+	wm, node := newSimWalletManager(t)
+	wallet := newSimWallet(t, wm, 1)
+	addr := wallet.addresses[0].Address
+	node.Mine(5)
+
+	bs, observer := newSimBlockScanner(wm, wallet)
+	bs.ScanBlockTask()
+
+	//分叉后重新充值，新分支的交易应被提取
+	if err := node.Reorg(2); err != nil {
+		t.Fatalf("Reorg unexpected error: %v", err)
+	}
+	txid, _ := node.Fund(addr, 100000000)
+	node.Mine(1)
+	bs.ScanBlockTask()
+
+	if h, hash, _ := bs.GetLocalBlockHead(); h != node.Height() || hash != node.BlockHash(h) {
+		t.Fatalf("local head = %d %s, want %d %s", h, hash, node.Height(), node.BlockHash(node.Height()))
+	}
+	if data := observer.extractData(txid); len(data) == 0 {
+		t.Fatalf("deposit on new branch is not extracted: %+v", data)
+	}
+}
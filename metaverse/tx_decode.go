@@ -16,6 +16,9 @@
 package metaverse
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"github.com/blocktree/go-owcdrivers/mateverseTransaction"
 	"github.com/blocktree/openwallet/v2/openwallet"
@@ -89,9 +92,10 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 		return nil, fmt.Errorf("transaction is not completed validation")
 	}
 
-	txid, err := decoder.wm.SendRawTx(rawTx.RawHex)
+	//交易已经广播过时返回ErrTxAlreadyExists，由调用方决定是否视为提交成功
+	txid, err := decoder.wm.SendRawTx(context.Background(), rawTx.RawHex)
 	if err != nil {
-		decoder.wm.Log.Warningf("[Sid: %s] submit raw hex: %s", rawTx.Sid, rawTx.RawHex)
+		decoder.wm.Log.Warningf("[Sid: %s] submit raw hex: %s, retryable: %v", rawTx.Sid, rawTx.RawHex, IsRetryable(err))
 		return nil, err
 	}
 
@@ -155,13 +159,14 @@ func (decoder *TransactionDecoder) CreateETPRawTransaction(wrapper openwallet.Wa
 		return fmt.Errorf("Receiver addresses is empty!")
 	}
 
+	//接收方可以是DID，解析为当前绑定的地址
+	amounts, dids, resolveErr := decoder.wm.resolveReceivers(context.Background(), rawTx.To)
+	if resolveErr != nil {
+		return resolveErr
+	}
+
 	//计算总发送金额
-	for addr, amount := range rawTx.To {
-		//totalSend, _ = decimal.NewFromString(amount)
-		//totalSend = totalSend
-		//destination = addr
-		//break
-		sendAmount, _ := decimal.NewFromString(amount)
+	for addr, sendAmount := range amounts {
 		totalSend = totalSend.Add(sendAmount)
 		receivers[addr] = sendAmount.Shift(decoder.wm.Decimal()).String()
 	}
@@ -173,7 +178,7 @@ func (decoder *TransactionDecoder) CreateETPRawTransaction(wrapper openwallet.Wa
 	}
 
 	for _, addr := range address {
-		etpBalance, etpErr := decoder.wm.GetAddressETP(addr.Address)
+		etpBalance, etpErr := decoder.wm.GetAddressETP(context.Background(), addr.Address)
 		if etpErr != nil {
 			continue
 		}
@@ -194,19 +199,20 @@ func (decoder *TransactionDecoder) CreateETPRawTransaction(wrapper openwallet.Wa
 		return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the balance is not enough! ")
 	}
 
-	rawHex, txErr := decoder.wm.CreateRawTx(
+	rawHex, txErr := decoder.wm.CreateRawTx(context.Background(),
 		[]string{availableETPBalance.Address},
 		receivers,
 		//map[string]string{destination: totalSend.Shift(decoder.wm.Decimal()).String()},
 		"",
 		fees.Shift(decoder.wm.Decimal()).String(),
 		"",
-		false)
+		false,
+		rawTx.GetExtParam().Get(ExtParamMemo).String())
 	if txErr != nil {
 		return txErr
 	}
 
-	etpTx, txErr := decoder.wm.DecodeRawTx(rawHex)
+	etpTx, txErr := decoder.wm.DecodeRawTx(context.Background(), rawHex)
 	if txErr != nil {
 		return txErr
 	}
@@ -220,7 +226,7 @@ func (decoder *TransactionDecoder) CreateETPRawTransaction(wrapper openwallet.Wa
 
 	rawTx.Fees = fees.String()
 
-	err = decoder.createRawTransaction(wrapper, rawTx, etpTx)
+	err = decoder.createRawTransaction(wrapper, rawTx, etpTx, dids)
 	if err != nil {
 		return err
 	}
@@ -287,7 +293,7 @@ func (decoder *TransactionDecoder) VerifyETPRawTransaction(wrapper openwallet.Wa
 		return err
 	}
 
-	etpTx, txErr := decoder.wm.DecodeRawTx(emptyTrans)
+	etpTx, txErr := decoder.wm.DecodeRawTx(context.Background(), emptyTrans)
 	if txErr != nil {
 		return txErr
 	}
@@ -305,7 +311,7 @@ func (decoder *TransactionDecoder) VerifyETPRawTransaction(wrapper openwallet.Wa
 	}
 
 	for i, input := range inputs {
-		input.SetLockScript(etpTx.Vins[i].LockScript)
+		input.SetLockScript(sigHashScript(etpTx.Vins[i]))
 	}
 
 	err = mateverseTransaction.GetSigHash(emptyTrans, &inputs)
@@ -324,6 +330,10 @@ func (decoder *TransactionDecoder) VerifyETPRawTransaction(wrapper openwallet.Wa
 	/////////验证交易单
 	pass, signedTrans := mateverseTransaction.VerifyAndCombineTransaction(emptyTrans, inputs)
 	if pass {
+		signedTrans, err = appendLockHeightPushes(signedTrans, etpTx.Vins)
+		if err != nil {
+			return err
+		}
 		decoder.wm.Log.Debug("transaction verify passed")
 		rawTx.IsCompleted = true
 		rawTx.RawHex = signedTrans
@@ -411,23 +421,24 @@ func (decoder *TransactionDecoder) CreateETPSummaryRawTransaction(wrapper openwa
 			senders = append(senders, addr.Address)
 		}
 
-		rawHex, txErr := decoder.wm.CreateRawTx(
+		rawHex, txErr := decoder.wm.CreateRawTx(context.Background(),
 			senders,
 			map[string]string{sumRawTx.SummaryAddress: sumAmount.Shift(decoder.wm.Decimal()).String()},
 			sumRawTx.SummaryAddress,
 			fees.Shift(decoder.wm.Decimal()).String(),
 			"",
-			false)
+			false,
+			"")
 		if txErr != nil {
 			return rawTxArray, nil
 		}
 
-		etpTx, txErr := decoder.wm.DecodeRawTx(rawHex)
+		etpTx, txErr := decoder.wm.DecodeRawTx(context.Background(), rawHex)
 		if txErr != nil {
 			return nil, txErr
 		}
 
-		createErr := decoder.createRawTransaction(wrapper, rawTx, etpTx)
+		createErr := decoder.createRawTransaction(wrapper, rawTx, etpTx, nil)
 		if createErr != nil {
 			return nil, createErr
 		}
@@ -445,11 +456,12 @@ func (decoder *TransactionDecoder) CreateETPSummaryRawTransaction(wrapper openwa
 	return rawTxArray, nil
 }
 
-//createRawTransaction 创建原始交易单
+//createRawTransaction 创建原始交易单，dids是接收地址对应的DID，记录在TxTo中
 func (decoder *TransactionDecoder) createRawTransaction(
 	wrapper openwallet.WalletDAI,
 	rawTx *openwallet.RawTransaction,
 	etpTx *Transaction,
+	dids map[string]string,
 ) error {
 
 	var (
@@ -465,16 +477,28 @@ func (decoder *TransactionDecoder) createRawTransaction(
 	//计算总发送金额
 	for _, output := range etpTx.Vouts {
 
+		//备注的消息附件输出不是转账
+		if output.Message != nil {
+			continue
+		}
+
 		amount := decimal.Zero
 		if isToken {
-			amount, _ = decimal.NewFromString(output.AssetAttachment.Quantity)
+			if asset := output.Asset(); asset != nil {
+				amount, _ = decimal.NewFromString(asset.Quantity)
+			}
 		} else {
 			//主币需要计算好精度
 			amount, _ = decimal.NewFromString(output.Value)
 			amount = amount.Shift(-decoder.wm.Decimal())
 		}
 
-		txTo = append(txTo, fmt.Sprintf("%s:%s", output.Addr, amount.String()))
+		//接收方是DID时记录为 地址(DID):金额
+		if did, ok := dids[output.Addr]; ok {
+			txTo = append(txTo, fmt.Sprintf("%s(%s):%s", output.Addr, did, amount.String()))
+		} else {
+			txTo = append(txTo, fmt.Sprintf("%s:%s", output.Addr, amount.String()))
+		}
 		//计算账户的实际转账amount
 		addresses, findErr := wrapper.GetAddressList(0, -1, "AccountID", accountID, "Address", output.Addr)
 		if findErr != nil || len(addresses) == 0 {
@@ -494,12 +518,18 @@ func (decoder *TransactionDecoder) createRawTransaction(
 		return fmt.Errorf(errStr)
 	}
 
+	if maxInputs := decoder.wm.Config.MaxTxInputs; maxInputs > 0 && len(etpTx.Vins) > maxInputs {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "transaction has %d inputs, more than maxTxInputs = %d", len(etpTx.Vins), maxInputs)
+	}
+
 	//装配输入
 	for i, input := range etpTx.Vins {
 
 		amount := decimal.Zero
 		if isToken {
-			amount, _ = decimal.NewFromString(input.AssetAttachment.Quantity)
+			if asset := input.Asset; asset != nil {
+				amount, _ = decimal.NewFromString(asset.Quantity)
+			}
 		} else {
 			//主币需要计算好精度
 			amount, _ = decimal.NewFromString(input.Value)
@@ -508,8 +538,17 @@ func (decoder *TransactionDecoder) createRawTransaction(
 
 		txFrom = append(txFrom, fmt.Sprintf("%s:%s", input.Addr, amount.String()))
 
+		//只能签名单个私钥锁定的输入，多重签名的输入无法花费
+		if !input.Script.signable() {
+			scriptType := ScriptNonStandard
+			if input.Script != nil {
+				scriptType = input.Script.Type
+			}
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "input %s:%d is locked by a %s script, which can not be signed", input.TxID, input.Vout, scriptType)
+		}
+
 		// 设定锁定脚本
-		inputs[i].SetLockScript(input.LockScript)
+		inputs[i].SetLockScript(sigHashScript(input))
 	}
 
 	// 2 . 获取待签哈希
@@ -608,13 +647,14 @@ func (decoder *TransactionDecoder) CreateTokenRawTransaction(wrapper openwallet.
 		return fmt.Errorf("Receiver addresses is empty!")
 	}
 
+	//接收方可以是DID，解析为当前绑定的地址
+	amounts, dids, resolveErr := decoder.wm.resolveReceivers(context.Background(), rawTx.To)
+	if resolveErr != nil {
+		return resolveErr
+	}
+
 	//计算总发送金额
-	for addr, amount := range rawTx.To {
-		//totalSend, _ = decimal.NewFromString(amount)
-		//totalSend = totalSend
-		//destination = addr
-		//break
-		sendAmount, _ := decimal.NewFromString(amount)
+	for addr, sendAmount := range amounts {
 		totalSend = totalSend.Add(sendAmount)
 		receivers[addr] = sendAmount.Shift(tokenDecimals).String()
 	}
@@ -626,7 +666,7 @@ func (decoder *TransactionDecoder) CreateTokenRawTransaction(wrapper openwallet.
 	}
 
 	for _, addr := range address {
-		etpBalance, etpErr := decoder.wm.GetAddressETP(addr.Address)
+		etpBalance, etpErr := decoder.wm.GetAddressETP(context.Background(), addr.Address)
 		if etpErr != nil {
 			continue
 		}
@@ -638,7 +678,7 @@ func (decoder *TransactionDecoder) CreateTokenRawTransaction(wrapper openwallet.
 			continue
 		}
 
-		tokenBalance, etpErr := decoder.wm.GetAddressAsset(addr.Address, tokenAddress)
+		tokenBalance, etpErr := decoder.wm.GetAddressAsset(context.Background(), addr.Address, tokenAddress)
 		if etpErr != nil {
 			continue
 		}
@@ -664,19 +704,20 @@ func (decoder *TransactionDecoder) CreateTokenRawTransaction(wrapper openwallet.
 		return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the %s balance is not enough! ", decoder.wm.Symbol())
 	}
 
-	rawHex, txErr := decoder.wm.CreateRawTx(
+	rawHex, txErr := decoder.wm.CreateRawTx(context.Background(),
 		[]string{availableTokenBalance.Address},
 		receivers,
 		//map[string]string{destination: totalSend.Shift(tokenDecimals).String()},
 		"",
 		fees.Shift(decoder.wm.Decimal()).String(),
 		tokenAddress,
-		true)
+		true,
+		rawTx.GetExtParam().Get(ExtParamMemo).String())
 	if txErr != nil {
 		return txErr
 	}
 
-	etpTx, txErr := decoder.wm.DecodeRawTx(rawHex)
+	etpTx, txErr := decoder.wm.DecodeRawTx(context.Background(), rawHex)
 	if txErr != nil {
 		return txErr
 	}
@@ -691,7 +732,7 @@ func (decoder *TransactionDecoder) CreateTokenRawTransaction(wrapper openwallet.
 
 	rawTx.Fees = "0"
 
-	err = decoder.createRawTransaction(wrapper, rawTx, etpTx)
+	err = decoder.createRawTransaction(wrapper, rawTx, etpTx, dids)
 	if err != nil {
 		return err
 	}
@@ -755,7 +796,7 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 
 	for _, addr := range address {
 
-		etpBalance, _ := decoder.wm.GetAddressETP(addr.Address)
+		etpBalance, _ := decoder.wm.GetAddressETP(context.Background(), addr.Address)
 		if etpBalance != nil {
 			available, _ := decimal.NewFromString(etpBalance.Available)
 			available = available.Shift(-decoder.wm.Decimal())
@@ -765,7 +806,7 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 			}
 		}
 
-		tokenBalance, etpErr := decoder.wm.GetAddressAsset(addr.Address, tokenAddress)
+		tokenBalance, etpErr := decoder.wm.GetAddressAsset(context.Background(), addr.Address, tokenAddress)
 		if etpErr != nil {
 			continue
 		}
@@ -811,18 +852,19 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 
 	senders = append(senders, feesSupportETPBalance.Address)
 
-	rawHex, txErr := decoder.wm.CreateRawTx(
+	rawHex, txErr := decoder.wm.CreateRawTx(context.Background(),
 		senders,
 		map[string]string{sumRawTx.SummaryAddress: sumAmount.Shift(tokenDecimals).String()},
 		change,
 		fees.Shift(decoder.wm.Decimal()).String(),
 		tokenAddress,
-		true)
+		true,
+		"")
 	if txErr != nil {
 		return nil, txErr
 	}
 
-	etpTx, txErr := decoder.wm.DecodeRawTx(rawHex)
+	etpTx, txErr := decoder.wm.DecodeRawTx(context.Background(), rawHex)
 	if txErr != nil {
 		return nil, txErr
 	}
@@ -839,7 +881,7 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 		Required: 1,
 	}
 
-	createTxErr := decoder.createRawTransaction(wrapper, rawTx, etpTx)
+	createTxErr := decoder.createRawTransaction(wrapper, rawTx, etpTx, nil)
 	rawTxWithErr := &openwallet.RawTransactionWithError{
 		RawTx: rawTx,
 		Error: openwallet.ConvertError(createTxErr),
@@ -878,7 +920,7 @@ func (decoder *TransactionDecoder) getFeeSupportAccountAvailableETPAndTokens(wra
 	}
 
 	for _, addr := range address {
-		etpBalance, _ := decoder.wm.GetAddressETP(addr.Address)
+		etpBalance, _ := decoder.wm.GetAddressETP(context.Background(), addr.Address)
 		if etpBalance != nil {
 			available, _ := decimal.NewFromString(etpBalance.Available)
 			available = available.Shift(-decoder.wm.Decimal())
@@ -888,7 +930,7 @@ func (decoder *TransactionDecoder) getFeeSupportAccountAvailableETPAndTokens(wra
 			}
 		}
 
-		tokenBalance, _ := decoder.wm.GetAddressAsset(addr.Address, tokenAddress)
+		tokenBalance, _ := decoder.wm.GetAddressAsset(context.Background(), addr.Address, tokenAddress)
 		if tokenBalance != nil {
 			//TODO: 大于0的才添加
 			tokenAmount, _ := decimal.NewFromString(tokenBalance.Quantity)
@@ -969,3 +1011,119 @@ func (decoder *TransactionDecoder) getFeeSupportAccountAvailableETPAndTokens(wra
 //	newHashs = append(newHashs, origins[end+1:]...)
 //	return newHashs
 //}
+
+//sigHashScript 计算签名hash使用的锁定脚本。签名库不能识别锁仓脚本的可读形式，锁仓存款使用二进制脚本的hex
+func sigHashScript(input *Vin) string {
+	if input.Script != nil && input.Script.Type == ScriptLockHeight {
+		if script, err := input.Script.Bytes(); err == nil {
+			return hex.EncodeToString(script)
+		}
+	}
+	return input.LockScript
+}
+
+//appendLockHeightPushes 花费锁仓存款时，签名脚本在签名和公钥之后压入锁定的区块数，与锁定脚本中的数据相同
+func appendLockHeightPushes(rawHex string, vins []*Vin) (string, error) {
+
+	pushes := make(map[int][]byte)
+	for i, input := range vins {
+		if input.Script != nil && input.Script.Type == ScriptLockHeight {
+			pushes[i] = input.Script.Ops[0].Data
+		}
+	}
+	if len(pushes) == 0 {
+		return rawHex, nil
+	}
+
+	raw, err := hex.DecodeString(rawHex)
+	if err != nil {
+		return "", fmt.Errorf("invalid raw transaction: %v", err)
+	}
+
+	//版本号4字节，输入：txid 32字节、vout 4字节、签名脚本、sequence 4字节
+	if len(raw) < 4 {
+		return "", fmt.Errorf("invalid raw transaction: unexpected end")
+	}
+	tx := append([]byte{}, raw[:4]...)
+	pos := 4
+	count, n := readVarInt(raw[pos:])
+	if n == 0 || int(count) != len(vins) {
+		return "", fmt.Errorf("invalid raw transaction: input count does not match")
+	}
+	tx = append(tx, raw[pos:pos+n]...)
+	pos += n
+
+	for i := 0; i < int(count); i++ {
+		if pos+36 > len(raw) {
+			return "", fmt.Errorf("invalid raw transaction: unexpected end")
+		}
+		tx = append(tx, raw[pos:pos+36]...)
+		pos += 36
+
+		size, n := readVarInt(raw[pos:])
+		if n == 0 || pos+n+int(size)+4 > len(raw) {
+			return "", fmt.Errorf("invalid raw transaction: unexpected end")
+		}
+		pos += n
+		script := append([]byte{}, raw[pos:pos+int(size)]...)
+		pos += int(size)
+		if data, ok := pushes[i]; ok {
+			script = append(script, pushData(data)...)
+		}
+		tx = append(tx, writeVarInt(uint64(len(script)))...)
+		tx = append(tx, script...)
+		tx = append(tx, raw[pos:pos+4]...)
+		pos += 4
+	}
+
+	tx = append(tx, raw[pos:]...)
+	return hex.EncodeToString(tx), nil
+}
+
+//readVarInt 读取变长整数，返回值和占用的字节数，数据不足时字节数为0
+func readVarInt(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	size := 1
+	switch b[0] {
+	case 0xfd:
+		size = 3
+	case 0xfe:
+		size = 5
+	case 0xff:
+		size = 9
+	}
+	if len(b) < size {
+		return 0, 0
+	}
+	switch size {
+	case 3:
+		return uint64(binary.LittleEndian.Uint16(b[1:])), size
+	case 5:
+		return uint64(binary.LittleEndian.Uint32(b[1:])), size
+	case 9:
+		return binary.LittleEndian.Uint64(b[1:]), size
+	}
+	return uint64(b[0]), size
+}
+
+//writeVarInt 变长整数
+func writeVarInt(v uint64) []byte {
+	switch {
+	case v < 0xfd:
+		return []byte{byte(v)}
+	case v <= 0xffff:
+		b := []byte{0xfd, 0, 0}
+		binary.LittleEndian.PutUint16(b[1:], uint16(v))
+		return b
+	case v <= 0xffffffff:
+		b := []byte{0xfe, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(b[1:], uint32(v))
+		return b
+	}
+	b := make([]byte, 9)
+	b[0] = 0xff
+	binary.LittleEndian.PutUint64(b[1:], v)
+	return b
+}
@@ -1,7 +1,6 @@
 package metaverse_addrdec
 
 import (
-	"github.com/blocktree/go-owaddress"
 	"github.com/blocktree/go-owcdrivers/addressEncoder"
 	"github.com/blocktree/openwallet/v2/openwallet"
 )
@@ -12,27 +11,68 @@ var (
 
 var (
 
-	ETP_mainnetAddressP2PKH         = addressEncoder.AddressType{"base58", alphabet, "doubleSHA256", "h160", 20, []byte{0x32}, nil}
-	ETP_testnetAddressP2PKH         = addressEncoder.AddressType{"base58", alphabet, "doubleSHA256", "h160", 20, []byte{0x7f}, nil}
-	ETP_mainnetAddressP2SH          = addressEncoder.AddressType{"base58", alphabet, "doubleSHA256", "h160", 20, []byte{0x05}, nil}
-	ETP_testnetAddressP2SH          = addressEncoder.AddressType{"base58", alphabet, "doubleSHA256", "h160", 20, []byte{0xc4}, nil}
+	ETP_mainnetAddressP2PKH         = addressEncoder.AddressType{EncodeType: "base58", Alphabet: alphabet, ChecksumType: "doubleSHA256", HashType: "h160", HashLen: 20, Prefix: []byte{0x32}, Suffix: nil}
+	ETP_testnetAddressP2PKH         = addressEncoder.AddressType{EncodeType: "base58", Alphabet: alphabet, ChecksumType: "doubleSHA256", HashType: "h160", HashLen: 20, Prefix: []byte{0x7f}, Suffix: nil}
+	ETP_mainnetAddressP2SH          = addressEncoder.AddressType{EncodeType: "base58", Alphabet: alphabet, ChecksumType: "doubleSHA256", HashType: "h160", HashLen: 20, Prefix: []byte{0x05}, Suffix: nil}
+	ETP_testnetAddressP2SH          = addressEncoder.AddressType{EncodeType: "base58", Alphabet: alphabet, ChecksumType: "doubleSHA256", HashType: "h160", HashLen: 20, Prefix: []byte{0xc4}, Suffix: nil}
 
+	//Default 主网的地址解析器，多个网络同时使用时应通过NewAddressDecoderV2为每个网络创建解析器，不要修改Default
 	Default = AddressDecoderV2{}
 )
 
+//NetworkParams 网络的地址版本和WIF版本
+type NetworkParams struct {
+	Name      string
+	P2PKH     addressEncoder.AddressType
+	P2SH      addressEncoder.AddressType
+	WIFPrefix byte
+}
+
+var (
+	//MainNetParams 主网
+	MainNetParams = NetworkParams{Name: "mainnet", P2PKH: ETP_mainnetAddressP2PKH, P2SH: ETP_mainnetAddressP2SH, WIFPrefix: 0x80}
+	//TestNetParams 测试网
+	TestNetParams = NetworkParams{Name: "testnet", P2PKH: ETP_testnetAddressP2PKH, P2SH: ETP_testnetAddressP2SH, WIFPrefix: 0xef}
+)
+
+//NewNetworkParams 自定义版本的网络参数，用于私有链
+func NewNetworkParams(name string, p2pkh, p2sh, wif byte) NetworkParams {
+	params := NetworkParams{Name: name, P2PKH: ETP_mainnetAddressP2PKH, P2SH: ETP_mainnetAddressP2SH, WIFPrefix: wif}
+	params.P2PKH.Prefix = []byte{p2pkh}
+	params.P2SH.Prefix = []byte{p2sh}
+	return params
+}
+
 //AddressDecoderV2
 type AddressDecoderV2 struct {
 	*openwallet.AddressDecoderV2Base
 	IsTestNet bool
+	Params    *NetworkParams //网络参数，为nil时按IsTestNet使用主网或测试网
 }
 
-//AddressDecode 地址解析
-func (dec *AddressDecoderV2) AddressDecode(addr string, opts ...interface{}) ([]byte, error) {
+//NewAddressDecoderV2 绑定网络参数的地址解析器，每个钱包管理者使用自己的解析器
+func NewAddressDecoderV2(params NetworkParams, isTestNet bool) *AddressDecoderV2 {
+	return &AddressDecoderV2{
+		IsTestNet: isTestNet,
+		Params:    &params,
+	}
+}
 
-	cfg := ETP_mainnetAddressP2PKH
+//NetworkParams 当前使用的网络参数
+func (dec *AddressDecoderV2) NetworkParams() NetworkParams {
+	if dec.Params != nil {
+		return *dec.Params
+	}
 	if dec.IsTestNet {
-		cfg = ETP_testnetAddressP2PKH
+		return TestNetParams
 	}
+	return MainNetParams
+}
+
+//AddressDecode 地址解析
+func (dec *AddressDecoderV2) AddressDecode(addr string, opts ...interface{}) ([]byte, error) {
+
+	cfg := dec.NetworkParams().P2PKH
 
 	if len(opts) > 0 {
 		for _, opt := range opts {
@@ -48,10 +88,7 @@ func (dec *AddressDecoderV2) AddressDecode(addr string, opts ...interface{}) ([]
 //AddressEncode 地址编码
 func (dec *AddressDecoderV2) AddressEncode(hash []byte, opts ...interface{}) (string, error) {
 
-	cfg := ETP_mainnetAddressP2PKH
-	if dec.IsTestNet {
-		cfg = ETP_testnetAddressP2PKH
-	}
+	cfg := dec.NetworkParams().P2PKH
 
 	if len(opts) > 0 {
 		for _, opt := range opts {
@@ -66,11 +103,8 @@ func (dec *AddressDecoderV2) AddressEncode(hash []byte, opts ...interface{}) (st
 }
 
 
-// AddressVerify 地址校验
+// AddressVerify 地址校验，P2PKH和P2SH地址都有效
 func (dec *AddressDecoderV2) AddressVerify(address string, opts ...interface{}) bool {
-	valid, err := owaddress.Verify("etp", address)
-	if err != nil {
-		return false
-	}
-	return valid
+	_, err := dec.ValidateAddress(address)
+	return err == nil
 }
\ No newline at end of file
@@ -30,3 +30,44 @@ func TestAddressDecoder_AddressDecode(t *testing.T) {
 	p2shHash, _ := Default.AddressDecode(p2shAddr, ETP_mainnetAddressP2SH)
 	t.Logf("p2shHash: %s", hex.EncodeToString(p2shHash))
 }
+
+func TestAddressDecoder_NetworkParams(t *testing.T) {
+	hash, _ := hex.DecodeString("d3e7f1c96a7be7903867a17f18e16cae8fad8d4d")
+
+	mainnet := AddressDecoderV2{}
+	testnet := AddressDecoderV2{IsTestNet: true}
+	regtestParams := NewNetworkParams("regtest", 0x41, 0x42, 0x43)
+	regtest := AddressDecoderV2{Params: &regtestParams}
+
+	mainnetAddr, _ := mainnet.AddressEncode(hash)
+	if mainnetAddr != "MTDcfh43xT93odL1Y2uULhRLeWED2fDvBX" {
+		t.Fatalf("mainnet address = %s", mainnetAddr)
+	}
+	testnetAddr, _ := testnet.AddressEncode(hash)
+	regtestAddr, _ := regtest.AddressEncode(hash)
+
+	tests := []struct {
+		dec     *AddressDecoderV2
+		address string
+		valid   bool
+	}{
+		{&mainnet, mainnetAddr, true},
+		{&mainnet, "33WuUsfKDHGho1KNSTknixEzYriUr2do8K", true},
+		{&mainnet, "MTDcfh43xT93odL1Y2uULhRLeWED2fDvBY", false},
+		{&mainnet, testnetAddr, false},
+		{&testnet, testnetAddr, true},
+		{&testnet, mainnetAddr, false},
+		{&regtest, regtestAddr, true},
+		{&regtest, mainnetAddr, false},
+	}
+	for _, test := range tests {
+		if valid := test.dec.AddressVerify(test.address); valid != test.valid {
+			t.Errorf("%s AddressVerify(%s) = %v, want %v", test.dec.NetworkParams().Name, test.address, valid, test.valid)
+		}
+	}
+
+	decoded, err := regtest.AddressDecode(regtestAddr)
+	if err != nil || hex.EncodeToString(decoded) != hex.EncodeToString(hash) {
+		t.Fatalf("regtest AddressDecode = %x, %v", decoded, err)
+	}
+}
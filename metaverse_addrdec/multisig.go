@@ -0,0 +1,105 @@
+package metaverse_addrdec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/blocktree/go-owcdrivers/addressEncoder"
+	"github.com/blocktree/go-owcrypt"
+)
+
+const (
+	opCheckMultisig = byte(0xae)
+	op1             = byte(0x51)
+
+	//MaxMultisigKeys 多重签名最多的公钥数量，OP_1~OP_16
+	MaxMultisigKeys = 16
+	//maxRedeemScriptLen 赎回脚本的最大长度，P2SH赎回脚本作为一次push不能超过520字节
+	maxRedeemScriptLen = 520
+)
+
+var (
+	ErrInvalidMultisig = errors.New("invalid multisig")
+)
+
+//checkPublicKey 公钥必须是33字节压缩格式或65字节非压缩格式
+func checkPublicKey(pub []byte) error {
+	switch {
+	case len(pub) == 33 && (pub[0] == 0x02 || pub[0] == 0x03):
+	case len(pub) == 65 && pub[0] == 0x04:
+	default:
+		return fmt.Errorf("%w: invalid public key %x", ErrInvalidMultisig, pub)
+	}
+	return nil
+}
+
+//SortPublicKeys 按字节序排序公钥，Metaverse节点生成多重签名地址前对公钥排序，
+//所以相同的公钥集合无论顺序都得到同一个地址
+func SortPublicKeys(pubs [][]byte) [][]byte {
+	sorted := make([][]byte, len(pubs))
+	copy(sorted, pubs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	return sorted
+}
+
+//MultisigRedeemScript 按公钥的顺序构建m-of-n赎回脚本：
+//OP_m <pubkey1> ... <pubkeyn> OP_n OP_CHECKMULTISIG
+func MultisigRedeemScript(required int, pubs [][]byte) ([]byte, error) {
+
+	n := len(pubs)
+	if n == 0 || n > MaxMultisigKeys {
+		return nil, fmt.Errorf("%w: %d public keys, must be between 1 and %d", ErrInvalidMultisig, n, MaxMultisigKeys)
+	}
+	if required < 1 || required > n {
+		return nil, fmt.Errorf("%w: required %d of %d public keys", ErrInvalidMultisig, required, n)
+	}
+
+	seen := make(map[string]bool, n)
+	script := []byte{op1 + byte(required-1)}
+	for _, pub := range pubs {
+		if err := checkPublicKey(pub); err != nil {
+			return nil, err
+		}
+		if seen[string(pub)] {
+			return nil, fmt.Errorf("%w: duplicate public key %x", ErrInvalidMultisig, pub)
+		}
+		seen[string(pub)] = true
+		script = append(script, byte(len(pub)))
+		script = append(script, pub...)
+	}
+	script = append(script, op1+byte(n-1), opCheckMultisig)
+
+	if len(script) > maxRedeemScriptLen {
+		return nil, fmt.Errorf("%w: redeem script is %d bytes, more than %d", ErrInvalidMultisig, len(script), maxRedeemScriptLen)
+	}
+
+	return script, nil
+}
+
+//RedeemScriptAddress 赎回脚本的P2SH地址
+func RedeemScriptAddress(redeemScript []byte, p2sh addressEncoder.AddressType) string {
+	hash := owcrypt.Hash(redeemScript, 0, owcrypt.HASH_ALG_HASH160)
+	return addressEncoder.AddressEncode(hash, p2sh)
+}
+
+//MultisigAddress 公钥排序后生成m-of-n多重签名地址，返回地址和赎回脚本
+func (dec *AddressDecoderV2) MultisigAddress(required int, pubs [][]byte) (string, []byte, error) {
+	redeemScript, err := MultisigRedeemScript(required, SortPublicKeys(pubs))
+	if err != nil {
+		return "", nil, err
+	}
+	return RedeemScriptAddress(redeemScript, dec.NetworkParams().P2SH), redeemScript, nil
+}
+
+//RedeemScriptToAddress 多重签名赎回脚本转地址
+func (dec *AddressDecoderV2) RedeemScriptToAddress(pubs [][]byte, required uint64, isTestnet bool) (string, error) {
+	redeemScript, err := MultisigRedeemScript(int(required), SortPublicKeys(pubs))
+	if err != nil {
+		return "", err
+	}
+	return RedeemScriptAddress(redeemScript, dec.paramsFor(isTestnet).P2SH), nil
+}
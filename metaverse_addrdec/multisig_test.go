@@ -0,0 +1,107 @@
+package metaverse_addrdec
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+//testMultisigPubKeys 测试用的3个非压缩公钥
+var testMultisigPubKeys = []string{
+	"0491bba2510912a5bd37da1fb5b1673010e43d2c6d812c514e91bfa9f2eb129e1c183329db55bd868e209aac2fbf02cb33d98fe74bf23f0c235d6126b1d8334f86",
+	"04865c40293a680cb9c020e7b1e106d8c1916d3cef99aa431a56d253e69256dac09ef122b1a986818a7cb624532f062c1d1f8722084861c5c3291ccffef4ec6874",
+	"048d2455d2403e08708fc1f556002f1b6cd83f992d085097f9974ab08a28838f07896fbab08f39495e15fa6fad6edbfb1e754e35fa1c7844c41f322a1863d46213",
+}
+
+func testDecodePubKeys(keys ...string) [][]byte {
+	pubs := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		pub, _ := hex.DecodeString(k)
+		pubs = append(pubs, pub)
+	}
+	return pubs
+}
+
+func TestMultisigRedeemScript(t *testing.T) {
+	pubs := testDecodePubKeys(testMultisigPubKeys...)
+
+	script, err := MultisigRedeemScript(2, pubs)
+	if err != nil {
+		t.Fatalf("MultisigRedeemScript unexpected error: %v", err)
+	}
+	want := "52" + "41" + testMultisigPubKeys[0] + "41" + testMultisigPubKeys[1] + "41" + testMultisigPubKeys[2] + "53ae"
+	if hex.EncodeToString(script) != want {
+		t.Fatalf("redeem script = %x, want %s", script, want)
+	}
+	address := RedeemScriptAddress(script, ETP_mainnetAddressP2SH)
+	if !(&AddressDecoderV2{}).AddressVerify(address) || address[0] != '3' {
+		t.Fatalf("redeem script address %s is invalid", address)
+	}
+
+	//地址与公钥的顺序无关
+	dec := AddressDecoderV2{}
+	a1, s1, _ := dec.MultisigAddress(2, pubs)
+	a2, s2, _ := dec.MultisigAddress(2, [][]byte{pubs[2], pubs[0], pubs[1]})
+	if a1 != a2 || hex.EncodeToString(s1) != hex.EncodeToString(s2) {
+		t.Fatalf("multisig address depends on the public key order: %s, %s", a1, a2)
+	}
+	if a1[0] != '3' {
+		t.Fatalf("mainnet multisig address should start with 3: %s", a1)
+	}
+	if a, _ := dec.RedeemScriptToAddress(pubs, 2, false); a != a1 {
+		t.Fatalf("RedeemScriptToAddress = %s, want %s", a, a1)
+	}
+	if a, _ := dec.RedeemScriptToAddress(pubs, 2, true); a == a1 || !(&AddressDecoderV2{IsTestNet: true}).AddressVerify(a) {
+		t.Fatalf("testnet multisig address %s is invalid", a)
+	}
+	//m不同地址不同
+	if a, _, _ := dec.MultisigAddress(1, pubs); a == a1 {
+		t.Fatalf("1-of-3 and 2-of-3 should have different addresses")
+	}
+}
+
+func TestMultisigRedeemScript_Invalid(t *testing.T) {
+	compressed := testDecodePubKeys(
+		"02d3e7f1c96a7be7903867a17f18e16cae8fad8d4d1406b6c5e35c62b425c62736",
+		"03b0bd634234abbb1ba1e986e884185c61cf43e001f9137f23c2c409273eb16e65",
+	)
+	tooMany := make([][]byte, 0)
+	for i := 0; i < MaxMultisigKeys+1; i++ {
+		pub := make([]byte, 33)
+		pub[0] = 0x02
+		pub[32] = byte(i)
+		tooMany = append(tooMany, pub)
+	}
+	//8个非压缩公钥的赎回脚本超过520字节
+	tooLong := make([][]byte, 0)
+	for i := 0; i < 8; i++ {
+		pub := make([]byte, 65)
+		pub[0] = 0x04
+		pub[64] = byte(i)
+		tooLong = append(tooLong, pub)
+	}
+
+	tests := []struct {
+		name     string
+		required int
+		pubs     [][]byte
+	}{
+		{"no keys", 1, nil},
+		{"required 0", 0, compressed},
+		{"required > n", 3, compressed},
+		{"duplicate", 1, [][]byte{compressed[0], compressed[0]}},
+		{"bad key", 1, [][]byte{compressed[0], compressed[1][:20]}},
+		{"bad prefix", 1, [][]byte{append([]byte{0x05}, compressed[0][1:]...)}},
+		{"too many", 1, tooMany},
+		{"too long", 1, tooLong},
+	}
+	for _, test := range tests {
+		if _, err := MultisigRedeemScript(test.required, test.pubs); !errors.Is(err, ErrInvalidMultisig) {
+			t.Errorf("%s: MultisigRedeemScript should fail with ErrInvalidMultisig: %v", test.name, err)
+		}
+	}
+
+	if _, err := MultisigRedeemScript(2, compressed); err != nil {
+		t.Fatalf("MultisigRedeemScript unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package metaverse_addrdec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/blocktree/go-owcdrivers/addressEncoder"
+	"github.com/blocktree/go-owcrypt"
+)
+
+const (
+	//hash160Len 地址中公钥或赎回脚本hash的长度
+	hash160Len = 20
+	//addressLen 解码后的地址长度：版本 + hash160 + 4字节校验和
+	addressLen = 1 + hash160Len + 4
+)
+
+//AddressKind 地址类型
+type AddressKind string
+
+const (
+	AddressKindP2PKH AddressKind = "p2pkh" //公钥hash地址
+	AddressKindP2SH  AddressKind = "p2sh"  //脚本hash地址，如多重签名地址
+)
+
+var (
+	ErrAddressBase58   = errors.New("address is not valid base58")
+	ErrAddressLength   = errors.New("address has wrong length")
+	ErrAddressChecksum = errors.New("address checksum mismatch")
+	ErrAddressVersion  = errors.New("address has unknown version byte")
+	//ErrAddressNetwork 地址格式正确，但属于其他网络，AddressInfo中的Network是地址所属的网络
+	ErrAddressNetwork = errors.New("address belongs to another network")
+
+	//KnownNetworks 解析地址所属网络时识别的网络
+	KnownNetworks = []NetworkParams{MainNetParams, TestNetParams}
+)
+
+//AddressInfo 地址的解析结果
+type AddressInfo struct {
+	Address string
+	Network string      //地址所属的网络
+	Kind    AddressKind //地址类型
+	Version byte        //版本字节
+	Hash    []byte      //hash160，P2PKH是公钥hash，P2SH是赎回脚本hash
+}
+
+//addressKind 版本字节在网络中对应的地址类型
+func (params NetworkParams) addressKind(version byte) (AddressKind, bool) {
+	switch version {
+	case params.P2PKH.Prefix[0]:
+		return AddressKindP2PKH, true
+	case params.P2SH.Prefix[0]:
+		return AddressKindP2SH, true
+	}
+	return "", false
+}
+
+//ValidateAddress 离线解析地址，依次检查base58编码、长度、校验和和版本字节。
+//版本字节属于KnownNetworks中的其他网络时，返回解析结果和ErrAddressNetwork，可以提示用户地址所属的网络。
+func (dec *AddressDecoderV2) ValidateAddress(address string) (*AddressInfo, error) {
+
+	data, err := addressEncoder.Base58Decode(address, addressEncoder.NewBase58Alphabet(alphabet))
+	if err != nil || len(address) == 0 {
+		return nil, ErrAddressBase58
+	}
+
+	if len(data) != addressLen {
+		return nil, fmt.Errorf("%w: %d bytes, want %d", ErrAddressLength, len(data), addressLen)
+	}
+
+	payload, checksum := data[:len(data)-4], data[len(data)-4:]
+	if !bytes.Equal(owcrypt.Hash(payload, 0, owcrypt.HASH_ALG_DOUBLE_SHA256)[:4], checksum) {
+		return nil, ErrAddressChecksum
+	}
+
+	info := &AddressInfo{
+		Address: address,
+		Version: payload[0],
+		Hash:    payload[1:],
+	}
+
+	params := dec.NetworkParams()
+	if kind, ok := params.addressKind(info.Version); ok {
+		info.Network = params.Name
+		info.Kind = kind
+		return info, nil
+	}
+
+	for _, other := range KnownNetworks {
+		if kind, ok := other.addressKind(info.Version); ok {
+			info.Network = other.Name
+			info.Kind = kind
+			return info, fmt.Errorf("%w: %s address on %s", ErrAddressNetwork, other.Name, params.Name)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: 0x%02x", ErrAddressVersion, info.Version)
+}
@@ -0,0 +1,72 @@
+package metaverse_addrdec
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/blocktree/go-owcdrivers/addressEncoder"
+	"github.com/blocktree/go-owcrypt"
+)
+
+func TestAddressDecoder_ValidateAddress(t *testing.T) {
+	hash, _ := hex.DecodeString("d3e7f1c96a7be7903867a17f18e16cae8fad8d4d")
+
+	mainnet := AddressDecoderV2{}
+	testnet := AddressDecoderV2{IsTestNet: true}
+
+	mainnetAddr, _ := mainnet.AddressEncode(hash)
+	mainnetP2SH, _ := mainnet.AddressEncode(hash, ETP_mainnetAddressP2SH)
+	testnetAddr, _ := testnet.AddressEncode(hash)
+	testnetP2SH, _ := testnet.AddressEncode(hash, ETP_testnetAddressP2SH)
+
+	//校验和正确，但长度或版本不对的地址
+	encode := func(payload []byte) string {
+		data := append(payload, owcrypt.Hash(payload, 0, owcrypt.HASH_ALG_DOUBLE_SHA256)[:4]...)
+		return addressEncoder.Base58Encode(data, addressEncoder.NewBase58Alphabet(alphabet))
+	}
+	shortAddr := encode(append([]byte{0x32}, hash[:19]...))
+	unknownVersion := encode(append([]byte{0x00}, hash...))
+
+	tests := []struct {
+		name    string
+		dec     *AddressDecoderV2
+		address string
+		network string
+		kind    AddressKind
+		err     error
+	}{
+		{"mainnet p2pkh", &mainnet, mainnetAddr, "mainnet", AddressKindP2PKH, nil},
+		{"mainnet p2sh", &mainnet, mainnetP2SH, "mainnet", AddressKindP2SH, nil},
+		{"testnet p2pkh", &testnet, testnetAddr, "testnet", AddressKindP2PKH, nil},
+		{"testnet p2sh", &testnet, testnetP2SH, "testnet", AddressKindP2SH, nil},
+		{"testnet address on mainnet", &mainnet, testnetAddr, "testnet", AddressKindP2PKH, ErrAddressNetwork},
+		{"mainnet p2sh on testnet", &testnet, mainnetP2SH, "mainnet", AddressKindP2SH, ErrAddressNetwork},
+		{"empty", &mainnet, "", "", "", ErrAddressBase58},
+		{"bad base58", &mainnet, "MTDcfh43xT93odL1Y2uULhRLeWED2fDv0l", "", "", ErrAddressBase58},
+		{"checksum", &mainnet, "MTDcfh43xT93odL1Y2uULhRLeWED2fDvBY", "", "", ErrAddressChecksum},
+		{"short", &mainnet, shortAddr, "", "", ErrAddressLength},
+		{"truncated", &mainnet, mainnetAddr[:len(mainnetAddr)-3], "", "", ErrAddressLength},
+		{"unknown version", &mainnet, unknownVersion, "", "", ErrAddressVersion},
+	}
+
+	for _, test := range tests {
+		info, err := test.dec.ValidateAddress(test.address)
+		if !errors.Is(err, test.err) || (test.err == nil && err != nil) {
+			t.Errorf("%s: ValidateAddress error = %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if len(test.network) == 0 {
+			if info != nil {
+				t.Errorf("%s: ValidateAddress should not return info: %+v", test.name, info)
+			}
+			continue
+		}
+		if info == nil || info.Network != test.network || info.Kind != test.kind || hex.EncodeToString(info.Hash) != hex.EncodeToString(hash) {
+			t.Errorf("%s: ValidateAddress = %+v, want %s %s", test.name, info, test.network, test.kind)
+		}
+		if valid := test.dec.AddressVerify(test.address); valid != (test.err == nil) {
+			t.Errorf("%s: AddressVerify = %v", test.name, valid)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package metaverse_addrdec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/blocktree/go-owcdrivers/addressEncoder"
+	"github.com/blocktree/go-owcrypt"
+)
+
+const (
+	//privateKeyLen 私钥长度
+	privateKeyLen = 32
+	//compressFlag WIF中表示对应压缩公钥的后缀
+	compressFlag = byte(0x01)
+)
+
+var (
+	//secp256k1Order secp256k1曲线的阶，私钥必须小于该值
+	secp256k1Order, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+
+	ErrInvalidPrivateKey = errors.New("invalid private key")
+	ErrInvalidWIF        = errors.New("invalid wif")
+	ErrWIFChecksum       = errors.New("wif checksum mismatch")
+)
+
+//checkPrivateKey 私钥必须是32字节，且在(0, n)范围内
+func checkPrivateKey(priv []byte) error {
+	if len(priv) != privateKeyLen {
+		return fmt.Errorf("%w: length %d, want %d", ErrInvalidPrivateKey, len(priv), privateKeyLen)
+	}
+	k := new(big.Int).SetBytes(priv)
+	if k.Sign() == 0 || k.Cmp(secp256k1Order) >= 0 {
+		return fmt.Errorf("%w: out of range", ErrInvalidPrivateKey)
+	}
+	return nil
+}
+
+//EncodeWIF 私钥编码为WIF：version + 私钥 [+ 0x01] + 4字节校验和。
+//compressed表示私钥对应压缩公钥，钱包生成的地址都使用压缩公钥。
+func EncodeWIF(priv []byte, compressed bool, version byte) (string, error) {
+	if err := checkPrivateKey(priv); err != nil {
+		return "", err
+	}
+
+	data := make([]byte, 0, 1+privateKeyLen+1+4)
+	data = append(data, version)
+	data = append(data, priv...)
+	if compressed {
+		data = append(data, compressFlag)
+	}
+	checksum := owcrypt.Hash(data, 0, owcrypt.HASH_ALG_DOUBLE_SHA256)[:4]
+	data = append(data, checksum...)
+
+	return addressEncoder.Base58Encode(data, addressEncoder.NewBase58Alphabet(alphabet)), nil
+}
+
+//DecodeWIF 解析WIF，返回私钥和是否对应压缩公钥，版本与version不一致时返回错误
+func DecodeWIF(wif string, version byte) ([]byte, bool, error) {
+
+	data, err := addressEncoder.Base58Decode(wif, addressEncoder.NewBase58Alphabet(alphabet))
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidWIF, err)
+	}
+
+	compressed := false
+	switch len(data) {
+	case 1 + privateKeyLen + 4:
+	case 1 + privateKeyLen + 1 + 4:
+		if data[1+privateKeyLen] != compressFlag {
+			return nil, false, fmt.Errorf("%w: unknown compression flag 0x%02x", ErrInvalidWIF, data[1+privateKeyLen])
+		}
+		compressed = true
+	default:
+		return nil, false, fmt.Errorf("%w: length %d", ErrInvalidWIF, len(data))
+	}
+
+	payload, checksum := data[:len(data)-4], data[len(data)-4:]
+	if !bytes.Equal(owcrypt.Hash(payload, 0, owcrypt.HASH_ALG_DOUBLE_SHA256)[:4], checksum) {
+		return nil, false, ErrWIFChecksum
+	}
+
+	if payload[0] != version {
+		return nil, false, fmt.Errorf("%w: version 0x%02x, want 0x%02x", ErrInvalidWIF, payload[0], version)
+	}
+
+	priv := make([]byte, privateKeyLen)
+	copy(priv, payload[1:1+privateKeyLen])
+	if err := checkPrivateKey(priv); err != nil {
+		return nil, false, err
+	}
+
+	return priv, compressed, nil
+}
+
+//paramsFor isTestnet与解析器的网络一致时使用解析器的网络参数，否则使用主网或测试网
+func (dec *AddressDecoderV2) paramsFor(isTestnet bool) NetworkParams {
+	if isTestnet == dec.IsTestNet {
+		return dec.NetworkParams()
+	}
+	if isTestnet {
+		return TestNetParams
+	}
+	return MainNetParams
+}
+
+//PrivateKeyToWIF 私钥转WIF，使用压缩公钥格式
+func (dec *AddressDecoderV2) PrivateKeyToWIF(priv []byte, isTestnet bool) (string, error) {
+	return EncodeWIF(priv, true, dec.paramsFor(isTestnet).WIFPrefix)
+}
+
+//WIFToPrivateKey WIF转私钥，压缩和非压缩格式都可以解析
+func (dec *AddressDecoderV2) WIFToPrivateKey(wif string, isTestnet bool) ([]byte, error) {
+	priv, _, err := DecodeWIF(wif, dec.paramsFor(isTestnet).WIFPrefix)
+	return priv, err
+}
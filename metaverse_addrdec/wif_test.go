@@ -0,0 +1,126 @@
+package metaverse_addrdec
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+const (
+	testWIFPrivateKey   = "0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d"
+	testWIFUncompressed = "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ"
+	testWIFCompressed   = "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617"
+)
+
+func TestEncodeWIF(t *testing.T) {
+	priv, _ := hex.DecodeString(testWIFPrivateKey)
+
+	wif, err := EncodeWIF(priv, false, MainNetParams.WIFPrefix)
+	if err != nil || wif != testWIFUncompressed {
+		t.Fatalf("EncodeWIF uncompressed = %s, %v", wif, err)
+	}
+	wif, err = EncodeWIF(priv, true, MainNetParams.WIFPrefix)
+	if err != nil || wif != testWIFCompressed {
+		t.Fatalf("EncodeWIF compressed = %s, %v", wif, err)
+	}
+
+	invalid := [][]byte{
+		nil,
+		make([]byte, 31),
+		make([]byte, 32),
+		secp256k1Order.Bytes(),
+	}
+	for _, key := range invalid {
+		if _, err := EncodeWIF(key, true, MainNetParams.WIFPrefix); !errors.Is(err, ErrInvalidPrivateKey) {
+			t.Errorf("EncodeWIF(%x) should fail with ErrInvalidPrivateKey: %v", key, err)
+		}
+	}
+}
+
+func TestDecodeWIF(t *testing.T) {
+	tests := []struct {
+		wif        string
+		compressed bool
+	}{
+		{testWIFUncompressed, false},
+		{testWIFCompressed, true},
+	}
+	for _, test := range tests {
+		priv, compressed, err := DecodeWIF(test.wif, MainNetParams.WIFPrefix)
+		if err != nil || hex.EncodeToString(priv) != testWIFPrivateKey || compressed != test.compressed {
+			t.Errorf("DecodeWIF(%s) = %x, %v, %v", test.wif, priv, compressed, err)
+		}
+	}
+
+	//校验和错误
+	if _, _, err := DecodeWIF("KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98618", MainNetParams.WIFPrefix); !errors.Is(err, ErrWIFChecksum) {
+		t.Errorf("DecodeWIF with a bad checksum should fail: %v", err)
+	}
+	//测试网的版本不同
+	if _, _, err := DecodeWIF(testWIFCompressed, TestNetParams.WIFPrefix); !errors.Is(err, ErrInvalidWIF) {
+		t.Errorf("DecodeWIF with a mainnet wif on testnet should fail: %v", err)
+	}
+	for _, wif := range []string{"", "0OIl", "1111111111"} {
+		if _, _, err := DecodeWIF(wif, MainNetParams.WIFPrefix); err == nil {
+			t.Errorf("DecodeWIF(%q) should fail", wif)
+		}
+	}
+}
+
+func TestAddressDecoder_WIF(t *testing.T) {
+	priv, _ := hex.DecodeString(testWIFPrivateKey)
+
+	dec := AddressDecoderV2{}
+	for _, isTestnet := range []bool{false, true} {
+		wif, err := dec.PrivateKeyToWIF(priv, isTestnet)
+		if err != nil {
+			t.Fatalf("PrivateKeyToWIF unexpected error: %v", err)
+		}
+		key, err := dec.WIFToPrivateKey(wif, isTestnet)
+		if err != nil || hex.EncodeToString(key) != testWIFPrivateKey {
+			t.Fatalf("WIFToPrivateKey(%s) = %x, %v", wif, key, err)
+		}
+		if _, err := dec.WIFToPrivateKey(wif, !isTestnet); err == nil {
+			t.Fatalf("WIFToPrivateKey(%s) on the other network should fail", wif)
+		}
+	}
+	if wif, _ := dec.PrivateKeyToWIF(priv, false); wif != testWIFCompressed {
+		t.Fatalf("PrivateKeyToWIF = %s, want %s", wif, testWIFCompressed)
+	}
+	if wif, _ := dec.PrivateKeyToWIF(priv, true); wif[0] != 'c' {
+		t.Fatalf("testnet compressed wif should start with c: %s", wif)
+	}
+}
+
+func TestAddressDecoder_WIF_Params(t *testing.T) {
+	priv, _ := hex.DecodeString(testWIFPrivateKey)
+	pubs := testDecodePubKeys(testMultisigPubKeys...)
+
+	//设置了主网参数时isTestnet = true仍然使用测试网版本
+	mainnet := NewAddressDecoderV2(MainNetParams, false)
+	testnet := AddressDecoderV2{IsTestNet: true}
+	want, _ := testnet.PrivateKeyToWIF(priv, true)
+	if wif, _ := mainnet.PrivateKeyToWIF(priv, true); wif != want {
+		t.Fatalf("PrivateKeyToWIF(isTestnet = true) = %s, want %s", wif, want)
+	}
+	if key, err := mainnet.WIFToPrivateKey(want, true); err != nil || hex.EncodeToString(key) != testWIFPrivateKey {
+		t.Fatalf("WIFToPrivateKey(%s, isTestnet = true) = %x, %v", want, key, err)
+	}
+	if _, err := mainnet.WIFToPrivateKey(testWIFCompressed, true); err == nil {
+		t.Fatalf("WIFToPrivateKey with a mainnet wif and isTestnet = true should fail")
+	}
+	wantAddr, _ := testnet.RedeemScriptToAddress(pubs, 2, true)
+	if a, _ := mainnet.RedeemScriptToAddress(pubs, 2, true); a != wantAddr {
+		t.Fatalf("RedeemScriptToAddress(isTestnet = true) = %s, want %s", a, wantAddr)
+	}
+
+	//与解析器网络一致时使用自定义参数
+	regtest := NewAddressDecoderV2(NewNetworkParams("regtest", 0x41, 0x42, 0x43), true)
+	wif, _ := regtest.PrivateKeyToWIF(priv, true)
+	if _, _, err := DecodeWIF(wif, 0x43); err != nil {
+		t.Fatalf("regtest wif %s unexpected error: %v", wif, err)
+	}
+	if wif, _ := regtest.PrivateKeyToWIF(priv, false); wif != testWIFCompressed {
+		t.Fatalf("PrivateKeyToWIF(isTestnet = false) = %s, want %s", wif, testWIFCompressed)
+	}
+}
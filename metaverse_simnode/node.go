@@ -0,0 +1,850 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+// Package metaverse_simnode 是一个进程内的Metaverse节点模拟器。
+// 它以内存中的UTXO链提供适配器所需的v3 JSON-RPC接口，
+// 测试可以出块、给地址充值和锁仓存款、发行MST资产、注册和转移MIT、制造分叉以及丢弃交易池中的交易，无需连接真实节点。
+package metaverse_simnode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+
+	"github.com/blocktree/go-owcdrivers/addressEncoder"
+	"github.com/blocktree/metaverse-adapter/metaverse_addrdec"
+)
+
+const (
+	//GenesisTime 创世区块时间
+	GenesisTime = uint64(1486796400)
+	//BlockInterval 模拟的出块间隔（秒）
+	BlockInterval = uint64(15)
+	//BlockReward 每个区块给矿工地址的奖励
+	BlockReward = uint64(300000000)
+	//WalletVersion getinfo返回的默认钱包版本
+	WalletVersion = "0.9.0"
+	//ProtocolVersion getinfo返回的协议版本
+	ProtocolVersion = uint64(70012)
+)
+
+// Block 模拟节点的区块
+type Block struct {
+	Hash       string
+	PrevHash   string
+	MerkleRoot string
+	Height     uint64
+	Timestamp  uint64
+	Nonce      uint64
+	Version    uint32
+	Txs        []*Tx
+}
+
+// outPoint 输出引用
+type outPoint struct {
+	hash  string
+	index uint32
+}
+
+// utxo 未花输出
+type utxo struct {
+	outPoint
+	out    *Output
+	height uint64
+}
+
+// txRecord 已上链的交易单
+type txRecord struct {
+	tx     *Tx
+	height uint64
+}
+
+// assetInfo 已发行的资产
+type assetInfo struct {
+	symbol      string
+	decimals    uint8
+	issuer      string
+	description string
+}
+
+// Node 进程内的Metaverse模拟节点
+type Node struct {
+	mu sync.Mutex
+
+	isTestNet    bool
+	decoder      *metaverse_addrdec.AddressDecoderV2
+	minerAddress string
+	nonce        uint64
+
+	blocks   []*Block
+	txs      map[string]*txRecord
+	utxos    map[outPoint]*utxo
+	received map[string]uint64
+	assets   map[string]*assetInfo
+	mits     map[string]string //已注册的MIT和注册时的内容
+
+	pending      []*Tx               //等待打包的生成交易（充值、发行资产）
+	mempool      []*Tx               //通过sendrawtx广播的交易
+	mempoolSpent map[outPoint]string //交易池中已被花费的输出
+
+	server   *httptest.Server
+	requests int //收到的HTTP请求数量
+
+	version string //getinfo返回的钱包版本
+	peers   uint64 //getinfo返回的连接节点数
+
+	notifyURL string //出块后POST新区块通知的地址
+
+	dids map[string][]string //DID绑定的地址历史，最后一个是当前地址
+}
+
+// NewNode 创建模拟节点，自动生成创世区块
+func NewNode(isTestNet bool) *Node {
+	n := &Node{
+		isTestNet:    isTestNet,
+		decoder:      &metaverse_addrdec.AddressDecoderV2{IsTestNet: isTestNet},
+		assets:       make(map[string]*assetInfo),
+		dids:         make(map[string][]string),
+		mempoolSpent: make(map[outPoint]string),
+		version:      WalletVersion,
+		peers:        8,
+	}
+	n.minerAddress, _ = n.decoder.AddressEncode(hash160([]byte("metaverse-simnode miner")))
+	n.rebuild()
+	n.mineBlock()
+	return n
+}
+
+// Start 启动HTTP服务，返回JSON-RPC地址
+func (n *Node) Start() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.server == nil {
+		n.server = httptest.NewServer(n)
+	}
+	return n.server.URL + "/rpc/v3"
+}
+
+// SetVersion 设置getinfo返回的钱包版本
+func (n *Node) SetVersion(version string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.version = version
+}
+
+// SetBlockNotify 设置新区块通知地址，出块和分叉后POST {"height":1,"hash":"..."}，为空时不通知
+func (n *Node) SetBlockNotify(url string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifyURL = url
+}
+
+// notifyBlock 发送新区块通知，调用时不能持有锁
+func (n *Node) notifyBlock(url string, b *Block) {
+	if len(url) == 0 || b == nil {
+		return
+	}
+	body := fmt.Sprintf(`{"height":%d,"hash":"%s"}`, b.Height, b.Hash)
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// RegisterDID 注册DID或把DID转移到新地址
+func (n *Node) RegisterDID(symbol, address string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, err := n.lockScript(address); err != nil {
+		return err
+	}
+	n.dids[symbol] = append(n.dids[symbol], address)
+	return nil
+}
+
+// Close 关闭HTTP服务
+func (n *Node) Close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.server != nil {
+		n.server.Close()
+		n.server = nil
+	}
+}
+
+// MultisigAddress 与节点getnewmultisig相同的方式生成m-of-n多重签名地址：
+// 公钥排序后构建 m [ pubkey ]... n checkmultisig 赎回脚本，返回P2SH地址和赎回脚本
+func (n *Node) MultisigAddress(required int, pubkeys ...[]byte) (string, []byte) {
+	sorted := make([][]byte, len(pubkeys))
+	copy(sorted, pubkeys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+
+	script := []byte{0x50 + byte(required)}
+	for _, pub := range sorted {
+		script = append(script, pushData(pub)...)
+	}
+	script = append(script, 0x50+byte(len(sorted)), opCheckMultiSig)
+
+	address, _ := n.decoder.AddressEncode(hash160(script), n.p2shType())
+	return address, script
+}
+
+// MinerAddress 接收区块奖励的地址
+func (n *Node) MinerAddress() string {
+	return n.minerAddress
+}
+
+// Height 当前最高区块高度
+func (n *Node) Height() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.tip().Height
+}
+
+// BlockHash 主链上指定高度的区块hash
+func (n *Node) BlockHash(height uint64) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if height >= uint64(len(n.blocks)) {
+		return ""
+	}
+	return n.blocks[height].Hash
+}
+
+// Mempool 交易池中的交易单ID
+func (n *Node) Mempool() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	txids := make([]string, 0, len(n.mempool))
+	for _, tx := range n.mempool {
+		txids = append(txids, tx.Hash())
+	}
+	return txids
+}
+
+// DropMempoolTx 把交易移出交易池，模拟交易过期或被节点丢弃，交易的输入可以再次花费
+func (n *Node) DropMempoolTx(txid string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i, tx := range n.mempool {
+		if tx.Hash() != txid {
+			continue
+		}
+		for _, in := range tx.Inputs {
+			delete(n.mempoolSpent, outPoint{in.PrevHash, in.PrevIndex})
+		}
+		n.mempool = append(n.mempool[:i], n.mempool[i+1:]...)
+		return nil
+	}
+	return fmt.Errorf("transaction %s is not in memory pool", txid)
+}
+
+// Fund 给地址充值ETP，交易在下一个区块打包
+func (n *Node) Fund(address string, value uint64) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	script, err := n.lockScript(address)
+	if err != nil {
+		return "", err
+	}
+
+	tx := n.generatedTx(&Output{Value: value, Script: script})
+	n.pending = append(n.pending, tx)
+	return tx.Hash(), nil
+}
+
+// Deposit 给地址锁仓存款ETP，存款在lockBlocks个区块后到期，交易在下一个区块打包
+func (n *Node) Deposit(address string, value, lockBlocks uint64) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if lockBlocks == 0 {
+		return "", fmt.Errorf("lock blocks must be greater than 0")
+	}
+	if _, err := n.lockScript(address); err != nil {
+		return "", err
+	}
+	hash, err := n.decoder.AddressDecode(address)
+	if err != nil {
+		return "", fmt.Errorf("deposit address must be a p2pkh address")
+	}
+
+	tx := n.generatedTx(&Output{Value: value, Script: lockHeightScript(lockBlocks, hash)})
+	n.pending = append(n.pending, tx)
+	return tx.Hash(), nil
+}
+
+// IssueAsset 给地址发行MST资产，交易在下一个区块打包
+func (n *Node) IssueAsset(address, symbol string, quantity uint64, decimals uint8) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, exist := n.assets[symbol]; exist {
+		return "", fmt.Errorf("asset %s already exists", symbol)
+	}
+	for _, tx := range n.pending {
+		for _, out := range tx.Outputs {
+			if out.Attachment.Type == attachmentAsset && out.Attachment.Status == assetStatusIssue && out.Attachment.Symbol == symbol {
+				return "", fmt.Errorf("asset %s already exists", symbol)
+			}
+		}
+	}
+
+	script, err := n.lockScript(address)
+	if err != nil {
+		return "", err
+	}
+
+	tx := n.generatedTx(&Output{
+		Script: script,
+		Attachment: Attachment{
+			Type:        attachmentAsset,
+			Status:      assetStatusIssue,
+			Symbol:      symbol,
+			Quantity:    quantity,
+			Decimals:    decimals,
+			Issuer:      symbol,
+			Address:     address,
+			Description: symbol + " issued by metaverse simnode",
+		},
+	})
+	n.pending = append(n.pending, tx)
+	return tx.Hash(), nil
+}
+
+// RegisterMIT 给地址注册MIT，交易在下一个区块打包
+func (n *Node) RegisterMIT(address, symbol, content string) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, exist := n.mits[symbol]; exist {
+		return "", fmt.Errorf("mit %s already exists", symbol)
+	}
+	for _, tx := range n.pending {
+		for _, out := range tx.Outputs {
+			if out.Attachment.Type == attachmentMIT && out.Attachment.Symbol == symbol {
+				return "", fmt.Errorf("mit %s already exists", symbol)
+			}
+		}
+	}
+
+	script, err := n.lockScript(address)
+	if err != nil {
+		return "", err
+	}
+
+	tx := n.generatedTx(&Output{
+		Script: script,
+		Attachment: Attachment{
+			Type:    attachmentMIT,
+			Status:  mitStatusRegister,
+			Symbol:  symbol,
+			Address: address,
+			Content: content,
+		},
+	})
+	n.pending = append(n.pending, tx)
+	return tx.Hash(), nil
+}
+
+// TransferMIT 把MIT转给地址，交易花费持有MIT的输出，在下一个区块打包。
+// 模拟节点不持有私钥，交易没有签名，分叉时不会重新进入交易池。
+func (n *Node) TransferMIT(symbol, to string) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	script, err := n.lockScript(to)
+	if err != nil {
+		return "", err
+	}
+
+	for _, u := range n.utxos {
+		a := u.out.Attachment
+		if a.Type != attachmentMIT || a.Symbol != symbol {
+			continue
+		}
+		if _, spent := n.mempoolSpent[u.outPoint]; spent || n.pendingSpent(u.outPoint) {
+			break
+		}
+		tx := &Tx{
+			Version: txVersion,
+			Inputs:  []*Input{{PrevHash: u.hash, PrevIndex: u.index, Sequence: maxSequence}},
+			Outputs: []*Output{{
+				Script: script,
+				Attachment: Attachment{
+					Type:    attachmentMIT,
+					Status:  mitStatusTransfer,
+					Symbol:  symbol,
+					Address: to,
+				},
+			}},
+		}
+		n.pending = append(n.pending, tx)
+		return tx.Hash(), nil
+	}
+	return "", fmt.Errorf("mit %s is not spendable", symbol)
+}
+
+// pendingSpent 输出是否已被等待打包的交易花费
+func (n *Node) pendingSpent(op outPoint) bool {
+	for _, tx := range n.pending {
+		for _, in := range tx.Inputs {
+			if in.PrevHash == op.hash && in.PrevIndex == op.index {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Mine 出count个区块，返回区块hash
+func (n *Node) Mine(count int) []string {
+	n.mu.Lock()
+
+	hashes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		hashes = append(hashes, n.mineBlock().Hash)
+	}
+	url, tip := n.notifyURL, n.tip()
+	n.mu.Unlock()
+
+	if count > 0 {
+		n.notifyBlock(url, tip)
+	}
+	return hashes
+}
+
+// Reorg 断开最高的depth个区块，再出depth+1个新区块使新分支成为主链。
+// 被断开区块中的生成交易被丢弃，广播的交易退回交易池重新校验；
+// 调用前通过Fund/IssueAsset加入的交易打包在第一个新区块中。
+func (n *Node) Reorg(depth int) error {
+	n.mu.Lock()
+
+	if depth <= 0 || depth >= len(n.blocks) {
+		n.mu.Unlock()
+		return fmt.Errorf("invalid reorg depth: %d", depth)
+	}
+
+	orphans := n.blocks[len(n.blocks)-depth:]
+	n.blocks = n.blocks[:len(n.blocks)-depth]
+	n.rebuild()
+
+	candidates := make([]*Tx, 0)
+	for _, b := range orphans {
+		for _, tx := range b.Txs {
+			if !tx.IsGenerated() {
+				candidates = append(candidates, tx)
+			}
+		}
+	}
+	candidates = append(candidates, n.mempool...)
+	n.mempool = nil
+	n.mempoolSpent = make(map[outPoint]string)
+	for _, tx := range candidates {
+		if n.validateTx(tx) == nil {
+			n.acceptToMempool(tx)
+		}
+	}
+
+	for i := 0; i <= depth; i++ {
+		n.mineBlock()
+	}
+	url, tip := n.notifyURL, n.tip()
+	n.mu.Unlock()
+
+	n.notifyBlock(url, tip)
+	return nil
+}
+
+// tip 最高区块
+func (n *Node) tip() *Block {
+	return n.blocks[len(n.blocks)-1]
+}
+
+// generatedTx 构造没有真实输入的交易，用于充值和发行资产
+func (n *Node) generatedTx(outputs ...*Output) *Tx {
+	n.nonce++
+	var tag [8]byte
+	binary.LittleEndian.PutUint64(tag[:], n.nonce)
+	return &Tx{
+		Version: txVersion,
+		Inputs: []*Input{{
+			PrevHash:  nullHash,
+			PrevIndex: maxSequence,
+			Script:    pushData(tag[:]),
+			Sequence:  maxSequence,
+		}},
+		Outputs: outputs,
+	}
+}
+
+// mineBlock 打包pending和交易池的交易，生成新区块
+func (n *Node) mineBlock() *Block {
+	var (
+		prevHash = nullHash
+		height   = uint64(0)
+		fees     = uint64(0)
+	)
+	if len(n.blocks) > 0 {
+		prevHash = n.tip().Hash
+		height = n.tip().Height + 1
+	}
+
+	for _, tx := range n.mempool {
+		fees += n.txFee(tx)
+	}
+
+	coinbase := n.generatedTx(&Output{Value: BlockReward + fees, Script: n.mustLockScript(n.minerAddress)})
+	txs := []*Tx{coinbase}
+	txs = append(txs, n.pending...)
+	txs = append(txs, n.mempool...)
+
+	n.nonce++
+	block := &Block{
+		PrevHash:  prevHash,
+		Height:    height,
+		Timestamp: GenesisTime + height*BlockInterval,
+		Nonce:     n.nonce,
+		Version:   1,
+		Txs:       txs,
+	}
+	block.MerkleRoot = merkleRoot(txs)
+	block.Hash = blockHash(block)
+
+	n.blocks = append(n.blocks, block)
+	n.connectBlock(block)
+
+	n.pending = nil
+	n.mempool = nil
+	n.mempoolSpent = make(map[outPoint]string)
+	return block
+}
+
+// rebuild 按主链重建UTXO集合和交易索引
+func (n *Node) rebuild() {
+	n.txs = make(map[string]*txRecord)
+	n.utxos = make(map[outPoint]*utxo)
+	n.received = make(map[string]uint64)
+	n.assets = make(map[string]*assetInfo)
+	n.mits = make(map[string]string)
+	for _, b := range n.blocks {
+		n.connectBlock(b)
+	}
+}
+
+// connectBlock 把区块的交易应用到UTXO集合
+func (n *Node) connectBlock(b *Block) {
+	for _, tx := range b.Txs {
+		txid := tx.Hash()
+		n.txs[txid] = &txRecord{tx: tx, height: b.Height}
+		if !tx.IsGenerated() {
+			for _, in := range tx.Inputs {
+				delete(n.utxos, outPoint{in.PrevHash, in.PrevIndex})
+			}
+		}
+		for i, out := range tx.Outputs {
+			op := outPoint{txid, uint32(i)}
+			n.utxos[op] = &utxo{outPoint: op, out: out, height: b.Height}
+			n.received[n.scriptAddress(out.Script)] += out.Value
+			if a := out.Attachment; a.Type == attachmentAsset && a.Status == assetStatusIssue {
+				n.assets[a.Symbol] = &assetInfo{
+					symbol:      a.Symbol,
+					decimals:    a.Decimals,
+					issuer:      a.Issuer,
+					description: a.Description,
+				}
+			}
+			if a := out.Attachment; a.Type == attachmentMIT && a.Status == mitStatusRegister {
+				n.mits[a.Symbol] = a.Content
+			}
+		}
+	}
+}
+
+// txFee 交易手续费，输入总额减输出总额
+func (n *Node) txFee(tx *Tx) uint64 {
+	in, out := uint64(0), uint64(0)
+	for _, input := range tx.Inputs {
+		if prev := n.findOutput(input.PrevHash, input.PrevIndex); prev != nil {
+			in += prev.Value
+		}
+	}
+	for _, output := range tx.Outputs {
+		out += output.Value
+	}
+	if in < out {
+		return 0
+	}
+	return in - out
+}
+
+// findOutput 查找已上链或交易池中交易的输出
+func (n *Node) findOutput(txid string, index uint32) *Output {
+	var tx *Tx
+	if rec, ok := n.txs[txid]; ok {
+		tx = rec.tx
+	} else {
+		tx = n.mempoolTx(txid)
+	}
+	if tx == nil || int(index) >= len(tx.Outputs) {
+		return nil
+	}
+	return tx.Outputs[index]
+}
+
+// mempoolTx 查找交易池中的交易
+func (n *Node) mempoolTx(txid string) *Tx {
+	for _, tx := range n.mempool {
+		if tx.Hash() == txid {
+			return tx
+		}
+	}
+	return nil
+}
+
+// validateTx 校验交易的输入、签名和金额
+func (n *Node) validateTx(tx *Tx) *rpcError {
+
+	txid := tx.Hash()
+	if _, exist := n.txs[txid]; exist {
+		return newRPCError(codeTxBroadcast, "transaction already exists in blockchain")
+	}
+	if n.mempoolTx(txid) != nil {
+		return newRPCError(codeTxBroadcast, "transaction already exists in memory pool")
+	}
+	if tx.IsGenerated() {
+		return newRPCError(codeTxValidate, "coinbase transaction can not be broadcast")
+	}
+
+	var (
+		etpIn, etpOut = uint64(0), uint64(0)
+		assetIn       = make(map[string]uint64)
+		assetOut      = make(map[string]uint64)
+	)
+
+	for i, in := range tx.Inputs {
+		op := outPoint{in.PrevHash, in.PrevIndex}
+		prev := n.spendable(op)
+		if prev == nil {
+			return newRPCError(codeTxValidate, "input %s:%d is missing or already spent", in.PrevHash, in.PrevIndex)
+		}
+		if u, ok := n.utxos[op]; ok && n.frozen(u) {
+			return newRPCError(codeTxValidate, "input %s:%d is locked until height %d", in.PrevHash, in.PrevIndex, u.height+scriptLockHeight(u.out.Script))
+		}
+		if spender, spent := n.mempoolSpent[op]; spent {
+			return newRPCError(codeTxBroadcast, "input %s:%d is double spent by %s", in.PrevHash, in.PrevIndex, spender)
+		}
+		if err := verifyInput(tx, i, prev.Script); err != nil {
+			return newRPCError(codeTxValidate, "input %d: %v", i, err)
+		}
+		etpIn += prev.Value
+		if prev.Attachment.Type == attachmentAsset {
+			assetIn[prev.Attachment.Symbol] += prev.Attachment.Quantity
+		}
+	}
+
+	for _, out := range tx.Outputs {
+		if _, _, ok := scriptHash(out.Script); !ok {
+			return newRPCError(codeTxValidate, "unsupported output script")
+		}
+		etpOut += out.Value
+		if out.Attachment.Type == attachmentAsset {
+			if out.Attachment.Status != assetStatusTransfer {
+				return newRPCError(codeTxValidate, "asset issue is not supported by sendrawtx")
+			}
+			assetOut[out.Attachment.Symbol] += out.Attachment.Quantity
+		}
+		if out.Attachment.Type == attachmentMIT {
+			return newRPCError(codeTxValidate, "mit is not supported by sendrawtx")
+		}
+	}
+
+	if etpIn < etpOut {
+		return newRPCError(codeTxValidate, "outputs value %d exceeds inputs value %d", etpOut, etpIn)
+	}
+	for symbol, quantity := range assetOut {
+		if assetIn[symbol] < quantity {
+			return newRPCError(codeTxValidate, "asset %s outputs exceed inputs", symbol)
+		}
+	}
+
+	return nil
+}
+
+// verifyInput 校验P2PKH输入的签名脚本，花费锁仓存款时签名和公钥之后压入锁定的区块数
+func verifyInput(tx *Tx, index int, prevScript []byte) error {
+	hash, isP2SH, ok := scriptHash(prevScript)
+	if !ok || isP2SH {
+		return fmt.Errorf("unsupported previous output script")
+	}
+	ops, err := parseScript(tx.Inputs[index].Script)
+	want := 2
+	if scriptLockHeight(prevScript) > 0 {
+		want = 3
+	}
+	if err != nil || len(ops) != want || !ops[0].push || !ops[1].push {
+		return fmt.Errorf("input script is not signed")
+	}
+	if want == 3 {
+		prevOps, _ := parseScript(prevScript)
+		if !ops[2].push || !bytes.Equal(ops[2].data, prevOps[0].data) {
+			return fmt.Errorf("input script does not match the lock height")
+		}
+	}
+	sig, hashType, err := decodeSignature(ops[0].data)
+	if err != nil {
+		return err
+	}
+	if uint32(hashType) != sigHashAll {
+		return fmt.Errorf("unsupported sighash type: %d", hashType)
+	}
+	pubkey := ops[1].data
+	if !bytes.Equal(hash160(pubkey), hash) {
+		return fmt.Errorf("public key does not match previous output")
+	}
+	if !verifySignature(pubkey, tx.SigHash(index, prevScript), sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// spendable 查找可花费的输出，包括交易池中交易的输出
+func (n *Node) spendable(op outPoint) *Output {
+	if u, ok := n.utxos[op]; ok {
+		return u.out
+	}
+	if tx := n.mempoolTx(op.hash); tx != nil && int(op.index) < len(tx.Outputs) {
+		return tx.Outputs[op.index]
+	}
+	return nil
+}
+
+// frozen 锁仓存款是否未到期，区块高度达到存款高度加锁定区块数后到期
+func (n *Node) frozen(u *utxo) bool {
+	lock := scriptLockHeight(u.out.Script)
+	return lock > 0 && n.tip().Height < u.height+lock
+}
+
+// acceptToMempool 交易加入交易池
+func (n *Node) acceptToMempool(tx *Tx) {
+	txid := tx.Hash()
+	for _, in := range tx.Inputs {
+		n.mempoolSpent[outPoint{in.PrevHash, in.PrevIndex}] = txid
+	}
+	n.mempool = append(n.mempool, tx)
+}
+
+// addressUTXOs 地址已确认、未冻结且未在交易池中花费的输出，按高度和交易排序
+func (n *Node) addressUTXOs(address string) []*utxo {
+	list := make([]*utxo, 0)
+	for _, u := range n.utxos {
+		if n.scriptAddress(u.out.Script) != address {
+			continue
+		}
+		if _, spent := n.mempoolSpent[u.outPoint]; spent || n.frozen(u) {
+			continue
+		}
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].height != list[j].height {
+			return list[i].height < list[j].height
+		}
+		if list[i].hash != list[j].hash {
+			return list[i].hash < list[j].hash
+		}
+		return list[i].index < list[j].index
+	})
+	return list
+}
+
+// lockScript 地址转锁定脚本
+func (n *Node) lockScript(address string) ([]byte, *rpcError) {
+	if hash, err := n.decoder.AddressDecode(address); err == nil {
+		return p2pkhScript(hash), nil
+	}
+	if hash, err := n.decoder.AddressDecode(address, n.p2shType()); err == nil {
+		return p2shScript(hash), nil
+	}
+	return nil, newRPCError(codeAddressInvalid, "invalid address: %s", address)
+}
+
+func (n *Node) mustLockScript(address string) []byte {
+	script, err := n.lockScript(address)
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// scriptAddress 锁定脚本转地址
+func (n *Node) scriptAddress(script []byte) string {
+	hash, isP2SH, ok := scriptHash(script)
+	if !ok {
+		return ""
+	}
+	if isP2SH {
+		address, _ := n.decoder.AddressEncode(hash, n.p2shType())
+		return address
+	}
+	address, _ := n.decoder.AddressEncode(hash)
+	return address
+}
+
+func (n *Node) p2shType() addressEncoder.AddressType {
+	if n.isTestNet {
+		return metaverse_addrdec.ETP_testnetAddressP2SH
+	}
+	return metaverse_addrdec.ETP_mainnetAddressP2SH
+}
+
+// merkleRoot 交易的默克尔根
+func merkleRoot(txs []*Tx) string {
+	level := make([][]byte, 0, len(txs))
+	for _, tx := range txs {
+		level = append(level, doubleSHA256(tx.Serialize()))
+	}
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		next := make([][]byte, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			next = append(next, doubleSHA256(append(append([]byte{}, level[i]...), level[i+1]...)))
+		}
+		level = next
+	}
+	return reverseHex(level[0])
+}
+
+// blockHash 区块hash
+func blockHash(b *Block) string {
+	var buf bytes.Buffer
+	writeUint32(&buf, b.Version)
+	buf.WriteString(b.PrevHash)
+	buf.WriteString(b.MerkleRoot)
+	writeUint64(&buf, b.Timestamp)
+	writeUint64(&buf, b.Height)
+	writeUint64(&buf, b.Nonce)
+	return reverseHex(doubleSHA256(buf.Bytes()))
+}
@@ -0,0 +1,420 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse_simnode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func testCall(t *testing.T, n *Node, method string, params ...interface{}) gjson.Result {
+	raw := make([]json.RawMessage, 0, len(params))
+	for _, p := range params {
+		b, _ := json.Marshal(p)
+		raw = append(raw, b)
+	}
+	result, err := n.Call(method, raw)
+	if err != nil {
+		t.Fatalf("%s unexpected error: %v", method, err)
+	}
+	b, _ := json.Marshal(result)
+	return gjson.ParseBytes(b)
+}
+
+func testAddress(n *Node, seed string) string {
+	address, _ := n.decoder.AddressEncode(hash160([]byte(seed)))
+	return address
+}
+
+func TestNode_MineAndFund(t *testing.T) {
+	n := NewNode(false)
+	addr := testAddress(n, "alice")
+
+	txid, err := n.Fund(addr, 12345)
+	if err != nil {
+		t.Fatalf("Fund unexpected error: %v", err)
+	}
+	hashes := n.Mine(2)
+	if len(hashes) != 2 || n.Height() != 2 {
+		t.Fatalf("height = %d, want 2", n.Height())
+	}
+
+	header := testCall(t, n, "getblockheader", map[string]interface{}{"height": 1})
+	if header.Get("hash").String() != hashes[0] || header.Get("previous_block_hash").String() != n.BlockHash(0) {
+		t.Fatalf("unexpected block header: %s", header.Raw)
+	}
+
+	block := testCall(t, n, "getblock", 1)
+	if block.Get("transactions.#").Int() != 2 || block.Get("transactions.1.hash").String() != txid {
+		t.Fatalf("unexpected block: %s", block.Raw)
+	}
+
+	tx := testCall(t, n, "gettx", txid)
+	if tx.Get("height").Uint() != 1 || tx.Get("outputs.0.address").String() != addr || tx.Get("outputs.0.value").Uint() != 12345 {
+		t.Fatalf("unexpected tx: %s", tx.Raw)
+	}
+
+	balance := testCall(t, n, "getaddressetp", addr)
+	if balance.Get("confirmed").Uint() != 12345 || balance.Get("received").Uint() != 12345 {
+		t.Fatalf("unexpected balance: %s", balance.Raw)
+	}
+
+	if _, err := n.Call("getblock", []json.RawMessage{json.RawMessage("100")}); err == nil || err.Code != codeBlockHeight {
+		t.Fatalf("getblock out of range error = %v", err)
+	}
+}
+
+func TestNode_IssueAsset(t *testing.T) {
+	n := NewNode(false)
+	addr := testAddress(n, "alice")
+
+	if _, err := n.IssueAsset(addr, "DNA", 1000000, 4); err != nil {
+		t.Fatalf("IssueAsset unexpected error: %v", err)
+	}
+	if _, err := n.IssueAsset(addr, "DNA", 1, 4); err == nil {
+		t.Fatalf("IssueAsset duplicate symbol should fail")
+	}
+	n.Mine(1)
+
+	assets := testCall(t, n, "getaddressasset", addr, map[string]string{"symbol": "DNA"})
+	if assets.Get("#").Int() != 1 || assets.Get("0.quantity").Uint() != 1000000 || assets.Get("0.decimal_number").Int() != 4 {
+		t.Fatalf("unexpected assets: %s", assets.Raw)
+	}
+}
+
+func TestNode_Deposit(t *testing.T) {
+	n := NewNode(false)
+	alice := testAddress(n, "alice")
+	bob := testAddress(n, "bob")
+
+	if _, err := n.Deposit(alice, 1000, 0); err == nil {
+		t.Fatalf("Deposit without lock blocks should fail")
+	}
+	txid, err := n.Deposit(alice, 50000, 3)
+	if err != nil {
+		t.Fatalf("Deposit unexpected error: %v", err)
+	}
+	n.Mine(1)
+
+	tx := testCall(t, n, "gettx", txid)
+	if out := tx.Get("outputs.0"); out.Get("locked_height_range").Uint() != 3 || out.Get("address").String() != alice ||
+		!strings.HasPrefix(out.Get("script").String(), "[ 03 ] numequalverify dup hash160") {
+		t.Fatalf("unexpected deposit output: %s", out.Raw)
+	}
+
+	balance := testCall(t, n, "getaddressetp", alice)
+	if balance.Get("confirmed").Uint() != 50000 || balance.Get("frozen").Uint() != 50000 || balance.Get("available").Uint() != 0 {
+		t.Fatalf("unexpected locked balance: %s", balance.Raw)
+	}
+
+	//冻结的存款不能作为输入
+	opt, _ := json.Marshal(map[string]interface{}{
+		"senders":   []string{alice},
+		"receivers": []string{bob + ":100"},
+		"fee":       "10000",
+		"type":      0,
+	})
+	if _, rpcErr := n.Call("createrawtx", []json.RawMessage{opt}); rpcErr == nil {
+		t.Fatalf("createrawtx should not spend frozen deposit")
+	}
+
+	//存款在高度1，高度4到期
+	n.Mine(2)
+	if frozen := testCall(t, n, "getaddressetp", alice).Get("frozen").Uint(); frozen != 50000 {
+		t.Fatalf("deposit matured before unlock height, frozen: %d", frozen)
+	}
+	n.Mine(1)
+	balance = testCall(t, n, "getaddressetp", alice)
+	if balance.Get("frozen").Uint() != 0 || balance.Get("available").Uint() != 50000 {
+		t.Fatalf("unexpected matured balance: %s", balance.Raw)
+	}
+}
+
+func TestNode_MIT(t *testing.T) {
+	n := NewNode(false)
+	alice := testAddress(n, "alice")
+	bob := testAddress(n, "bob")
+
+	registerTxID, err := n.RegisterMIT(alice, "ART.001", "hello")
+	if err != nil {
+		t.Fatalf("RegisterMIT unexpected error: %v", err)
+	}
+	if _, err := n.RegisterMIT(bob, "ART.001", "again"); err == nil {
+		t.Fatalf("RegisterMIT duplicate symbol should fail")
+	}
+	n.Mine(1)
+
+	tx := testCall(t, n, "gettx", registerTxID)
+	if a := tx.Get("outputs.0.attachment"); a.Get("type").String() != "asset-mit" || a.Get("status").String() != "registered" || a.Get("content").String() != "hello" {
+		t.Fatalf("unexpected mit attachment: %s", a.Raw)
+	}
+
+	transferTxID, err := n.TransferMIT("ART.001", bob)
+	if err != nil {
+		t.Fatalf("TransferMIT unexpected error: %v", err)
+	}
+	if _, err := n.TransferMIT("ART.001", alice); err == nil {
+		t.Fatalf("TransferMIT pending mit should fail")
+	}
+	n.Mine(1)
+
+	tx = testCall(t, n, "gettx", transferTxID)
+	if tx.Get("inputs.0.previous_output.hash").String() != registerTxID || tx.Get("outputs.0.attachment.status").String() != "transfered" {
+		t.Fatalf("unexpected mit transfer: %s", tx.Raw)
+	}
+
+	if mits := testCall(t, n, "getaddressmit", alice); mits.Get("#").Int() != 0 {
+		t.Fatalf("alice still holds mits: %s", mits.Raw)
+	}
+	mits := testCall(t, n, "getaddressmit", bob)
+	if mits.Get("#").Int() != 1 || mits.Get("0.symbol").String() != "ART.001" || mits.Get("0.content").String() != "hello" || mits.Get("0.height").Uint() != 2 {
+		t.Fatalf("unexpected bob mits: %s", mits.Raw)
+	}
+}
+
+func TestNode_CreateRawTx(t *testing.T) {
+	n := NewNode(false)
+	alice := testAddress(n, "alice")
+	bob := testAddress(n, "bob")
+	n.Fund(alice, 50000)
+	n.IssueAsset(alice, "DNA", 800, 4)
+	n.Mine(1)
+
+	rawHex := testCall(t, n, "createrawtx", map[string]interface{}{
+		"senders":   []string{alice},
+		"receivers": []string{bob + ":300"},
+		"fee":       "10000",
+		"type":      3,
+		"symbol":    "DNA",
+	}).String()
+
+	tx := testCall(t, n, "decoderawtx", rawHex)
+	if tx.Get("inputs.#").Int() != 2 || tx.Get("outputs.#").Int() != 3 {
+		t.Fatalf("unexpected raw tx: %s", tx.Raw)
+	}
+	if tx.Get("outputs.0.attachment.type").String() != "asset-transfer" || tx.Get("outputs.0.attachment.quantity").Uint() != 300 {
+		t.Fatalf("unexpected receiver output: %s", tx.Get("outputs.0").Raw)
+	}
+	if tx.Get("outputs.1.attachment.quantity").Uint() != 500 || tx.Get("outputs.2.value").Uint() != 40000 {
+		t.Fatalf("unexpected change outputs: %s", tx.Get("outputs").Raw)
+	}
+
+	//未签名的交易不能广播
+	raw, _ := json.Marshal(rawHex)
+	if _, err := n.Call("sendrawtx", []json.RawMessage{raw}); err == nil || err.Code != codeTxValidate {
+		t.Fatalf("sendrawtx unsigned error = %v", err)
+	}
+
+	if _, err := n.Call("createrawtx", []json.RawMessage{json.RawMessage(`{"senders":["` + alice + `"],"receivers":["` + bob + `:90000"],"fee":"0"}`)}); err == nil || err.Code != codeBalanceLack {
+		t.Fatalf("createrawtx insufficient balance error = %v", err)
+	}
+}
+
+func TestNode_CreateRawTx_Message(t *testing.T) {
+	n := NewNode(false)
+	alice := testAddress(n, "alice")
+	bob := testAddress(n, "bob")
+	n.Fund(alice, 100000)
+	n.Mine(1)
+
+	rawHex := testCall(t, n, "createrawtx", map[string]interface{}{
+		"senders":   []string{alice},
+		"receivers": []string{bob + ":1000"},
+		"fee":       10,
+		"type":      0,
+		"message":   "order 1024",
+	}).String()
+
+	tx := testCall(t, n, "decoderawtx", rawHex)
+	if tx.Get("outputs.#").Int() != 3 {
+		t.Fatalf("unexpected outputs: %s", tx.Raw)
+	}
+	message := tx.Get("outputs.1")
+	if message.Get("address").String() != bob || message.Get("value").Uint() != 0 || message.Get("attachment.type").String() != "message" || message.Get("attachment.content").String() != "order 1024" {
+		t.Fatalf("unexpected message output: %s", message.Raw)
+	}
+}
+
+func TestNode_MemoryPool(t *testing.T) {
+	n := NewNode(false)
+	alice := testAddress(n, "alice")
+	bob := testAddress(n, "bob")
+	fundTxID, _ := n.Fund(alice, 50000)
+	n.Mine(1)
+
+	tx := &Tx{
+		Version: txVersion,
+		Inputs:  []*Input{{PrevHash: fundTxID, PrevIndex: 0, Sequence: maxSequence}},
+		Outputs: []*Output{{Value: 40000, Script: n.mustLockScript(bob)}},
+	}
+	n.mu.Lock()
+	n.acceptToMempool(tx)
+	n.mu.Unlock()
+
+	pool := testCall(t, n, "getmemorypool", map[string]bool{"json": true})
+	if pool.Get("transactions.#").Int() != 1 || pool.Get("transactions.0.hash").String() != tx.Hash() {
+		t.Fatalf("unexpected memory pool: %s", pool.Raw)
+	}
+
+	if err := n.DropMempoolTx(tx.Hash()); err != nil {
+		t.Fatalf("DropMempoolTx unexpected error: %v", err)
+	}
+	if err := n.DropMempoolTx(tx.Hash()); err == nil {
+		t.Fatalf("DropMempoolTx twice should fail")
+	}
+	if pool := testCall(t, n, "getmemorypool"); pool.Get("transactions.#").Int() != 0 {
+		t.Fatalf("memory pool is not empty: %s", pool.Raw)
+	}
+	//丢弃后输入可以再次花费
+	if balance := testCall(t, n, "getaddressetp", alice); balance.Get("available").Uint() != 50000 {
+		t.Fatalf("unexpected balance after drop: %s", balance.Raw)
+	}
+}
+
+func TestNode_Reorg(t *testing.T) {
+	n := NewNode(false)
+	addr := testAddress(n, "alice")
+	n.Mine(3)
+	n.Fund(addr, 1000)
+	n.Mine(1)
+	oldHash := n.BlockHash(4)
+
+	if err := n.Reorg(2); err != nil {
+		t.Fatalf("Reorg unexpected error: %v", err)
+	}
+	if n.Height() != 5 {
+		t.Fatalf("height after reorg = %d, want 5", n.Height())
+	}
+	if n.BlockHash(4) == oldHash {
+		t.Fatalf("block 4 should be replaced")
+	}
+
+	//被断开区块中的充值已丢弃
+	balance := testCall(t, n, "getaddressetp", addr)
+	if balance.Get("confirmed").Uint() != 0 {
+		t.Fatalf("unexpected balance after reorg: %s", balance.Raw)
+	}
+
+	if err := n.Reorg(10); err == nil {
+		t.Fatalf("Reorg deeper than chain should fail")
+	}
+}
+
+func TestNode_ServeHTTP(t *testing.T) {
+	n := NewNode(true)
+	url := n.Start()
+	defer n.Close()
+
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(`{"jsonrpc":"2.0","id":"1","method":"getblockheader","params":[]}`))
+	if err != nil {
+		t.Fatalf("post unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	result := gjson.ParseBytes(body)
+	if result.Get("id").String() != "1" || result.Get("result.hash").String() != n.BlockHash(0) {
+		t.Fatalf("unexpected response: %s", body)
+	}
+
+	resp2, err := http.Post(url, "application/json", bytes.NewBufferString(`{"jsonrpc":"2.0","id":"2","method":"nosuchmethod","params":[]}`))
+	if err != nil {
+		t.Fatalf("post unexpected error: %v", err)
+	}
+	defer resp2.Body.Close()
+	body, _ = ioutil.ReadAll(resp2.Body)
+	if gjson.GetBytes(body, "error.code").Int() != codeMethodNotFound {
+		t.Fatalf("unexpected response: %s", body)
+	}
+}
+
+func TestNode_ServeHTTP_Batch(t *testing.T) {
+	n := NewNode(true)
+	url := n.Start()
+	defer n.Close()
+
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(`[
+		{"jsonrpc":"2.0","id":"a","method":"getblockheader","params":[]},
+		{"jsonrpc":"2.0","id":"b","method":"gettx","params":["0000"]}
+	]`))
+	if err != nil {
+		t.Fatalf("post unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	result := gjson.ParseBytes(body)
+	if !result.IsArray() || len(result.Array()) != 2 {
+		t.Fatalf("unexpected response: %s", body)
+	}
+	if result.Get("0.id").String() != "a" || result.Get("0.result.hash").String() != n.BlockHash(0) {
+		t.Fatalf("unexpected first response: %s", body)
+	}
+	if result.Get("1.id").String() != "b" || result.Get("1.error.code").Int() != codeTxNotFound {
+		t.Fatalf("unexpected second response: %s", body)
+	}
+
+	resp2, err := http.Post(url, "application/json", bytes.NewBufferString(`[]`))
+	if err != nil {
+		t.Fatalf("post unexpected error: %v", err)
+	}
+	defer resp2.Body.Close()
+	body, _ = ioutil.ReadAll(resp2.Body)
+	if gjson.GetBytes(body, "error.code").Int() != codeInvalidRequest {
+		t.Fatalf("unexpected response: %s", body)
+	}
+
+	if n.Requests() != 2 {
+		t.Fatalf("requests = %d, want 2", n.Requests())
+	}
+}
+
+func TestDecodeTx(t *testing.T) {
+	n := NewNode(false)
+	addr := testAddress(n, "alice")
+	n.IssueAsset(addr, "DNA", 800, 4)
+	n.Mine(1)
+
+	block := n.blocks[1]
+	for _, tx := range block.Txs {
+		raw := tx.Serialize()
+		decoded, err := DecodeTx(raw)
+		if err != nil {
+			t.Fatalf("DecodeTx unexpected error: %v", err)
+		}
+		if decoded.Hash() != tx.Hash() {
+			t.Fatalf("decoded hash = %s, want %s", decoded.Hash(), tx.Hash())
+		}
+	}
+
+	if _, err := DecodeTx(append(block.Txs[0].Serialize(), 0x00)); err == nil {
+		t.Fatalf("DecodeTx with trailing bytes should fail")
+	}
+	if _, err := DecodeTx([]byte{0x04, 0x00}); err == nil {
+		t.Fatalf("DecodeTx with short data should fail")
+	}
+
+	script := scriptText(block.Txs[1].Outputs[0].Script)
+	hash, _ := n.decoder.AddressDecode(addr)
+	if want := "dup hash160 [ " + hex.EncodeToString(hash) + " ] equalverify checksig"; script != want {
+		t.Fatalf("scriptText = %s, want %s", script, want)
+	}
+}
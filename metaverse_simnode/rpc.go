@@ -0,0 +1,691 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse_simnode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 节点错误码，与Metaverse节点返回的error.code一致
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+
+	codeBalanceLack    = 3302
+	codeAddressInvalid = 4010
+	codeAssetLack      = 5002
+	codeBlockHeight    = 5101
+	codeTxValidate     = 5301
+	codeTxBroadcast    = 5302
+	codeTxNotFound     = 5304
+	codeDIDNotFound    = 7006
+)
+
+// rpcError 节点返回的错误
+type rpcError struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+}
+
+func newRPCError(code int64, format string, a ...interface{}) *rpcError {
+	return &rpcError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("[%d]%s", e.Code, e.Message)
+}
+
+// rpcRequest JSON-RPC请求
+type rpcRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// rpcResponse JSON-RPC响应
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// ServeHTTP 处理JSON-RPC请求，支持JSON-RPC 2.0批量数组
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	n.requests++
+	n.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			json.NewEncoder(w).Encode(rpcResponse{JSONRPC: "2.0", Error: newRPCError(codeParseError, "parse error: %v", err)})
+			return
+		}
+		if len(batch) == 0 {
+			json.NewEncoder(w).Encode(rpcResponse{JSONRPC: "2.0", Error: newRPCError(codeInvalidRequest, "empty batch")})
+			return
+		}
+		resps := make([]rpcResponse, 0, len(batch))
+		for _, item := range batch {
+			resps = append(resps, n.handle(item))
+		}
+		json.NewEncoder(w).Encode(resps)
+		return
+	}
+
+	json.NewEncoder(w).Encode(n.handle(body))
+}
+
+// handle 处理单个JSON-RPC请求
+func (n *Node) handle(body []byte) rpcResponse {
+	var (
+		req  rpcRequest
+		resp = rpcResponse{JSONRPC: "2.0"}
+	)
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		resp.Error = newRPCError(codeParseError, "parse error: %v", err)
+	} else {
+		resp.ID = req.ID
+		resp.Result, resp.Error = n.Call(req.Method, req.Params)
+	}
+	return resp
+}
+
+// Requests 返回已收到的HTTP请求数量，批量请求计为一次
+func (n *Node) Requests() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.requests
+}
+
+// Call 执行一个RPC方法
+func (n *Node) Call(method string, params []json.RawMessage) (interface{}, *rpcError) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch method {
+	case "getinfo":
+		return n.getInfo(params)
+	case "getblockheader":
+		return n.getBlockHeader(params)
+	case "getblock":
+		return n.getBlock(params)
+	case "gettx":
+		return n.getTx(params)
+	case "getaddressetp":
+		return n.getAddressETP(params)
+	case "getaddressasset":
+		return n.getAddressAsset(params)
+	case "createrawtx":
+		return n.createRawTx(params)
+	case "decoderawtx":
+		return n.decodeRawTx(params)
+	case "sendrawtx":
+		return n.sendRawTx(params)
+	case "getaddressmit":
+		return n.getAddressMIT(params)
+	case "getdid":
+		return n.getDID(params)
+	case "getmemorypool":
+		return n.getMemoryPool(params)
+	}
+	return nil, newRPCError(codeMethodNotFound, "method not found: %s", method)
+}
+
+// getInfo []
+func (n *Node) getInfo(params []json.RawMessage) (interface{}, *rpcError) {
+	return map[string]interface{}{
+		"protocol-version": ProtocolVersion,
+		"wallet-version":   n.version,
+		"database-version": n.version,
+		"testnet":          n.isTestNet,
+		"peers":            n.peers,
+		"height":           n.tip().Height,
+		"is-mining":        false,
+	}, nil
+}
+
+// getBlockHeader [{"height": n}] 或 []
+func (n *Node) getBlockHeader(params []json.RawMessage) (interface{}, *rpcError) {
+	block := n.tip()
+	if len(params) > 0 {
+		var opt struct {
+			Height *uint64 `json:"height"`
+			Hash   string  `json:"hash"`
+		}
+		if err := json.Unmarshal(params[0], &opt); err != nil {
+			return nil, newRPCError(codeInvalidParams, "invalid params: %v", err)
+		}
+		switch {
+		case opt.Height != nil:
+			b, err := n.blockAt(*opt.Height)
+			if err != nil {
+				return nil, err
+			}
+			block = b
+		case len(opt.Hash) > 0:
+			b, err := n.blockByHash(opt.Hash)
+			if err != nil {
+				return nil, err
+			}
+			block = b
+		}
+	}
+	return n.headerJSON(block), nil
+}
+
+// getBlock [height] 或 [hash]
+func (n *Node) getBlock(params []json.RawMessage) (interface{}, *rpcError) {
+	if len(params) == 0 {
+		return nil, newRPCError(codeInvalidParams, "block height or hash is required")
+	}
+	var (
+		block *Block
+		err   *rpcError
+	)
+	if height, convErr := strconv.ParseUint(string(params[0]), 10, 64); convErr == nil {
+		block, err = n.blockAt(height)
+	} else {
+		var hash string
+		if jsonErr := json.Unmarshal(params[0], &hash); jsonErr != nil {
+			return nil, newRPCError(codeInvalidParams, "invalid block height or hash")
+		}
+		block, err = n.blockByHash(hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	obj := n.headerJSON(block)
+	txs := make([]interface{}, 0, len(block.Txs))
+	for _, tx := range block.Txs {
+		txs = append(txs, n.txJSON(tx))
+	}
+	obj["transactions"] = txs
+	return obj, nil
+}
+
+// getTx [txid]
+func (n *Node) getTx(params []json.RawMessage) (interface{}, *rpcError) {
+	txid, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if rec, ok := n.txs[txid]; ok {
+		obj := n.txJSON(rec.tx)
+		obj["height"] = rec.height
+		return obj, nil
+	}
+	if tx := n.mempoolTx(txid); tx != nil {
+		return n.txJSON(tx), nil
+	}
+	return nil, newRPCError(codeTxNotFound, "transaction %s not found", txid)
+}
+
+// getMemoryPool [{"json": true}]
+func (n *Node) getMemoryPool(params []json.RawMessage) (interface{}, *rpcError) {
+	txs := make([]interface{}, 0, len(n.mempool))
+	for _, tx := range n.mempool {
+		txs = append(txs, n.txJSON(tx))
+	}
+	return map[string]interface{}{"transactions": txs}, nil
+}
+
+// getAddressETP [address]
+func (n *Node) getAddressETP(params []json.RawMessage) (interface{}, *rpcError) {
+	address, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := n.lockScript(address); err != nil {
+		return nil, err
+	}
+
+	confirmed, spent, incoming, frozen := uint64(0), uint64(0), uint64(0), uint64(0)
+	for _, u := range n.utxos {
+		if n.scriptAddress(u.out.Script) != address {
+			continue
+		}
+		confirmed += u.out.Value
+		if n.frozen(u) {
+			frozen += u.out.Value
+		}
+		if _, ok := n.mempoolSpent[u.outPoint]; ok {
+			spent += u.out.Value
+		}
+	}
+	for _, tx := range n.mempool {
+		for _, out := range tx.Outputs {
+			if n.scriptAddress(out.Script) == address {
+				incoming += out.Value
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"address":   address,
+		"available": confirmed - spent - frozen,
+		"confirmed": confirmed,
+		"frozen":    frozen,
+		"received":  n.received[address],
+		"unspent":   confirmed - spent + incoming,
+	}, nil
+}
+
+// getAddressAsset [address, {"symbol": symbol}]
+func (n *Node) getAddressAsset(params []json.RawMessage) (interface{}, *rpcError) {
+	address, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	var opt struct {
+		Symbol string `json:"symbol"`
+	}
+	if len(params) > 1 {
+		json.Unmarshal(params[1], &opt)
+	}
+
+	quantities := make(map[string]uint64)
+	symbols := make([]string, 0)
+	for _, u := range n.addressUTXOs(address) {
+		a := u.out.Attachment
+		if a.Type != attachmentAsset || (len(opt.Symbol) > 0 && a.Symbol != opt.Symbol) {
+			continue
+		}
+		if _, ok := quantities[a.Symbol]; !ok {
+			symbols = append(symbols, a.Symbol)
+		}
+		quantities[a.Symbol] += a.Quantity
+	}
+
+	list := make([]interface{}, 0, len(symbols))
+	for _, symbol := range symbols {
+		info := n.assets[symbol]
+		list = append(list, map[string]interface{}{
+			"address":                  address,
+			"decimal_number":           info.decimals,
+			"description":              info.description,
+			"issuer":                   info.issuer,
+			"locked_quantity":          0,
+			"quantity":                 quantities[symbol],
+			"secondaryissue_threshold": 0,
+			"status":                   "unspent",
+			"symbol":                   symbol,
+		})
+	}
+	return list, nil
+}
+
+// createRawTx [{"senders", "receivers", "mychange", "fee", "type", "symbol", "message"}]
+func (n *Node) createRawTx(params []json.RawMessage) (interface{}, *rpcError) {
+	if len(params) == 0 {
+		return nil, newRPCError(codeInvalidParams, "createrawtx options are required")
+	}
+	var opt struct {
+		Senders   []string    `json:"senders"`
+		Receivers []string    `json:"receivers"`
+		MyChange  string      `json:"mychange"`
+		Fee       json.Number `json:"fee"`
+		Type      int         `json:"type"`
+		Symbol    string      `json:"symbol"`
+		Message   string      `json:"message"`
+	}
+	if err := json.Unmarshal(params[0], &opt); err != nil {
+		return nil, newRPCError(codeInvalidParams, "invalid params: %v", err)
+	}
+	if len(opt.Senders) == 0 || len(opt.Receivers) == 0 {
+		return nil, newRPCError(codeInvalidParams, "senders and receivers are required")
+	}
+	fee, convErr := strconv.ParseUint(opt.Fee.String(), 10, 64)
+	if convErr != nil && len(opt.Fee) > 0 {
+		return nil, newRPCError(codeInvalidParams, "invalid fee: %s", opt.Fee)
+	}
+	isAsset := opt.Type == 3
+	if isAsset && len(opt.Symbol) == 0 {
+		return nil, newRPCError(codeInvalidParams, "asset symbol is required")
+	}
+
+	change := opt.MyChange
+	if len(change) == 0 {
+		change = opt.Senders[0]
+	}
+	changeScript, err := n.lockScript(change)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Tx{Version: txVersion}
+
+	//接收者输出
+	needETP, needAsset := fee, uint64(0)
+	for _, rec := range opt.Receivers {
+		parts := strings.Split(rec, ":")
+		if len(parts) != 2 {
+			return nil, newRPCError(codeInvalidParams, "invalid receiver: %s", rec)
+		}
+		amount, convErr := strconv.ParseUint(parts[1], 10, 64)
+		if convErr != nil {
+			return nil, newRPCError(codeInvalidParams, "invalid receiver amount: %s", rec)
+		}
+		script, err := n.lockScript(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		out := &Output{Script: script}
+		if isAsset {
+			out.Attachment = Attachment{Type: attachmentAsset, Status: assetStatusTransfer, Symbol: opt.Symbol, Quantity: amount}
+			needAsset += amount
+		} else {
+			out.Value = amount
+			needETP += amount
+		}
+		tx.Outputs = append(tx.Outputs, out)
+	}
+
+	//消息附件输出给第一个接收者
+	if len(opt.Message) > 0 {
+		tx.Outputs = append(tx.Outputs, &Output{
+			Script:     tx.Outputs[0].Script,
+			Attachment: Attachment{Type: attachmentMessage, Content: opt.Message},
+		})
+	}
+
+	//选择输入
+	candidates := make([]*utxo, 0)
+	for _, sender := range opt.Senders {
+		candidates = append(candidates, n.addressUTXOs(sender)...)
+	}
+
+	gotETP, gotAsset := uint64(0), uint64(0)
+	if isAsset {
+		for _, u := range candidates {
+			a := u.out.Attachment
+			if gotAsset >= needAsset || a.Type != attachmentAsset || a.Symbol != opt.Symbol {
+				continue
+			}
+			tx.Inputs = append(tx.Inputs, &Input{PrevHash: u.hash, PrevIndex: u.index, Sequence: maxSequence})
+			gotAsset += a.Quantity
+			gotETP += u.out.Value
+		}
+		if gotAsset < needAsset {
+			return nil, newRPCError(codeAssetLack, "not enough asset amount, unspent = %d, payment = %d", gotAsset, needAsset)
+		}
+	}
+	for _, u := range candidates {
+		if gotETP >= needETP {
+			break
+		}
+		if u.out.Attachment.Type != attachmentETP || u.out.Value == 0 {
+			continue
+		}
+		tx.Inputs = append(tx.Inputs, &Input{PrevHash: u.hash, PrevIndex: u.index, Sequence: maxSequence})
+		gotETP += u.out.Value
+	}
+	if gotETP < needETP {
+		return nil, newRPCError(codeBalanceLack, "not enough balance, unspent = %d, payment = %d", gotETP, needETP)
+	}
+
+	//找零
+	if gotAsset > needAsset {
+		tx.Outputs = append(tx.Outputs, &Output{
+			Script:     changeScript,
+			Attachment: Attachment{Type: attachmentAsset, Status: assetStatusTransfer, Symbol: opt.Symbol, Quantity: gotAsset - needAsset},
+		})
+	}
+	if gotETP > needETP {
+		tx.Outputs = append(tx.Outputs, &Output{Value: gotETP - needETP, Script: changeScript})
+	}
+
+	return hex.EncodeToString(tx.Serialize()), nil
+}
+
+// decodeRawTx [rawHex]
+func (n *Node) decodeRawTx(params []json.RawMessage) (interface{}, *rpcError) {
+	tx, err := n.parseRawTx(params)
+	if err != nil {
+		return nil, err
+	}
+	return n.txJSON(tx), nil
+}
+
+// sendRawTx [rawHex]
+func (n *Node) sendRawTx(params []json.RawMessage) (interface{}, *rpcError) {
+	tx, err := n.parseRawTx(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.validateTx(tx); err != nil {
+		return nil, err
+	}
+	n.acceptToMempool(tx)
+	return tx.Hash(), nil
+}
+
+// getAddressMIT [address]，返回地址当前持有的MIT
+func (n *Node) getAddressMIT(params []json.RawMessage) (interface{}, *rpcError) {
+	address, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := n.lockScript(address); err != nil {
+		return nil, err
+	}
+
+	list := make([]interface{}, 0)
+	for _, u := range n.addressUTXOs(address) {
+		a := u.out.Attachment
+		if a.Type != attachmentMIT {
+			continue
+		}
+		status := "registered"
+		if a.Status == mitStatusTransfer {
+			status = "transfered"
+		}
+		list = append(list, map[string]interface{}{
+			"symbol":  a.Symbol,
+			"address": address,
+			"content": n.mits[a.Symbol],
+			"status":  status,
+			"height":  u.height,
+		})
+	}
+	return list, nil
+}
+
+// getDID [symbol]，返回DID的地址历史，当前地址在最前面
+func (n *Node) getDID(params []json.RawMessage) (interface{}, *rpcError) {
+	symbol, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	history, ok := n.dids[symbol]
+	if !ok {
+		return nil, newRPCError(codeDIDNotFound, "did symbol %s does not exist", symbol)
+	}
+
+	result := make([]map[string]interface{}, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		status := "history"
+		if i == len(history)-1 {
+			status = "current"
+		}
+		result = append(result, map[string]interface{}{
+			"address": history[i],
+			"status":  status,
+		})
+	}
+	return result, nil
+}
+
+func (n *Node) parseRawTx(params []json.RawMessage) (*Tx, *rpcError) {
+	rawHex, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	raw, decodeErr := hex.DecodeString(rawHex)
+	if decodeErr != nil {
+		return nil, newRPCError(codeInvalidParams, "invalid raw transaction hex")
+	}
+	tx, decodeErr := DecodeTx(raw)
+	if decodeErr != nil {
+		return nil, newRPCError(codeTxValidate, "invalid raw transaction: %v", decodeErr)
+	}
+	return tx, nil
+}
+
+func (n *Node) blockAt(height uint64) (*Block, *rpcError) {
+	if height >= uint64(len(n.blocks)) {
+		return nil, newRPCError(codeBlockHeight, "block height %d out of range", height)
+	}
+	return n.blocks[height], nil
+}
+
+func (n *Node) blockByHash(hash string) (*Block, *rpcError) {
+	for _, b := range n.blocks {
+		if b.Hash == hash {
+			return b, nil
+		}
+	}
+	return nil, newRPCError(codeBlockHeight, "block %s not found", hash)
+}
+
+func (n *Node) headerJSON(b *Block) map[string]interface{} {
+	return map[string]interface{}{
+		"bits":                "1",
+		"hash":                b.Hash,
+		"merkle_tree_hash":    b.MerkleRoot,
+		"mixhash":             "0",
+		"nonce":               strconv.FormatUint(b.Nonce, 10),
+		"number":              b.Height,
+		"previous_block_hash": b.PrevHash,
+		"timestamp":           b.Timestamp,
+		"transaction_count":   len(b.Txs),
+		"version":             b.Version,
+	}
+}
+
+func (n *Node) txJSON(tx *Tx) map[string]interface{} {
+	inputs := make([]interface{}, 0, len(tx.Inputs))
+	for _, in := range tx.Inputs {
+		address := ""
+		if prev := n.findOutput(in.PrevHash, in.PrevIndex); prev != nil {
+			address = n.scriptAddress(prev.Script)
+		}
+		inputs = append(inputs, map[string]interface{}{
+			"address": address,
+			"previous_output": map[string]interface{}{
+				"hash":  in.PrevHash,
+				"index": in.PrevIndex,
+			},
+			"script":   scriptText(in.Script),
+			"sequence": in.Sequence,
+		})
+	}
+
+	outputs := make([]interface{}, 0, len(tx.Outputs))
+	for i, out := range tx.Outputs {
+		outputs = append(outputs, map[string]interface{}{
+			"address":             n.scriptAddress(out.Script),
+			"attachment":          attachmentJSON(&out.Attachment),
+			"index":               i,
+			"locked_height_range": scriptLockHeight(out.Script),
+			"script":              scriptText(out.Script),
+			"value":               out.Value,
+		})
+	}
+
+	return map[string]interface{}{
+		"hash":      tx.Hash(),
+		"inputs":    inputs,
+		"lock_time": strconv.FormatUint(uint64(tx.LockTime), 10),
+		"outputs":   outputs,
+		"version":   strconv.FormatUint(uint64(tx.Version), 10),
+	}
+}
+
+func attachmentJSON(a *Attachment) map[string]interface{} {
+	switch {
+	case a.Type == attachmentAsset && a.Status == assetStatusIssue:
+		return map[string]interface{}{
+			"type":                     "asset-issue",
+			"symbol":                   a.Symbol,
+			"quantity":                 a.Quantity,
+			"decimal_number":           a.Decimals,
+			"issuer":                   a.Issuer,
+			"address":                  a.Address,
+			"description":              a.Description,
+			"secondaryissue_threshold": 0,
+		}
+	case a.Type == attachmentAsset:
+		return map[string]interface{}{
+			"type":     "asset-transfer",
+			"symbol":   a.Symbol,
+			"quantity": a.Quantity,
+		}
+	case a.Type == attachmentMessage:
+		return map[string]interface{}{
+			"type":    "message",
+			"content": a.Content,
+		}
+	case a.Type == attachmentMIT && a.Status == mitStatusRegister:
+		return map[string]interface{}{
+			"type":    "asset-mit",
+			"symbol":  a.Symbol,
+			"address": a.Address,
+			"status":  "registered",
+			"content": a.Content,
+		}
+	case a.Type == attachmentMIT:
+		return map[string]interface{}{
+			"type":    "asset-mit",
+			"symbol":  a.Symbol,
+			"address": a.Address,
+			"status":  "transfered",
+		}
+	}
+	return map[string]interface{}{"type": "etp"}
+}
+
+func stringParam(params []json.RawMessage, index int) (string, *rpcError) {
+	if len(params) <= index {
+		return "", newRPCError(codeInvalidParams, "missing parameter %d", index)
+	}
+	var s string
+	if err := json.Unmarshal(params[index], &s); err != nil {
+		return "", newRPCError(codeInvalidParams, "parameter %d must be a string", index)
+	}
+	return s, nil
+}
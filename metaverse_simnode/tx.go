@@ -0,0 +1,569 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package metaverse_simnode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/blocktree/go-owcrypt"
+)
+
+const (
+	txVersion   = uint32(4)
+	sigHashAll  = uint32(1)
+	maxSequence = uint32(0xffffffff)
+)
+
+// 附件类型，与节点的attachment type编号保持一致
+const (
+	attachmentETP     = uint32(0)
+	attachmentAsset   = uint32(2)
+	attachmentMessage = uint32(3)
+	attachmentMIT     = uint32(6)
+)
+
+// 资产附件状态
+const (
+	assetStatusIssue    = uint32(1)
+	assetStatusTransfer = uint32(2)
+)
+
+// MIT附件状态
+const (
+	mitStatusRegister = uint32(1)
+	mitStatusTransfer = uint32(2)
+)
+
+// 脚本操作码
+const (
+	opZero                   = byte(0x00)
+	opPushData1              = byte(0x4c)
+	opPushData2              = byte(0x4d)
+	opPushData4              = byte(0x4e)
+	opDup                    = byte(0x76)
+	opEqual                  = byte(0x87)
+	opEqualVerify            = byte(0x88)
+	opHash160                = byte(0xa9)
+	opCheckSig               = byte(0xac)
+	opCheckMultiSig          = byte(0xae)
+	opNumEqualVerify         = byte(0x9d)
+	opCheckAttenuationVerify = byte(0xb2)
+)
+
+var (
+	nullHash = strings.Repeat("0", 64)
+
+	errShortData = errors.New("unexpected end of tx data")
+)
+
+// Attachment 输出附件
+type Attachment struct {
+	Type        uint32
+	Status      uint32
+	Symbol      string
+	Quantity    uint64
+	Decimals    uint8
+	Issuer      string
+	Address     string
+	Description string
+	Content     string //MIT注册时的内容或消息内容
+}
+
+// Input 交易输入
+type Input struct {
+	PrevHash  string
+	PrevIndex uint32
+	Script    []byte
+	Sequence  uint32
+}
+
+// Output 交易输出
+type Output struct {
+	Value      uint64
+	Script     []byte
+	Attachment Attachment
+}
+
+// Tx 模拟节点的交易单
+type Tx struct {
+	Version  uint32
+	Inputs   []*Input
+	Outputs  []*Output
+	LockTime uint32
+}
+
+// IsGenerated 是否由模拟节点直接生成的交易（无真实输入）
+func (tx *Tx) IsGenerated() bool {
+	return len(tx.Inputs) > 0 && tx.Inputs[0].PrevHash == nullHash
+}
+
+// Hash 交易单ID
+func (tx *Tx) Hash() string {
+	return reverseHex(doubleSHA256(tx.Serialize()))
+}
+
+// Serialize 序列化交易单
+func (tx *Tx) Serialize() []byte {
+	var buf bytes.Buffer
+	writeUint32(&buf, tx.Version)
+	writeVarInt(&buf, uint64(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		writeInput(&buf, in, in.Script)
+	}
+	buf.Write(tx.serializeOutputs())
+	return buf.Bytes()
+}
+
+// serializeOutputs 输入之后的部分，包括输出和锁定时间
+func (tx *Tx) serializeOutputs() []byte {
+	var buf bytes.Buffer
+	writeVarInt(&buf, uint64(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		writeUint64(&buf, out.Value)
+		writeVarBytes(&buf, out.Script)
+		writeAttachment(&buf, &out.Attachment)
+	}
+	writeUint32(&buf, tx.LockTime)
+	return buf.Bytes()
+}
+
+// SigHash 计算输入的待签哈希，与mateverseTransaction.GetSigHash保持一致
+func (tx *Tx) SigHash(index int, lockScript []byte) []byte {
+	var buf bytes.Buffer
+	writeUint32(&buf, tx.Version)
+	writeVarInt(&buf, uint64(len(tx.Inputs)))
+	for i, in := range tx.Inputs {
+		if i == index {
+			writeInput(&buf, in, lockScript)
+		} else {
+			writeInput(&buf, in, nil)
+		}
+	}
+	buf.Write(tx.serializeOutputs())
+	writeUint32(&buf, sigHashAll)
+	return doubleSHA256(buf.Bytes())
+}
+
+// DecodeTx 反序列化交易单
+func DecodeTx(raw []byte) (*Tx, error) {
+	r := &reader{data: raw}
+	tx := &Tx{}
+	tx.Version = r.uint32()
+	inCount := r.varInt()
+	for i := uint64(0); i < inCount && r.err == nil; i++ {
+		in := &Input{}
+		in.PrevHash = reverseHex(r.bytes(32))
+		in.PrevIndex = r.uint32()
+		in.Script = r.varBytes()
+		in.Sequence = r.uint32()
+		tx.Inputs = append(tx.Inputs, in)
+	}
+	outCount := r.varInt()
+	for i := uint64(0); i < outCount && r.err == nil; i++ {
+		out := &Output{}
+		out.Value = r.uint64()
+		out.Script = r.varBytes()
+		out.Attachment = r.attachment()
+		tx.Outputs = append(tx.Outputs, out)
+	}
+	tx.LockTime = r.uint32()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos != len(raw) {
+		return nil, fmt.Errorf("unexpected %d trailing bytes in tx data", len(raw)-r.pos)
+	}
+	if tx.Version != txVersion {
+		return nil, fmt.Errorf("unsupported tx version: %d", tx.Version)
+	}
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return nil, errors.New("tx has no inputs or outputs")
+	}
+	return tx, nil
+}
+
+func writeInput(buf *bytes.Buffer, in *Input, script []byte) {
+	prev, _ := hex.DecodeString(in.PrevHash)
+	buf.Write(reverseBytes(prev))
+	writeUint32(buf, in.PrevIndex)
+	writeVarBytes(buf, script)
+	writeUint32(buf, in.Sequence)
+}
+
+func writeAttachment(buf *bytes.Buffer, a *Attachment) {
+	writeUint32(buf, 1)
+	writeUint32(buf, a.Type)
+	switch a.Type {
+	case attachmentAsset:
+		writeUint32(buf, a.Status)
+		writeVarBytes(buf, []byte(a.Symbol))
+		writeUint64(buf, a.Quantity)
+		if a.Status == assetStatusIssue {
+			buf.WriteByte(a.Decimals)
+			writeVarBytes(buf, []byte(a.Issuer))
+			writeVarBytes(buf, []byte(a.Address))
+			writeVarBytes(buf, []byte(a.Description))
+		}
+	case attachmentMessage:
+		writeVarBytes(buf, []byte(a.Content))
+	case attachmentMIT:
+		buf.WriteByte(byte(a.Status))
+		writeVarBytes(buf, []byte(a.Symbol))
+		writeVarBytes(buf, []byte(a.Address))
+		if a.Status == mitStatusRegister {
+			writeVarBytes(buf, []byte(a.Content))
+		}
+	}
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeUint64(buf *bytes.Buffer, v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeVarInt(buf *bytes.Buffer, v uint64) {
+	switch {
+	case v < 0xfd:
+		buf.WriteByte(byte(v))
+	case v <= 0xffff:
+		buf.WriteByte(0xfd)
+		var b [2]byte
+		binary.LittleEndian.PutUint16(b[:], uint16(v))
+		buf.Write(b[:])
+	case v <= 0xffffffff:
+		buf.WriteByte(0xfe)
+		writeUint32(buf, uint32(v))
+	default:
+		buf.WriteByte(0xff)
+		writeUint64(buf, v)
+	}
+}
+
+func writeVarBytes(buf *bytes.Buffer, b []byte) {
+	writeVarInt(buf, uint64(len(b)))
+	buf.Write(b)
+}
+
+// reader 顺序读取字节流，出错后所有读取返回零值
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = errShortData
+		return make([]byte, n)
+	}
+	b := make([]byte, n)
+	copy(b, r.data[r.pos:r.pos+n])
+	r.pos += n
+	return b
+}
+
+func (r *reader) byte() byte {
+	return r.bytes(1)[0]
+}
+
+func (r *reader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(r.bytes(4))
+}
+
+func (r *reader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(r.bytes(8))
+}
+
+func (r *reader) varInt() uint64 {
+	switch prefix := r.byte(); prefix {
+	case 0xfd:
+		return uint64(binary.LittleEndian.Uint16(r.bytes(2)))
+	case 0xfe:
+		return uint64(r.uint32())
+	case 0xff:
+		return r.uint64()
+	default:
+		return uint64(prefix)
+	}
+}
+
+func (r *reader) varBytes() []byte {
+	n := r.varInt()
+	if n > uint64(len(r.data)) {
+		r.err = errShortData
+		return nil
+	}
+	return r.bytes(int(n))
+}
+
+func (r *reader) attachment() Attachment {
+	a := Attachment{}
+	r.uint32() //version
+	a.Type = r.uint32()
+	switch a.Type {
+	case attachmentETP:
+	case attachmentAsset:
+		a.Status = r.uint32()
+		a.Symbol = string(r.varBytes())
+		a.Quantity = r.uint64()
+		if a.Status == assetStatusIssue {
+			a.Decimals = r.byte()
+			a.Issuer = string(r.varBytes())
+			a.Address = string(r.varBytes())
+			a.Description = string(r.varBytes())
+		}
+	case attachmentMessage:
+		a.Content = string(r.varBytes())
+	case attachmentMIT:
+		a.Status = uint32(r.byte())
+		a.Symbol = string(r.varBytes())
+		a.Address = string(r.varBytes())
+		if a.Status == mitStatusRegister {
+			a.Content = string(r.varBytes())
+		}
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown attachment type: %d", a.Type)
+		}
+	}
+	return a
+}
+
+// p2pkhScript dup hash160 [ hash ] equalverify checksig
+func p2pkhScript(hash []byte) []byte {
+	script := []byte{opDup, opHash160, byte(len(hash))}
+	script = append(script, hash...)
+	return append(script, opEqualVerify, opCheckSig)
+}
+
+// lockHeightScript [ height ] numequalverify dup hash160 [ hash ] equalverify checksig，锁仓存款
+func lockHeightScript(height uint64, hash []byte) []byte {
+	script := pushData(scriptNum(height))
+	script = append(script, opNumEqualVerify)
+	return append(script, p2pkhScript(hash)...)
+}
+
+// scriptNum 小端序的脚本数字，最高位是符号位
+func scriptNum(v uint64) []byte {
+	b := make([]byte, 0, 9)
+	for ; v > 0; v >>= 8 {
+		b = append(b, byte(v))
+	}
+	if len(b) > 0 && b[len(b)-1]&0x80 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// scriptLockHeight 锁仓脚本锁定的区块数，不是锁仓脚本返回0
+func scriptLockHeight(script []byte) uint64 {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) != 7 || !ops[0].push || ops[1].code != opNumEqualVerify || len(ops[0].data) > 8 {
+		return 0
+	}
+	height := uint64(0)
+	for i, b := range ops[0].data {
+		height |= uint64(b) << (8 * uint(i))
+	}
+	return height
+}
+
+// p2shScript hash160 [ hash ] equal
+func p2shScript(hash []byte) []byte {
+	script := []byte{opHash160, byte(len(hash))}
+	script = append(script, hash...)
+	return append(script, opEqual)
+}
+
+// pushData 压入数据的脚本片段
+func pushData(data []byte) []byte {
+	n := len(data)
+	switch {
+	case n < int(opPushData1):
+		return append([]byte{byte(n)}, data...)
+	case n <= 0xff:
+		return append([]byte{opPushData1, byte(n)}, data...)
+	default:
+		b := []byte{opPushData2, byte(n), byte(n >> 8)}
+		return append(b, data...)
+	}
+}
+
+// scriptOp 脚本的一个操作
+type scriptOp struct {
+	code byte
+	data []byte
+	push bool
+}
+
+// parseScript 拆分脚本操作
+func parseScript(script []byte) ([]scriptOp, error) {
+	ops := make([]scriptOp, 0)
+	r := &reader{data: script}
+	for r.pos < len(script) && r.err == nil {
+		code := r.byte()
+		switch {
+		case code > opZero && code < opPushData1:
+			ops = append(ops, scriptOp{code: code, data: r.bytes(int(code)), push: true})
+		case code == opPushData1:
+			n := r.byte()
+			ops = append(ops, scriptOp{code: code, data: r.bytes(int(n)), push: true})
+		case code == opPushData2:
+			n := binary.LittleEndian.Uint16(r.bytes(2))
+			ops = append(ops, scriptOp{code: code, data: r.bytes(int(n)), push: true})
+		case code == opPushData4:
+			n := r.uint32()
+			ops = append(ops, scriptOp{code: code, data: r.bytes(int(n)), push: true})
+		default:
+			ops = append(ops, scriptOp{code: code})
+		}
+	}
+	return ops, r.err
+}
+
+var opNames = map[byte]string{
+	opZero:                   "zero",
+	opDup:                    "dup",
+	opEqual:                  "equal",
+	opEqualVerify:            "equalverify",
+	opHash160:                "hash160",
+	opCheckSig:               "checksig",
+	opCheckMultiSig:          "checkmultisig",
+	opNumEqualVerify:         "numequalverify",
+	opCheckAttenuationVerify: "checkattenuationverify",
+}
+
+// scriptText 节点可读形式的脚本，例如：dup hash160 [ 74b5... ] equalverify checksig
+func scriptText(script []byte) string {
+	ops, err := parseScript(script)
+	if err != nil {
+		return "<invalid script>"
+	}
+	parts := make([]string, 0, len(ops))
+	for _, op := range ops {
+		switch {
+		case op.push:
+			parts = append(parts, "[ "+hex.EncodeToString(op.data)+" ]")
+		case op.code >= 0x51 && op.code <= 0x60:
+			parts = append(parts, fmt.Sprintf("%d", op.code-0x50))
+		default:
+			if name, ok := opNames[op.code]; ok {
+				parts = append(parts, name)
+			} else {
+				parts = append(parts, fmt.Sprintf("<%02x>", op.code))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// scriptHash 提取标准脚本中的hash160，返回是否P2SH
+func scriptHash(script []byte) ([]byte, bool, bool) {
+	ops, err := parseScript(script)
+	if err != nil {
+		return nil, false, false
+	}
+	//锁仓脚本去掉高度前缀后按P2PKH处理
+	if len(ops) == 7 && ops[0].push && ops[1].code == opNumEqualVerify {
+		ops = ops[2:]
+	}
+	switch {
+	case len(ops) == 5 && ops[0].code == opDup && ops[1].code == opHash160 && ops[2].push && len(ops[2].data) == 20 &&
+		ops[3].code == opEqualVerify && ops[4].code == opCheckSig:
+		return ops[2].data, false, true
+	case len(ops) == 3 && ops[0].code == opHash160 && ops[1].push && len(ops[1].data) == 20 && ops[2].code == opEqual:
+		return ops[1].data, true, true
+	}
+	return nil, false, false
+}
+
+// decodeSignature DER签名转换为64字节的r||s，返回hashType
+func decodeSignature(der []byte) ([]byte, byte, error) {
+	if len(der) < 9 || der[0] != 0x30 {
+		return nil, 0, errors.New("invalid DER signature")
+	}
+	hashType := der[len(der)-1]
+	der = der[:len(der)-1]
+	if int(der[1])+2 != len(der) {
+		return nil, 0, errors.New("invalid DER signature length")
+	}
+	r := &reader{data: der, pos: 2}
+	sig := make([]byte, 0, 64)
+	for i := 0; i < 2; i++ {
+		if r.byte() != 0x02 {
+			return nil, 0, errors.New("invalid DER integer")
+		}
+		n := r.byte()
+		v := r.bytes(int(n))
+		if r.err != nil {
+			return nil, 0, r.err
+		}
+		v = bytes.TrimLeft(v, "\x00")
+		if len(v) > 32 {
+			return nil, 0, errors.New("invalid DER integer length")
+		}
+		sig = append(sig, make([]byte, 32-len(v))...)
+		sig = append(sig, v...)
+	}
+	return sig, hashType, nil
+}
+
+// verifySignature 校验secp256k1签名
+func verifySignature(pubkey, hash, sig []byte) bool {
+	if len(pubkey) != 33 {
+		return false
+	}
+	point := owcrypt.PointDecompress(pubkey, owcrypt.ECC_CURVE_SECP256K1)
+	if len(point) != 65 {
+		return false
+	}
+	return owcrypt.Verify(point[1:], nil, hash, sig, owcrypt.ECC_CURVE_SECP256K1) == owcrypt.SUCCESS
+}
+
+func doubleSHA256(data []byte) []byte {
+	return owcrypt.Hash(data, 0, owcrypt.HASH_ALG_DOUBLE_SHA256)
+}
+
+func hash160(data []byte) []byte {
+	return owcrypt.Hash(data, 0, owcrypt.HASH_ALG_HASH160)
+}
+
+func reverseBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+func reverseHex(b []byte) string {
+	return hex.EncodeToString(reverseBytes(b))
+}